@@ -0,0 +1,80 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+)
+
+// pendingWrite is a formatted file waiting for writing
+type pendingWrite struct {
+	filename string
+	src      []byte
+	res      []byte
+	perm     os.FileMode
+}
+
+// atomicRun collects the formatted files of -atomic-run, they are written
+// after all files are processed, or none of them when any error occurred
+type atomicRun struct {
+	mu    sync.Mutex
+	files []pendingWrite
+}
+
+// atomicWrites is not nil when -atomic-run and -w are set
+var atomicWrites *atomicRun
+
+// Add records the file, src and res are copied because they may be reused
+func (a *atomicRun) Add(filename string, src, res []byte, perm os.FileMode) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.files = append(a.files, pendingWrite{
+		filename: filename,
+		src:      append([]byte(nil), src...),
+		res:      append([]byte(nil), res...),
+		perm:     perm,
+	})
+}
+
+func (a *atomicRun) sort() {
+	sort.Slice(a.files, func(i, j int) bool {
+		return a.files[i].filename < a.files[j].filename
+	})
+}
+
+// Commit writes all files, the written files are restored when a write failed
+func (a *atomicRun) Commit() error {
+	a.sort()
+	for i, f := range a.files {
+		if err := writeFile(f.filename, f.src, f.res, f.perm); err != nil {
+			for _, written := range a.files[:i] {
+				if rerr := writeFile(written.filename, written.res, written.src, written.perm); rerr != nil {
+					report(fmt.Errorf("restoring %s: %s", written.filename, rerr))
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Discard logs the summary of files not written
+func (a *atomicRun) Discard(l *slog.Logger) {
+	if len(a.files) == 0 {
+		return
+	}
+	a.sort()
+	l.Error(fmt.Sprintf("atomic-run: %d files were not written because of errors", len(a.files)))
+	for _, f := range a.files {
+		l.Error("atomic-run: not written", "pos", f.filename)
+	}
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAtomicRun(t *testing.T) {
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	src := "package a\n\ntype A struct {\n\tName string `json:\"name\" xml:\"name\"`\n\tB int `json:\"b\" xml:\"b\"`\n}\n"
+	formatted := "package a\n\ntype A struct {\n\tName string `json:\"name\" xml:\"name\"`\n\tB    int    `json:\"b\"    xml:\"b\"`\n}\n"
+	good := filepath.Join(dir, "a.go")
+	bad := filepath.Join(dir, "b.go")
+	require.NoError(t, ioutil.WriteFile(good, []byte(src), 0644))
+	require.NoError(t, ioutil.WriteFile(bad, []byte("package a\n\ntype B struct {\n\tName string `json`\n}\n"), 0644))
+
+	osArgs := os.Args
+	defer func() { os.Args = osArgs }()
+	run := func(args ...string) int {
+		resetFlags()
+		exitCode = 0
+		os.Args = append([]string{"tagfmt"}, args...)
+		gofmtMain()
+		return exitCode
+	}
+
+	// b.go has an error, a.go is not written
+	assert.Equal(t, exitSource, run("-w", "-atomic-run", dir))
+	data, err := ioutil.ReadFile(good)
+	require.NoError(t, err)
+	assert.Equal(t, src, string(data))
+
+	// without -atomic-run a.go is written
+	assert.Equal(t, exitSource, run("-w", dir))
+	data, err = ioutil.ReadFile(good)
+	require.NoError(t, err)
+	assert.Equal(t, formatted, string(data))
+
+	require.NoError(t, ioutil.WriteFile(good, []byte(src), 0644))
+	require.NoError(t, os.Remove(bad))
+	assert.Equal(t, 0, run("-w", "-atomic-run", dir))
+	data, err = ioutil.ReadFile(good)
+	require.NoError(t, err)
+	assert.Equal(t, formatted, string(data))
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"go/ast"
+	"go/token"
+	"sync"
+)
+
+// maxPoolBufferSize is the biggest buffer keep in pool, avoid a very large file
+// hold its memory for the rest of run
+const maxPoolBufferSize = 4 << 20
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPoolBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// commentMaps cache the file's comment map, so every executor share one
+// instead of build it again
+var commentMaps sync.Map // *ast.File => ast.CommentMap
+
+func fileCommentMap(fs *token.FileSet, f *ast.File) ast.CommentMap {
+	if cmap, ok := commentMaps.Load(f); ok {
+		return cmap.(ast.CommentMap)
+	}
+	cmap := ast.NewCommentMap(fs, f, f.Comments)
+	commentMaps.Store(f, cmap)
+	return cmap
+}
+
+func releaseCommentMap(f *ast.File) {
+	commentMaps.Delete(f)
+}
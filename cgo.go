@@ -0,0 +1,95 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// cgoComments returns the cgo preambles and the //export comments of f, they are
+// read by cgo, nil means f doesn't import "C"
+func cgoComments(f *ast.File) []string {
+	var comments []string
+	isCgo := false
+	for _, imp := range f.Imports {
+		if imp.Path.Value != `"C"` {
+			continue
+		}
+		isCgo = true
+		doc := imp.Doc
+		if doc == nil {
+			// the preamble of the single import is the doc of the declaration
+			for _, decl := range f.Decls {
+				if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT && len(gen.Specs) == 1 && gen.Specs[0] == imp {
+					doc = gen.Doc
+				}
+			}
+		}
+		if doc != nil {
+			comments = append(comments, commentGroupText(doc))
+		}
+	}
+	if !isCgo {
+		return nil
+	}
+	for _, group := range f.Comments {
+		for _, c := range group.List {
+			if strings.HasPrefix(c.Text, "//export ") {
+				comments = append(comments, c.Text)
+			}
+		}
+	}
+	return comments
+}
+
+func commentGroupText(group *ast.CommentGroup) string {
+	var lines []string
+	for _, c := range group.List {
+		lines = append(lines, c.Text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checkCgo reports whether the cgo preambles and //export comments of src are
+// changed in res, the changed preamble is a different C program
+func checkCgo(filename string, src, res []byte) error {
+	fs := token.NewFileSet()
+	f, err := parser.ParseFile(fs, filename, src, parser.ImportsOnly)
+	if err != nil {
+		return err
+	}
+	isCgo := false
+	for _, imp := range f.Imports {
+		isCgo = isCgo || imp.Path.Value == `"C"`
+	}
+	if !isCgo {
+		return nil
+	}
+	before, err := parser.ParseFile(fs, filename, src, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	after, err := parser.ParseFile(fs, filename, res, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	a, b := cgoComments(before), cgoComments(after)
+	if len(a) != len(b) {
+		return fmt.Errorf("%s: the cgo comments are changed by formatting", filename)
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return fmt.Errorf("%s: the cgo comment is changed by formatting: %s", filename, strings.SplitN(a[i], "\n", 2)[0])
+		}
+	}
+	return nil
+}
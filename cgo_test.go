@@ -0,0 +1,24 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCheckCgo(t *testing.T) {
+	src := "package a\n\n// #include <stdio.h>\n//   #define N 1\nimport \"C\"\n\n//export f\nfunc f() {}\n"
+	assert.NoError(t, checkCgo("a.go", []byte(src), []byte(src)))
+	assert.EqualError(t, checkCgo("a.go", []byte(src), []byte("package a\n\n// #include <stdio.h>\n// #define N 1\nimport \"C\"\n\n//export f\nfunc f() {}\n")),
+		"a.go: the cgo comment is changed by formatting: // #include <stdio.h>")
+	assert.EqualError(t, checkCgo("a.go", []byte(src), []byte("package a\n\n// #include <stdio.h>\n//   #define N 1\nimport \"C\"\n\nfunc f() {}\n")),
+		"a.go: the cgo comments are changed by formatting")
+	// not a cgo file
+	assert.NoError(t, checkCgo("a.go", []byte("package a\n\n//export f\nfunc f() {}\n"), []byte("package a\n")))
+}
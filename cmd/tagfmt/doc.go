@@ -0,0 +1,383 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Tagfmt formats struct tag within Go programs.
+It uses blanks for alignment.
+tag must be in key:"value" pair format
+
+usage: tagfmt [flags] [path ...]
+       tagfmt [flags] command [arguments]
+  -P value
+        field name with inverse regular expression pattern, the fields matched it are excluded from -p or -pg, the repeated patterns are ORed
+  -a    align with nearby field's tag (default true)
+  -align-group string
+        the key groups share one align column e.g json,yaml|gorm, the adjacent keys of a group are separated by one space
+  -align-key string
+        only align the structs have one of the keys e.g gorm|db
+  -atomic-run
+        with -w, write the files only when all files are formatted without error
+  -client
+        send the standard input to daemon and print the result, with -struct print the declarations of the struct looked up by daemon
+  -color string
+        color the diffs and diagnostics, auto, always or never, auto honors NO_COLOR, CLICOLOR_FORCE and TERM=dumb and colors only the terminal (default "auto")
+  -config string
+        config file with per-package option overrides
+  -cpuprofile string
+        write cpu profile to this file
+  -d    display diffs instead of rewriting files
+  -daemon
+        run as daemon, format the source sent by -client
+  -default-source string
+        the sources of default() fill rule in order, const is the constant Default<Struct><Field>, constructor is the constant set to the field in New<Struct> or Default<Struct> (default "const,constructor")
+  -dry-run-then-write
+        check all files and report the counts first, then write the changed files on confirmation or -yes
+  -e    report all errors (not just the first 10 on different lines)
+  -exact
+        the field and struct patterns match the full name instead of the substring e.g -sp User doesn't match UserAudit
+  -f value
+        fill key and value for field e.g json=lower(:field)|yaml=snake(:field), the repeated flags are joined e.g -f json=lower(:field) -f yaml=snake(:field)
+  -follow-symlinks
+        follow symbolic links when walking directories
+  -j int
+        number of files processed in parallel (default the number of CPUs)
+  -journal
+        with -w, record the written files in .tagfmt/undo, tagfmt undo reverts the last run
+  -keep-options
+        the fill keeps the options e.g ,omitempty of the replaced value if the rule result has no options
+  -known-keys string
+        the extra known keys of -strict-keys e.g foo|bar
+  -l    list files whose formatting differs from tagfmt's
+  -log-format string
+        format of the diagnostics on stderr, text or json, json writes an object per line with the level, msg and pos (default "text")
+  -max-line-len int
+        the max length of the aligned field lines, the tabs of indent count as 4 columns, 0 means no limit
+  -max-tag-len int
+        the doctor reports the tags longer than it with the length of every key, 0 means no limit
+  -n    dry run, list the planned tag operations of every changed field instead of formatting
+  -offset int
+        only format the struct type enclosing the byte offset of the file or standard input and print the changed range as "start end" and the new text (default -1)
+  -overflow string
+        the policy of the lines over -max-line-len, unalign leaves them unaligned, shrink aligns fewer key columns, report reports them as errors (default "unalign")
+  -p value
+        field name with regular expression pattern, the pattern with \. matches the qualified name e.g User\.Email, the repeated patterns are ORed (default .*)
+  -pad string
+        padding of the gap between the field type and tag, space or tab, tab prints the source with the tab padding, the padding inside the tags is always space (default "space")
+  -patch
+        print the changes as a json line per file with the byte ranges of the original source and their new text instead of the whole file
+  -persistent_worker
+        run as bazel persistent worker, read work requests from standard input
+  -pg value
+        field name with glob pattern e.g Created*, it's anchored and preferred to -p, the repeated patterns are ORed
+  -pi
+        the field and struct patterns are case-insensitive
+  -pipeline string
+        executors order e.g doctor,fill,sort,align, the executors not listed are dropped, default split,doctor,comment,remnant,rewrite,rename,migrate,fill,sync,time,redact,sort,align
+  -position-keys string
+        the keys of the field positions e.g csv|fixed, the doctor reports the gaps and overlaps of their indexes and start,end ranges in a struct
+  -post-generate
+        format the generated files of //go:generate in place, it implies -w, disables -l -d -n -require-match -v and logs the errors only
+  -preset string
+        tag key presets e.g json|msgpack, fill and sort the keys with their conventions and check their options
+  -r string
+        rewrite rule for tag key value e.g 'json:"a" -> json:"b"', empty replacement delete the key
+  -redact string
+        the regexp of secret field names e.g (?i)password|secret|token, their redaction keys are set to hide them from the serialization
+  -redact-key string
+        the redaction keys of -redact e.g json|yaml, the key is set to -, key=value sets the value e.g log=mask (default "json")
+  -remnants string
+        the policy of the trailing comments look like the disabled tags e.g // json:"old", report reports them, remove removes them, restore adds their missing keys to the tags and removes them
+  -require-match
+        fail if the field and struct patterns matched no struct or field in all files
+  -s    sort struct tag by key
+  -sP value
+        struct name with inverse regular expression pattern, the structs matched it are excluded from -sp or -spg, the repeated patterns are ORed
+  -so string
+        sort struct tag keys order e.g json|yaml|x-*|desc, the wildcard key matches a family of keys
+  -socket string
+        unix socket of daemon (default "$TMPDIR/tagfmt-<uid>.sock")
+  -sp value
+        struct name with regular expression pattern, the repeated patterns are ORed (default .*)
+  -spg value
+        struct name with glob pattern e.g User*, it's anchored and preferred to -sp, the repeated patterns are ORed
+  -split-multi
+        split multi-name field e.g 'A, B string' to separate fields
+  -srcdir string
+        choose options as if the standard input source is from dir, dir may be the complete file name
+  -strict-comments
+        fail if the directive comments e.g //go:generate //nolint would be moved by formatting
+  -strict-fill
+        the unquoted text of fill rule must be a variable e.g :field, the literal text must be quoted
+  -strict-keys
+        report the unknown tag keys, the common keys and preset keys are known
+  -struct string
+        only format the struct of the name in the packages and print its declaration, -w -l -d work as usual, it selects the struct of show, preview, promoted, check-payload and gen csv-header too
+  -struct-index
+        persist the struct index in .tagfmt/index.json, -struct parses only the files changed since the last lookup
+  -sw string
+        sort struct tag keys weight e.g json=1|yaml=2|desc=-1 the higher weight, the higher the ranking, default keys weight is 0, validate=after:json places the key right after the other key
+  -sync string
+        keep the values of key pairs the same e.g binding=validate, the empty one is copied from the other
+  -tests
+        process the _test.go files when walking directories, default true except -w, the files in arguments are always processed
+  -time-hint string
+        the companion keys and options of time.Time fields e.g time_format=2006-01-02|parquet,timestamp(millisecond), the missing ones are added
+  -tmpl
+        also format the struct declarations without template actions in .go.tmpl files
+  -tmpl-delims string
+        the left and right delimiters of template actions (default "{{ }}")
+  -v
+        report the files, structs and fields skipped by the patterns and why
+  -w    write result to (source) file instead of stdout
+  -worker_protocol string
+        bazel worker protocol, proto or json (default "proto")
+  -yes
+        with -dry-run-then-write, write the files without confirmation
+
+
+
+Commands:
+	check-payload -struct name [-key json] payload.json [path ...]
+		report the keys of the sample json payload which don't map to a tagged
+		field of the struct and the missing keys of the fields without omitempty,
+		without -struct the struct is -struct of the main command or the -sp -sP
+		matching one struct
+	conformance [-json] [path ...]
+		print the struct fields whose tag keys are not in the canonical order of
+		-so -sw -preset and the config, and the percentage of the fields in order
+		per package without modifying anything
+	gen csv-header [-key csv] [-sep ,] [-check file] [-struct name] name [path ...]
+		print the gocsv header row of the struct name implied by the csv tags,
+		with -check compare it to the header of the csv file, exit 1 if they differ,
+		-struct of csv-header or the main command gives the name instead
+	gen struct -from file [-name name] [-package main] [-o file]
+		generate the go structs of a json schema or an example json payload, the
+		tags are filled and aligned by the flags and the config
+	install-hook [-fix] [-force]
+		write the git pre-commit hook that runs tagfmt with the flags on staged files
+	pre-commit [-fix]
+		check the staged files, it's used by the pre-commit hook
+	preview [path ...]
+		print every struct with its tags as an aligned table of the fields and
+		their key values without modifying anything, -struct or -sp and -sP
+		select the structs
+	promoted [-key key] [path ...]
+		print the effective field set of the structs in the packages of paths,
+		including the fields promoted from embedded structs, -struct or -sp and
+		-sP select the structs, the root of go.work workspace is the packages of
+		its modules
+	rename-values [-key key] [-strict] -map file path ...
+		rename the names of key by the json mapping file of old name to new name,
+		the names never found are reported, with -strict they are errors and no
+		file is written
+	show [-json] [-struct name] name [path ...]
+		print the parsed tags of the struct name in the packages of paths, the
+		key, value and options of every field, it's for debugging the rules, -struct
+		of show or the main command gives the name instead of the first argument
+	swag-check [path ...]
+		report the swaggo @Param annotations whose example(...), Format(...)
+		or required don't agree with the struct tags of the parameter
+	undo [-force] [-list]
+		revert the files written by the last -w -journal run, the files changed
+		after the run are not reverted unless -force, -list prints the diffs
+
+Debugging support:
+	-cpuprofile filename
+		Write cpu profile to the specified file.
+
+
+Examples
+
+	struct tag format example:
+	//tagfmt
+	struct User struct {
+		Name     string `json:"name" xml:"name" yaml:"name"`
+		Password string `json:"password" xml:"password" yaml:"password"`
+	}
+	// after format
+	struct User struct {
+		Name     string `json:"name"     xml:"name"     yaml:"name"    `
+		Password string `json:"password" xml:"password" yaml:"password"`
+	}
+
+When invoke with -s tagfmt will sort struct tags by key.
+
+	struct tag key example:
+	//tagfmt -s
+	struct User struct {
+		Name     string `xml:"name" json:"name" yaml:"name"`
+	}
+	// after format
+	struct User struct {
+		Name     string `json:"name" xml:"name" yaml:"name"`
+	}
+
+When invoke with -so <order> and -s will sort struct tags by your custom <order>
+
+	//tagfmt -s -so "json|yaml|desc"
+	package main
+	type Example struct {
+		Data string `desc:"some inuse data" yaml:"data" json:"data" `
+	}
+
+	type Example struct {
+		Data string `json:"data" yaml:"data" desc:"some inuse data"`
+	}
+
+When invoke with -sw <weight> and -s will sort struct tags by your custom <weight>
+
+	//tagfmt -s -sw "json=2|yaml=1|toml=1|desc=-1"
+	package main
+	type Example struct {
+		Data string `desc:"some inuse data" yaml:"data" toml:"data" binding:"required" json:"data" `
+	}
+
+	package main
+
+	type Example struct {
+		Data string `json:"data" toml:"data" yaml:"data" binding:"required" desc:"some inuse data"`
+	}
+
+
+When invoke with -f "*" tagfmt will fill missing key and empty value in group(group split by black line or field without tag)
+
+	struct tag fill example:
+	//tagfmt -f "*"
+	type User struct {
+		Name     string `json:"name"`
+		Password string `xml:"password"`
+		EmptyTag string
+		City     string `json:"group" xml:"group"`
+		State    string `gorm:"type:varchar(64)" xml:"state"`
+	}
+	// after format
+	type User struct {
+		Name     string `json:"name"    xml:""`
+		Password string `xml:"password" json:""`
+		EmptyTag string
+		City     string `json:"group"            xml:"group" gorm:""`
+		State    string `gorm:"type:varchar(64)" xml:"state" json:""`
+	}
+
+You also can only fill "json" tag key and field name as its value
+
+    struct tag fill example:
+	//tagfmt -f "json=:field"
+
+	type Order struct {
+		ID  string ``
+		Tag string ``
+		Fee float32 ``
+	}
+	// after format
+	type Order struct {
+		ID  string  `json:"ID"`
+		Tag string  `json:"Tag"`
+		Fee float32 `json:"Fee"`
+	}
+
+
+use `// tagfill: [key1 key2]` to filter below struct requires key
+
+	struct tag fill example:
+	//tagfmt -f "json=snake(:tag)|yaml=lower_camel(:tag)|bson=lower_camel(:tag)|toml=upper_camel(:tag)"
+
+	package main
+	// tagfill: toml yaml
+	type OrderConfig struct {
+		Name     string ``
+		UserName string ``
+		Pay      int    ``
+	}
+	// tagfill: json bson
+	type OrderDetail struct {
+		ID       string ``
+		UserName string ``
+		Pay      int    ``
+	}
+
+
+	//after format
+
+	package main
+
+	// tagfill: toml yaml
+	type OrderConfig struct {
+		Name     string `toml:"" yaml:""`
+		UserName string `toml:"" yaml:""`
+		Pay      int    `toml:"" yaml:""`
+	}
+
+	// tagfill: json bson
+	type OrderDetail struct {
+		ID       string `bson:"" json:""`
+		UserName string `bson:"" json:""`
+		Pay      int    `bson:"" json:""`
+	}
+
+
+	fill rule are rich and flexible here is example about fill json key and snake converted field name as its value, final keep it's origin extra tag
+	struct tag fill example:
+	//tagfmt -f "json=snake(:field)+:tag_extra"
+
+	package main
+	type OrderDetail struct {
+		ID       string   `json:",omitempty"`
+		UserName string   `json:",omitempty"`
+		OrderID  string   `json:",omitempty"`
+		Callback string   ``
+		Address  []string ``
+	}
+	// after format
+	type OrderDetail struct {
+		ID       string   `json:"id,omitempty"`
+		UserName string   `json:"user_name,omitempty"`
+		OrderID  string   `json:"order_id,omitempty"`
+		Callback string   `json:"callback"`
+		Address  []string `json:"address"`
+	}
+
+	fill rule:
+		multiple key rule split with '|'
+		<key>[=<function or placehold_val or string>[+ <function or placehold_val or string> ]]
+		'*' is special key, it will fill missing key and empty value in group(group split by black line or field without tag)
+
+	fill rule functions:
+		upper(s string) // a-z to A-Z
+		lower(s string) // A-Z to a-z
+		snake(s string) // convert upper_camel/lower_camel word to snake case
+		upper_camel(s string) // convert snake case/lower camel case to upper camel case
+		lower_camel(s string) // convert upper camel case/snake case to lower camel case
+		or(s string, s string) // return return first params if it's not zero,else return the second
+
+	fill rule placehold value:
+		:field // replace with struct field name
+		:tag   // replace with  struct field existed tag's value
+		:tag_basic // replace with field existed tag's basic value (the value before the first ',' )
+		:tag_extra // replace with field existed tag's extra data (the value after the first ',' )
+		:proto_name // replace with the proto field name of protoc-gen-go protobuf or protobuf_oneof tag
+		:index // replace with the position of the field in the struct from 0
+
+	fill Concatenated string
+		fill rule also support use '+' to concatenated string
+		//tagfmt -f "json=snake(:tag_basic)+',omitempty'"
+
+		type OrderDetail struct {
+			ID       string   `json:"id"`
+			UserName string   `json:"user_name"`
+			OrderID  string   `json:"order_id"`
+			Callback string   `json:"callback"`
+			Address  []string `json:"address"`
+		}
+
+		type OrderDetail struct {
+			ID       string   `json:"id,omitempty"`
+			UserName string   `json:"user_name,omitempty"`
+			OrderID  string   `json:"order_id,omitempty"`
+			Callback string   `json:"callback,omitempty"`
+			Address  []string `json:"address,omitempty"`
+		}
+
+*/
+package main
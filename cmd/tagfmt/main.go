@@ -0,0 +1,14 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import "github.com/bigpigeon/tagfmt"
+
+func main() {
+	tagfmt.Main()
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// the ansi colors of output
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorCyan   = "\x1b[36m"
+)
+
+// parseColorMode checks the -color value
+func parseColorMode(mode string) error {
+	switch mode {
+	case "auto", "always", "never":
+		return nil
+	}
+	return fmt.Errorf("invalid -color %q, must be auto, always or never", mode)
+}
+
+// colorEnabled report whether the output to f is colored, all colored output
+// checks it, with -color auto the conventions are honored:
+// NO_COLOR disables the color, CLICOLOR_FORCE enables the color even f isn't a
+// terminal, TERM=dumb and the non-terminal disable the color
+func colorEnabled(f *os.File) bool {
+	switch *colorMode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force := os.Getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return true
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(f)
+}
+
+// isTerminal report whether f is a character device e.g terminal
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// paint wraps text in the color
+func paint(color, text string) string {
+	return color + text + colorReset
+}
+
+// colorDiff colors the lines of unified diff, the headers are bold, the hunk
+// ranges are cyan, the deleted lines are red and the inserted lines are green
+func colorDiff(data []byte) []byte {
+	var buf bytes.Buffer
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		text := string(bytes.TrimSuffix(line, []byte("\n")))
+		color := ""
+		switch {
+		case bytes.HasPrefix(line, []byte("--- ")), bytes.HasPrefix(line, []byte("+++ ")), bytes.HasPrefix(line, []byte("diff ")):
+			color = colorBold
+		case bytes.HasPrefix(line, []byte("@@")):
+			color = colorCyan
+		case line[0] == '-':
+			color = colorRed
+		case line[0] == '+':
+			color = colorGreen
+		}
+		if color != "" {
+			text = paint(color, text)
+		}
+		buf.WriteString(text)
+		if bytes.HasSuffix(line, []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestColorEnabled(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	f, err := ioutil.TempFile("", "tagfmt")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("CLICOLOR_FORCE", "")
+	t.Setenv("TERM", "xterm")
+	// the regular file isn't a terminal
+	assert.False(t, colorEnabled(f))
+	t.Setenv("CLICOLOR_FORCE", "1")
+	assert.True(t, colorEnabled(f))
+	t.Setenv("NO_COLOR", "1")
+	assert.False(t, colorEnabled(f))
+
+	*colorMode = "always"
+	assert.True(t, colorEnabled(f))
+	*colorMode = "never"
+	t.Setenv("NO_COLOR", "")
+	assert.False(t, colorEnabled(f))
+
+	assert.NoError(t, parseColorMode("auto"))
+	assert.EqualError(t, parseColorMode("yes"), `invalid -color "yes", must be auto, always or never`)
+}
+
+func TestColorDiff(t *testing.T) {
+	d, err := diff([]byte("a\nb\n"), []byte("a\nc\n"), "x.go")
+	require.NoError(t, err)
+	assert.Equal(t, "\x1b[1m--- x.go.orig\x1b[0m\n"+
+		"\x1b[1m+++ x.go\x1b[0m\n"+
+		"\x1b[36m@@ -1,2 +1,2 @@\x1b[0m\n"+
+		" a\n"+
+		"\x1b[31m-b\x1b[0m\n"+
+		"\x1b[32m+c\x1b[0m\n", string(colorDiff(d)))
+}
@@ -0,0 +1,237 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options is the set of formatting options used for one file,
+// it's build from command line flags and can be overridden by config
+type Options struct {
+	Align                bool   `json:"align"`
+	AlignKey             string `json:"align_key"`
+	AlignGroup           string `json:"align_group"`
+	MaxLineLen           int    `json:"max_line_len"`
+	MaxTagLen            int    `json:"max_tag_len"`
+	PositionKeys         string `json:"position_keys"`
+	Overflow             string `json:"overflow"`
+	Sort                 bool   `json:"sort"`
+	SortOrder            string `json:"sort_order"`
+	SortWeight           string `json:"sort_weight"`
+	Fill                 string `json:"fill"`
+	Pattern              string `json:"pattern"`
+	InversePattern       string `json:"inverse_pattern"`
+	StructPattern        string `json:"struct_pattern"`
+	InverseStructPattern string `json:"inverse_struct_pattern"`
+	FieldGlob            string `json:"field_glob"`
+	StructGlob           string `json:"struct_glob"`
+	PatternIgnoreCase    bool   `json:"pattern_ignore_case"`
+	ExactPattern         bool   `json:"exact_pattern"`
+	SplitMulti           bool   `json:"split_multi"`
+	Rewrite              string `json:"rewrite"`
+	Preset               string `json:"preset"`
+	StrictKeys           bool   `json:"strict_keys"`
+	KnownKeys            string `json:"known_keys"`
+	Sync                 string `json:"sync"`
+	Pipeline             string `json:"pipeline"`
+	StrictComments       bool   `json:"strict_comments"`
+	StrictFill           bool   `json:"strict_fill"`
+	Pad                  string `json:"pad"`
+	KeepOptions          bool   `json:"keep_options"`
+	DefaultSource        string `json:"default_source"`
+	TimeHint             string `json:"time_hint"`
+	Remnants             string `json:"remnants"`
+	Redact               string `json:"redact"`
+	RedactKey            string `json:"redact_key"`
+
+	// recheck is the source formatted again to check the fixed point, it doesn't warn
+	// or apply the one-shot changes e.g rename-values again
+	recheck bool
+	// fragment is the source from standard input or Source, it isn't a file of the
+	// package in its directory, snippet is the fragment without package clause
+	fragment, snippet bool
+	// dir is the package directory of the fragment, default the directory of its name
+	dir string
+	// structName is the struct selected by -struct or -offset, it replaces the struct
+	// patterns of the flags and config
+	structName string
+	// source is the src of Source, it's parsed by defaultParserMode and the roles of the
+	// command's config aren't applied, so the concurrent calls share no command state
+	source bool
+}
+
+func optionsFromFlags() Options {
+	return Options{
+		Align:                *align,
+		AlignKey:             *alignKey,
+		AlignGroup:           *alignGroups,
+		MaxLineLen:           *maxLineLen,
+		MaxTagLen:            *maxTagLen,
+		PositionKeys:         *positionKeys,
+		Overflow:             *overflow,
+		Sort:                 *tagSort,
+		SortOrder:            *tagSortOrder,
+		SortWeight:           *tagSortWeight,
+		Fill:                 *fill,
+		Pattern:              *pattern,
+		InversePattern:       *inversePattern,
+		StructPattern:        *structPattern,
+		InverseStructPattern: *inverseStructPattern,
+		FieldGlob:            *fieldGlob,
+		StructGlob:           *structGlob,
+		PatternIgnoreCase:    *patternIgnoreCase,
+		ExactPattern:         *exactPattern,
+		SplitMulti:           *splitMulti,
+		Rewrite:              *rewrite,
+		Preset:               *preset,
+		StrictKeys:           *strictKeys,
+		KnownKeys:            *knownKeys,
+		Sync:                 *syncKeys,
+		Pipeline:             *pipeline,
+		StrictComments:       *strictComments,
+		StrictFill:           *strictFill,
+		Pad:                  *pad,
+		KeepOptions:          *keepOptions,
+		DefaultSource:        *defaultSource,
+		TimeHint:             *timeHints,
+		Remnants:             *remnants,
+		Redact:               *redact,
+		RedactKey:            *redactKey,
+		structName:           *structName,
+	}
+}
+
+// packageConfig overrides options for packages matched by Path
+// Path is relative to config file directory, e.g ./api or ./internal/...
+type packageConfig struct {
+	Path    string          `json:"path"`
+	Options json.RawMessage `json:"options"`
+}
+
+// Config is the tagfmt config file content
+//
+//	{
+//		"packages": [
+//			{"path": "./api/...", "options": {"sort": true, "sort_order": "json|yaml"}},
+//			{"path": "./internal/store/...", "options": {"fill": "gorm=snake(:field)"}}
+//		],
+//		"roles": {
+//			"dto": {"fill": "json=lower_camel(:field)|yaml=lower_camel(:field)", "sort": true},
+//			"entity": {"fill": "gorm=snake(:field)|db=snake(:field)"}
+//		},
+//		"role_patterns": [{"struct": "DTO$", "role": "dto"}]
+//	}
+type Config struct {
+	Packages []packageConfig `json:"packages"`
+	// Roles are the named options applied on the structs of the role,
+	// a struct choose its role by // tagrole: name comment or RolePatterns
+	Roles        map[string]json.RawMessage `json:"roles"`
+	RolePatterns []rolePattern              `json:"role_patterns"`
+
+	dir string // config file directory
+}
+
+func loadConfig(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return nil, err
+	}
+	c.dir = dir
+	if err := c.compileRoles(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// cachedConfig is a parsed config file and the modification time and size it's parsed at
+type cachedConfig struct {
+	modTime time.Time
+	size    int64
+	config  *Config
+}
+
+// configCache is the parsed config files, the daemon and worker reuse the parsed
+// config and its compiled role patterns until the file changes
+var configCache = struct {
+	sync.Mutex
+	files map[string]cachedConfig
+}{files: map[string]cachedConfig{}}
+
+// loadConfigCached returns the cached config of filename, the file changed since it's
+// parsed is loaded again, the stat is cheaper than parsing on every request
+func loadConfigCached(filename string) (*Config, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	configCache.Lock()
+	cached, ok := configCache.files[filename]
+	configCache.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.config, nil
+	}
+	c, err := loadConfig(filename)
+	if err != nil {
+		return nil, err
+	}
+	configCache.Lock()
+	configCache.files[filename] = cachedConfig{modTime: info.ModTime(), size: info.Size(), config: c}
+	configCache.Unlock()
+	// the Filters of the patterns only the old config has are never used again
+	resetFilterCache()
+	return c, nil
+}
+
+// Options return the options for filename, every package config that matched
+// filename's directory will override base in order, so the later wins
+func (c *Config) Options(base Options, filename string) (Options, error) {
+	if c == nil || len(c.Packages) == 0 {
+		return base, nil
+	}
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return base, err
+	}
+	for _, pkg := range c.Packages {
+		if !matchPackage(filepath.Join(c.dir, pkg.Path), strings.HasSuffix(pkg.Path, "..."), dir) {
+			continue
+		}
+		if len(pkg.Options) != 0 {
+			if err := json.Unmarshal(pkg.Options, &base); err != nil {
+				return base, err
+			}
+		}
+	}
+	return base, nil
+}
+
+// matchPackage report whether dir is the pattern's package,
+// when recursive is true the sub packages also matched
+func matchPackage(pattern string, recursive bool, dir string) bool {
+	if recursive {
+		pattern = strings.TrimSuffix(pattern, "...")
+		pattern = filepath.Clean(pattern)
+		return dir == pattern || strings.HasPrefix(dir, pattern+string(os.PathSeparator))
+	}
+	return dir == filepath.Clean(pattern)
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigPackageOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	configName := filepath.Join(dir, "tagfmt.json")
+	err = ioutil.WriteFile(configName, []byte(`{
+	"packages": [
+		{"path": "./api/...", "options": {"sort": true, "sort_order": "json|yaml"}},
+		{"path": "./api/v2", "options": {"sort_order": "yaml|json"}},
+		{"path": "./internal/store", "options": {"fill": "gorm=snake(:field)", "align": false}}
+	]
+}`), 0644)
+	require.NoError(t, err)
+	c, err := loadConfig(configName)
+	require.NoError(t, err)
+
+	base := Options{Align: true, Pattern: ".*"}
+	{
+		opts, err := c.Options(base, filepath.Join(dir, "api", "user.go"))
+		require.NoError(t, err)
+		assert.Equal(t, Options{Align: true, Pattern: ".*", Sort: true, SortOrder: "json|yaml"}, opts)
+	}
+	{
+		opts, err := c.Options(base, filepath.Join(dir, "api", "v2", "user.go"))
+		require.NoError(t, err)
+		assert.Equal(t, Options{Align: true, Pattern: ".*", Sort: true, SortOrder: "yaml|json"}, opts)
+	}
+	{
+		opts, err := c.Options(base, filepath.Join(dir, "internal", "store", "user.go"))
+		require.NoError(t, err)
+		assert.Equal(t, Options{Align: false, Pattern: ".*", Fill: "gorm=snake(:field)"}, opts)
+	}
+	{
+		// sub package not matched without "..."
+		opts, err := c.Options(base, filepath.Join(dir, "internal", "store", "sql", "user.go"))
+		require.NoError(t, err)
+		assert.Equal(t, base, opts)
+	}
+	{
+		opts, err := c.Options(base, filepath.Join(dir, "apiv3", "user.go"))
+		require.NoError(t, err)
+		assert.Equal(t, base, opts)
+	}
+}
+
+func TestSrcdirOptions(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	configName := filepath.Join(dir, "tagfmt.json")
+	err = ioutil.WriteFile(configName, []byte(`{"packages": [{"path": "./api", "options": {"sort": true}}]}`), 0644)
+	require.NoError(t, err)
+	config, err = loadConfig(configName)
+	require.NoError(t, err)
+
+	src := "package api\n\ntype User struct {\n\tName string `yaml:\"name\" json:\"name\"`\n}\n"
+	for _, dirOrFile := range []string{filepath.Join(dir, "api"), filepath.Join(dir, "api", "user.go")} {
+		*srcdir = dirOrFile
+		var buf bytes.Buffer
+		err = processFile("<standard input>", strings.NewReader(src), &buf, true)
+		require.NoError(t, err)
+		assert.Equal(t, "package api\n\ntype User struct {\n\tName string `json:\"name\" yaml:\"name\"`\n}\n", buf.String())
+	}
+}
+
+func TestConfigRoles(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	configName := filepath.Join(dir, "tagfmt.json")
+	err = ioutil.WriteFile(configName, []byte(`{
+	"roles": {
+		"dto": {"fill": "json=lower_camel(:field)|yaml=lower_camel(:field)", "sort": true},
+		"entity": {"fill": "gorm=snake(:field)|db=snake(:field)"}
+	},
+	"role_patterns": [{"struct": "DTO$", "role": "dto"}]
+}`), 0644)
+	require.NoError(t, err)
+	config, err = loadConfig(configName)
+	require.NoError(t, err)
+
+	src := `package api
+
+type UserDTO struct {
+	UserName string ` + "``" + `
+	Address  struct {
+		ZipCode string ` + "``" + `
+	} ` + "``" + `
+}
+
+// tagrole: entity
+type User struct {
+	UserName string ` + "``" + `
+}
+
+// tagrole: dto
+type Order struct {
+	OrderID string ` + "``" + `
+}
+
+type Other struct {
+	Name string ` + "`json:\"name\"`" + `
+}
+`
+	var buf bytes.Buffer
+	err = formatSource(&buf, "api.go", []byte(src), optionsFromFlags())
+	require.NoError(t, err)
+	assert.Equal(t, `package api
+
+type UserDTO struct {
+	UserName string `+"`json:\"userName\" yaml:\"userName\"`"+`
+	Address  struct {
+		ZipCode string `+"`json:\"zipCode\" yaml:\"zipCode\"`"+`
+	} `+"`json:\"address\" yaml:\"address\"`"+`
+}
+
+// tagrole: entity
+type User struct {
+	UserName string `+"`db:\"user_name\" gorm:\"user_name\"`"+`
+}
+
+// tagrole: dto
+type Order struct {
+	OrderID string `+"`json:\"orderID\" yaml:\"orderID\"`"+`
+}
+
+type Other struct {
+	Name string `+"`json:\"name\"`"+`
+}
+`, buf.String())
+
+	buf.Reset()
+	err = formatSource(&buf, "api.go", []byte("package api\n\n// tagrole: view\ntype A struct {\n\tName string\n}\n"), optionsFromFlags())
+	assert.EqualError(t, err, "api.go:4:8: unknown role view")
+}
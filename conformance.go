@@ -0,0 +1,156 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"strings"
+)
+
+// conformanceField is a field whose keys are not in the canonical order
+type conformanceField struct {
+	Name      string   `json:"name"`
+	Keys      []string `json:"keys"`
+	Canonical []string `json:"canonical"`
+}
+
+// conformanceStruct is the key order conformance of a struct, Fields are the
+// tagged fields and Mismatches are the ones out of the canonical order
+type conformanceStruct struct {
+	Name       string             `json:"name"`
+	Pos        string             `json:"pos"`
+	Fields     int                `json:"fields"`
+	Mismatches []conformanceField `json:"mismatches,omitempty"`
+}
+
+// conformancePackage is the structs of a package directory and its score, the
+// percentage of the tagged fields in the canonical order
+type conformancePackage struct {
+	Dir     string              `json:"dir"`
+	Fields  int                 `json:"fields"`
+	Matches int                 `json:"matches"`
+	Score   float64             `json:"score"`
+	Structs []conformanceStruct `json:"structs"`
+}
+
+// conformanceMain prints the structs whose tag keys are not in the canonical order of
+// -so -sw -preset and the config, and the conformance score per package, nothing is
+// modified, it measures the adoption before enforcing the sort
+//
+//	tagfmt -so "json|yaml" conformance ./...
+func conformanceMain(args []string) int {
+	fs := flag.NewFlagSet("conformance", flag.ContinueOnError)
+	return reportMain(fs, args, func(paths []string) (interface{}, error) {
+		return conformancePackages(paths)
+	}, func(w io.Writer, packages interface{}) error {
+		return writeConformance(w, packages.([]conformancePackage))
+	})
+}
+
+// conformancePackages checks the files of paths, the packages are in the order of
+// their first file
+func conformancePackages(paths []string) ([]conformancePackage, error) {
+	var packages []conformancePackage
+	err := walkPackageFiles(paths, func(pkg int, dir, filename string, opts Options) error {
+		structs, err := conformanceFile(filename, opts)
+		if err != nil {
+			return err
+		}
+		if pkg == len(packages) {
+			packages = append(packages, conformancePackage{Dir: dir, Structs: []conformanceStruct{}})
+		}
+		p := &packages[pkg]
+		for _, st := range structs {
+			p.Fields += st.Fields
+			p.Matches += st.Fields - len(st.Mismatches)
+		}
+		p.Structs = append(p.Structs, structs...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range packages {
+		packages[i].Score = 100
+		if packages[i].Fields != 0 {
+			packages[i].Score = float64(packages[i].Matches) * 100 / float64(packages[i].Fields)
+		}
+	}
+	return packages, nil
+}
+
+// conformanceFile checks the key order of the structs selected by opts in filename
+func conformanceFile(filename string, opts Options) ([]conformanceStruct, error) {
+	filter, err := opts.Filter()
+	if err != nil {
+		return nil, err
+	}
+	presets, err := parsePresets(opts.Preset)
+	if err != nil {
+		return nil, err
+	}
+	weights, err := parseSortWeight(opts.SortWeight)
+	if err != nil {
+		return nil, err
+	}
+	order, err := parseSortOrder(opts.SortOrder)
+	if err != nil {
+		return nil, err
+	}
+	order = presetSortOrder(order, presets)
+	fset := token.NewFileSet()
+	var structs []conformanceStruct
+	err = walkTaggedStructs(fset, filename, filter, func(spec *ast.TypeSpec, fields []taggedField) {
+		if len(fields) == 0 {
+			return
+		}
+		result := conformanceStruct{Name: spec.Name.Name, Pos: fset.Position(spec.Pos()).String(), Fields: len(fields)}
+		for _, f := range fields {
+			var keys []string
+			for _, kv := range f.keyValues {
+				keys = append(keys, kv.Key)
+			}
+			var canonical []string
+			for _, kv := range sortKeyValues(f.keyValues, order, weights) {
+				canonical = append(canonical, kv.Key)
+			}
+			if strings.Join(keys, " ") != strings.Join(canonical, " ") {
+				result.Mismatches = append(result.Mismatches, conformanceField{Name: f.path, Keys: keys, Canonical: canonical})
+			}
+		}
+		structs = append(structs, result)
+	})
+	return structs, err
+}
+
+// writeConformance writes the mismatched fields of the structs and the package scores
+func writeConformance(w io.Writer, packages []conformancePackage) error {
+	for _, pkg := range packages {
+		for _, st := range pkg.Structs {
+			if len(st.Mismatches) == 0 {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%s: %s %d/%d fields in order\n", st.Pos, st.Name, st.Fields-len(st.Mismatches), st.Fields); err != nil {
+				return err
+			}
+			for _, field := range st.Mismatches {
+				if _, err := fmt.Fprintf(w, "\t%s: %s -> %s\n", field.Name, strings.Join(field.Keys, " "), strings.Join(field.Canonical, " ")); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s: %.1f%% (%d/%d fields)\n", pkg.Dir, pkg.Score, pkg.Matches, pkg.Fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	src := "package user\n\n" +
+		"type User struct {\n" +
+		"\tID   int    `json:\"id\" yaml:\"id\"`\n" +
+		"\tName string `yaml:\"name\" json:\"name\"`\n" +
+		"\tAddress struct {\n\t\tZip string `xml:\"zip\" json:\"zip\"`\n\t}\n" +
+		"\tAge int\n" +
+		"}\n\n" +
+		"type Role struct {\n\tName string `json:\"name\" yaml:\"name\"`\n}\n"
+	dir, filename := writeReportPackage(t, src)
+	*tagSortOrder = "json|yaml|xml"
+
+	packages, err := conformancePackages([]string{dir})
+	require.NoError(t, err)
+	require.Len(t, packages, 1)
+	assert.Equal(t, 4, packages[0].Fields)
+	assert.Equal(t, 2, packages[0].Matches)
+	assert.Equal(t, []conformanceField{
+		{Name: "Name", Keys: []string{"yaml", "json"}, Canonical: []string{"json", "yaml"}},
+		{Name: "Address.Zip", Keys: []string{"xml", "json"}, Canonical: []string{"json", "xml"}},
+	}, packages[0].Structs[0].Mismatches)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeConformance(&buf, packages))
+	assert.Equal(t, filename+":3:6: User 1/3 fields in order\n"+
+		"\tName: yaml json -> json yaml\n"+
+		"\tAddress.Zip: xml json -> json xml\n"+
+		dir+": 50.0% (2/4 fields)\n", buf.String())
+}
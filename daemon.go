@@ -0,0 +1,226 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// configMu guards config in the daemon, the requests read it and the reload after the
+// config file changes replaces it
+var configMu sync.RWMutex
+
+// daemonRequest is sent by client, one request per connection
+type daemonRequest struct {
+	Filename string `json:"filename"` // used to find the config options and report errors
+	Src      []byte `json:"src"`
+	// Struct looks up the declarations of the struct in the packages of Paths instead
+	// of formatting Src
+	Struct string   `json:"struct,omitempty"`
+	Paths  []string `json:"paths,omitempty"`
+}
+
+type daemonResponse struct {
+	Src       []byte   `json:"src"`
+	Positions []string `json:"positions,omitempty"` // the struct declarations, file:line:column
+	Error     string   `json:"error,omitempty"`
+}
+
+func defaultSocket() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("tagfmt-%d.sock", os.Getuid()))
+}
+
+// runDaemon listens on the unix socket until interrupted, the flags are parsed once
+// and used by every request, the config is reloaded when its file changes
+func runDaemon(socket string) error {
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		return fmt.Errorf("daemon is already running on %s", socket)
+	}
+	// the socket left by a dead daemon
+	os.Remove(socket)
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		l.Close()
+	}()
+	err = serveDaemon(l, optionsFromFlags())
+	os.Remove(socket)
+	return err
+}
+
+func serveDaemon(l net.Listener, base Options) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go handleDaemonConn(conn, base)
+	}
+}
+
+func handleDaemonConn(conn net.Conn, base Options) {
+	defer conn.Close()
+	var req daemonRequest
+	var resp daemonResponse
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		resp.Error = err.Error()
+	} else if req.Struct != "" {
+		if err := daemonLookup(&resp, req); err != nil {
+			resp.Error = err.Error()
+		}
+	} else if err := daemonFormat(&resp, req, base); err != nil {
+		resp.Error = err.Error()
+	}
+	json.NewEncoder(conn).Encode(resp)
+}
+
+func daemonFormat(resp *daemonResponse, req daemonRequest, base Options) error {
+	if err := reloadConfig(); err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	configMu.RLock()
+	defer configMu.RUnlock()
+	filename := req.Filename
+	if filename == "" {
+		filename = "<standard input>"
+	}
+	opts, err := config.Options(base, filename)
+	if err != nil {
+		return err
+	}
+	// the client sends the file of -srcdir, the snippet is formatted in its package
+	if req.Filename != "" {
+		opts.dir = filepath.Dir(req.Filename)
+	}
+	var buf bytes.Buffer
+	if err := formatInput(&buf, filename, req.Src, opts, true); err != nil {
+		return err
+	}
+	resp.Src = buf.Bytes()
+	return nil
+}
+
+// daemonLookup finds the declarations of the struct by the index kept in memory, the
+// files changed since the last lookup are parsed again, it's saved with -struct-index
+func daemonLookup(resp *daemonResponse, req daemonRequest) error {
+	paths := req.Paths
+	if len(paths) == 0 {
+		paths = []string{"./..."}
+	}
+	candidates, err := packageFiles(paths)
+	if err != nil {
+		return err
+	}
+	idx := loadStructIndex(indexFile)
+	positions, err := idx.Lookup(req.Struct, candidates)
+	if err != nil {
+		return err
+	}
+	if *structIndexFlag {
+		if err := idx.Save(indexFile); err != nil {
+			return err
+		}
+	}
+	if len(positions) == 0 {
+		return fmt.Errorf("struct %s is not found in %s", req.Struct, strings.Join(paths, " "))
+	}
+	resp.Positions = []string{}
+	for _, pos := range positions {
+		resp.Positions = append(resp.Positions, pos.String())
+	}
+	return nil
+}
+
+// reloadConfig replaces config with the config file parsed again if it's changed, the
+// invalid config file is an error of the request instead of using the stale one
+func reloadConfig() error {
+	if *configFile == "" {
+		return nil
+	}
+	c, err := loadConfigCached(*configFile)
+	if err != nil {
+		return err
+	}
+	configMu.RLock()
+	same := c == config
+	configMu.RUnlock()
+	if !same {
+		configMu.Lock()
+		config = c
+		configMu.Unlock()
+	}
+	return nil
+}
+
+// daemonClient sends src to daemon and returns the formatted source
+func daemonClient(socket string, filename string, src []byte) ([]byte, error) {
+	resp, err := daemonCall(socket, daemonRequest{Filename: filename, Src: src})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Src, nil
+}
+
+// daemonLookupClient asks daemon for the declarations of struct name in the packages
+// of paths, the paths are made absolute because the daemon runs in its own directory
+func daemonLookupClient(socket string, name string, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		paths = []string{"./..."}
+	}
+	absPaths := make([]string, len(paths))
+	for i, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		absPaths[i] = abs
+	}
+	resp, err := daemonCall(socket, daemonRequest{Struct: name, Paths: absPaths})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Positions, nil
+}
+
+func daemonCall(socket string, req daemonRequest) (daemonResponse, error) {
+	var resp daemonResponse
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return resp, err
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return resp, err
+	}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return resp, err
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
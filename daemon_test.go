@@ -0,0 +1,94 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDaemon(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "tagfmt.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix socket is not supported: %s", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- serveDaemon(l, Options{Align: true, Sort: true, Pattern: ".*", StructPattern: ".*"})
+	}()
+
+	src := []byte("package a\n\ntype A struct {\n\tName string `yaml:\"n\" json:\"name\"`\n\tB int `json:\"b\"`\n}\n")
+	res, err := daemonClient(socket, "a.go", src)
+	require.NoError(t, err)
+	assert.Equal(t, "package a\n\ntype A struct {\n\tName string `json:\"name\" yaml:\"n\"`\n\tB    int    `json:\"b\"`\n}\n", string(res))
+
+	_, err = daemonClient(socket, "a.go", []byte("package a\n\ntype A struct {\n\tName string `json`\n}\n"))
+	assert.EqualError(t, err, "a.go:4:14: invalid tag")
+
+	// the snippet is formatted like the standard input of the command
+	res, err = daemonClient(socket, "", []byte("\ttype A struct {\n\t\tName string `yaml:\"n\" json:\"name\"`\n\t}\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "\ttype A struct {\n\t\tName string `json:\"name\" yaml:\"n\"`\n\t}\n", string(res))
+
+	l.Close()
+	require.NoError(t, <-done)
+}
+
+func TestDaemonConfigReload(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	configName := filepath.Join(dir, "tagfmt.json")
+	require.NoError(t, ioutil.WriteFile(configName, []byte(`{"packages": [{"path": "...", "options": {"sort": true}}]}`), 0644))
+	*configFile = configName
+	socket := filepath.Join(dir, "tagfmt.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix socket is not supported: %s", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- serveDaemon(l, Options{Align: true, Pattern: ".*", StructPattern: ".*"})
+	}()
+
+	filename := filepath.Join(dir, "a.go")
+	src := []byte("package a\n\ntype A struct {\n\tName string `yaml:\"n\" json:\"name\"`\n}\n")
+	res, err := daemonClient(socket, filename, src)
+	require.NoError(t, err)
+	assert.Equal(t, "package a\n\ntype A struct {\n\tName string `json:\"name\" yaml:\"n\"`\n}\n", string(res))
+	// the unchanged config file is parsed once
+	cached, err := loadConfigCached(configName)
+	require.NoError(t, err)
+	assert.True(t, cached == config)
+
+	require.NoError(t, ioutil.WriteFile(configName, []byte(`{"packages": [{"path": "...", "options": {"sort": false}}]}`), 0644))
+	res, err = daemonClient(socket, filename, src)
+	require.NoError(t, err)
+	assert.Equal(t, string(src), string(res))
+
+	require.NoError(t, ioutil.WriteFile(configName, []byte(`{"packages": [`), 0644))
+	_, err = daemonClient(socket, filename, src)
+	assert.EqualError(t, err, "loading config: unexpected end of JSON input")
+
+	l.Close()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestDiffHunks(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	b := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nL\nm\nn"
+	d, err := diff([]byte(a), []byte(b), "x.go")
+	require.NoError(t, err)
+	assert.Equal(t, "--- x.go.orig\n+++ x.go\n"+
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n"+
+		"@@ -9,5 +9,6 @@\n i\n j\n k\n-l\n+L\n m\n+n\n\\ No newline at end of file\n", string(d))
+
+	// the hunks with 2*diffContext unchanged lines between them are joined
+	d, err = diff([]byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n"), []byte("X\n2\n3\n4\n5\n6\n7\nY\n9\n"), "x.go")
+	require.NoError(t, err)
+	assert.Equal(t, "--- x.go.orig\n+++ x.go\n@@ -1,9 +1,9 @@\n-1\n+X\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+Y\n 9\n", string(d))
+
+	d, err = diff(nil, []byte("a\nb\n"), "x.go")
+	require.NoError(t, err)
+	assert.Equal(t, "--- x.go.orig\n+++ x.go\n@@ -0,0 +1,2 @@\n+a\n+b\n", string(d))
+	d, err = diff([]byte("a\n"), nil, "x.go")
+	require.NoError(t, err)
+	assert.Equal(t, "--- x.go.orig\n+++ x.go\n@@ -1 +0,0 @@\n-a\n", string(d))
+}
+
+func TestDiffLinesRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randLines := func() []string {
+		lines := make([]string, rnd.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a'+rnd.Intn(4))) + "\n"
+		}
+		return lines
+	}
+	for i := 0; i < 200; i++ {
+		a, b := randLines(), randLines()
+		var gotA, gotB []string
+		for _, op := range diffLines(a, b) {
+			if op.kind != '+' {
+				gotA = append(gotA, op.line)
+			}
+			if op.kind != '-' {
+				gotB = append(gotB, op.line)
+			}
+		}
+		require.Equal(t, strings.Join(a, ""), strings.Join(gotA, ""))
+		require.Equal(t, strings.Join(b, ""), strings.Join(gotB, ""))
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// directivePrefixes is the comments read by tools, they must stay where they are
+var directivePrefixes = []string{"//go:", "//tagfmt:", "// +build", "//+build", "//nolint", "//lint:", "//line ", "/*line ", "//export ", "//extern "}
+
+// isDirective report whether the comment text is a directive e.g //go:generate
+func isDirective(text string) bool {
+	for _, prefix := range directivePrefixes {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// disableFileDirective is the comment leaves the whole file untouched
+const disableFileDirective = "//tagfmt:disable-file"
+
+// fileDisabled returns the line of //tagfmt:disable-file in the header of src, the
+// header is the comments before the package clause and the comments right after it,
+// the directive is followed by the end of line or a space and the reason
+func fileDisabled(src []byte) (int, bool) {
+	inBlock, seenPackage := false, false
+	for i, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case inBlock:
+			inBlock = !strings.Contains(line, "*/")
+		case line == "":
+		case strings.HasPrefix(line, "//"):
+			// the directive may be followed by a reason e.g //tagfmt:disable-file generated
+			if rest := strings.TrimPrefix(line, disableFileDirective); rest != line &&
+				(rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+				return i + 1, true
+			}
+		case strings.HasPrefix(line, "/*"):
+			inBlock = !strings.Contains(line[2:], "*/")
+		case !seenPackage && strings.HasPrefix(line, "package "):
+			seenPackage = true
+		default:
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// directiveStrings matches the string literals, they are removed from the directive anchor
+// so the changed tags don't change it
+var directiveStrings = regexp.MustCompile("`[^`]*`|\"(\\\\.|[^\"\\\\])*\"")
+
+// directiveAnchors returns the count of directives and their anchors in src, the anchor
+// is the code before the directive in the same line, or the next code line if the
+// directive is the whole line
+func directiveAnchors(filename string, src []byte) (map[string]int, error) {
+	fs := token.NewFileSet()
+	f, err := parser.ParseFile(fs, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(src), "\n")
+	anchors := map[string]int{}
+	for _, group := range f.Comments {
+		for _, c := range group.List {
+			if !isDirective(c.Text) {
+				continue
+			}
+			pos := fs.Position(c.Slash)
+			anchor := normalizeCode(lines[pos.Line-1][:pos.Column-1])
+			for i := pos.Line; anchor == "" && i < len(lines); i++ {
+				if line := strings.TrimSpace(lines[i]); !strings.HasPrefix(line, "//") {
+					anchor = normalizeCode(line)
+				}
+			}
+			anchors[c.Text+"\n"+anchor]++
+		}
+	}
+	return anchors, nil
+}
+
+func normalizeCode(code string) string {
+	return strings.Join(strings.Fields(directiveStrings.ReplaceAllString(code, "")), " ")
+}
+
+// checkDirectives reports whether the directives of src are moved or added in res
+func checkDirectives(filename string, src, res []byte) error {
+	before, err := directiveAnchors(filename, src)
+	if err != nil {
+		return err
+	}
+	after, err := directiveAnchors(filename, res)
+	if err != nil {
+		return err
+	}
+	for anchor, n := range after {
+		if before[anchor] != n {
+			return fmt.Errorf("%s: the directive %s would be moved or added by formatting", filename, strings.SplitN(anchor, "\n", 2)[0])
+		}
+	}
+	for anchor, n := range before {
+		if after[anchor] != n {
+			return fmt.Errorf("%s: the directive %s would be moved or removed by formatting", filename, strings.SplitN(anchor, "\n", 2)[0])
+		}
+	}
+	return nil
+}
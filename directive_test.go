@@ -0,0 +1,53 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDirectiveAnchors(t *testing.T) {
+	src := "package a\n\n//go:generate stringer\n// Kind doc\ntype Kind int\n\ntype A struct {\n\tX int `json:\"x\"` //nolint\n}\n"
+	anchors, err := directiveAnchors("a.go", []byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"//go:generate stringer\ntype Kind int": 1,
+		"//nolint\nX int":                       1,
+	}, anchors)
+
+	// the changed tag doesn't move the directive
+	assert.NoError(t, checkDirectives("a.go", []byte(src), []byte("package a\n\n//go:generate stringer\n// Kind doc\ntype Kind int\n\ntype A struct {\n\tX int `json:\"x,omitempty\"` //nolint\n}\n")))
+	assert.EqualError(t, checkDirectives("a.go", []byte(src), []byte("package a\n\n//go:generate stringer\n\ntype A struct {\n\tX int `json:\"x\"` //nolint\n}\n")),
+		"a.go: the directive //go:generate stringer would be moved or added by formatting")
+	assert.True(t, isDirective("//go:embed a.txt"))
+	assert.False(t, isDirective("// go:embed is a directive"))
+}
+
+func TestFileDisabled(t *testing.T) {
+	for _, c := range []struct {
+		src  string
+		line int
+		ok   bool
+	}{
+		{"//tagfmt:disable-file\n\npackage a\n", 1, true},
+		{"/*\n * copyright\n */\n\n// Package a doc\npackage a\n\n//tagfmt:disable-file\n\ntype A struct{}\n", 8, true},
+		{"package a // tagfmt\n//tagfmt:disable-file\n", 2, true},
+		{"/*\n//tagfmt:disable-file\n*/\npackage a\n", 0, false},
+		{"// tagfmt:disable-file\npackage a\n", 0, false},
+		{"//tagfmt:disable-file generated by protoc\npackage a\n", 1, true},
+		{"//tagfmt:disable-file\tgenerated\npackage a\n", 1, true},
+		{"//tagfmt:disable-files\npackage a\n", 0, false},
+		{"package a\n\nimport \"fmt\"\n\n//tagfmt:disable-file\n", 0, false},
+	} {
+		line, ok := fileDisabled([]byte(c.src))
+		assert.Equal(t, c.ok, ok, c.src)
+		assert.Equal(t, c.line, line, c.src)
+	}
+}
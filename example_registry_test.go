@@ -0,0 +1,65 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt_test
+
+import (
+	"errors"
+	"github.com/bigpigeon/tagfmt"
+	"go/ast"
+	"go/token"
+)
+
+// noXML rejects the xml key in the selected fields
+type noXML struct {
+	f      *ast.File
+	fs     *token.FileSet
+	filter *tagfmt.Filter
+}
+
+func (s *noXML) Scan() error {
+	var err error
+	ast.Inspect(s.f, func(node ast.Node) bool {
+		spec, ok := node.(*ast.TypeSpec)
+		if !ok || err != nil {
+			return err == nil
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok || !s.filter.Struct(spec.Name.Name) {
+			return false
+		}
+		for _, field := range st.Fields.List {
+			if field.Tag == nil || len(field.Names) == 0 || !s.filter.Field(spec.Name.Name, field.Names[0].Name) {
+				continue
+			}
+			_, keyValues, parseErr := tagfmt.ParseTag(field.Tag.Value)
+			if parseErr != nil {
+				continue
+			}
+			for _, kv := range keyValues {
+				if kv.Key == "xml" {
+					err = tagfmt.NewAstError(s.fs, field.Tag, errors.New("xml key is not allowed"))
+				}
+			}
+		}
+		return false
+	})
+	return err
+}
+
+func (s *noXML) Execute() error {
+	return nil
+}
+
+// the command with the company policy is a main package calls tagfmt.Main after
+// registering it
+func ExampleRegisterExecutor() {
+	tagfmt.RegisterExecutor("noxml", func(f *ast.File, fs *token.FileSet, opts tagfmt.Options, filter *tagfmt.Filter) (tagfmt.Executor, error) {
+		return &noXML{f: f, fs: fs, filter: filter}, nil
+	})
+	tagfmt.Main()
+}
@@ -0,0 +1,27 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt_test
+
+import (
+	"fmt"
+	"github.com/bigpigeon/tagfmt"
+)
+
+func ExampleSource() {
+	src := "type User struct {\n\tUserName string ``\n\tID int `json:\"id\"`\n}\n"
+	res, err := tagfmt.Source([]byte(src), tagfmt.Options{Align: true, Fill: "json=snake(:field)", Pattern: ".*", StructPattern: ".*"})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(string(res))
+	//output:
+	//type User struct {
+	//	UserName string `json:"user_name"`
+	//	ID       int    `json:"id"`
+	//}
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"errors"
+	"fmt"
+	"go/scanner"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// the exit codes of the error classes, the wrapper scripts fix the source for
+// exitChanged and exitSource, retry exitIO and fail for exitUsage
+const (
+	exitOK = 0
+	// exitChanged is the files need formatting found by -l -d -n or -patch
+	exitChanged = 1
+	// exitUsage is the invalid flags, patterns, rules and config
+	exitUsage = 2
+	// exitSource is the parse errors and the tag errors of the source
+	exitSource = 3
+	// exitIO is reading or writing the files failed
+	exitIO = 4
+)
+
+// exitRanks is the precedence of the exit codes, the run exits with the highest one
+// of its errors e.g the usage error hides the source errors caused by it
+var exitRanks = map[int]int{exitOK: 0, exitChanged: 1, exitSource: 2, exitIO: 3, exitUsage: 4}
+
+// exitSummary counts the changed files and the errors of every class in a run, they
+// are reported when the run exits with non-zero
+type exitSummary struct {
+	mu     sync.Mutex
+	counts map[int]int
+}
+
+var exits = &exitSummary{counts: map[int]int{}}
+
+// exitWith records a file or an error of the class code, the exit code is raised to
+// code if it's preferred, it's safe for concurrent use by the file workers
+func exitWith(code int) {
+	exits.mu.Lock()
+	defer exits.mu.Unlock()
+	exits.counts[code]++
+	exitCode = raiseExit(exitCode, code)
+}
+
+// raiseExit returns code raised to next if next is preferred, the subcommands combine
+// the exit codes of their errors and changes by it
+func raiseExit(code, next int) int {
+	if exitRanks[next] > exitRanks[code] {
+		return next
+	}
+	return code
+}
+
+// errorClass returns the exit code of err, the errors at a source position are
+// exitSource, the file system errors are exitIO, the others are exitUsage
+func errorClass(err error) int {
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	var syscallErr *os.SyscallError
+	switch {
+	case errors.As(err, &pathErr), errors.As(err, &linkErr), errors.As(err, &syscallErr):
+		return exitIO
+	}
+	var astErr *AstError
+	var scanErrs scanner.ErrorList
+	var scanErr *scanner.Error
+	switch {
+	case errors.As(err, &astErr), errors.As(err, &scanErrs), errors.As(err, &scanErr), errors.Is(err, ErrNotConverge):
+		return exitSource
+	}
+	return exitUsage
+}
+
+// Reset clears the counts for a new run
+func (s *exitSummary) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts = map[int]int{}
+}
+
+// Log logs the counts of the run as one record e.g
+// "summary: 2 files need formatting, 1 source error (exit 3)", nothing is logged when
+// the run has no change or error
+func (s *exitSummary) Log(l *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plural := func(n int, one, many string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, one)
+		}
+		return fmt.Sprintf("%d %s", n, many)
+	}
+	var parts []string
+	if n := s.counts[exitChanged]; n != 0 {
+		parts = append(parts, plural(n, "file needs formatting", "files need formatting"))
+	}
+	if n := s.counts[exitUsage]; n != 0 {
+		parts = append(parts, plural(n, "usage error", "usage errors"))
+	}
+	if n := s.counts[exitSource]; n != 0 {
+		parts = append(parts, plural(n, "source error", "source errors"))
+	}
+	if n := s.counts[exitIO]; n != 0 {
+		parts = append(parts, plural(n, "I/O error", "I/O errors"))
+	}
+	if len(parts) == 0 {
+		return
+	}
+	l.Info(fmt.Sprintf("%s (exit %d)", strings.Join(parts, ", "), exitCode), "kind", "summary:",
+		"changed", s.counts[exitChanged], "usage", s.counts[exitUsage], "source", s.counts[exitSource], "io", s.counts[exitIO], "exit", exitCode)
+}
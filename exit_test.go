@@ -0,0 +1,96 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/scanner"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrorClass(t *testing.T) {
+	pos := token.Position{Filename: "a.go", Line: 3, Column: 2}
+	var list scanner.ErrorList
+	list.Add(pos, "expected ';'")
+	_, statErr := os.Stat(filepath.Join(os.TempDir(), "tagfmt-missing", "a.go"))
+	assert.Equal(t, exitSource, errorClass(list))
+	assert.Equal(t, exitSource, errorClass(tagDockerErr{&AstError{Pos: pos, Err: ErrInvalidTag}}))
+	assert.Equal(t, exitSource, errorClass(fmt.Errorf("a.go: %w in 3 passes", ErrNotConverge)))
+	assert.Equal(t, exitIO, errorClass(statErr))
+	assert.Equal(t, exitIO, errorClass(fmt.Errorf("restoring a.go: %w", statErr)))
+	assert.Equal(t, exitUsage, errorClass(errors.New("require-match: -sp \"^Usr$\" matched no struct")))
+}
+
+func TestExitSummary(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n\ntype A struct {\n\tName string `json:\"name\" xml:\"name\"`\n\tB int `json:\"b\" xml:\"b\"`\n}\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b.go"), []byte("package a\n\ntype B struct {\n\tName string `json`\n}\n"), 0644))
+	var buf bytes.Buffer
+	defer func() { logger = newLogger(stderrWriter{}, "text", false) }()
+	logger = newLogger(&buf, "text", false)
+	devNull, err := os.Create(os.DevNull)
+	require.NoError(t, err)
+	defer devNull.Close()
+	stdout := os.Stdout
+	os.Stdout = devNull
+	defer func() { os.Stdout = stdout }()
+
+	// the I/O error is preferred to the source error and the changed file
+	*list = true
+	processPaths([]string{dir, filepath.Join(dir, "a.go", "c.go")}, nil)
+	assert.Equal(t, exitIO, exitCode)
+	assert.Contains(t, buf.String(), "summary: 1 file needs formatting, 1 source error, 1 I/O error (exit 4)\n")
+
+	// the source error is preferred to the changed file
+	buf.Reset()
+	exitCode = 0
+	processPaths([]string{dir}, nil)
+	assert.Equal(t, exitSource, exitCode)
+	assert.Contains(t, buf.String(), "summary: 1 file needs formatting, 1 source error (exit 3)\n")
+
+	// the usage error is preferred to all
+	buf.Reset()
+	exitCode = 0
+	*structPattern = "("
+	processPaths([]string{filepath.Join(dir, "a.go")}, nil)
+	assert.Equal(t, exitUsage, exitCode)
+	exitCode = 0
+}
+
+func TestSubcommandExitClass(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	var buf bytes.Buffer
+	defer func() { logger = newLogger(stderrWriter{}, "text", false) }()
+	logger = newLogger(&buf, "text", false)
+
+	// the subcommands report the errors by their classes like the formatting
+	assert.Equal(t, exitIO, renameValuesMain([]string{"-map", filepath.Join(dir, "none.json"), dir}))
+	assert.Equal(t, exitUsage, renameValuesMain([]string{"-map", filepath.Join(dir, "none.json")}))
+	assert.Contains(t, buf.String(), "rename-values: ")
+
+	assert.Equal(t, exitIO, raiseExit(exitSource, exitIO))
+	assert.Equal(t, exitSource, raiseExit(exitSource, exitChanged))
+	assert.Equal(t, exitUsage, raiseExit(exitIO, exitUsage))
+}
@@ -0,0 +1,32 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// fileID identifies a file by its real path, the system has no inode
+type fileID struct {
+	path string
+}
+
+func getFileID(path string, fi os.FileInfo) (fileID, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fileID{}, err
+	}
+	real, err = filepath.Abs(real)
+	if err != nil {
+		return fileID{}, err
+	}
+	return fileID{path: real}, nil
+}
@@ -0,0 +1,329 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"go/ast"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Filter decide which structs and fields will be processed, every executor
+// has its own Filter, so files can be formatted concurrently with different patterns
+type Filter struct {
+	// Field report whether the field name of struct structName is selected, the
+	// struct name is empty for the struct declared without type name
+	Field func(structName, name string) bool
+	// Struct report whether the struct is selected, a struct can have
+	// many names, e.g generic struct Pair[K, V] has name "Pair" and "Pair[K, V]",
+	// any name matched means the struct matched
+	Struct func(names ...string) bool
+	// Node report whether the struct node is selected, nil means all nodes,
+	// it's used to split the structs of a file by their role
+	Node func(n *ast.StructType) bool
+	// Proto report whether the messages and oneof wrappers generated by protoc-gen-go
+	// are selected, only the fill rules read :proto_name select them explicitly
+	Proto bool
+}
+
+// selectStruct report whether the struct n with names is selected
+func (f *Filter) selectStruct(n *ast.StructType, names ...string) bool {
+	return f.Struct(names...) && (f.Node == nil || f.Node(n))
+}
+
+// filterKey is the options a Filter is built from, the other options e.g the package
+// directory of the standard input don't change the Filter
+type filterKey struct {
+	pattern, inversePattern, structPattern, inverseStructPattern string
+	fieldGlob, structGlob                                        string
+	structName                                                   string
+	ignoreCase, exact, proto                                     bool
+}
+
+// maxCachedFilters is the most Filters cached, the cache is cleared when it's full
+const maxCachedFilters = 256
+
+// filterCache is the compiled Filters, the Node is set on the copies. the daemon and
+// worker run for long, so it's bounded and cleared after the config file changes
+var filterCache = struct {
+	sync.Mutex
+	filters map[filterKey]Filter
+}{filters: map[filterKey]Filter{}}
+
+// resetFilterCache clears the compiled Filters e.g of the patterns of the old config
+func resetFilterCache() {
+	filterCache.Lock()
+	filterCache.filters = map[filterKey]Filter{}
+	filterCache.Unlock()
+}
+
+// Filter build the Filter from options patterns, the glob pattern is preferred to the
+// pattern, the names matched the inverse pattern are excluded from them
+func (o Options) Filter() (*Filter, error) {
+	key := filterKey{
+		pattern: o.Pattern, inversePattern: o.InversePattern,
+		structPattern: o.StructPattern, inverseStructPattern: o.InverseStructPattern,
+		fieldGlob: o.FieldGlob, structGlob: o.StructGlob, structName: o.structName,
+		ignoreCase: o.PatternIgnoreCase, exact: o.ExactPattern, proto: strings.Contains(o.Fill, ":proto_name"),
+	}
+	// the daemon and worker format many files with the same options
+	filterCache.Lock()
+	cached, ok := filterCache.filters[key]
+	filterCache.Unlock()
+	if ok {
+		return &cached, nil
+	}
+	var filter Filter
+	var err error
+	fieldExpr := o.Pattern
+	if o.FieldGlob != "" {
+		fieldExpr = globsExpr(o.FieldGlob)
+	}
+	filter.Field, err = fieldSelect(o.patternExpr(fieldExpr), o.inverseExpr(o.InversePattern))
+	if err != nil {
+		return nil, err
+	}
+
+	if o.structName != "" {
+		filter.Struct = structNameSelect(o.structName)
+	} else {
+		structExpr := o.StructPattern
+		if o.StructGlob != "" {
+			structExpr = globsExpr(o.StructGlob)
+		}
+		filter.Struct, err = structSelect(o.patternExpr(structExpr), o.inverseExpr(o.InverseStructPattern))
+		if err != nil {
+			return nil, err
+		}
+	}
+	filter.Proto = key.proto
+	filterCache.Lock()
+	if len(filterCache.filters) >= maxCachedFilters {
+		filterCache.filters = map[filterKey]Filter{}
+	}
+	filterCache.filters[key] = filter
+	filterCache.Unlock()
+	return &filter, nil
+}
+
+// inverseExpr returns the regular expression of the inverse pattern, it's empty if
+// nothing is excluded
+func (o Options) inverseExpr(expr string) string {
+	if expr == "" {
+		return ""
+	}
+	return o.patternExpr(expr)
+}
+
+// patternExpr returns the regular expression of pattern with the pattern options,
+// the exact pattern matches the full name instead of the substring, the options are
+// applied to every alternative, so they are still split by splitAlternatives
+func (o Options) patternExpr(expr string) string {
+	if !o.ExactPattern && !o.PatternIgnoreCase {
+		return expr
+	}
+	alternatives := splitAlternatives(expr)
+	for i, alt := range alternatives {
+		if o.ExactPattern {
+			alt = "^(?:" + alt + ")$"
+		}
+		if o.PatternIgnoreCase {
+			alt = "(?i:" + alt + ")"
+		}
+		alternatives[i] = alt
+	}
+	return strings.Join(alternatives, "|")
+}
+
+// splitAlternatives splits the regular expression to its top level alternatives, the
+// | in the groups, the character classes and escaped e.g (a|b) and \| isn't split
+func splitAlternatives(expr string) []string {
+	var alternatives []string
+	depth, start := 0, 0
+	for i := 0; i < len(expr); i++ {
+		switch expr[i] {
+		case '\\':
+			i++
+		case '[':
+			// ] right after [ or [^ is a literal
+			i++
+			if i < len(expr) && expr[i] == '^' {
+				i++
+			}
+			if i < len(expr) && expr[i] == ']' {
+				i++
+			}
+			for ; i < len(expr) && expr[i] != ']'; i++ {
+				if expr[i] == '\\' {
+					i++
+				}
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '|':
+			if depth == 0 {
+				alternatives = append(alternatives, expr[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(alternatives, expr[start:])
+}
+
+// globExpr translates the glob pattern to the anchored regular expression, * matches
+// any characters, ? matches one character, [...] and [!...] match the character class,
+// e.g User* => ^User.*$
+func globExpr(glob string) string {
+	var buf strings.Builder
+	buf.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			buf.WriteString(".*")
+		case '?':
+			buf.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == -1 {
+				buf.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	buf.WriteString("$")
+	return buf.String()
+}
+
+// globsExpr translates the globs joined by | to the alternatives of regular expression
+func globsExpr(globs string) string {
+	exprs := strings.Split(globs, "|")
+	for i, glob := range exprs {
+		exprs[i] = globExpr(glob)
+	}
+	return strings.Join(exprs, "|")
+}
+
+// isQualifiedPattern report whether the field pattern matches the qualified name
+// e.g User\.Email, the field name never contains a dot, so the pattern with an
+// escaped dot is for the qualified name
+func isQualifiedPattern(expr string) bool {
+	return strings.Contains(expr, `\.`)
+}
+
+// fieldSelect returns the field selector matched expr and not matched inverseExpr,
+// the empty inverseExpr excludes nothing
+func fieldSelect(expr, inverseExpr string) (func(structName, name string) bool, error) {
+	match, err := fieldMatch(expr)
+	if err != nil {
+		return nil, err
+	}
+	if inverseExpr == "" {
+		return match, nil
+	}
+	inverse, err := fieldMatch(inverseExpr)
+	if err != nil {
+		return nil, err
+	}
+	return func(structName, name string) bool {
+		return match(structName, name) && !inverse(structName, name)
+	}, nil
+}
+
+// fieldMatch returns the matcher of field names, every alternative of expr is checked
+// by itself, the qualified ones match the qualified name and the others match the field
+// name, e.g User\.Email|Id doesn't select the fields of Identity
+func fieldMatch(expr string) (func(structName, name string) bool, error) {
+	if _, err := regexp.Compile(expr); err != nil {
+		return nil, err
+	}
+	var plainExprs, qualifiedExprs []string
+	for _, alt := range splitAlternatives(expr) {
+		if isQualifiedPattern(alt) {
+			qualifiedExprs = append(qualifiedExprs, alt)
+		} else {
+			plainExprs = append(plainExprs, alt)
+		}
+	}
+	plain, err := compileAlternatives(plainExprs)
+	if err != nil {
+		return nil, err
+	}
+	qualified, err := compileAlternatives(qualifiedExprs)
+	if err != nil {
+		return nil, err
+	}
+	return func(structName, name string) bool {
+		return plain != nil && plain.MatchString(name) ||
+			qualified != nil && qualified.MatchString(structName+"."+name)
+	}, nil
+}
+
+// compileAlternatives compiles the alternatives joined by |, it's nil without them
+func compileAlternatives(alternatives []string) (*regexp.Regexp, error) {
+	if len(alternatives) == 0 {
+		return nil, nil
+	}
+	return regexp.Compile(strings.Join(alternatives, "|"))
+}
+
+// structSelect returns the struct selector matched expr and not matched inverseExpr,
+// the empty inverseExpr excludes nothing
+func structSelect(expr, inverseExpr string) (func(names ...string) bool, error) {
+	match, err := structMatch(expr)
+	if err != nil {
+		return nil, err
+	}
+	if inverseExpr == "" {
+		return match, nil
+	}
+	inverse, err := structMatch(inverseExpr)
+	if err != nil {
+		return nil, err
+	}
+	return func(names ...string) bool {
+		return match(names...) && !inverse(names...)
+	}, nil
+}
+
+// structNameSelect selects the struct of name, the struct patterns are ignored
+func structNameSelect(name string) func(names ...string) bool {
+	return func(names ...string) bool {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func structMatch(expr string) (func(names ...string) bool, error) {
+	selRule, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return func(names ...string) bool {
+		for _, name := range names {
+			if selRule.MatchString(name) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
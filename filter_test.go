@@ -0,0 +1,186 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestFormatSourceConcurrent(t *testing.T) {
+	src := []byte("package main\n\ntype User struct {\n\tName string ``\n\tAge  int    ``\n}\n")
+	cases := []struct {
+		opts   Options
+		expect string
+	}{
+		{
+			Options{Align: true, Fill: "json=snake(:field)", Pattern: "^Name$", StructPattern: ".*"},
+			"package main\n\ntype User struct {\n\tName string `json:\"name\"`\n\tAge  int    ``\n}\n",
+		},
+		{
+			Options{Align: true, Fill: "json=snake(:field)", Pattern: "^Age$", StructPattern: ".*"},
+			"package main\n\ntype User struct {\n\tName string ``\n\tAge  int    `json:\"age\"`\n}\n",
+		},
+		{
+			Options{Align: true, Fill: "json=snake(:field)", Pattern: ".*", InverseStructPattern: "^User$"},
+			string(src),
+		},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		for _, c := range cases {
+			wg.Add(1)
+			go func(opts Options, expect string) {
+				defer wg.Done()
+				var buf bytes.Buffer
+				err := formatSource(&buf, "user.go", src, opts)
+				require.NoError(t, err)
+				assert.Equal(t, expect, buf.String())
+			}(c.opts, c.expect)
+		}
+	}
+	wg.Wait()
+}
+
+func TestFieldSelectQualified(t *testing.T) {
+	sel, err := fieldSelect(`^User\.(Email|Phone)$`, "")
+	require.NoError(t, err)
+	assert.True(t, sel("User", "Email"))
+	assert.False(t, sel("Contact", "Email"))
+	assert.False(t, sel("User", "Name"))
+
+	sel, err = fieldSelect(".*", `^User\.Email$`)
+	require.NoError(t, err)
+	assert.False(t, sel("User", "Email"))
+	assert.True(t, sel("Contact", "Email"))
+
+	// the pattern without escaped dot matches the field name
+	sel, err = fieldSelect(`^Email$`, "")
+	require.NoError(t, err)
+	assert.True(t, sel("User", "Email"))
+	assert.True(t, sel("", "Email"))
+
+	// every alternative is qualified or not by itself
+	sel, err = fieldSelect(`User\.Email|Id|(Name|Age)\b|[|.]x`, "")
+	require.NoError(t, err)
+	assert.True(t, sel("User", "Email"))
+	assert.True(t, sel("Order", "UserId"))
+	assert.True(t, sel("Order", "Age"))
+	assert.False(t, sel("Identity", "Token"))
+	assert.False(t, sel("Order", "Email"))
+	assert.Equal(t, []string{`User\.Email`, "Id", `(Name|Age)\b`, "[|.]x"}, splitAlternatives(`User\.Email|Id|(Name|Age)\b|[|.]x`))
+	assert.Equal(t, []string{`[]|]`, `a\|b`}, splitAlternatives(`[]|]|a\|b`))
+
+	filter, err := Options{Pattern: `User\.Email|Id`, ExactPattern: true, PatternIgnoreCase: true}.Filter()
+	require.NoError(t, err)
+	assert.True(t, filter.Field("user", "email"))
+	assert.True(t, filter.Field("Order", "ID"))
+	assert.False(t, filter.Field("Identity", "Token"))
+	assert.False(t, filter.Field("Order", "UserId"))
+}
+
+func TestGlobExpr(t *testing.T) {
+	for glob, expr := range map[string]string{
+		"User*":      `^User.*$`,
+		"?ser":       `^.ser$`,
+		"User.Email": `^User\.Email$`,
+		"[!A]*":      `^[^A].*$`,
+		"[a-c]d":     `^[a-c]d$`,
+		"a[b":        `^a\[b$`,
+	} {
+		assert.Equal(t, expr, globExpr(glob), glob)
+	}
+	filter, err := Options{StructGlob: "user*", Pattern: ".*", PatternIgnoreCase: true}.Filter()
+	require.NoError(t, err)
+	assert.True(t, filter.Struct("UserAudit"))
+	assert.False(t, filter.Struct("PowerUser"))
+
+	filter, err = Options{StructPattern: "User|Order", Pattern: `User\.Email`, ExactPattern: true}.Filter()
+	require.NoError(t, err)
+	assert.True(t, filter.Struct("User"))
+	assert.False(t, filter.Struct("UserAudit"))
+	assert.True(t, filter.Field("User", "Email"))
+	assert.False(t, filter.Field("User", "EmailVerified"))
+}
+
+func TestRepeatedPatterns(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	for _, arg := range [][2]string{
+		{"p", "^Name$"}, {"p", `^User\.Email$`}, {"P", "^ID$"}, {"P", "Secret"},
+		{"spg", "User*"}, {"spg", "Order"}, {"sP", "Audit"},
+	} {
+		require.NoError(t, commandLine.Set(arg[0], arg[1]))
+	}
+	assert.Equal(t, `^Name$|^User\.Email$`, *pattern)
+	assert.Equal(t, ".*", *structPattern)
+	filter, err := optionsFromFlags().Filter()
+	require.NoError(t, err)
+	assert.True(t, filter.Field("User", "Name"))
+	assert.True(t, filter.Field("User", "Email"))
+	assert.False(t, filter.Field("Order", "Email"))
+	assert.False(t, filter.Field("User", "ID"))
+	assert.True(t, filter.Struct("UserInfo"))
+	assert.True(t, filter.Struct("Order"))
+	assert.False(t, filter.Struct("UserAudit"))
+	assert.False(t, filter.Struct("OrderItem"))
+
+	// the include pattern is all with only the exclude pattern
+	resetFlags()
+	require.NoError(t, commandLine.Set("P", "Secret"))
+	filter, err = optionsFromFlags().Filter()
+	require.NoError(t, err)
+	assert.True(t, filter.Field("User", "Name"))
+	assert.False(t, filter.Field("User", "APISecret"))
+	assert.Equal(t, `-p "^Name$" -P "Secret"`, Options{Pattern: "^Name$", InversePattern: "Secret"}.fieldPatternDesc())
+	assert.Equal(t, `-P "Secret"`, optionsFromFlags().fieldPatternDesc())
+
+	// the qualified and plain patterns are matched against their own names
+	resetFlags()
+	require.NoError(t, commandLine.Set("p", `User\.Email`))
+	require.NoError(t, commandLine.Set("p", "Id"))
+	filter, err = optionsFromFlags().Filter()
+	require.NoError(t, err)
+	assert.True(t, filter.Field("User", "Email"))
+	assert.True(t, filter.Field("Order", "UserId"))
+	assert.False(t, filter.Field("Identity", "Name"))
+	assert.False(t, filter.Field("Order", "Email"))
+}
+
+func TestFilterCacheBound(t *testing.T) {
+	resetFilterCache()
+	// the options out of the patterns share the Filter
+	for _, dir := range []string{"a", "b", "c"} {
+		_, err := Options{Pattern: ".*", StructPattern: ".*", dir: dir}.Filter()
+		require.NoError(t, err)
+	}
+	assert.Len(t, filterCache.filters, 1)
+
+	for i := 0; i < maxCachedFilters+10; i++ {
+		_, err := Options{Pattern: fmt.Sprintf("^Field%d$", i), StructPattern: ".*"}.Filter()
+		require.NoError(t, err)
+		assert.True(t, len(filterCache.filters) <= maxCachedFilters)
+	}
+
+	// the changed config clears the cache
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	configName := filepath.Join(dir, "tagfmt.json")
+	require.NoError(t, ioutil.WriteFile(configName, []byte(`{"packages": [{"path": "...", "options": {"sort": true}}]}`), 0644))
+	_, err = loadConfigCached(configName)
+	require.NoError(t, err)
+	assert.Empty(t, filterCache.filters)
+}
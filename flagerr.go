@@ -0,0 +1,72 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FlagError is the error in the mini-language of a flag value e.g the fill rule of -f,
+// Text is the offending part and Offset is its byte offset in Value
+type FlagError struct {
+	Flag   string
+	Value  string
+	Offset int
+	Text   string
+	Err    error
+}
+
+func (e *FlagError) Error() string {
+	return fmt.Sprintf("%s %q:%d: %s (near %q)", e.Flag, e.Value, e.Offset, e.Err, e.Text)
+}
+
+func (e *FlagError) Unwrap() error {
+	return e.Err
+}
+
+// ruleError is the error of a part of the flag value, the parsers of sub parts don't know
+// their offset, so the caller locates Text in its part
+type ruleError struct {
+	Offset  int
+	Text    string
+	Err     error
+	located bool
+}
+
+func (e *ruleError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ruleError) Unwrap() error {
+	return e.Err
+}
+
+// locateRuleError sets the offset of err in s, s is at offset of the flag value,
+// the error not located yet is found by its text in s
+func locateRuleError(err error, s string, offset int) error {
+	var re *ruleError
+	if !errors.As(err, &re) {
+		return &ruleError{Offset: offset, Text: s, Err: err, located: true}
+	}
+	if !re.located {
+		if i := strings.Index(s, re.Text); i != -1 {
+			offset += i
+		}
+		re.Offset, re.located = offset, true
+	}
+	return re
+}
+
+// newFlagError converts the error of parsing value to FlagError
+func newFlagError(flag, value string, err error) error {
+	err = locateRuleError(err, value, 0)
+	re := err.(*ruleError)
+	return &FlagError{Flag: flag, Value: value, Offset: re.Offset, Text: re.Text, Err: re.Err}
+}
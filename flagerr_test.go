@@ -0,0 +1,107 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFlagError(t *testing.T) {
+	_, err := newTagFill(nil, nil, nil, "json=snak(:field)", false)
+	assert.EqualError(t, err, `-f "json=snak(:field)":5: invalid field rule snak, the functions are upper lower snake upper_camel lower_camel title dot trimprefix trimsuffix replace coalesce key or set unset skip default layout (near "snak(:field)")`)
+	var flagErr *FlagError
+	require.True(t, errors.As(err, &flagErr))
+	assert.Equal(t, 5, flagErr.Offset)
+
+	_, err = newTagFill(nil, nil, nil, "json=snake(:field)|yaml=or(:tag)", false)
+	assert.EqualError(t, err, `-f "json=snake(:field)|yaml=or(:tag)":27: args number wrong, want 2 args (near ":tag")`)
+
+	_, err = newTagFill(nil, nil, nil, "json=snake(:field)|(yaml,)=:tag", false)
+	assert.EqualError(t, err, `-f "json=snake(:field)|(yaml,)=:tag":19: invalid fill key group ((yaml,)) (near "(yaml,)")`)
+
+	_, err = newTagFill(nil, nil, nil, "json=snake(:field)|yaml[val=-]=:tag", false)
+	assert.EqualError(t, err, `-f "json=snake(:field)|yaml[val=-]=:tag":23: invalid fill condition [val=-], must be [val="value"] or [val!="value"] (near "[val=-]")`)
+
+	_, err = newTagFill(nil, nil, nil, "yaml=coalesce(key(:tag),:field)", false)
+	assert.EqualError(t, err, `-f "yaml=coalesce(key(:tag),:field)":14: invalid key ":tag" of key(), it must be a tag key e.g key(json) (near "key(:tag)")`)
+
+	_, err = newTagFill(nil, nil, nil, "json=snake(:field)|yaml='abc", false)
+	assert.EqualError(t, err, `-f "json=snake(:field)|yaml='abc":24: unclosed quote (near "'abc")`)
+	assert.True(t, errors.Is(err, ErrUnclosedQuote))
+
+	// the unquoted text is literal text unless in strict mode
+	_, err = newTagFill(nil, nil, nil, "json=snake(:field)+_omitempty", false)
+	assert.NoError(t, err)
+	_, err = newTagFill(nil, nil, nil, "json=snake(:field)+_omitempty", true)
+	assert.EqualError(t, err, `-f "json=snake(:field)+_omitempty":19: unknown variable _omitempty, the variables are :field :tag :tag_basic :tag_extra :proto_name :index, quote the literal text e.g '_omitempty' (near "_omitempty")`)
+	_, err = newTagFill(nil, nil, nil, "json=or(:tag, snake(:feild))", true)
+	assert.EqualError(t, err, `-f "json=or(:tag, snake(:feild))":20: unknown variable :feild, the variables are :field :tag :tag_basic :tag_extra :proto_name :index, quote the literal text e.g ':feild' (near ":feild")`)
+	_, err = newTagFill(nil, nil, nil, "json=snake(:field)+',omitempty'|yaml", true)
+	assert.NoError(t, err)
+
+	_, err = parseSortWeight("json=1|yaml=x")
+	assert.EqualError(t, err, `-sw "json=1|yaml=x":12: weight must be an integer (near "x")`)
+	_, err = parseSortWeight("json=1|yaml")
+	assert.EqualError(t, err, `-sw "json=1|yaml":7: weight must be the form key=weight (near "yaml")`)
+
+	_, err = parseSortOrder("json||yaml")
+	assert.EqualError(t, err, `-so "json||yaml":5: empty key (near "")`)
+	_, err = parseSortOrder("json|yaml|json")
+	assert.EqualError(t, err, `-so "json|yaml|json":10: duplicate key (near "json")`)
+	order, err := parseSortOrder(" json | yaml")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"json", "yaml"}, order)
+	_, err = parseSortOrder("json|x-[a")
+	assert.EqualError(t, err, `-so "json|x-[a":5: invalid key pattern (near "x-[a")`)
+	_, err = parseSortWeight("json=1|x-[=2")
+	assert.EqualError(t, err, `-sw "json=1|x-[=2":7: invalid key pattern (near "x-[")`)
+	_, err = parseSortWeight("json=1|validate=next:json")
+	assert.EqualError(t, err, `-sw "json=1|validate=next:json":16: unknown relation next, the relations are before after (near "next:json")`)
+	_, err = parseSortWeight("validate=after:validate")
+	assert.EqualError(t, err, `-sw "validate=after:validate":9: key is relative to itself (near "after:validate")`)
+	_, err = parseSortWeight("x-*=after:json")
+	assert.EqualError(t, err, `-sw "x-*=after:json":4: relation doesn't support key pattern (near "after:json")`)
+	weights, err := parseSortWeight("x-id=1|x-*=-1|x-i*=2")
+	require.NoError(t, err)
+	assert.Equal(t, 1, weights.weight("x-id"))
+	assert.Equal(t, -1, weights.weight("x-internal"))
+	assert.Equal(t, 0, weights.weight("json"))
+	assert.Equal(t, 1, sortRank([]string{"json", "x-*", "x-id"}, "x-doc"))
+	assert.Equal(t, 2, sortRank([]string{"json", "x-*", "x-id"}, "x-id"))
+
+	_, err = newTagRedact(nil, nil, nil, "(?i)password", "json|log=")
+	assert.True(t, errors.Is(err, ErrRedactKey))
+	redactor, err := newTagRedact(nil, nil, nil, "(?i)password", " json | log=mask")
+	require.NoError(t, err)
+	assert.Equal(t, []KeyValue{{Key: "json", Value: "-"}, {Key: "log", Value: "mask"}}, redactor.keys)
+
+	_, err = parseAlignGroups("json,yaml|gorm,json")
+	assert.EqualError(t, newFlagError("-align-group", "json,yaml|gorm,json", err), `-align-group "json,yaml|gorm,json":15: key is in more than one align group (near "json")`)
+
+	_, err = newTagTimeHint(nil, nil, nil, "time_format")
+	assert.True(t, errors.Is(err, ErrTimeHint))
+	hinter, err := newTagTimeHint(nil, nil, nil, "time_format=2006-01-02 | parquet,timestamp(millisecond)")
+	require.NoError(t, err)
+	assert.Equal(t, []timeHint{{Key: "time_format", Value: "2006-01-02"}, {Key: "parquet", Value: "timestamp(millisecond)", Option: true}}, hinter.hints)
+}
+
+func TestFieldErrorPosition(t *testing.T) {
+	initParserMode()
+	// the doctor is dropped, the invalid tag is reported by the sort with the field position
+	src := []byte("package a\n\ntype A struct {\n\tName string `json:\"name\" xml`\n}\n")
+	var buf bytes.Buffer
+	err := formatSource(&buf, "a.go", src, Options{Sort: true, Pattern: ".*", StructPattern: ".*", Pipeline: "sort"})
+	assert.EqualError(t, err, "a.go:4:2: invalid tag")
+
+	err = formatSource(&buf, "a.go", src, Options{Fill: "yaml=:field", Pattern: ".*", StructPattern: ".*", Pipeline: "fill"})
+	assert.EqualError(t, err, "a.go:4:14: invalid tag")
+}
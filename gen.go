@@ -0,0 +1,203 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// generators are the outputs of gen derived from the tags, the sources are untouched
+var generators = map[string]func(args []string) int{
+	"csv-header": genCSVHeaderMain,
+	"struct":     genStructMain,
+}
+
+// genMain runs the generator named by the first argument
+//
+//	tagfmt gen csv-header Record ./models
+func genMain(args []string) int {
+	if len(args) == 0 {
+		commandError("gen", fmt.Errorf("usage: tagfmt gen generator [arguments], the generators are %s", strings.Join(generatorNames(), " ")))
+		return exitUsage
+	}
+	gen, ok := generators[args[0]]
+	if !ok {
+		commandError("gen", fmt.Errorf("unknown generator %q, the generators are %s", args[0], strings.Join(generatorNames(), " ")))
+		return exitUsage
+	}
+	return gen(args[1:])
+}
+
+func generatorNames() []string {
+	var names []string
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// genCSVHeaderMain prints the header row gocarina/gocsv writes for the struct, with
+// -check the header of the csv file must be it, so the exports and the structs are
+// kept in sync by ci, the name is the first argument or -struct like show
+//
+//	tagfmt gen csv-header -check testdata/records.csv Record ./models
+func genCSVHeaderMain(args []string) int {
+	fs := flag.NewFlagSet("gen csv-header", flag.ContinueOnError)
+	key := fs.String("key", "csv", "the tag key of the column names")
+	sep := fs.String("sep", ",", "the column separator")
+	check := fs.String("check", "", "compare the header of the csv file instead of printing it, exit 1 if they differ")
+	structFlag := fs.String("struct", "", "the struct name, instead of the first argument")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	comma, size := utf8.DecodeRuneInString(*sep)
+	name, paths := structArg(*structFlag, fs.Args())
+	if size != len(*sep) || name == "" {
+		commandError("gen csv-header", errors.New("usage: tagfmt gen csv-header [-key csv] [-sep ,] [-check file] [-struct name] name [path ...]"))
+		return exitUsage
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	header, err := csvHeader(name, paths, *key)
+	if err != nil {
+		commandError("gen csv-header", err)
+		return errorClass(err)
+	}
+	if *check != "" {
+		got, err := readCSVHeader(*check, comma)
+		if err != nil {
+			commandError("gen csv-header", err)
+			return exitIO
+		}
+		if diff := csvHeaderDiff(header, got); diff != "" {
+			commandError("gen csv-header", fmt.Errorf("%s: the header isn't of %s, %s", *check, name, diff))
+			return exitChanged
+		}
+		return exitOK
+	}
+	w := csv.NewWriter(os.Stdout)
+	w.Comma = comma
+	w.Write(header)
+	w.Flush()
+	if err := w.Error(); err != nil {
+		commandError("gen csv-header", err)
+		return exitIO
+	}
+	return exitOK
+}
+
+// csvHeader returns the columns of the struct name, the struct must be declared once
+// in paths, the embedded structs are resolved in its package
+func csvHeader(name string, paths []string, key string) ([]string, error) {
+	filename, err := uniqueStructFile(name, paths)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, nil, parserMode)
+	if err != nil {
+		return nil, err
+	}
+	structs, err := packageStructs(fset, filename)
+	if err != nil {
+		return nil, err
+	}
+	spec := findStructDecl(f, name)
+	return csvColumns(structs, spec.Type.(*ast.StructType), key), nil
+}
+
+// csvColumns returns the columns of st like gocsv, the name of key or the field name,
+// the fields of - and the unexported fields are skipped, the embedded structs without
+// name are flattened
+func csvColumns(structs map[string]*ast.StructType, st *ast.StructType, key string) []string {
+	var columns []string
+	for _, f := range keyFields(structs, "", st, key, map[*ast.StructType]bool{}) {
+		columns = append(columns, f.name)
+	}
+	return columns
+}
+
+// fieldNames returns the names of field, the type name of the embedded field
+func fieldNames(field *ast.Field) []string {
+	if len(field.Names) == 0 {
+		return []string{embeddedName(field.Type)}
+	}
+	names := make([]string, 0, len(field.Names))
+	for _, name := range field.Names {
+		names = append(names, name.Name)
+	}
+	return names
+}
+
+// readCSVHeader reads the first record of the csv file
+func readCSVHeader(filename string, comma rune) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r := csv.NewReader(bufio.NewReader(file))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	// the exports of excel have the byte order mark
+	if len(header) != 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	return header, nil
+}
+
+// csvHeaderDiff describes the columns missing in got, the extra ones and the moved
+// ones, empty if they are the same
+func csvHeaderDiff(want, got []string) string {
+	wantSet, gotSet := map[string]bool{}, map[string]bool{}
+	for _, c := range want {
+		wantSet[c] = true
+	}
+	for _, c := range got {
+		gotSet[c] = true
+	}
+	var missing, extra []string
+	for _, c := range want {
+		if !gotSet[c] {
+			missing = append(missing, c)
+		}
+	}
+	for _, c := range got {
+		if !wantSet[c] {
+			extra = append(extra, c)
+		}
+	}
+	var parts []string
+	if len(missing) != 0 {
+		parts = append(parts, "missing "+strings.Join(missing, " "))
+	}
+	if len(extra) != 0 {
+		parts = append(parts, "extra "+strings.Join(extra, " "))
+	}
+	if len(parts) == 0 && strings.Join(want, "\x00") != strings.Join(got, "\x00") {
+		parts = append(parts, fmt.Sprintf("the order is %s", strings.Join(want, " ")))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenCSVHeader(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	src := "package wire\n\ntype Audit struct {\n\tCreatedBy string `csv:\"created_by\"`\n}\n\n" +
+		"type Record struct {\n" +
+		"\tID     int    `csv:\"id\"`\n" +
+		"\tName   string `csv:\"name,omitempty\"`\n" +
+		"\tSecret string `csv:\"-\"`\n" +
+		"\tsecret string\n" +
+		"\tCity   string\n" +
+		"\tAudit\n" +
+		"}\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "wire.go"), []byte(src), 0644))
+
+	header, err := csvHeader("Record", []string{dir}, "csv")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "City", "created_by"}, header)
+
+	assert.Equal(t, "", csvHeaderDiff(header, []string{"id", "name", "City", "created_by"}))
+	assert.Equal(t, "missing City, extra city", csvHeaderDiff(header, []string{"id", "name", "city", "created_by"}))
+	assert.Equal(t, "the order is id name City created_by", csvHeaderDiff(header, []string{"name", "id", "City", "created_by"}))
+
+	export := filepath.Join(dir, "records.csv")
+	require.NoError(t, ioutil.WriteFile(export, []byte("\ufeffid;name;City;created_by\n1;a;b;c\n"), 0644))
+	assert.Equal(t, exitOK, genMain([]string{"csv-header", "-sep", ";", "-check", export, "Record", dir}))
+	assert.Equal(t, exitChanged, genMain([]string{"csv-header", "-check", export, "Record", dir}))
+	assert.Equal(t, exitIO, genMain([]string{"csv-header", "-check", filepath.Join(dir, "none.csv"), "Record", dir}))
+	assert.Equal(t, exitUsage, genMain([]string{"csv-header", "User", dir}))
+	assert.Equal(t, exitUsage, genMain([]string{"csv-header"}))
+	// -struct of csv-header or of the main command instead of the argument
+	assert.Equal(t, exitOK, genMain([]string{"csv-header", "-sep", ";", "-check", export, "-struct", "Record", dir}))
+	*structName = "Record"
+	assert.Equal(t, exitOK, genMain([]string{"csv-header", "-sep", ";", "-check", export, dir}))
+	assert.Equal(t, exitUsage, genMain([]string{"csv-footer"}))
+}
+
+func TestGenCSVHeaderEmbeddedAcrossFiles(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	audit := "package wire\n\ntype Audit struct {\n\tCreatedBy string `csv:\"created_by\"`\n}\n"
+	record := "package wire\n\ntype Record struct {\n\tID int `csv:\"id\"`\n\t*Audit\n}\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "audit.go"), []byte(audit), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "record.go"), []byte(record), 0644))
+
+	header, err := csvHeader("Record", []string{dir}, "csv")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "created_by"}, header)
+}
+
+func TestCSVPreset(t *testing.T) {
+	p := tagPresets["csv"]
+	assert.NoError(t, p.Check("name,omitempty,default=0"))
+	assert.NoError(t, p.Check("-"))
+	assert.EqualError(t, p.Check("name,omitemtpy"), "unknown csv option omitemtpy")
+	assert.EqualError(t, p.Check("name,default"), "csv option default needs the value e.g default=0")
+	assert.EqualError(t, p.Check("name,omitempty=1"), "csv option omitempty has no value")
+}
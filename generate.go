@@ -0,0 +1,33 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// applyPostGenerate sets the flags of -post-generate, it formats the files created by
+// the generators before it in //go:generate lines, so they are written in place, the
+// check modes -l -d -n -require-match and the -v report are disabled, the _test.go
+// files e.g the generated mocks are processed when walking the directories, only the
+// errors are logged to keep go generate quiet
+//
+//	//go:generate mockgen -source user.go -destination mock_user.go
+//	//go:generate tagfmt -post-generate -f "json=snake(:field)" mock_user.go
+func applyPostGenerate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("-post-generate needs the generated files")
+	}
+	*write = true
+	*list, *doDiff, *dryRun = false, false, false
+	*requireMatch, *verbose = false, false
+	tests = optionalBool{set: true, value: true}
+	logger = slog.New(quietHandler{logger.Handler()})
+	return nil
+}
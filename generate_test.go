@@ -0,0 +1,49 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPostGenerate(t *testing.T) {
+	defer resetFlags()
+	defer func(l *slog.Logger) { logger = l }(logger)
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	src := "package a\n\ntype A struct {\n\tName string ``\n\tB int ``\n}\n"
+	formatted := "package a\n\ntype A struct {\n\tName string `json:\"name\"`\n\tB    int    `json:\"b\"`\n}\n"
+	mock := filepath.Join(dir, "mock_a_test.go")
+	require.NoError(t, ioutil.WriteFile(mock, []byte(src), 0644))
+
+	osArgs := os.Args
+	defer func() { os.Args = osArgs }()
+	run := func(args ...string) int {
+		resetFlags()
+		exitCode = 0
+		os.Args = append([]string{"tagfmt"}, args...)
+		gofmtMain()
+		return exitCode
+	}
+
+	// the generated _test.go file is written in the walked directory, -l is disabled
+	assert.Equal(t, 0, run("-post-generate", "-l", "-f", "json=snake(:field)", dir))
+	data, err := ioutil.ReadFile(mock)
+	require.NoError(t, err)
+	assert.Equal(t, formatted, string(data))
+
+	assert.Equal(t, 2, run("-post-generate", "-f", "json=snake(:field)"))
+	exitCode = 0
+}
@@ -0,0 +1,573 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// jsonNode is a decoded json value keeping the order of the object keys, the fields
+// of the generated structs are in the order of the spec
+type jsonNode struct {
+	// Kind is object, array, string, number, bool or null
+	Kind   string
+	Keys   []string
+	Values map[string]*jsonNode
+	Items  []*jsonNode
+	Text   string
+	// Offset is the byte offset of the value, KeyOffsets are of the object keys, the
+	// offsets may be before the separators and spaces of the value
+	Offset     int64
+	KeyOffsets map[string]int64
+}
+
+// decodeJSONNode decodes a json value from dec, dec must use json.Number
+func decodeJSONNode(dec *json.Decoder) (*jsonNode, error) {
+	offset := dec.InputOffset()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '[' {
+			node := &jsonNode{Kind: "array", Offset: offset}
+			for dec.More() {
+				item, err := decodeJSONNode(dec)
+				if err != nil {
+					return nil, err
+				}
+				node.Items = append(node.Items, item)
+			}
+			_, err := dec.Token()
+			return node, err
+		}
+		node := &jsonNode{Kind: "object", Values: map[string]*jsonNode{}, Offset: offset, KeyOffsets: map[string]int64{}}
+		for dec.More() {
+			keyOffset := dec.InputOffset()
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := tok.(string)
+			value, err := decodeJSONNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := node.Values[key]; !ok {
+				node.Keys = append(node.Keys, key)
+				node.KeyOffsets[key] = keyOffset
+			}
+			node.Values[key] = value
+		}
+		_, err := dec.Token()
+		return node, err
+	case string:
+		return &jsonNode{Kind: "string", Text: v, Offset: offset}, nil
+	case json.Number:
+		return &jsonNode{Kind: "number", Text: v.String(), Offset: offset}, nil
+	case bool:
+		return &jsonNode{Kind: "bool", Text: strconv.FormatBool(v), Offset: offset}, nil
+	default:
+		return &jsonNode{Kind: "null", Offset: offset}, nil
+	}
+}
+
+// readJSONNode decodes the json file, the data is returned for the positions of the
+// offsets
+func readJSONNode(filename string) (*jsonNode, []byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := decodeJSONNode(dec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return root, data, nil
+}
+
+// field returns the value of key of the object, nil if it's not an object or hasn't key
+func (n *jsonNode) field(key string) *jsonNode {
+	if n == nil || n.Kind != "object" {
+		return nil
+	}
+	return n.Values[key]
+}
+
+// str returns the text of the string value of key, empty if it isn't a string
+func (n *jsonNode) str(key string) string {
+	if v := n.field(key); v != nil && v.Kind == "string" {
+		return v.Text
+	}
+	return ""
+}
+
+// genField is a field of the generated struct, Name is the json name of the spec
+type genField struct {
+	GoName  string
+	Type    string
+	Name    string
+	Omit    bool
+	Comment string
+}
+
+// genStructDecl is a generated struct
+type genStructDecl struct {
+	Name   string
+	Fields []genField
+}
+
+// structGen generates the structs of a json schema or an example payload, the nested
+// objects are the structs named by their fields
+type structGen struct {
+	structs []*genStructDecl
+	names   map[string]bool
+	// defs are the structs of the schema definitions generated, by the ref
+	defs map[string]string
+	// generating are the structs whose fields are being generated, the refs to them
+	// are recursive
+	generating map[string]bool
+	root       *jsonNode
+	imports    map[string]bool
+}
+
+func newStructGen(root *jsonNode) *structGen {
+	return &structGen{root: root, names: map[string]bool{}, defs: map[string]string{}, generating: map[string]bool{}, imports: map[string]bool{}}
+}
+
+// isJSONSchema reports whether the spec is a json schema rather than a payload, a
+// schema has $schema or is an object type with properties
+func isJSONSchema(n *jsonNode) bool {
+	if n.field("$schema") != nil {
+		return true
+	}
+	props := n.field("properties")
+	return n.str("type") == "object" && props != nil && props.Kind == "object"
+}
+
+// goIdentWords are the initialisms of golint, the field names have them upper case
+var goIdentWords = map[string]string{
+	"id": "ID", "url": "URL", "uri": "URI", "api": "API", "http": "HTTP", "https": "HTTPS", "json": "JSON",
+	"xml": "XML", "uuid": "UUID", "ip": "IP", "sql": "SQL", "html": "HTML", "ttl": "TTL", "utc": "UTC",
+}
+
+// goIdent converts the json name to the exported go identifier e.g user_id and
+// user-id to UserID, the name starts with a digit has the prefix X
+func goIdent(name string) string {
+	var words []string
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words = append(words, snakeWords(part)...)
+	}
+	var b strings.Builder
+	for _, w := range words {
+		if upper, ok := goIdentWords[asciiLower(w)]; ok {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(upperCamelConvert(asciiLower(w)))
+	}
+	ident := b.String()
+	if ident == "" {
+		return "Field"
+	}
+	if unicode.IsDigit([]rune(ident)[0]) {
+		ident = "X" + ident
+	}
+	return ident
+}
+
+// uniqueName returns name or name with the number suffix if it's taken
+func (g *structGen) uniqueName(name string) string {
+	unique := name
+	for i := 2; g.names[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	return unique
+}
+
+// newStruct adds the struct named by name, the taken name has the number suffix
+func (g *structGen) newStruct(name string) *genStructDecl {
+	unique := g.uniqueName(name)
+	g.names[unique] = true
+	decl := &genStructDecl{Name: unique}
+	g.structs = append(g.structs, decl)
+	return decl
+}
+
+// uniqueFields renames the fields with the same go name e.g user_id and userId
+func uniqueFields(fields []genField) []genField {
+	seen := map[string]int{}
+	for i := range fields {
+		name := fields[i].GoName
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			fields[i].GoName = name + strconv.Itoa(n)
+		}
+	}
+	return fields
+}
+
+// schemaStruct generates the struct of the object schema
+func (g *structGen) schemaStruct(name string, schema *jsonNode) string {
+	decl := g.newStruct(name)
+	g.generating[decl.Name] = true
+	defer delete(g.generating, decl.Name)
+	required := map[string]bool{}
+	if req := schema.field("required"); req != nil {
+		for _, item := range req.Items {
+			required[item.Text] = true
+		}
+	}
+	props := schema.field("properties")
+	for _, key := range props.Keys {
+		prop := props.Values[key]
+		goName := goIdent(key)
+		typ := g.schemaType(decl.Name+goName, goName, prop)
+		// omitempty never omits the structs, the optional ones are pointers, and the
+		// recursive refs are pointers even they are required
+		if g.generating[typ] || (!required[key] && (g.names[typ] || typ == "time.Time")) {
+			typ = "*" + typ
+		}
+		decl.Fields = append(decl.Fields, genField{
+			GoName:  goName,
+			Type:    typ,
+			Name:    key,
+			Omit:    !required[key],
+			Comment: strings.Join(strings.Fields(prop.str("description")), " "),
+		})
+	}
+	decl.Fields = uniqueFields(decl.Fields)
+	return decl.Name
+}
+
+// schemaType returns the go type of the schema, the object schemas are the structs
+// named by name or fallback if name is taken, the nullable type e.g ["integer", "null"]
+// is the pointer unless its zero value is already nil
+func (g *structGen) schemaType(fallback, name string, schema *jsonNode) string {
+	typ := g.schemaValueType(fallback, name, schema)
+	if t := schema.field("type"); t != nil && t.Kind == "array" && !nilableType(typ) {
+		for _, item := range t.Items {
+			if item.Text == "null" {
+				return "*" + typ
+			}
+		}
+	}
+	return typ
+}
+
+// nilableType reports whether the zero value of the go type typ is nil
+func nilableType(typ string) bool {
+	return typ == "interface{}" || strings.HasPrefix(typ, "*") || strings.HasPrefix(typ, "[]") || strings.HasPrefix(typ, "map[")
+}
+
+// schemaValueType returns the go type of the schema without its nullability
+func (g *structGen) schemaValueType(fallback, name string, schema *jsonNode) string {
+	if ref := schema.str("$ref"); ref != "" {
+		return g.schemaRef(ref)
+	}
+	typ := schema.str("type")
+	if t := schema.field("type"); t != nil && t.Kind == "array" {
+		for _, item := range t.Items {
+			if item.Text != "null" {
+				typ = item.Text
+				break
+			}
+		}
+	}
+	if typ == "" && schema.field("properties") != nil {
+		typ = "object"
+	}
+	switch typ {
+	case "object":
+		if props := schema.field("properties"); props != nil && props.Kind == "object" {
+			if g.names[name] {
+				name = fallback
+			}
+			return g.schemaStruct(name, schema)
+		}
+		if extra := schema.field("additionalProperties"); extra != nil && extra.Kind == "object" {
+			return "map[string]" + g.schemaType(fallback+"Value", name+"Value", extra)
+		}
+		return "map[string]interface{}"
+	case "array":
+		items := schema.field("items")
+		if items == nil || items.Kind != "object" {
+			return "[]interface{}"
+		}
+		return "[]" + g.schemaType(fallback+"Item", name+"Item", items)
+	case "string":
+		if schema.str("format") == "date-time" {
+			g.imports["time"] = true
+			return "time.Time"
+		}
+		return "string"
+	case "integer":
+		return "int64"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	}
+	return "interface{}"
+}
+
+// schemaRef returns the struct of the local ref e.g #/definitions/User or #/$defs/User,
+// the definition is generated once
+func (g *structGen) schemaRef(ref string) string {
+	if name, ok := g.defs[ref]; ok {
+		return name
+	}
+	parts := strings.Split(strings.TrimPrefix(ref, "#/"), "/")
+	node := g.root
+	for _, part := range parts {
+		node = node.field(part)
+	}
+	if !strings.HasPrefix(ref, "#/") || node == nil {
+		return "interface{}"
+	}
+	name := goIdent(parts[len(parts)-1])
+	if node.field("properties") == nil {
+		return g.schemaType(name, name, node)
+	}
+	// the recursive refs use the name before the fields are generated
+	name = g.uniqueName(name)
+	g.defs[ref] = name
+	g.schemaStruct(name, node)
+	return name
+}
+
+// exampleStruct generates the struct of the object payload, the fields of the objects
+// in an array are merged
+func (g *structGen) exampleStruct(name string, objects []*jsonNode) string {
+	decl := g.newStruct(name)
+	var keys []string
+	values := map[string][]*jsonNode{}
+	for _, obj := range objects {
+		for _, key := range obj.Keys {
+			if _, ok := values[key]; !ok {
+				keys = append(keys, key)
+			}
+			values[key] = append(values[key], obj.Values[key])
+		}
+	}
+	for _, key := range keys {
+		goName := goIdent(key)
+		decl.Fields = append(decl.Fields, genField{
+			GoName: goName,
+			Type:   g.exampleType(decl.Name+goName, goName, values[key]),
+			Name:   key,
+		})
+	}
+	decl.Fields = uniqueFields(decl.Fields)
+	return decl.Name
+}
+
+// exampleType returns the go type of the values of a field, the null values are skipped,
+// the numbers are float64 if any of them has the fraction or exponent, the integers over
+// int64 are uint64, or json.Number if they are over uint64 too or mixed with the negative
+// integers
+func (g *structGen) exampleType(fallback, name string, values []*jsonNode) string {
+	var kind string
+	var objects, items []*jsonNode
+	float, negative, overInt, overUint := false, false, false, false
+	for _, v := range values {
+		if v.Kind == "null" {
+			continue
+		}
+		if kind != "" && kind != v.Kind {
+			return "interface{}"
+		}
+		kind = v.Kind
+		switch v.Kind {
+		case "object":
+			objects = append(objects, v)
+		case "array":
+			items = append(items, v.Items...)
+		case "number":
+			if strings.ContainsAny(v.Text, ".eE") {
+				float = true
+			} else if _, err := strconv.ParseInt(v.Text, 10, 64); err != nil {
+				overInt = true
+				_, err := strconv.ParseUint(v.Text, 10, 64)
+				overUint = overUint || err != nil
+			} else {
+				negative = negative || strings.HasPrefix(v.Text, "-")
+			}
+		}
+	}
+	switch kind {
+	case "object":
+		if g.names[name] {
+			name = fallback
+		}
+		return g.exampleStruct(name, objects)
+	case "array":
+		if len(items) == 0 {
+			return "[]interface{}"
+		}
+		return "[]" + g.exampleType(fallback+"Item", name+"Item", items)
+	case "string":
+		return "string"
+	case "number":
+		switch {
+		case float:
+			return "float64"
+		case overUint || (overInt && negative):
+			g.imports["encoding/json"] = true
+			return "json.Number"
+		case overInt:
+			return "uint64"
+		}
+		return "int64"
+	case "bool":
+		return "bool"
+	}
+	return "interface{}"
+}
+
+// writeStructs writes the source of the generated structs in package pkg, the tags
+// are json:"name" with omitempty for the optional properties of the schema
+func (g *structGen) writeStructs(w io.Writer, pkg string) {
+	fmt.Fprintf(w, "package %s\n\n", pkg)
+	var imports []string
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		fmt.Fprintf(w, "import %q\n\n", imp)
+	}
+	for i, decl := range g.structs {
+		if i != 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "type %s struct {\n", decl.Name)
+		for _, f := range decl.Fields {
+			value := f.Name
+			if f.Omit {
+				value += ",omitempty"
+			}
+			tag := "json:" + strconv.Quote(value)
+			literal := "`" + tag + "`"
+			if strings.ContainsRune(tag, '`') {
+				literal = strconv.Quote(tag)
+			}
+			fmt.Fprintf(w, "\t%s %s %s", f.GoName, f.Type, literal)
+			if f.Comment != "" {
+				fmt.Fprintf(w, " // %s", f.Comment)
+			}
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, "}")
+	}
+}
+
+// genStructMain generates the go structs of a json schema or an example json payload,
+// the tags are filled and aligned by the flags and the config like formatting, so
+// -f -preset and -s give the structs the keys of the other formats
+//
+//	tagfmt -f 'yaml=:tag_basic' gen struct -from spec.json -name Order -o order.go
+func genStructMain(args []string) int {
+	fs := flag.NewFlagSet("gen struct", flag.ContinueOnError)
+	from := fs.String("from", "", "the json schema or the example json payload")
+	name := fs.String("name", "", "the root struct name, default the title of schema or the file name")
+	pkg := fs.String("package", "main", "the package of the generated file")
+	output := fs.String("o", "", "write the generated file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *from == "" || fs.NArg() != 0 {
+		commandError("gen struct", errors.New("usage: tagfmt [flags] gen struct -from file [-name Name] [-package main] [-o file]"))
+		return exitUsage
+	}
+	res, err := genStruct(*from, *name, *pkg, *output)
+	if err != nil {
+		commandError("gen struct", err)
+		return errorClass(err)
+	}
+	if *output == "" {
+		os.Stdout.Write(res)
+		return exitOK
+	}
+	if err := ioutil.WriteFile(*output, res, 0644); err != nil {
+		commandError("gen struct", err)
+		return exitIO
+	}
+	return exitOK
+}
+
+// genStruct returns the formatted source of the structs of the spec file, output is
+// the file name the config options are resolved for
+func genStruct(from, name, pkg, output string) ([]byte, error) {
+	root, _, err := readJSONNode(from)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = root.str("title")
+		if name == "" || !isJSONSchema(root) {
+			name = strings.TrimSuffix(filepath.Base(from), filepath.Ext(from))
+			name = strings.TrimSuffix(name, ".schema")
+		}
+	}
+	name = goIdent(name)
+	g := newStructGen(root)
+	switch {
+	case isJSONSchema(root):
+		if root.field("properties") == nil {
+			return nil, fmt.Errorf("%s: the schema root must be an object with properties", from)
+		}
+		// the ref # is the root struct
+		g.defs["#"] = name
+		g.schemaStruct(name, root)
+	case root.Kind == "object":
+		g.exampleStruct(name, []*jsonNode{root})
+	case root.Kind == "array" && len(root.Items) != 0 && root.Items[0].Kind == "object":
+		g.exampleStruct(name, root.Items)
+	default:
+		return nil, fmt.Errorf("%s: the payload must be an object or an array of objects", from)
+	}
+	var src bytes.Buffer
+	g.writeStructs(&src, pkg)
+	filename := output
+	if filename == "" {
+		filename = strings.ToLower(name) + ".go"
+	}
+	opts, err := config.Options(optionsFromFlags(), filename)
+	if err != nil {
+		return nil, err
+	}
+	// the generated code is gofmt-ed first, formatting only realigns the changed structs
+	gen, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	var res bytes.Buffer
+	if err := formatSource(&res, filename, gen, opts); err != nil {
+		return nil, err
+	}
+	return res.Bytes(), nil
+}
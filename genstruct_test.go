@@ -0,0 +1,159 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGoIdent(t *testing.T) {
+	for name, ident := range map[string]string{
+		"user_id":    "UserID",
+		"userId":     "UserID",
+		"zip-code":   "ZipCode",
+		"api url":    "APIURL",
+		"2fa":        "X2fa",
+		"UserName":   "UserName",
+		"__":         "Field",
+		"created_at": "CreatedAt",
+	} {
+		assert.Equal(t, ident, goIdent(name), name)
+	}
+}
+
+func TestGenStruct(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	schema := filepath.Join(dir, "order.schema.json")
+	require.NoError(t, ioutil.WriteFile(schema, []byte(`{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["id", "items"],
+  "properties": {
+    "id": {"type": "integer", "description": "the order id"},
+    "created_at": {"type": "string", "format": "date-time"},
+    "items": {"type": "array", "items": {"$ref": "#/$defs/line_item"}},
+    "shipping": {"type": "object", "properties": {"city": {"type": ["string", "null"]}, "floor": {"type": ["integer", "null"]}, "notes": {"type": ["array", "null"], "items": {"type": "string"}}}},
+    "meta": {"type": "object", "additionalProperties": {"type": "number"}}
+  },
+  "$defs": {
+    "line_item": {"type": "object", "required": ["sku"], "properties": {"sku": {"type": "string"}, "parent": {"$ref": "#/$defs/line_item"}}}
+  }
+}`), 0644))
+	res, err := genStruct(schema, "", "model", "")
+	require.NoError(t, err)
+	assert.Equal(t, "package model\n\nimport \"time\"\n\n"+
+		"type Order struct {\n"+
+		"\tID        int64              `json:\"id\"` // the order id\n"+
+		"\tCreatedAt *time.Time         `json:\"created_at,omitempty\"`\n"+
+		"\tItems     []LineItem         `json:\"items\"`\n"+
+		"\tShipping  *Shipping          `json:\"shipping,omitempty\"`\n"+
+		"\tMeta      map[string]float64 `json:\"meta,omitempty\"`\n"+
+		"}\n\n"+
+		"type LineItem struct {\n"+
+		"\tSku    string    `json:\"sku\"`\n"+
+		"\tParent *LineItem `json:\"parent,omitempty\"`\n"+
+		"}\n\n"+
+		"type Shipping struct {\n"+
+		"\tCity  *string  `json:\"city,omitempty\"`\n"+
+		"\tFloor *int64   `json:\"floor,omitempty\"`\n"+
+		"\tNotes []string `json:\"notes,omitempty\"`\n"+
+		"}\n", string(res))
+
+	// the objects of the array are merged, the rules of -f fill the other keys
+	payload := filepath.Join(dir, "users.json")
+	require.NoError(t, ioutil.WriteFile(payload, []byte(`[{"id": 1, "score": 2, "tags": []}, {"id": 2, "score": 1.5, "nick": null, "tags": ["a"]}]`), 0644))
+	*fill = "yaml=key(json)"
+	res, err = genStruct(payload, "user", "main", "")
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\n"+
+		"type User struct {\n"+
+		"\tID    int64       `json:\"id\"    yaml:\"id\"`\n"+
+		"\tScore float64     `json:\"score\" yaml:\"score\"`\n"+
+		"\tTags  []string    `json:\"tags\"  yaml:\"tags\"`\n"+
+		"\tNick  interface{} `json:\"nick\"  yaml:\"nick\"`\n"+
+		"}\n", string(res))
+	*fill = ""
+
+	// the integers over int64 are uint64, over uint64 or mixed with the negative ones
+	// are json.Number
+	require.NoError(t, ioutil.WriteFile(payload, []byte(`[{"id": 18446744073709551615, "seq": 1, "big": 18446744073709551616, "delta": -1}, {"id": 1, "seq": 2, "big": 1, "delta": 9223372036854775808}]`), 0644))
+	res, err = genStruct(payload, "counter", "main", "")
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nimport \"encoding/json\"\n\n"+
+		"type Counter struct {\n"+
+		"\tID    uint64      `json:\"id\"`\n"+
+		"\tSeq   int64       `json:\"seq\"`\n"+
+		"\tBig   json.Number `json:\"big\"`\n"+
+		"\tDelta json.Number `json:\"delta\"`\n"+
+		"}\n", string(res))
+
+	require.NoError(t, ioutil.WriteFile(payload, []byte(`[1, 2]`), 0644))
+	_, err = genStruct(payload, "", "main", "")
+	assert.EqualError(t, err, payload+": the payload must be an object or an array of objects")
+	assert.Equal(t, exitIO, genMain([]string{"struct", "-from", filepath.Join(dir, "none.json")}))
+	assert.Equal(t, exitUsage, genMain([]string{"struct"}))
+
+	output := filepath.Join(dir, "order.go")
+	assert.Equal(t, exitOK, genMain([]string{"struct", "-from", schema, "-o", output}))
+	data, err := ioutil.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "type Order struct {\n")
+}
+
+func TestGenStructRecursive(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	schema := filepath.Join(dir, "forest.schema.json")
+	require.NoError(t, ioutil.WriteFile(schema, []byte(`{
+  "type": "object",
+  "required": ["tree", "parent"],
+  "properties": {
+    "tree": {"$ref": "#/definitions/Tree"},
+    "parent": {"$ref": "#"}
+  },
+  "definitions": {
+    "Tree": {"type": "object", "required": ["left"], "properties": {"left": {"$ref": "#/definitions/Tree"}, "leaves": {"type": "array", "items": {"$ref": "#/definitions/Tree"}}}}
+  }
+}`), 0644))
+	res, err := genStruct(schema, "", "model", "")
+	require.NoError(t, err)
+	assert.Equal(t, "package model\n\n"+
+		"type Forest struct {\n"+
+		"\tTree   Tree    `json:\"tree\"`\n"+
+		"\tParent *Forest `json:\"parent\"`\n"+
+		"}\n\n"+
+		"type Tree struct {\n"+
+		"\tLeft   *Tree  `json:\"left\"`\n"+
+		"\tLeaves []Tree `json:\"leaves,omitempty\"`\n"+
+		"}\n", string(res))
+
+	// the generated code compiles
+	fs := token.NewFileSet()
+	f, err := parser.ParseFile(fs, "forest.go", res, 0)
+	require.NoError(t, err)
+	_, err = (&types.Config{}).Check("model", fs, []*ast.File{f}, nil)
+	assert.NoError(t, err)
+}
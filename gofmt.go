@@ -5,7 +5,7 @@
 // This file was copied from the src/cmd/gofmt/gofmt.go
 // but processFile function is modified
 
-package main
+package tagfmt
 
 import (
 	"bytes"
@@ -15,65 +15,184 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/printer"
-	"go/scanner"
 	"go/token"
 	"io"
 	"io/ioutil"
+	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"regexp"
 	"runtime"
 	"runtime/pprof"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// tests is the -tests flag
+var tests optionalBool
+
+func init() {
+	commandLine.Var(&tests, "tests", "process the _test.go files when walking directories, default true except -w, the files in arguments are always processed")
+}
+
+// commandLine is the flags of the tagfmt command, they aren't registered in the
+// flag.CommandLine of the programs importing the package, the invalid flags don't exit
+// so the worker keeps serving the next request
+var commandLine = flag.NewFlagSet("tagfmt", flag.ContinueOnError)
+
 var (
 	// main operation modes
-	list                 = flag.Bool("l", false, "list files whose formatting differs from tagfmt's")
-	align                = flag.Bool("a", true, "align with nearby field's tag")
-	write                = flag.Bool("w", false, "write result to (source) file instead of stdout")
-	tagSort              = flag.Bool("s", false, "sort struct tag by key")
-	tagSortOrder         = flag.String("so", "", "sort struct tag keys order e.g json|yaml|desc")
-	tagSortWeight        = flag.String("sw", "", "sort struct tag keys weight e.g json=1|yaml=2|desc=-1 the higher weight, the higher the ranking, default keys weight is 0")
-	doDiff               = flag.Bool("d", false, "display diffs instead of rewriting files")
-	allErrors            = flag.Bool("e", false, "report all errors (not just the first 10 on different lines)")
-	fill                 = flag.String("f", "", "fill key and value for field e.g json=lower(_val)|yaml=snake(_val)")
-	pattern              = flag.String("p", ".*", "field name with regular expression pattern")
-	inversePattern       = flag.String("P", "", "field name with inverse regular expression pattern")
-	structPattern        = flag.String("sp", ".*", "struct name with regular expression pattern")
-	inverseStructPattern = flag.String("sP", "", "struct name with inverse regular expression pattern")
+	list                 = commandLine.Bool("l", false, "list files whose formatting differs from tagfmt's")
+	align                = commandLine.Bool("a", true, "align with nearby field's tag")
+	write                = commandLine.Bool("w", false, "write result to (source) file instead of stdout")
+	alignKey             = commandLine.String("align-key", "", "only align the structs have one of the keys e.g gorm|db")
+	maxLineLen           = commandLine.Int("max-line-len", 0, "the max length of the aligned field lines, the tabs of indent count as 4 columns, 0 means no limit")
+	maxTagLen            = commandLine.Int("max-tag-len", 0, "the doctor reports the tags longer than it with the length of every key, 0 means no limit")
+	positionKeys         = commandLine.String("position-keys", "", "the keys of the field positions e.g csv|fixed, the doctor reports the gaps and overlaps of their indexes and start,end ranges in a struct")
+	overflow             = commandLine.String("overflow", "unalign", "the policy of the lines over -max-line-len, unalign leaves them unaligned, shrink aligns fewer key columns, report reports them as errors")
+	alignGroups          = commandLine.String("align-group", "", "the key groups share one align column e.g json,yaml|gorm, the adjacent keys of a group are separated by one space")
+	preset               = commandLine.String("preset", "", "tag key presets e.g json|msgpack, fill and sort the keys with their conventions and check their options")
+	strictComments       = commandLine.Bool("strict-comments", false, "fail if the directive comments e.g //go:generate //nolint would be moved by formatting")
+	pad                  = commandLine.String("pad", "space", "padding of the gap between the field type and tag, space or tab, tab prints the source with the tab padding, the padding inside the tags is always space")
+	strictFill           = commandLine.Bool("strict-fill", false, "the unquoted text of fill rule must be a variable e.g :field, the literal text must be quoted")
+	keepOptions          = commandLine.Bool("keep-options", false, "the fill keeps the options e.g ,omitempty of the replaced value if the rule result has no options")
+	defaultSource        = commandLine.String("default-source", "const,constructor", "the sources of default() fill rule in order, const is the constant Default<Struct><Field>, constructor is the constant set to the field in New<Struct> or Default<Struct>")
+	strictKeys           = commandLine.Bool("strict-keys", false, "report the unknown tag keys, the common keys and preset keys are known")
+	knownKeys            = commandLine.String("known-keys", "", "the extra known keys of -strict-keys e.g foo|bar")
+	timeHints            = commandLine.String("time-hint", "", "the companion keys and options of time.Time fields e.g time_format=2006-01-02|parquet,timestamp(millisecond), the missing ones are added")
+	remnants             = commandLine.String("remnants", "", "the policy of the trailing comments look like the disabled tags e.g // json:\"old\", report reports them, remove removes them, restore adds their missing keys to the tags and removes them")
+	redact               = commandLine.String("redact", "", "the regexp of secret field names e.g (?i)password|secret|token, their redaction keys are set to hide them from the serialization")
+	redactKey            = commandLine.String("redact-key", "json", "the redaction keys of -redact e.g json|yaml, the key is set to -, key=value sets the value e.g log=mask")
+	syncKeys             = commandLine.String("sync", "", "keep the values of key pairs the same e.g binding=validate, the empty one is copied from the other")
+	tagSort              = commandLine.Bool("s", false, "sort struct tag by key")
+	tagSortOrder         = commandLine.String("so", "", "sort struct tag keys order e.g json|yaml|x-*|desc, the wildcard key matches a family of keys")
+	tagSortWeight        = commandLine.String("sw", "", "sort struct tag keys weight e.g json=1|yaml=2|desc=-1 the higher weight, the higher the ranking, default keys weight is 0, validate=after:json places the key right after the other key")
+	doDiff               = commandLine.Bool("d", false, "display diffs instead of rewriting files")
+	allErrors            = commandLine.Bool("e", false, "report all errors (not just the first 10 on different lines)")
+	fill                 = joinedFlag("f", "", "|", "fill key and value for field e.g json=lower(:field)|yaml=snake(:field), the repeated flags are joined e.g -f json=lower(:field) -f yaml=snake(:field)")
+	pattern              = joinedFlag("p", ".*", "|", "field name with regular expression pattern, the pattern with \\. matches the qualified name e.g User\\.Email, the repeated patterns are ORed")
+	inversePattern       = joinedFlag("P", "", "|", "field name with inverse regular expression pattern, the fields matched it are excluded from -p or -pg, the repeated patterns are ORed")
+	structPattern        = joinedFlag("sp", ".*", "|", "struct name with regular expression pattern, the repeated patterns are ORed")
+	inverseStructPattern = joinedFlag("sP", "", "|", "struct name with inverse regular expression pattern, the structs matched it are excluded from -sp or -spg, the repeated patterns are ORed")
+	fieldGlob            = joinedFlag("pg", "", "|", "field name with glob pattern e.g Created*, it's anchored and preferred to -p, the repeated patterns are ORed")
+	structGlob           = joinedFlag("spg", "", "|", "struct name with glob pattern e.g User*, it's anchored and preferred to -sp, the repeated patterns are ORed")
+	patternIgnoreCase    = commandLine.Bool("pi", false, "the field and struct patterns are case-insensitive")
+	requireMatch         = commandLine.Bool("require-match", false, "fail if the field and struct patterns matched no struct or field in all files")
+	colorMode            = commandLine.String("color", "auto", "color the diffs and diagnostics, auto, always or never, auto honors NO_COLOR, CLICOLOR_FORCE and TERM=dumb and colors only the terminal")
+	logFormat            = commandLine.String("log-format", "text", "format of the diagnostics on stderr, text or json, json writes an object per line with the level, msg and pos")
+	verbose              = commandLine.Bool("v", false, "report the files, structs and fields skipped by the patterns and why")
+	exactPattern         = commandLine.Bool("exact", false, "the field and struct patterns match the full name instead of the substring e.g -sp User doesn't match UserAudit")
+	srcdir               = commandLine.String("srcdir", "", "choose options as if the standard input source is from dir, dir may be the complete file name")
+	daemon               = commandLine.Bool("daemon", false, "run as daemon, format the source sent by -client")
+	daemonClientMode     = commandLine.Bool("client", false, "send the standard input to daemon and print the result, with -struct print the declarations of the struct looked up by daemon")
+	socket               = commandLine.String("socket", defaultSocket(), "unix socket of daemon")
+	persistentWorker     = commandLine.Bool("persistent_worker", false, "run as bazel persistent worker, read work requests from standard input")
+	workerProtocol       = commandLine.String("worker_protocol", "proto", "bazel worker protocol, proto or json")
+	configFile           = commandLine.String("config", "", "config file with per-package option overrides")
+	parallel             = commandLine.Int("j", runtime.NumCPU(), "number of files processed in parallel")
+	dryRun               = commandLine.Bool("n", false, "dry run, list the planned tag operations of every changed field instead of formatting")
+	atomicRunFlag        = commandLine.Bool("atomic-run", false, "with -w, write the files only when all files are formatted without error")
+	postGenerate         = commandLine.Bool("post-generate", false, "format the generated files of //go:generate in place, it implies -w, disables -l -d -n -require-match -v and logs the errors only")
+	twoPhase             = commandLine.Bool("dry-run-then-write", false, "check all files and report the counts first, then write the changed files on confirmation or -yes")
+	yes                  = commandLine.Bool("yes", false, "with -dry-run-then-write, write the files without confirmation")
+	journalFlag          = commandLine.Bool("journal", false, "with -w, record the written files in .tagfmt/undo, tagfmt undo reverts the last run")
+	followSymlinks       = commandLine.Bool("follow-symlinks", false, "follow symbolic links when walking directories")
+	rewrite              = commandLine.String("r", "", "rewrite rule for tag key value e.g 'json:\"a\" -> json:\"b\"', empty replacement delete the key")
+	splitMulti           = commandLine.Bool("split-multi", false, "split multi-name field e.g 'A, B string' to separate fields")
+	tmpl                 = commandLine.Bool("tmpl", false, "also format the struct declarations without template actions in "+templateSuffix+" files")
+	tmplDelims           = commandLine.String("tmpl-delims", "{{ }}", "the left and right delimiters of template actions")
+	patch                = commandLine.Bool("patch", false, "print the changes as a json line per file with the byte ranges of the original source and their new text instead of the whole file")
+	offset               = commandLine.Int("offset", -1, "only format the struct type enclosing the byte offset of the file or standard input and print the changed range as \"start end\" and the new text")
+	structName           = commandLine.String("struct", "", "only format the struct of the name in the packages and print its declaration, -w -l -d work as usual, it selects the struct of show, preview, promoted, check-payload and gen csv-header too")
+	structIndexFlag      = commandLine.Bool("struct-index", false, "persist the struct index in .tagfmt/index.json, -struct parses only the files changed since the last lookup")
+	pipeline             = commandLine.String("pipeline", "", "executors order e.g doctor,fill,sort,align, the executors not listed are dropped, default "+strings.Join(pipelineStages, ","))
 
 	// debugging
-	cpuprofile = flag.String("cpuprofile", "", "write cpu profile to this file")
+	cpuprofile = commandLine.String("cpuprofile", "", "write cpu profile to this file")
 )
 
 func resetFlags() {
 	*list = false
 	*align = true
+	*alignKey = ""
+	*alignGroups = ""
+	*maxLineLen = 0
+	*maxTagLen = 0
+	*positionKeys = ""
+	*overflow = "unalign"
+	*preset = ""
+	*strictKeys = false
+	*strictComments = false
+	*strictFill = false
+	*keepOptions = false
+	*defaultSource = "const,constructor"
+	*pad = "space"
+	*timeHints = ""
+	*remnants = ""
+	*redact = ""
+	*redactKey = "json"
+	*syncKeys = ""
+	*knownKeys = ""
 	*write = false
 	*tagSort = false
 	*tagSortOrder = ""
+	*tagSortWeight = ""
 	*doDiff = false
 	*allErrors = false
 	*fill = ""
+	resetJoinedFlags()
 	*pattern = ".*"
 	*inversePattern = ""
 	*structPattern = ".*"
 	*inverseStructPattern = ""
+	*fieldGlob = ""
+	*structGlob = ""
+	*patternIgnoreCase = false
+	*exactPattern = false
+	*verbose = false
+	*colorMode = "auto"
+	*logFormat = "text"
+	logger = newLogger(stderrWriter{}, "text", false)
+	*requireMatch = false
+	tests = optionalBool{}
+	*configFile = ""
+	*srcdir = ""
+	*daemon = false
+	*daemonClientMode = false
+	*socket = defaultSocket()
+	*persistentWorker = false
+	*workerProtocol = "proto"
+	*rewrite = ""
+	*splitMulti = false
+	*pipeline = ""
+	*structName = ""
+	*structIndexFlag = false
+	*offset = -1
+	*patch = false
+	*tmpl = false
+	*tmplDelims = "{{ }}"
+	*followSymlinks = false
+	*atomicRunFlag = false
+	*journalFlag = false
+	*postGenerate = false
+	*twoPhase = false
+	*yes = false
+	*dryRun = false
+	*parallel = runtime.NumCPU()
 	*cpuprofile = ""
+	config = nil
 }
 
 const (
 	tabWidth    = 4
-	printerMode = printer.UseSpaces
+	printerMode = printer.UseSpaces | printer.TabIndent
 )
 
 var (
-	fileSet    = token.NewFileSet() // per process FileSet
 	exitCode   = 0
 	parserMode parser.Mode
+	config     *Config
+	scheduler  *fileScheduler
 )
 
 // error define
@@ -81,39 +200,247 @@ var (
 	ErrUnclosedQuote   = errors.New("unclosed quote")
 	ErrUnclosedBracket = errors.New("unclosed bracket")
 	ErrInvalidTag      = errors.New("invalid tag")
+	ErrNotConverge     = errors.New("the result of executors does not converge")
+	ErrConflictMarker  = errors.New("merge conflict marker, the file is skipped, resolve the conflict and run tagfmt again")
+	// ErrRedactKey is the -redact-key cell not in the form key or key=value
+	ErrRedactKey = errors.New("redaction key must be the form key or key=value e.g json|log=mask")
+	// ErrTimeHint is the -time-hint cell not in the form key=value or key,option
+	ErrTimeHint = errors.New("time hint must be the form key=value or key,option e.g time_format=2006-01-02|parquet,timestamp(millisecond)")
 )
 
+// AstError is the error at a node position, it's printed as file:line:col: message
+// just like the go compiler, so the editors can parse it
+type AstError struct {
+	Pos token.Position
+	Err error
+}
+
+func (e *AstError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Err)
+}
+
+func (e *AstError) Unwrap() error {
+	return e.Err
+}
+
 func NewAstError(fs *token.FileSet, n ast.Node, err error) error {
-	s := fs.Position(n.Pos())
-	return fmt.Errorf("%s:%d %s", filepath.Base(s.Filename), s.Line, err)
+	return &AstError{Pos: fs.Position(n.Pos()), Err: err}
 }
 
+// report print the err and raise the exit code to its class
 func report(err error) {
-	scanner.PrintError(os.Stderr, err)
-	exitCode = 2
+	logError(logger, slog.LevelError, err)
+	exitWith(errorClass(err))
+}
+
+// warn print the err but don't change the exit code
+func warn(err error) {
+	var astErr *AstError
+	if errors.As(err, &astErr) {
+		err = astErr
+	}
+	logError(logger, slog.LevelWarn, err)
+}
+
+// subcommands run by `tagfmt [flags] command [arguments]`, return the exit code
+var subcommands = map[string]func(args []string) int{
+	"install-hook":  installHookMain,
+	"keys":          keysMain,
+	"migrate":       migrateMain,
+	"pre-commit":    preCommitMain,
+	"check-payload": checkPayloadMain,
+	"conformance":   conformanceMain,
+	"gen":           genMain,
+	"preview":       previewMain,
+	"promoted":      promotedMain,
+	"rename-values": renameValuesMain,
+	"show":          showMain,
+	"swag-check":    swagCheckMain,
+	"undo":          undoMain,
 }
 
 func usage() {
 	fmt.Fprintf(os.Stderr, "usage: tagfmt [flags] [path ...]\n")
-	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "       tagfmt [flags] command [arguments]\n")
+	commandLine.PrintDefaults()
 }
 
+// defaultParserMode is the parser mode without -e, tagfmt never use the object, skip
+// it to save memory
+const defaultParserMode = parser.ParseComments | parser.SkipObjectResolution
+
 func initParserMode() {
-	parserMode = parser.ParseComments
+	parserMode = defaultParserMode
 	if *allErrors {
 		parserMode |= parser.AllErrors
 	}
 }
 
+// parseMode returns the parser mode of the source formatted with o, the mode of the
+// command is set by initParserMode, Source doesn't depend on it
+func (o Options) parseMode() parser.Mode {
+	if o.source {
+		return defaultParserMode
+	}
+	return parserMode
+}
+
+// roleConfig returns the config whose roles apply to the source formatted with o, the
+// config of the command isn't applied to Source
+func (o Options) roleConfig() *Config {
+	if o.source {
+		return nil
+	}
+	return config
+}
+
+// srcdirFile returns a file name in srcdir, it's used to find the config options of standard input
+func srcdirFile(dir string) string {
+	if strings.HasSuffix(dir, ".go") {
+		return dir
+	}
+	return filepath.Join(dir, "<standard input>")
+}
+
+// optionalBool is a bool flag can be unset, the default of unset flag depends on
+// the other flags
+type optionalBool struct {
+	set   bool
+	value bool
+}
+
+func (b *optionalBool) String() string {
+	if b == nil || !b.set {
+		return ""
+	}
+	return strconv.FormatBool(b.value)
+}
+
+func (b *optionalBool) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	b.set, b.value = true, v
+	return nil
+}
+
+func (b *optionalBool) IsBoolFlag() bool { return true }
+
+// joinedValue is a string flag can be repeated, the values are joined by sep, so the
+// long lists needn't be quoted as one argument, the first value replaces the default
+type joinedValue struct {
+	p   *string
+	sep string
+	set bool
+}
+
+// joinedFlag defines a joinedValue flag with the default value and returns the
+// joined string
+func joinedFlag(name, value, sep, usage string) *string {
+	p := new(string)
+	*p = value
+	commandLine.Var(&joinedValue{p: p, sep: sep}, name, usage)
+	return p
+}
+
+// resetJoinedFlags makes the next value of joined flags replace the current one
+func resetJoinedFlags() {
+	commandLine.VisitAll(func(f *flag.Flag) {
+		if v, ok := f.Value.(*joinedValue); ok {
+			v.set = false
+		}
+	})
+}
+
+func (v *joinedValue) String() string {
+	if v == nil || v.p == nil {
+		return ""
+	}
+	return *v.p
+}
+
+func (v *joinedValue) Set(s string) error {
+	if !v.set {
+		*v.p, v.set = "", true
+	}
+	switch {
+	case s == "":
+	case *v.p == "":
+		*v.p = s
+	default:
+		*v.p += v.sep + s
+	}
+	return nil
+}
+
+// includeTests report whether the _test.go files are processed when walking the
+// directories, default true except -w, so the test fixtures are not rewritten by
+// tree-wide runs
+func includeTests() bool {
+	if tests.set {
+		return tests.value
+	}
+	return !*write
+}
+
+// isSkippedTestFile report whether the walked file is a _test.go file to skip
+func isSkippedTestFile(name string) bool {
+	return strings.HasSuffix(name, "_test.go") && !includeTests()
+}
+
 func isGoFile(f os.FileInfo) bool {
 	// ignore non-Go files
 	name := f.Name()
-	return !f.IsDir() && !strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".go")
+	return !f.IsDir() && !strings.HasPrefix(name, ".") && (strings.HasSuffix(name, ".go") || *tmpl && isTemplateFile(name))
+}
+
+// conflictMarker returns the position of the first merge conflict marker in src, the
+// file is in conflict if a <<<<<<< line is closed by a >>>>>>> line, the parse errors
+// of it are confusing
+func conflictMarker(filename string, src []byte) (token.Position, bool) {
+	isMarker := func(line []byte, marker string) bool {
+		return bytes.HasPrefix(line, []byte(marker)) && (len(line) == len(marker) || line[len(marker)] == ' ' || line[len(marker)] == '\r')
+	}
+	start := 0
+	for i, line := range bytes.Split(src, []byte("\n")) {
+		switch {
+		case isMarker(line, "<<<<<<<"):
+			if start == 0 {
+				start = i + 1
+			}
+		case start != 0 && isMarker(line, ">>>>>>>"):
+			return token.Position{Filename: filename, Line: start, Column: 1}, true
+		}
+	}
+	return token.Position{}, false
+}
+
+// formatInput formats src of filename to out by its kind, the templates are formatted
+// by their go blocks, the standard input e.g of the editors and the daemon client can
+// be a snippet e.g a struct type without package clause
+func formatInput(out *bytes.Buffer, filename string, src []byte, opts Options, stdin bool) error {
+	if pos, ok := conflictMarker(filename, src); ok {
+		return &AstError{Pos: pos, Err: ErrConflictMarker}
+	}
+	switch {
+	case isTemplateFile(filename):
+		return processTemplate(out, filename, src, opts)
+	case stdin:
+		res, err := formatFragment(filename, src, opts)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(res)
+		return err
+	}
+	return formatSource(out, filename, src, opts)
 }
 
 // If in == nil, the source is the contents of the file with the given filename.
 func processFile(filename string, in io.Reader, out io.Writer, stdin bool) error {
 	var perm os.FileMode = 0644
+	var size int64
 	if in == nil {
 		f, err := os.Open(filename)
 		if err != nil {
@@ -126,157 +453,416 @@ func processFile(filename string, in io.Reader, out io.Writer, stdin bool) error
 		}
 		in = f
 		perm = fi.Mode().Perm()
+		size = fi.Size()
+	}
+	optsName := filename
+	if stdin && *srcdir != "" {
+		optsName = srcdirFile(*srcdir)
+	}
+	opts, err := config.Options(optionsFromFlags(), optsName)
+	if err != nil {
+		return err
+	}
+
+	srcBuf := getBuffer()
+	defer putBuffer(srcBuf)
+	if size > 0 {
+		srcBuf.Grow(int(size) + bytes.MinRead)
+	}
+	_, err = srcBuf.ReadFrom(in)
+	if err != nil {
+		return err
 	}
-	if *inversePattern != "" {
-		err := selectInit(*inversePattern, true)
+	src := srcBuf.Bytes()
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if stdin && *srcdir != "" {
+		opts.dir = filepath.Dir(optsName)
+	}
+	if err := formatInput(buf, filename, src, opts, stdin); err != nil {
+		return err
+	}
+	res := buf.Bytes()
+	if !*write && (*list || *doDiff || *patch || *dryRun) && !bytes.Equal(src, res) {
+		exitWith(exitChanged)
+	}
+
+	if *dryRun {
+		plan, err := planChanges(filename, src, res)
 		if err != nil {
 			return err
 		}
-	} else {
-		err := selectInit(*pattern, false)
-		if err != nil {
+		writePlan(out, filename, plan)
+		return nil
+	}
+
+	if !bytes.Equal(src, res) {
+		// formatting has changed
+		if *list {
+			fmt.Fprintln(out, filename)
+		}
+		if *write {
+			if undoJournal != nil {
+				if err := undoJournal.Record(filename, src, res, perm); err != nil {
+					return err
+				}
+			}
+			if atomicWrites != nil {
+				atomicWrites.Add(filename, src, res, perm)
+			} else if err := writeFile(filename, src, res, perm); err != nil {
+				return err
+			}
+		}
+		if *doDiff {
+			data, err := diff(src, res, filename)
+			if err != nil {
+				return fmt.Errorf("computing diff: %s", err)
+			}
+			data = append([]byte(fmt.Sprintf("diff -u %s %s\n", filepath.ToSlash(filename+".orig"), filepath.ToSlash(filename))), data...)
+			if colorEnabled(os.Stdout) {
+				data = colorDiff(data)
+			}
+			out.Write(data)
+		}
+		if *patch {
+			if err := writePatch(out, filename, lineEdits(src, res)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !*list && !*write && !*doDiff && !*patch {
+		_, err = out.Write(res)
+	}
+
+	return err
+}
+
+// maxFormatPasses is the most passes to reach the fixed point, the result doesn't
+// change when it's formatted again
+const maxFormatPasses = 3
+
+// formatSource formats src with opts and writes the result to out,
+// it's safe for concurrent use with different options.
+// the executors may depend on each other's result, e.g. fill from the tag sorted and
+// aligned, the source is formatted until the result doesn't change, and it's an error if
+// the result is still changing after maxFormatPasses, instead of the output changed
+// again by the next run
+func formatSource(out *bytes.Buffer, filename string, src []byte, opts Options) error {
+	if line, ok := fileDisabled(src); ok {
+		if skips != nil {
+			skips.Add(token.Position{Filename: filename, Line: line, Column: 1}, "file: disabled by %s", disableFileDirective)
+		}
+		_, err := out.Write(src)
+		return err
+	}
+	var res bytes.Buffer
+	if err := formatPass(&res, filename, src, opts); err != nil {
+		return err
+	}
+	// the unchanged source is the fixed point, it isn't parsed again
+	if bytes.Equal(res.Bytes(), src) {
+		_, err := out.Write(src)
+		return err
+	}
+	opts.recheck = true
+	for pass := 1; pass < maxFormatPasses; pass++ {
+		var next bytes.Buffer
+		if err := formatPass(&next, filename, res.Bytes(), opts); err != nil {
+			return err
+		}
+		if bytes.Equal(next.Bytes(), res.Bytes()) {
+			if err := checkCgo(filename, src, res.Bytes()); err != nil {
+				return err
+			}
+			if opts.StrictComments {
+				if err := checkDirectives(filename, src, res.Bytes()); err != nil {
+					return err
+				}
+			}
+			_, err := out.Write(res.Bytes())
 			return err
 		}
+		res = next
 	}
+	return fmt.Errorf("%s: %w in %d passes", filename, ErrNotConverge, maxFormatPasses)
+}
 
-	if *inverseStructPattern != "" {
-		err := structSelectInit(*inverseStructPattern, true)
-		if err != nil {
+// formatPass runs the executors on src once
+func formatPass(out *bytes.Buffer, filename string, src []byte, opts Options) error {
+	// per file FileSet, the per process one keep growing when walk a large tree
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, filename, src, opts.parseMode())
+	if err != nil {
+		return err
+	}
+	defer releaseCommentMap(file)
+	spans := recordSpans(file, fileSet)
+	if skips != nil && !opts.recheck {
+		if err := skips.scanFile(file, fileSet, opts); err != nil {
 			return err
 		}
-	} else {
-		err := structSelectInit(*structPattern, false)
-		if err != nil {
+	}
+	if matches != nil && !opts.recheck {
+		if err := matches.scanFile(file, fileSet, opts); err != nil {
 			return err
 		}
 	}
 
-	src, err := ioutil.ReadAll(in)
+	// the structs of different roles are processed by their own executors in one pass
+	chains, err := opts.roleConfig().roleChains(file, fileSet, opts)
 	if err != nil {
 		return err
 	}
+	var executor []Executor
+	for _, chain := range chains {
+		exes, err := newExecutors(file, fileSet, chain.Options, chain.Filter)
+		if err != nil {
+			return err
+		}
+		executor = append(executor, exes...)
+	}
+	// the errors of all executors are reported together so they are fixed in one edit
+	// cycle, nothing is executed when the scan failed
+	var errs tagDockerErr
+	for _, scan := range executor {
+		errs = appendErrors(errs, scan.Scan())
+	}
+	if len(errs) == 0 {
+		for _, exe := range executor {
+			errs = appendErrors(errs, exe.Execute())
+		}
+	}
+	switch len(errs) {
+	case 0:
+	case 1:
+		return errs[0]
+	default:
+		return errs
+	}
 
-	file, err := parser.ParseFile(fileSet, filename, src, parserMode)
+	// splice the changes into the source instead of printing the ast, the code,
+	// comments and line breaks out of the changed structs' field lines are never
+	// reflowed
+	edits, err := spans.edits(file, src)
 	if err != nil {
 		return err
 	}
+	mode, err := padPrinterMode(opts.Pad)
+	if err != nil {
+		return err
+	}
+	realign := realignedStructs(file, spans, executor, edits)
+	// the unedited source is realigned by its own ast, only the edited one is parsed again
+	if len(edits) == 0 {
+		return printSpliced(out, filename, src, realign, fileSet, file, opts.parseMode(), mode)
+	}
+	return printSpliced(out, filename, splice(src, edits), realign, nil, nil, opts.parseMode(), mode)
+}
 
-	var executor []Executor
+// newExecutors returns the executors of opts in the pipeline order, they only process
+// the structs and fields selected by filter
+func newExecutors(file *ast.File, fileSet *token.FileSet, opts Options, filter *Filter) ([]Executor, error) {
+	order, err := parsePipeline(opts.Pipeline)
+	if err != nil {
+		return nil, err
+	}
+	stages := map[string]Executor{}
+	presets, err := parsePresets(opts.Preset)
+	if err != nil {
+		return nil, err
+	}
+	fill, err := presetFill(opts.Fill, presets)
+	if err != nil {
+		return nil, err
+	}
 
-	executor = append(executor, &tagDoctor{
-		f:  file,
-		fs: fileSet,
-	})
+	if opts.SplitMulti {
+		stages["split"] = newTagSplit(file, fileSet, filter)
+	}
+
+	doctor := &tagDoctor{
+		f:       file,
+		fs:      fileSet,
+		filter:  filter,
+		presets: presets,
+	}
+	if opts.StrictKeys {
+		doctor.known = knownTagKeys(opts.KnownKeys)
+	}
+	doctor.checkBools = opts.StrictKeys || len(presets) != 0
+	doctor.maxTagLen = opts.MaxTagLen
+	if opts.PositionKeys != "" {
+		doctor.positionKeys = strings.Split(opts.PositionKeys, "|")
+	}
+	stages["doctor"] = doctor
+	stages["comment"] = newTagCommentReflow(file, fileSet, filter)
+	if opts.Remnants != "" {
+		if err := parseRemnants(opts.Remnants); err != nil {
+			return nil, err
+		}
+		stages["remnant"] = &tagRemnant{f: file, fs: fileSet, filter: filter, policy: opts.Remnants}
+	}
 
-	if *fill != "" {
-		filler, err := newTagFill(file, fileSet, *fill)
+	if opts.Rewrite != "" {
+		rewriter, err := newTagRewrite(file, fileSet, filter, opts.Rewrite)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		executor = append(executor, filler)
+		stages["rewrite"] = rewriter
 	}
 
-	if *tagSort {
+	// renaming twice makes the chain a->b, b->c rename a to c
+	if valueRenames != nil && !opts.recheck {
+		stages["rename"] = newTagValueRename(file, fileSet, filter, valueRenames)
+	}
+	if tagMigration != nil && !opts.recheck {
+		stages["migrate"] = newTagMigrator(file, fileSet, filter, tagMigration)
+	}
 
-		weights := map[string]int{}
-		for _, weightStr := range strings.Split(*tagSortWeight, "|") {
-			weightStr = strings.TrimSpace(weightStr)
-			if strings.TrimSpace(weightStr) == "" {
-				continue
+	if fill != "" {
+		filler, err := newTagFill(file, fileSet, filter, fill, opts.StrictFill)
+		if err != nil {
+			return nil, err
+		}
+		filler.quiet = opts.recheck
+		// like rename the conditions are checked once, against the values of the source
+		filler.recheck = opts.recheck
+		filler.keepOptions = opts.KeepOptions
+		if fillUsesDefaults(fill) {
+			dir := filepath.Dir(fileSet.Position(file.Package).Filename)
+			if opts.dir != "" {
+				dir = opts.dir
 			}
-			keyVals := strings.Split(weightStr, "=")
-			if len(keyVals) != 2 {
-				return errors.New("tagSortWeight format error please check 'sw' arg")
+			if opts.fragment {
+				filler.defaults, err = loadFragmentDefaults(fileSet, file, dir, opts.DefaultSource, opts.snippet, !opts.recheck)
+			} else {
+				filler.defaults, err = loadFieldDefaults(dir, opts.DefaultSource)
 			}
-			key := strings.TrimSpace(keyVals[0])
-			val, err := strconv.Atoi(strings.TrimSpace(keyVals[1]))
 			if err != nil {
-				return errors.New("tagSortWeight format error please check 'sw' arg: " + err.Error())
+				return nil, err
 			}
-			weights[key] = val
 		}
-		executor = append(executor, newTagSort(file, fileSet, strings.Split(*tagSortOrder, "|"), weights))
-	}
-	if *align {
-		executor = append(executor, newTagFmt(file, fileSet))
+		stages["fill"] = filler
 	}
-	for _, scan := range executor {
-		err := scan.Scan()
+
+	if opts.Sync != "" {
+		syncer, err := newTagSync(file, fileSet, filter, opts.Sync)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		stages["sync"] = syncer
 	}
-	for _, exe := range executor {
-		err := exe.Execute()
+
+	if opts.TimeHint != "" {
+		hinter, err := newTagTimeHint(file, fileSet, filter, opts.TimeHint)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		stages["time"] = hinter
 	}
 
-	var buf bytes.Buffer
-	cfg := printer.Config{Mode: printerMode, Tabwidth: tabWidth}
-
-	err = cfg.Fprint(&buf, fileSet, file)
-	if err != nil {
-		return err
+	if opts.Redact != "" {
+		redactor, err := newTagRedact(file, fileSet, filter, opts.Redact, opts.RedactKey)
+		if err != nil {
+			return nil, err
+		}
+		stages["redact"] = redactor
 	}
-	res := buf.Bytes()
 
-	if !bytes.Equal(src, res) {
-		// formatting has changed
-		if *list {
-			fmt.Fprintln(out, filename)
+	if opts.Sort {
+		weights, err := parseSortWeight(opts.SortWeight)
+		if err != nil {
+			return nil, err
 		}
-		if *write {
-			// make a temporary backup before overwriting original
-			bakname, err := backupFile(filename+".", src, perm)
-			if err != nil {
-				return err
-			}
-			err = ioutil.WriteFile(filename, res, perm)
-			if err != nil {
-				os.Rename(bakname, filename)
-				return err
-			}
-			err = os.Remove(bakname)
-			if err != nil {
-				return err
-			}
+		order, err := parseSortOrder(opts.SortOrder)
+		if err != nil {
+			return nil, err
 		}
-		if *doDiff {
-			data, err := diff(src, res, filename)
-			if err != nil {
-				return fmt.Errorf("computing diff: %s", err)
+		stages["sort"] = newTagSort(file, fileSet, filter, presetSortOrder(order, presets), weights)
+	}
+	if opts.Align {
+		var keys []string
+		for _, key := range strings.Split(opts.AlignKey, "|") {
+			if key = strings.TrimSpace(key); key != "" {
+				keys = append(keys, key)
 			}
-			fmt.Printf("diff -u %s %s\n", filepath.ToSlash(filename+".orig"), filepath.ToSlash(filename))
-			out.Write(data)
 		}
+		groups, err := parseAlignGroups(opts.AlignGroup)
+		if err != nil {
+			return nil, newFlagError("-align-group", opts.AlignGroup, err)
+		}
+		if err := parseOverflow(opts.Overflow); err != nil {
+			return nil, err
+		}
+		formatter := newTagFmt(file, fileSet, filter, keys, groups, lineLimit{max: opts.MaxLineLen, policy: opts.Overflow})
+		stages["align"] = formatter
 	}
 
-	if !*list && !*write && !*doDiff {
-		_, err = out.Write(res)
+	for _, exe := range registered() {
+		custom, err := exe.factory(file, fileSet, opts, filter)
+		if err != nil {
+			return nil, err
+		}
+		if custom != nil {
+			stages[exe.name] = custom
+		}
 	}
 
-	return err
+	var executor []Executor
+	for _, name := range order {
+		if stage, ok := stages[name]; ok {
+			executor = append(executor, stage)
+		}
+	}
+	return executor, nil
+}
+
+// writeFile overwrites filename with res, src is kept in a temporary backup
+// until the writing succeed
+func writeFile(filename string, src, res []byte, perm os.FileMode) error {
+	bakname, err := backupFile(filename+".", src, perm)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filename, res, perm)
+	if err != nil {
+		os.Rename(bakname, filename)
+		return err
+	}
+	return os.Remove(bakname)
 }
 
 func visitFile(path string, f os.FileInfo, err error) error {
+	if err == nil && isGoFile(f) && isSkippedTestFile(f.Name()) {
+		if skips != nil {
+			skips.Add(token.Position{Filename: path}, "file: the _test.go files are skipped by -w, use -tests to include them")
+		}
+		return nil
+	}
 	if err == nil && isGoFile(f) {
-		err = processFile(path, nil, os.Stdout, false)
+		scheduler.Process(path)
+		return nil
 	}
-	// Don't complain if a file was deleted in the meantime (i.e.
-	// the directory changed concurrently while running gofmt).
-	if err != nil && !os.IsNotExist(err) {
-		report(err)
+	if err != nil {
+		scheduler.Report(path, err)
 	}
 	return nil
 }
 
 func walkDir(path string) {
+	if *followSymlinks {
+		walkFollowSymlinks(path, visitFile)
+		return
+	}
 	filepath.Walk(path, visitFile)
 }
 
-func main() {
+// Main runs the tagfmt command with os.Args and exits with its exit code, it's the
+// main of cmd/tagfmt, the commands built with custom executors call it after
+// RegisterExecutor
+func Main() {
 	// call gofmtMain in a separate function
 	// so that it can use defer and have them
 	// run before the exit.
@@ -285,15 +871,59 @@ func main() {
 }
 
 func gofmtMain() {
-	flag.Usage = usage
+	commandLine.Usage = usage
+
+	if err := commandLine.Parse(os.Args[1:]); err != nil {
+		// the error and usage are printed by Parse
+		if err != flag.ErrHelp {
+			exitWith(exitUsage)
+		}
+		return
+	}
 
-	flag.Parse()
+	if err := parseLogFormat(*logFormat); err != nil {
+		report(err)
+		return
+	}
+	// the flag errors are logged in the format before the color is known
+	logger = newLogger(stderrWriter{}, *logFormat, false)
+	if err := parseColorMode(*colorMode); err != nil {
+		report(err)
+		return
+	}
+	if _, err := padPrinterMode(*pad); err != nil {
+		report(err)
+		return
+	}
+	if err := parseOverflow(*overflow); err != nil {
+		report(err)
+		return
+	}
+	if err := parseRemnants(*remnants); err != nil {
+		report(err)
+		return
+	}
+	if _, err := parseDefaultSources(*defaultSource); err != nil {
+		report(err)
+		return
+	}
+	logger = newLogger(stderrWriter{}, *logFormat, colorEnabled(os.Stderr))
+	if *postGenerate {
+		if err := applyPostGenerate(commandLine.Args()); err != nil {
+			logger.Error(err.Error(), "kind", "error:")
+			exitWith(errorClass(err))
+			return
+		}
+	}
+	if *twoPhase {
+		*write = true
+	}
 
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "creating cpu profile: %s\n", err)
-			exitCode = 2
+			logger.Error("creating cpu profile: " + err.Error())
+			exitWith(exitIO)
 			return
 		}
 		defer f.Close()
@@ -303,103 +933,214 @@ func gofmtMain() {
 
 	initParserMode()
 
-	if flag.NArg() == 0 {
-		if *write {
-			fmt.Fprintln(os.Stderr, "error: cannot use -w with standard input")
-			exitCode = 2
+	if *configFile != "" {
+		c, err := loadConfigCached(*configFile)
+		if err != nil {
+			logger.Error("loading config: " + err.Error())
+			exitWith(errorClass(err))
 			return
 		}
-		if err := processFile("<standard input>", os.Stdin, os.Stdout, true); err != nil {
+		config = c
+	}
+
+	if *daemon {
+		if err := runDaemon(*socket); err != nil {
 			report(err)
 		}
 		return
 	}
 
-	for i := 0; i < flag.NArg(); i++ {
-		path := flag.Arg(i)
-		switch dir, err := os.Stat(path); {
-		case err != nil:
+	if *persistentWorker {
+		if *workerProtocol != "proto" && *workerProtocol != "json" {
+			report(fmt.Errorf("unknown worker protocol %q", *workerProtocol))
+			return
+		}
+		if err := runWorker(os.Stdin, os.Stdout, *workerProtocol == "json"); err != nil {
 			report(err)
-		case dir.IsDir():
-			walkDir(path)
-		default:
-			if err := processFile(path, nil, os.Stdout, false); err != nil {
-				report(err)
-			}
 		}
+		return
 	}
-}
 
-func writeTempFile(dir, prefix string, data []byte) (string, error) {
-	file, err := ioutil.TempFile(dir, prefix)
-	if err != nil {
-		return "", err
-	}
-	_, err = file.Write(data)
-	if err1 := file.Close(); err == nil {
-		err = err1
+	if *daemonClientMode && *structName != "" {
+		positions, err := daemonLookupClient(*socket, *structName, commandLine.Args())
+		if err != nil {
+			report(err)
+			return
+		}
+		for _, pos := range positions {
+			fmt.Fprintln(os.Stdout, pos)
+		}
+		return
 	}
-	if err != nil {
-		os.Remove(file.Name())
-		return "", err
+
+	if *daemonClientMode {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err == nil {
+			var filename string
+			if *srcdir != "" {
+				filename, err = filepath.Abs(srcdirFile(*srcdir))
+			}
+			if err == nil {
+				src, err = daemonClient(*socket, filename, src)
+			}
+		}
+		if err != nil {
+			report(err)
+			return
+		}
+		os.Stdout.Write(src)
+		return
 	}
-	return file.Name(), nil
-}
 
-func diff(b1, b2 []byte, filename string) (data []byte, err error) {
-	f1, err := writeTempFile("", "gofmt", b1)
-	if err != nil {
+	if cmd, ok := subcommands[commandLine.Arg(0)]; ok {
+		exitCode = raiseExit(exitCode, cmd(commandLine.Args()[1:]))
 		return
 	}
-	defer os.Remove(f1)
 
-	f2, err := writeTempFile("", "gofmt", b2)
-	if err != nil {
+	if *structName != "" {
+		if commandLine.NArg() == 0 {
+			logger.Error("-struct needs the package paths", "kind", "error:")
+			exitWith(exitUsage)
+			return
+		}
+		formatStruct(*structName, commandLine.Args())
 		return
 	}
-	defer os.Remove(f2)
 
-	cmd := "diff"
-	if runtime.GOOS == "plan9" {
-		cmd = "/bin/ape/diff"
+	if *offset >= 0 {
+		if *write || *list || *doDiff || *dryRun {
+			logger.Error("-offset can't be used with -w -l -d -n", "kind", "error:")
+			exitWith(exitUsage)
+			return
+		}
+		formatOffset(*offset, commandLine.Args())
+		return
 	}
 
-	data, err = exec.Command(cmd, "-u", f1, f2).CombinedOutput()
-	if len(data) > 0 {
-		// diff exits with a non-zero status when the files don't match.
-		// Ignore that failure as long as we get output.
-		return replaceTempFilename(data, filename)
+	if commandLine.NArg() == 0 {
+		if *write {
+			logger.Error("cannot use -w with standard input", "kind", "error:")
+			exitWith(exitUsage)
+			return
+		}
+		filename := "<standard input>"
+		// the complete file name make errors point to the editor's file
+		if strings.HasSuffix(*srcdir, ".go") {
+			filename = *srcdir
+		}
+		if *verbose {
+			skips = &skipReport{}
+			defer func() { skips.Log(logger); skips = nil }()
+		}
+		if *requireMatch {
+			matches = &matchCount{}
+			defer func() { matches = nil }()
+		}
+		if err := processFile(filename, os.Stdin, os.Stdout, true); err != nil {
+			report(err)
+		} else if matches != nil {
+			if err := matches.check(optionsFromFlags()); err != nil {
+				report(err)
+			}
+		}
+		return
 	}
-	return
+
+	processPaths(commandLine.Args(), nil)
 }
 
-// replaceTempFilename replaces temporary filenames in diff with actual one.
-//
-// --- /tmp/gofmt316145376	2017-02-03 19:13:00.280468375 -0500
-// +++ /tmp/gofmt617882815	2017-02-03 19:13:00.280468375 -0500
-// ...
-// ->
-// --- path/to/file.go.orig	2017-02-03 19:13:00.280468375 -0500
-// +++ path/to/file.go	2017-02-03 19:13:00.280468375 -0500
-// ...
-func replaceTempFilename(diff []byte, filename string) ([]byte, error) {
-	bs := bytes.SplitN(diff, []byte{'\n'}, 3)
-	if len(bs) < 3 {
-		return nil, fmt.Errorf("got unexpected diff for %s", filename)
+// processPaths formats the files and directories in paths, verify checks the result
+// of all files if it's not nil, its error discards the atomic writes
+func processPaths(paths []string, verify func() error) {
+	atomicWrites = nil
+	if (*atomicRunFlag || *twoPhase) && *write {
+		atomicWrites = &atomicRun{}
+	}
+	progress = nil
+	if *twoPhase && *write {
+		progress = newProgressBar("checking", 0)
+	}
+	undoJournal = nil
+	if *journalFlag && *write {
+		j, err := newJournal(journalRoot, time.Now())
+		if err != nil {
+			report(err)
+			return
+		}
+		undoJournal = j
+	}
+	skips = nil
+	if *verbose {
+		skips = &skipReport{}
+	}
+	matches = nil
+	if *requireMatch {
+		matches = &matchCount{}
+	}
+	exits.Reset()
+	scheduler = newFileScheduler(*parallel, os.Stdout)
+	for _, path := range paths {
+		// go package pattern e.g ./... is the same as walk the directory
+		if strings.HasSuffix(path, "...") {
+			path = filepath.Clean(strings.TrimSuffix(path, "..."))
+		}
+		switch dir, err := os.Stat(path); {
+		case err != nil:
+			scheduler.Report(path, err)
+		case dir.IsDir():
+			walkDir(path)
+		default:
+			scheduler.Process(path)
+		}
+	}
+	scheduler.Wait()
+	if progress != nil {
+		progress.Finish()
+	}
+	if matches != nil {
+		if err := matches.check(optionsFromFlags()); err != nil {
+			report(err)
+		}
+		matches = nil
+	}
+	if verify != nil {
+		if err := verify(); err != nil {
+			report(err)
+		}
+	}
+
+	if atomicWrites != nil {
+		if exitCode != 0 && *atomicRunFlag {
+			atomicWrites.Discard(logger)
+			if undoJournal != nil {
+				undoJournal.Discard()
+			}
+		} else if progress != nil && !confirmWrites(atomicWrites, progress) {
+			if undoJournal != nil {
+				undoJournal.Discard()
+			}
+		} else if !*atomicRunFlag {
+			atomicWrites.Write()
+		} else if err := atomicWrites.Commit(); err != nil {
+			report(err)
+			logger.Error("atomic-run: the written files are restored")
+			if undoJournal != nil {
+				undoJournal.Discard()
+			}
+		}
+		progress = nil
 	}
-	// Preserve timestamps.
-	var t0, t1 []byte
-	if i := bytes.LastIndexByte(bs[0], '\t'); i != -1 {
-		t0 = bs[0][i:]
+	if undoJournal != nil {
+		if err := undoJournal.Close(); err != nil {
+			report(err)
+		}
+		undoJournal = nil
 	}
-	if i := bytes.LastIndexByte(bs[1], '\t'); i != -1 {
-		t1 = bs[1][i:]
+	if skips != nil {
+		skips.Log(logger)
+		skips = nil
 	}
-	// Always print filepath with slash separator.
-	f := filepath.ToSlash(filename)
-	bs[0] = []byte(fmt.Sprintf("--- %s%s", f+".orig", t0))
-	bs[1] = []byte(fmt.Sprintf("+++ %s%s", f, t1))
-	return bytes.Join(bs, []byte{'\n'}), nil
+	exits.Log(logger)
 }
 
 const chmodSupported = runtime.GOOS != "windows"
@@ -433,48 +1174,9 @@ func backupFile(filename string, data []byte, perm os.FileMode) (string, error)
 }
 
 // change field's tag will cause the token.Pos wrong
-// so I make all token.Pos step in Scan and field's tag change in Execute
+// so I make all token.Pos step in Scan and field's tag change in Execute,
+// the custom executors are added by RegisterExecutor
 type Executor interface {
 	Scan() error
 	Execute() error
 }
-
-var fieldFilter func(s string) bool
-
-func selectInit(expr string, inverse bool) error {
-	var err error
-	selRule, err := regexp.Compile(expr)
-	if err != nil {
-		return err
-	}
-	if inverse {
-		fieldFilter = func(s string) bool {
-			return !selRule.MatchString(s)
-		}
-	} else {
-		fieldFilter = func(s string) bool {
-			return selRule.MatchString(s)
-		}
-	}
-	return nil
-}
-
-var structFieldSelect func(s string) bool
-
-func structSelectInit(expr string, inverse bool) error {
-	var err error
-	selRule, err := regexp.Compile(expr)
-	if err != nil {
-		return err
-	}
-	if inverse {
-		structFieldSelect = func(s string) bool {
-			return !selRule.MatchString(s)
-		}
-	} else {
-		structFieldSelect = func(s string) bool {
-			return selRule.MatchString(s)
-		}
-	}
-	return nil
-}
@@ -4,16 +4,14 @@
 
 // This file was copied from the src/cmd/gofmt/gofmt_test.go
 
-package main
+package tagfmt
 
 import (
 	"bytes"
 	"flag"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strconv"
 	"strings"
 	"testing"
@@ -115,6 +113,10 @@ func runTest(t *testing.T, in, out string) {
 			stdin = true
 		case "-s":
 			*tagSort = true
+		case "-a=false":
+			*align = false
+		case "-split-multi":
+			*splitMulti = true
 		case "-f":
 			nextVal = func(s string) {
 				var err error
@@ -123,6 +125,134 @@ func runTest(t *testing.T, in, out string) {
 					panic("err: " + err.Error() + " str: " + s)
 				}
 			}
+		case "-align-key":
+			nextVal = func(s string) {
+				var err error
+				*alignKey, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-max-line-len":
+			nextVal = func(s string) {
+				var err error
+				*maxLineLen, err = strconv.Atoi(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-max-tag-len":
+			nextVal = func(s string) {
+				var err error
+				*maxTagLen, err = strconv.Atoi(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-position-keys":
+			nextVal = func(s string) {
+				var err error
+				*positionKeys, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-remnants":
+			nextVal = func(s string) {
+				var err error
+				*remnants, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-overflow":
+			nextVal = func(s string) {
+				var err error
+				*overflow, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-align-group":
+			nextVal = func(s string) {
+				var err error
+				*alignGroups, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-strict-fill":
+			*strictFill = true
+		case "-keep-options":
+			*keepOptions = true
+		case "-strict-comments":
+			*strictComments = true
+		case "-strict-keys":
+			*strictKeys = true
+		case "-known-keys":
+			nextVal = func(s string) {
+				var err error
+				*knownKeys, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-sync":
+			nextVal = func(s string) {
+				var err error
+				*syncKeys, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-time-hint":
+			nextVal = func(s string) {
+				var err error
+				*timeHints, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-redact":
+			nextVal = func(s string) {
+				var err error
+				*redact, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-redact-key":
+			nextVal = func(s string) {
+				var err error
+				*redactKey, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-pipeline":
+			nextVal = func(s string) {
+				var err error
+				*pipeline, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-preset":
+			nextVal = func(s string) {
+				var err error
+				*preset, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-r":
+			nextVal = func(s string) {
+				var err error
+				*rewrite, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
 		case "-p":
 			nextVal = func(s string) {
 				var err error
@@ -155,6 +285,30 @@ func runTest(t *testing.T, in, out string) {
 					panic(err)
 				}
 			}
+		case "-pg":
+			nextVal = func(s string) {
+				var err error
+				*fieldGlob, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-spg":
+			nextVal = func(s string) {
+				var err error
+				*structGlob, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-pad":
+			nextVal = func(s string) {
+				*pad = s
+			}
+		case "-pi":
+			*patternIgnoreCase = true
+		case "-exact":
+			*exactPattern = true
 		case "-so":
 			nextVal = func(s string) {
 				var err error
@@ -255,10 +409,25 @@ func TestRewrite(t *testing.T) {
 	}
 }
 
-func TestDiff(t *testing.T) {
-	if _, err := exec.LookPath("diff"); err != nil {
-		t.Skipf("skip test on %s: diff command is required", runtime.GOOS)
+// TestReproducible formats the testdata several times and compares with the same
+// golden files, the map iteration order is random in every run, so the output
+// depending on it is caught, the case mappings are covered by TestASCIICaseConvert
+func TestReproducible(t *testing.T) {
+	if *update {
+		t.Skip("the golden files are updated by TestRewrite")
 	}
+	match, err := filepath.Glob("testdata/*.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		for _, in := range match {
+			runTest(t, in, in[:len(in)-len(".input")]+".golden")
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
 	in := []byte("first\nsecond\n")
 	out := []byte("first\nthird\n")
 	filename := "difftest.txt"
@@ -267,10 +436,6 @@ func TestDiff(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if runtime.GOOS == "windows" {
-		b = bytes.ReplaceAll(b, []byte{'\r', '\n'}, []byte{'\n'})
-	}
-
 	bs := bytes.SplitN(b, []byte{'\n'}, 3)
 	line0, line1 := bs[0], bs[1]
 
@@ -293,30 +458,100 @@ func TestDiff(t *testing.T) {
 	}
 }
 
-func TestReplaceTempFilename(t *testing.T) {
-	diff := []byte(`--- /tmp/tmpfile1	2017-02-08 00:53:26.175105619 +0900
-+++ /tmp/tmpfile2	2017-02-08 00:53:38.415151275 +0900
-@@ -1,2 +1,2 @@
- first
--second
-+third
-`)
-	want := []byte(`--- path/to/file.go.orig	2017-02-08 00:53:26.175105619 +0900
-+++ path/to/file.go	2017-02-08 00:53:38.415151275 +0900
-@@ -1,2 +1,2 @@
- first
--second
-+third
-`)
-	// Check path in diff output is always slash regardless of the
-	// os.PathSeparator (`/` or `\`).
-	sep := string(os.PathSeparator)
-	filename := strings.Join([]string{"path", "to", "file.go"}, sep)
-	got, err := replaceTempFilename(diff, filename)
+func TestWalkTests(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !bytes.Equal(got, want) {
-		t.Errorf("os.PathSeparator='%s': replacedDiff:\ngot:\n%s\nwant:\n%s", sep, got, want)
+	defer os.RemoveAll(dir)
+	src := "package user\n\ntype User struct {\n\tName string `json:\"name\" yaml:\"name\"`\n\tAge int `json:\"age\" yaml:\"age\"`\n}\n"
+	for _, name := range []string{"user.go", "user_test.go"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changed := func(name string) bool {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data) != src
+	}
+
+	// the _test.go files are skipped by -w
+	*write = true
+	processPaths([]string{dir}, nil)
+	if !changed("user.go") || changed("user_test.go") {
+		t.Errorf("-w must skip the _test.go files")
+	}
+	// the file in arguments is always processed
+	processPaths([]string{filepath.Join(dir, "user_test.go")}, nil)
+	if !changed("user_test.go") {
+		t.Errorf("the _test.go file in arguments must be processed")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "user_test.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := commandLine.Set("tests", "true"); err != nil {
+		t.Fatal(err)
+	}
+	processPaths([]string{dir}, nil)
+	if !changed("user_test.go") {
+		t.Errorf("-tests must process the _test.go files")
+	}
+	if exitCode != 0 {
+		t.Errorf("exit code %d", exitCode)
+	}
+}
+
+func TestExecutorErrors(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	src := "package main\n\ntype Login struct {\n\tUser string `json:\"user\" binding:\"required\" validate:\"required\"`\n\tCode string `json:\"code\" binding:\"required\" validate:\"omitempty\"`\n}\n"
+	opts := optionsFromFlags()
+	opts.Sync = "binding=validate"
+	opts.MaxLineLen, opts.Overflow = 60, "report"
+	var out bytes.Buffer
+	err := formatSource(&out, "login.go", []byte(src), opts)
+	// the errors of sync and align are reported together
+	want := "login.go:5:14: binding:\"required\" contradicts validate:\"omitempty\"\n" +
+		"login.go:4:14: line length 68 exceeds -max-line-len 60\n" +
+		"login.go:5:14: line length 69 exceeds -max-line-len 60"
+	if err == nil || err.Error() != want {
+		t.Errorf("got error:\n%v\nwant:\n%s", err, want)
+	}
+
+	// the invalid tag is reported by the doctor and the fill once
+	src = "package main\n\ntype Login struct {\n\tUser string `json:\"user`\n}\n"
+	opts = optionsFromFlags()
+	opts.Fill = "json=snake(:field)"
+	err = formatSource(&out, "login.go", []byte(src), opts)
+	if _, ok := err.(tagDockerErr); ok {
+		t.Errorf("got the duplicate errors:\n%v", err)
+	}
+}
+
+func TestRepeatedFill(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	for _, rule := range []string{"json=snake(:field)", "", "yaml=lower_camel(:field)|toml=:field"} {
+		if err := commandLine.Set("f", rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expected := "json=snake(:field)|yaml=lower_camel(:field)|toml=:field"; *fill != expected {
+		t.Errorf("-f %q, expected %q", *fill, expected)
+	}
+	resetFlags()
+	if err := commandLine.Set("f", "json=:field"); err != nil {
+		t.Fatal(err)
+	}
+	if *fill != "json=:field" {
+		t.Errorf("-f %q is not reset", *fill)
 	}
 }
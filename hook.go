@@ -0,0 +1,213 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// hookModeFlags are the flags decided by the hook itself or the modes of other
+// commands, they are not copied to the hook
+var hookModeFlags = map[string]bool{
+	"l":                  true,
+	"w":                  true,
+	"d":                  true,
+	"n":                  true,
+	"j":                  true,
+	"patch":              true,
+	"journal":            true,
+	"atomic-run":         true,
+	"dry-run-then-write": true,
+	"yes":                true,
+	"post-generate":      true,
+	"daemon":             true,
+	"client":             true,
+	"socket":             true,
+	"persistent_worker":  true,
+	"worker_protocol":    true,
+	"srcdir":             true,
+	"offset":             true,
+	"struct":             true,
+	"struct-index":       true,
+	"cpuprofile":         true,
+}
+
+// installHookMain writes the git pre-commit hook, the formatting flags given
+// before install-hook are written into the hook
+//
+//	tagfmt -s -so "json|yaml" install-hook [-fix] [-force]
+func installHookMain(args []string) int {
+	fs := flag.NewFlagSet("install-hook", flag.ContinueOnError)
+	fix := fs.Bool("fix", false, "format the staged files and add them again instead of rejecting the commit")
+	force := fs.Bool("force", false, "overwrite the existing pre-commit hook")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	hooksDir, err := gitOutput("", "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		commandError("install-hook", err)
+		return errorClass(err)
+	}
+	hookName := filepath.Join(hooksDir, "pre-commit")
+	if _, err := os.Stat(hookName); err == nil && !*force {
+		commandError("install-hook", fmt.Errorf("%s already exists, use -force to overwrite it", hookName))
+		return exitUsage
+	}
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		commandError("install-hook", err)
+		return errorClass(err)
+	}
+	var flags []string
+	commandLine.Visit(func(f *flag.Flag) {
+		if hookModeFlags[f.Name] {
+			return
+		}
+		value := f.Value.String()
+		// git runs the hook in the top directory of the work tree
+		if f.Name == "config" && value != "" {
+			if value, err = filepath.Abs(value); err != nil {
+				return
+			}
+		}
+		flags = append(flags, "-"+f.Name+"="+value)
+	})
+	if err != nil {
+		commandError("install-hook", err)
+		return errorClass(err)
+	}
+	if err := ioutil.WriteFile(hookName, []byte(preCommitScript(flags, *fix)), 0755); err != nil {
+		commandError("install-hook", err)
+		return errorClass(err)
+	}
+	logger.Info("installed "+hookName, "command", "install-hook")
+	return exitOK
+}
+
+func preCommitScript(flags []string, fix bool) string {
+	args := []string{"tagfmt"}
+	for _, f := range flags {
+		args = append(args, shellQuote(f))
+	}
+	args = append(args, "pre-commit")
+	if fix {
+		args = append(args, "-fix")
+	}
+	return "#!/bin/sh\n" +
+		"# tagfmt pre-commit hook, installed by \"tagfmt install-hook\"\n" +
+		"exec " + strings.Join(args, " ") + "\n"
+}
+
+// shellQuote quotes s for sh
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// preCommitMain checks the staged go files, the staged content is read by
+// `git show :path` so the unstaged changes don't affect the result
+//
+// with -fix the files need formatting are written and added again, but the file
+// has unstaged changes is only reported, because writing it will mix the changes
+func preCommitMain(args []string) int {
+	fs := flag.NewFlagSet("pre-commit", flag.ContinueOnError)
+	fix := fs.Bool("fix", false, "format the staged files and add them again")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	root, err := gitOutput("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		commandError("pre-commit", err)
+		return errorClass(err)
+	}
+	names, err := gitOutput(root, "diff", "--cached", "--name-only", "--diff-filter=ACMR", "-z", "--", "*.go")
+	if err != nil {
+		commandError("pre-commit", err)
+		return errorClass(err)
+	}
+	code := exitOK
+	for _, name := range strings.Split(names, "\x00") {
+		if name == "" {
+			continue
+		}
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		staged, err := gitOutputBytes(root, "show", ":"+name)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+			report(err)
+			code = raiseExit(code, errorClass(err))
+			continue
+		}
+		opts, err := config.Options(optionsFromFlags(), filename)
+		if err != nil {
+			report(err)
+			return errorClass(err)
+		}
+		// the staged content is checked like a file of the run e.g for the conflict markers
+		var res bytes.Buffer
+		if err := formatInput(&res, filename, staged, opts, false); err != nil {
+			report(err)
+			code = raiseExit(code, errorClass(err))
+			continue
+		}
+		if bytes.Equal(staged, res.Bytes()) {
+			continue
+		}
+		if *fix {
+			worktree, err := ioutil.ReadFile(filename)
+			if err == nil && bytes.Equal(worktree, staged) {
+				fi, err := os.Stat(filename)
+				if err == nil {
+					err = ioutil.WriteFile(filename, res.Bytes(), fi.Mode().Perm())
+				}
+				if err == nil {
+					_, err = gitOutput(root, "add", "--", name)
+				}
+				if err != nil {
+					err = fmt.Errorf("%s: %w", name, err)
+					report(err)
+					code = raiseExit(code, errorClass(err))
+				}
+				continue
+			}
+			logger.Error(name+": has unstaged changes, can't be fixed", "command", "pre-commit")
+		} else {
+			logger.Error(name, "command", "pre-commit")
+		}
+		code = raiseExit(code, exitChanged)
+	}
+	if code == exitChanged {
+		logger.Error("tagfmt: the files above need formatting, run tagfmt -w on them and add again")
+	}
+	return code
+}
+
+func gitOutput(dir string, args ...string) (string, error) {
+	out, err := gitOutputBytes(dir, args...)
+	return strings.TrimRight(string(out), "\n"), err
+}
+
+func gitOutputBytes(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestPreCommitScript(t *testing.T) {
+	assert.Equal(t, "#!/bin/sh\n"+
+		"# tagfmt pre-commit hook, installed by \"tagfmt install-hook\"\n"+
+		"exec tagfmt '-s=true' '-f=json=or(:tag, '\\''x'\\'')' pre-commit -fix\n",
+		preCommitScript([]string{"-s=true", "-f=json=or(:tag, 'x')"}, true))
+}
+
+// gitRepo creates a git repository in a temporary directory and changes to it, it
+// returns the directory and the function restores the working directory
+func gitRepo(t *testing.T) (string, func()) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	dir, err = filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	_, err = gitOutput(dir, "init", "-q")
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	return dir, func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestInstallHook(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	dir, cleanup := gitRepo(t)
+	defer cleanup()
+
+	require.NoError(t, commandLine.Parse([]string{"-s", "-n", "-patch", "-struct", "User", "-config", "tagfmt.json", "install-hook"}))
+	assert.Equal(t, exitOK, installHookMain(commandLine.Args()[1:]))
+	script, err := ioutil.ReadFile(filepath.Join(dir, ".git", "hooks", "pre-commit"))
+	require.NoError(t, err)
+	assert.Contains(t, string(script), "'-s=true'")
+	// the relative config is resolved before git runs the hook in another directory
+	assert.Contains(t, string(script), "'-config="+filepath.Join(dir, "tagfmt.json")+"'")
+	for _, name := range []string{"-n=", "-patch=", "-struct="} {
+		assert.NotContains(t, string(script), name)
+	}
+}
+
+func TestPreCommit(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, cleanup := gitRepo(t)
+	defer cleanup()
+	filename := filepath.Join(dir, "a.go")
+	unformatted := []byte("package a\n\ntype A struct {\n\tName string `json:\"name\"`\n\tID int `json:\"id\"`\n}\n")
+	formatted := []byte("package a\n\ntype A struct {\n\tName string `json:\"name\"`\n\tID   int    `json:\"id\"`\n}\n")
+
+	// the staged content is checked instead of the formatted work tree
+	require.NoError(t, ioutil.WriteFile(filename, unformatted, 0644))
+	_, err := gitOutput(dir, "add", "a.go")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filename, formatted, 0644))
+	assert.Equal(t, exitChanged, preCommitMain(nil))
+	// the file with unstaged changes isn't fixed
+	assert.Equal(t, exitChanged, preCommitMain([]string{"-fix"}))
+	worktree, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, string(formatted), string(worktree))
+
+	// the formatted staged content passes even the work tree isn't formatted
+	_, err = gitOutput(dir, "add", "a.go")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filename, unformatted, 0644))
+	assert.Equal(t, exitOK, preCommitMain(nil))
+
+	// the file without unstaged changes is fixed and added again
+	_, err = gitOutput(dir, "add", "a.go")
+	require.NoError(t, err)
+	assert.Equal(t, exitOK, preCommitMain([]string{"-fix"}))
+	staged, err := gitOutputBytes(dir, "show", ":a.go")
+	require.NoError(t, err)
+	assert.Equal(t, string(formatted), string(staged))
+
+	// the staged conflict markers are reported like a normal run
+	conflicted := []byte("package a\n\n<<<<<<< HEAD\ntype A struct{}\n=======\ntype B struct{}\n>>>>>>> b\n")
+	require.NoError(t, ioutil.WriteFile(filename, conflicted, 0644))
+	_, err = gitOutput(dir, "add", "a.go")
+	require.NoError(t, err)
+	assert.Equal(t, exitSource, preCommitMain(nil))
+	exitCode = 0
+}
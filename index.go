@@ -0,0 +1,192 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// indexFile is the struct index persisted by -struct-index
+var indexFile = filepath.Join(".tagfmt", "index.json")
+
+// indexedStruct is a struct declaration in the index
+type indexedStruct struct {
+	Name   string `json:"name"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// indexedFile is the struct declarations of a file, the file is parsed again when its
+// modification time or size changed
+type indexedFile struct {
+	ModTime int64           `json:"mod_time"`
+	Size    int64           `json:"size"`
+	Structs []indexedStruct `json:"structs"`
+}
+
+// structIndex maps the files to their struct declarations, -struct and the daemon
+// lookups resolve the structs by it without parsing the unchanged files
+type structIndex struct {
+	mu    sync.Mutex
+	Files map[string]*indexedFile `json:"files"`
+	dirty bool
+}
+
+// structIndexes is the loaded indexes by their files, the daemon keeps them in memory
+var structIndexes = struct {
+	sync.Mutex
+	m map[string]*structIndex
+}{m: map[string]*structIndex{}}
+
+// loadStructIndex returns the index of filename, it's empty if the file doesn't exist,
+// the index of a corrupt file is rebuilt
+func loadStructIndex(filename string) *structIndex {
+	structIndexes.Lock()
+	defer structIndexes.Unlock()
+	if idx, ok := structIndexes.m[filename]; ok {
+		return idx
+	}
+	idx := &structIndex{}
+	if data, err := ioutil.ReadFile(filename); err == nil {
+		json.Unmarshal(data, idx)
+	}
+	if idx.Files == nil {
+		idx.Files = map[string]*indexedFile{}
+	}
+	structIndexes.m[filename] = idx
+	return idx
+}
+
+// Lookup returns the positions of struct name declared in files, the files changed
+// since they were indexed are parsed again, the files fail to parse are skipped, their
+// errors are returned only when the struct isn't found in the others
+func (x *structIndex) Lookup(name string, files []string) ([]token.Position, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var positions []token.Position
+	var errs tagDockerErr
+	for _, filename := range files {
+		entry, err := x.update(filename)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, st := range entry.Structs {
+			if st.Name == name {
+				positions = append(positions, token.Position{Filename: filename, Line: st.Line, Column: st.Column})
+			}
+		}
+	}
+	// the removed files of the looked up directories
+	dirs, listed := map[string]bool{}, map[string]bool{}
+	for _, filename := range files {
+		dirs[filepath.Dir(filename)], listed[filename] = true, true
+	}
+	for filename := range x.Files {
+		if dirs[filepath.Dir(filename)] && !listed[filename] {
+			delete(x.Files, filename)
+			x.dirty = true
+		}
+	}
+	if len(positions) == 0 && len(errs) != 0 {
+		return nil, errs
+	}
+	return positions, nil
+}
+
+// update returns the entry of filename, it's indexed again if it's changed, only the
+// type specs of struct literal are indexed, the aliases e.g type A = B and the named
+// types of other structs e.g type A B are not resolved
+func (x *structIndex) update(filename string) (*indexedFile, error) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := x.Files[filename]; ok && entry.ModTime == fi.ModTime().UnixNano() && entry.Size == fi.Size() {
+		return entry, nil
+	}
+	fs := token.NewFileSet()
+	f, err := parser.ParseFile(fs, filename, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	entry := &indexedFile{ModTime: fi.ModTime().UnixNano(), Size: fi.Size(), Structs: []indexedStruct{}}
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if _, ok := ts.Type.(*ast.StructType); ok {
+				pos := fs.Position(ts.Pos())
+				entry.Structs = append(entry.Structs, indexedStruct{Name: ts.Name.Name, Line: pos.Line, Column: pos.Column})
+			}
+		}
+	}
+	x.Files[filename] = entry
+	x.dirty = true
+	return entry, nil
+}
+
+// Save writes the changed index to filename, the file is replaced by renaming so the
+// concurrent readers never see a partial index
+func (x *structIndex) Save(filename string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if !x.dirty {
+		return nil
+	}
+	data, err := json.Marshal(x)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("saving struct index: %w", err)
+	}
+	x.dirty = false
+	return nil
+}
+
+// indexedStructFiles returns the files of positions in order without duplicates
+func indexedStructFiles(positions []token.Position) []string {
+	var files []string
+	seen := map[string]bool{}
+	for _, pos := range positions {
+		if !seen[pos.Filename] {
+			seen[pos.Filename] = true
+			files = append(files, pos.Filename)
+		}
+	}
+	return files
+}
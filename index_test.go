@@ -0,0 +1,105 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/token"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStructIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	require.NoError(t, ioutil.WriteFile(a, []byte("package a\n\ntype User struct {\n\tName string\n}\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(b, []byte("package a\n\ntype Group struct{}\n"), 0644))
+	indexName := filepath.Join(dir, ".tagfmt", "index.json")
+
+	idx := loadStructIndex(indexName)
+	defer delete(structIndexes.m, indexName)
+	positions, err := idx.Lookup("User", []string{a, b})
+	require.NoError(t, err)
+	assert.Equal(t, []token.Position{{Filename: a, Line: 3, Column: 6}}, positions)
+	require.NoError(t, idx.Save(indexName))
+
+	// the saved index is loaded without parsing the files
+	saved := &structIndex{}
+	data, err := ioutil.ReadFile(indexName)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, saved))
+	assert.Equal(t, []indexedStruct{{Name: "Group", Line: 3, Column: 6}}, saved.Files[b].Structs)
+
+	// the changed file is indexed again
+	require.NoError(t, ioutil.WriteFile(b, []byte("package a\n\ntype Group struct{}\n\ntype User struct{}\n"), 0644))
+	require.NoError(t, os.Chtimes(b, time.Now(), time.Now().Add(time.Second)))
+	positions, err = idx.Lookup("User", []string{a, b})
+	require.NoError(t, err)
+	assert.Equal(t, []string{a, b}, indexedStructFiles(positions))
+
+	// the removed file is pruned
+	require.NoError(t, os.Remove(b))
+	positions, err = idx.Lookup("User", []string{a})
+	require.NoError(t, err)
+	assert.Equal(t, []string{a}, indexedStructFiles(positions))
+	assert.NotContains(t, idx.Files, b)
+
+	// the file fails to parse doesn't hide the struct of the others
+	require.NoError(t, ioutil.WriteFile(b, []byte("package a\n\ntype Broken struct {\n"), 0644))
+	positions, err = idx.Lookup("User", []string{a, b})
+	require.NoError(t, err)
+	assert.Equal(t, []string{a}, indexedStructFiles(positions))
+	_, err = idx.Lookup("Broken", []string{a, b})
+	assert.Error(t, err)
+	files, err := scanStructFiles("User", []string{a, b})
+	require.NoError(t, err)
+	assert.Equal(t, []string{a}, files)
+}
+
+func TestDaemonStructLookup(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	a := filepath.Join(dir, "a.go")
+	require.NoError(t, ioutil.WriteFile(a, []byte("package a\n\ntype User struct {\n\tName string\n}\n"), 0644))
+	socket := filepath.Join(dir, "tagfmt.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix socket is not supported: %s", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- serveDaemon(l, Options{Pattern: ".*", StructPattern: ".*"})
+	}()
+	defer delete(structIndexes.m, indexFile)
+
+	// the relative path is resolved in the directory of the client
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	rel, err := filepath.Rel(wd, dir)
+	require.NoError(t, err)
+	positions, err := daemonLookupClient(socket, "User", []string{rel})
+	require.NoError(t, err)
+	assert.Equal(t, []string{a + ":3:6"}, positions)
+	_, err = daemonLookupClient(socket, "Group", []string{dir})
+	assert.EqualError(t, err, "struct Group is not found in "+dir)
+
+	l.Close()
+	require.NoError(t, <-done)
+}
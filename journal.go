@@ -0,0 +1,293 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// journalRoot is the directory of the undo journals, every -w -journal run has a
+// journal directory named by its start time
+var journalRoot = filepath.Join(".tagfmt", "undo")
+
+// journalFile is the index of journal directory, a json line per written file, the
+// original contents and the diffs are stored beside it
+const journalFile = "journal.jsonl"
+
+// journalEntry is a written file of the run
+type journalEntry struct {
+	Path    string      `json:"path"`
+	Perm    os.FileMode `json:"perm"`
+	OldHash string      `json:"old_hash"`
+	NewHash string      `json:"new_hash"`
+	// Orig is the file name of the original content in the journal directory
+	Orig string `json:"orig"`
+	// Diff is the file name of the diff in the journal directory
+	Diff string `json:"diff,omitempty"`
+}
+
+// journal records the written files of a run, undo reverts them
+type journal struct {
+	dir string
+
+	mu sync.Mutex
+	// index is the journal index opened by the first record
+	index *os.File
+	count int
+}
+
+// undoJournal is not nil when -journal and -w are set
+var undoJournal *journal
+
+// newJournal creates the journal directory named by now in root
+func newJournal(root string, now time.Time) (*journal, error) {
+	dir := filepath.Join(root, now.UTC().Format("20060102T150405.000000000"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &journal{dir: dir}, nil
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Record stores the original content and the diff of filename before it's written in
+// their own files and appends the entry to the journal index, the files written before
+// a crash can be undone, the diff is only for reading, undo restores the original content
+func (j *journal) Record(filename string, src, res []byte, perm os.FileMode) error {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	n := strconv.Itoa(j.count)
+	j.count++
+	j.mu.Unlock()
+
+	entry := journalEntry{
+		Path:    path,
+		Perm:    perm,
+		OldHash: contentHash(src),
+		NewHash: contentHash(res),
+		Orig:    n + ".orig",
+	}
+	if err := ioutil.WriteFile(filepath.Join(j.dir, entry.Orig), src, 0644); err != nil {
+		return fmt.Errorf("journal: %s", err)
+	}
+	if data, err := diff(src, res, filename); err == nil {
+		entry.Diff = n + ".diff"
+		if err := ioutil.WriteFile(filepath.Join(j.dir, entry.Diff), data, 0644); err != nil {
+			return fmt.Errorf("journal: %s", err)
+		}
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.index == nil {
+		j.index, err = os.OpenFile(filepath.Join(j.dir, journalFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("journal: %s", err)
+		}
+	}
+	if _, err := j.index.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("journal: %s", err)
+	}
+	return nil
+}
+
+// Close closes the journal index and removes the journal of a run without written files
+func (j *journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.index == nil {
+		return j.remove()
+	}
+	err := j.index.Close()
+	j.index = nil
+	if err != nil {
+		return fmt.Errorf("journal: %s", err)
+	}
+	return nil
+}
+
+// Discard removes the journal, no file of the run is written
+func (j *journal) Discard() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.index != nil {
+		j.index.Close()
+		j.index = nil
+	}
+	return j.remove()
+}
+
+// readJournal returns the entries of the journal index in dir sorted by path, the
+// partial last line of a crashed run is skipped, its file isn't written yet
+func readJournal(dir string) ([]journalEntry, error) {
+	f, err := os.Open(filepath.Join(dir, journalFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []journalEntry
+	r := bufio.NewReader(f)
+	for {
+		line, _ := r.ReadBytes('\n')
+		if len(line) == 0 || line[len(line)-1] != '\n' {
+			break
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("%s: %w", dir, err)
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(a, b int) bool {
+		return entries[a].Path < entries[b].Path
+	})
+	return entries, nil
+}
+
+func (j *journal) remove() error {
+	if err := os.RemoveAll(j.dir); err != nil {
+		return err
+	}
+	// the empty parents are removed, the error of non-empty directory is ignored
+	parent := filepath.Dir(j.dir)
+	if os.Remove(parent) == nil {
+		os.Remove(filepath.Dir(parent))
+	}
+	return nil
+}
+
+// lastJournal returns the latest journal directory in root, the names of directories
+// are sorted by time, the directories without index e.g of a running or crashed run
+// before its first write are skipped
+func lastJournal(root string) (string, error) {
+	infos, err := ioutil.ReadDir(root)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	for i := len(infos) - 1; i >= 0; i-- {
+		if !infos[i].IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(root, infos[i].Name(), journalFile)); err == nil {
+			return filepath.Join(root, infos[i].Name()), nil
+		}
+	}
+	return "", fmt.Errorf("no journal in %s", root)
+}
+
+// undoJournalDir restores the original contents of the files in the journal dir,
+// the files changed after the run are conflicts and not restored unless force
+func undoJournalDir(dir string, force bool) error {
+	entries, err := readJournal(dir)
+	if err != nil {
+		return err
+	}
+	var errs tagDockerErr
+	for _, entry := range entries {
+		src, err := ioutil.ReadFile(entry.Path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		switch contentHash(src) {
+		case entry.OldHash:
+			// not written or already reverted
+			continue
+		case entry.NewHash:
+		default:
+			if !force {
+				errs = append(errs, fmt.Errorf("%s: changed after the run, use -force to revert it", entry.Path))
+				continue
+			}
+		}
+		orig, err := ioutil.ReadFile(filepath.Join(dir, entry.Orig))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if contentHash(orig) != entry.OldHash {
+			errs = append(errs, fmt.Errorf("%s: the original content of %s is corrupted", dir, entry.Path))
+			continue
+		}
+		if err := writeFile(entry.Path, src, orig, entry.Perm); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// undoMain reverts the files written by the last -w -journal run, the journal is
+// removed after all files are reverted, so the next undo reverts the run before it
+//
+//	tagfmt undo [-force] [-list]
+func undoMain(args []string) int {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+	force := fs.Bool("force", false, "also revert the files changed after the run")
+	list := fs.Bool("list", false, "print the diffs of the last run instead of reverting them")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	dir, err := lastJournal(journalRoot)
+	if err != nil {
+		commandError("undo", err)
+		return errorClass(err)
+	}
+	if *list {
+		entries, err := readJournal(dir)
+		if err != nil {
+			commandError("undo", err)
+			return errorClass(err)
+		}
+		for _, entry := range entries {
+			if entry.Diff == "" {
+				continue
+			}
+			data, err := ioutil.ReadFile(filepath.Join(dir, entry.Diff))
+			if err != nil {
+				commandError("undo", err)
+				return errorClass(err)
+			}
+			os.Stdout.Write(data)
+		}
+		return exitOK
+	}
+	if err := undoJournalDir(dir, *force); err != nil {
+		commandError("undo", err)
+		return errorClass(err)
+	}
+	j := &journal{dir: dir}
+	if err := j.remove(); err != nil {
+		commandError("undo", err)
+		return errorClass(err)
+	}
+	return exitOK
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalUndo(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(root string) { journalRoot = root }(journalRoot)
+	journalRoot = filepath.Join(dir, ".tagfmt", "undo")
+
+	src := filepath.Join(dir, "user.go")
+	orig := "package user\n\ntype User struct {\n\tName string `json:\"name\" xml:\"name\"`\n\tAge int `json:\"age\" xml:\"age\"`\n}\n"
+	formatted := "package user\n\ntype User struct {\n\tName string `json:\"name\" xml:\"name\"`\n\tAge  int    `json:\"age\"  xml:\"age\"`\n}\n"
+	require.NoError(t, ioutil.WriteFile(src, []byte(orig), 0644))
+
+	*write = true
+	*journalFlag = true
+	processPaths([]string{dir}, nil)
+	require.Equal(t, 0, exitCode)
+	res, err := ioutil.ReadFile(src)
+	require.NoError(t, err)
+	assert.Equal(t, formatted, string(res))
+
+	// the run without changes doesn't leave a journal
+	processPaths([]string{dir}, nil)
+	last, err := lastJournal(journalRoot)
+	require.NoError(t, err)
+	data, err := ioutil.ReadFile(filepath.Join(last, journalFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), contentHash([]byte(orig)))
+	assert.Contains(t, string(data), contentHash([]byte(formatted)))
+
+	// the file changed after the run is a conflict
+	require.NoError(t, ioutil.WriteFile(src, []byte(formatted+"\n// edited\n"), 0644))
+	assert.Equal(t, exitUsage, undoMain(nil))
+	require.NoError(t, ioutil.WriteFile(src, []byte(formatted), 0644))
+
+	assert.Equal(t, exitOK, undoMain(nil))
+	res, err = ioutil.ReadFile(src)
+	require.NoError(t, err)
+	assert.Equal(t, orig, string(res))
+	_, err = os.Stat(filepath.Join(dir, ".tagfmt"))
+	assert.True(t, os.IsNotExist(err))
+
+	// nothing to undo
+	assert.Equal(t, exitUsage, undoMain(nil))
+}
+
+func TestJournalIndexBeforeClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "undo")
+	first, err := newJournal(root, time.Unix(1, 0))
+	require.NoError(t, err)
+	src := filepath.Join(dir, "user.go")
+	require.NoError(t, first.Record(src, []byte("a"), []byte("b"), 0644))
+
+	// the index is written with the entry, a crashed run can be undone
+	data, err := ioutil.ReadFile(filepath.Join(first.dir, journalFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), contentHash([]byte("a")))
+	// the diff is stored beside the original content instead of the index
+	assert.NotContains(t, string(data), "+b")
+	diffData, err := ioutil.ReadFile(filepath.Join(first.dir, "0.diff"))
+	require.NoError(t, err)
+	assert.Contains(t, string(diffData), "+b")
+
+	// the partial line of a crash is skipped
+	index, err := os.OpenFile(filepath.Join(first.dir, journalFile), os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = index.WriteString(`{"path":"`)
+	require.NoError(t, err)
+	require.NoError(t, index.Close())
+	entries, err := readJournal(first.dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, src, entries[0].Path)
+	assert.Equal(t, "0.orig", entries[0].Orig)
+
+	// the run without a written file yet is skipped
+	second, err := newJournal(root, time.Unix(2, 0))
+	require.NoError(t, err)
+	last, err := lastJournal(root)
+	require.NoError(t, err)
+	assert.Equal(t, first.dir, last)
+	require.NoError(t, second.Close())
+	require.NoError(t, first.Close())
+}
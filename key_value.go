@@ -4,7 +4,13 @@
  * license that can be found in the LICENSE file.
  */
 
-package main
+package tagfmt
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
 
 type KeyValue struct {
 	Key   string
@@ -16,7 +22,7 @@ func (kv KeyValue) String() string {
 	if kv.quote == "`" {
 		return kv.Key + `:"` + kv.Value + `"`
 	} else if kv.quote == "\"" {
-		return kv.Key + `:\\"` + kv.Value + `:\\"`
+		return kv.Key + `:\"` + kv.Value + `\"`
 	} else {
 		panic("invalid quote " + kv.quote)
 	}
@@ -31,6 +37,8 @@ func ParseTag(tag string) (quote string, keyValues []KeyValue, err error) {
 
 	quote = tag[:1]
 	tag = tag[1 : len(tag)-1]
+	// every key has one colon at least, it avoid growing the slice again and again
+	keyValues = make([]KeyValue, 0, strings.Count(tag, ":"))
 
 	for tag != "" {
 		// Skip leading space.
@@ -93,3 +101,62 @@ func ParseTag(tag string) (quote string, keyValues []KeyValue, err error) {
 	}
 	return
 }
+
+// tagEdit is the key values of a field tag being edited, the added keys take the
+// quote of the tag
+type tagEdit struct {
+	quote     string
+	keyValues []KeyValue
+	changed   bool
+}
+
+// index returns the index of the first key value of key, -1 if not found
+func (e *tagEdit) index(key string) int {
+	for i, kv := range e.keyValues {
+		if kv.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// add appends the key value to the tag
+func (e *tagEdit) add(key, value string) {
+	e.keyValues = append(e.keyValues, KeyValue{Key: key, quote: e.quote, Value: value})
+	e.changed = true
+}
+
+// set replaces the value of the i-th key value
+func (e *tagEdit) set(i int, value string) {
+	if e.keyValues[i].Value != value {
+		e.keyValues[i].Value = value
+		e.changed = true
+	}
+}
+
+// editFieldTag calls edit with the key values of the tag of field, the field without
+// tag has none, the tag is rebuilt only if edit changed them
+func editFieldTag(field *ast.Field, edit func(e *tagEdit)) error {
+	e := &tagEdit{quote: "`"}
+	if field.Tag != nil {
+		var err error
+		e.quote, e.keyValues, err = ParseTag(field.Tag.Value)
+		if err != nil {
+			return err
+		}
+	}
+	edit(e)
+	if !e.changed {
+		return nil
+	}
+	var keyValuesRaw []string
+	for _, kv := range e.keyValues {
+		keyValuesRaw = append(keyValuesRaw, kv.String())
+	}
+	if field.Tag == nil {
+		field.Tag = &ast.BasicLit{Kind: token.STRING}
+	}
+	field.Tag.Value = e.quote + strings.Join(keyValuesRaw, " ") + e.quote
+	field.Tag.ValuePos = 0
+	return nil
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"sort"
+)
+
+// keyUsage is a tag key and the count of the fields have it, Positions are the fields
+// of the rare keys, they are often the typos e.g jsn
+type keyUsage struct {
+	Key       string   `json:"key"`
+	Fields    int      `json:"fields"`
+	Known     bool     `json:"known"`
+	Positions []string `json:"positions,omitempty"`
+}
+
+// keysPackage is the tag keys used in a package directory from the most used, Fields
+// is the count of the tagged fields
+type keysPackage struct {
+	Dir    string     `json:"dir"`
+	Fields int        `json:"fields"`
+	Keys   []keyUsage `json:"keys"`
+}
+
+// keysMain prints every distinct tag key and the count of the fields have it per
+// package, the keys unknown by -strict-keys are marked and the positions of the keys
+// used by at most -pos fields are listed, it's the quickest way to find the typo'd keys
+// and plan -known-keys before enabling -strict-keys, nothing is modified
+//
+//	tagfmt keys ./...
+func keysMain(args []string) int {
+	fs := flag.NewFlagSet("keys", flag.ContinueOnError)
+	rare := fs.Int("pos", 3, "list the positions of the keys used by at most n fields")
+	return reportMain(fs, args, func(paths []string) (interface{}, error) {
+		return keysPackages(paths, *rare)
+	}, func(w io.Writer, packages interface{}) error {
+		return writeKeys(w, packages.([]keysPackage))
+	})
+}
+
+// keysPackages counts the keys of the files of paths, the packages are in the order
+// of their first file
+func keysPackages(paths []string, rare int) ([]keysPackage, error) {
+	var packages []keysPackage
+	var usages []map[string]*keyUsage
+	err := walkPackageFiles(paths, func(pkg int, dir, filename string, opts Options) error {
+		if pkg == len(packages) {
+			packages = append(packages, keysPackage{Dir: dir, Keys: []keyUsage{}})
+			usages = append(usages, map[string]*keyUsage{})
+		}
+		fields, err := keysFile(filename, opts, usages[pkg])
+		if err != nil {
+			return err
+		}
+		packages[pkg].Fields += fields
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range packages {
+		pkg := &packages[i]
+		for _, usage := range usages[i] {
+			if usage.Fields > rare {
+				usage.Positions = nil
+			}
+			pkg.Keys = append(pkg.Keys, *usage)
+		}
+		sort.Slice(pkg.Keys, func(i, j int) bool {
+			if pkg.Keys[i].Fields != pkg.Keys[j].Fields {
+				return pkg.Keys[i].Fields > pkg.Keys[j].Fields
+			}
+			return pkg.Keys[i].Key < pkg.Keys[j].Key
+		})
+	}
+	return packages, nil
+}
+
+// keysFile counts the keys of the tagged fields selected by opts in filename into
+// usages, returns the count of the tagged fields
+func keysFile(filename string, opts Options, usages map[string]*keyUsage) (int, error) {
+	filter, err := opts.Filter()
+	if err != nil {
+		return 0, err
+	}
+	known := knownTagKeys(opts.KnownKeys)
+	fset := token.NewFileSet()
+	count := 0
+	err = walkTaggedStructs(fset, filename, filter, func(spec *ast.TypeSpec, fields []taggedField) {
+		for _, f := range fields {
+			count++
+			seen := map[string]bool{}
+			for _, kv := range f.keyValues {
+				if seen[kv.Key] {
+					continue
+				}
+				seen[kv.Key] = true
+				usage, ok := usages[kv.Key]
+				if !ok {
+					usage = &keyUsage{Key: kv.Key, Known: known[kv.Key]}
+					usages[kv.Key] = usage
+				}
+				usage.Fields++
+				usage.Positions = append(usage.Positions, fset.Position(f.field.Tag.Pos()).String())
+			}
+		}
+	})
+	return count, err
+}
+
+// writeKeys writes the keys of the packages, a key per line from the most used, the
+// unknown keys are marked and the positions of the rare keys follow them
+func writeKeys(w io.Writer, packages []keysPackage) error {
+	for _, pkg := range packages {
+		if _, err := fmt.Fprintf(w, "%s: %d tagged fields\n", pkg.Dir, pkg.Fields); err != nil {
+			return err
+		}
+		for _, usage := range pkg.Keys {
+			line := fmt.Sprintf("\t%s: %d fields", usage.Key, usage.Fields)
+			if usage.Fields == 1 {
+				line = fmt.Sprintf("\t%s: 1 field", usage.Key)
+			}
+			if !usage.Known {
+				line += ", unknown key"
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+			for _, pos := range usage.Positions {
+				if _, err := fmt.Fprintf(w, "\t\t%s\n", pos); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
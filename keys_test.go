@@ -0,0 +1,53 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestKeys(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	src := "package user\n\n" +
+		"type User struct {\n" +
+		"\tID   int    `json:\"id\" yaml:\"id\"`\n" +
+		"\tName string `jsn:\"name\" yaml:\"name\"`\n" +
+		"\tAddress struct {\n\t\tZip string `json:\"zip\" json:\"zip_code\"`\n\t}\n" +
+		"\tAge int\n" +
+		"}\n\n" +
+		"type Role struct {\n\tName string `json:\"name\" custom:\"name\"`\n}\n"
+	dir, filename := writeReportPackage(t, src)
+	*knownKeys = "custom"
+
+	packages, err := keysPackages([]string{dir}, 1)
+	require.NoError(t, err)
+	require.Len(t, packages, 1)
+	assert.Equal(t, 4, packages[0].Fields)
+	// the repeated key counts the field once
+	assert.Equal(t, []keyUsage{
+		{Key: "json", Fields: 3, Known: true},
+		{Key: "yaml", Fields: 2, Known: true},
+		{Key: "custom", Fields: 1, Known: true, Positions: []string{filename + ":13:14"}},
+		{Key: "jsn", Fields: 1, Positions: []string{filename + ":5:14"}},
+	}, packages[0].Keys)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeKeys(&buf, packages))
+	assert.Equal(t, dir+": 4 tagged fields\n"+
+		"\tjson: 3 fields\n"+
+		"\tyaml: 2 fields\n"+
+		"\tcustom: 1 field\n"+
+		"\t\t"+filename+":13:14\n"+
+		"\tjsn: 1 field, unknown key\n"+
+		"\t\t"+filename+":5:14\n", buf.String())
+}
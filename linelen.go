@@ -0,0 +1,186 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// lineLimit is the max line length of the aligned fields and the policy of the
+// lines over it, max 0 means no limit
+type lineLimit struct {
+	max    int
+	policy string
+}
+
+// parseOverflow checks the -overflow value
+func parseOverflow(policy string) error {
+	switch policy {
+	case "", "unalign", "shrink", "report":
+		return nil
+	}
+	return fmt.Errorf("invalid overflow %q, must be unalign, shrink or report", policy)
+}
+
+// alignedFields is the tag columns of the fields aligned together, pads are the number
+// of leading columns padded to the column widths per field
+type alignedFields struct {
+	fs      *token.FileSet
+	fields  []*ast.Field
+	columns [][]string
+	pads    []int
+}
+
+// widths returns the column widths of the padded columns, every column of the fully
+// aligned fields counts
+func (a *alignedFields) widths() []int {
+	var widths []int
+	for fi, columns := range a.columns {
+		for i, column := range columns {
+			if i >= a.pads[fi] {
+				break
+			}
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(displayWidth(column), widths[i])
+		}
+	}
+	return widths
+}
+
+// tagWidth returns the width of the field tag with the quotes
+func (a *alignedFields) tagWidth(fi int, widths []int) int {
+	columns := a.columns[fi]
+	width := 2 + len(columns) - 1
+	for i, column := range columns {
+		if i < a.pads[fi] && i != len(columns)-1 {
+			width += widths[i]
+		} else {
+			width += displayWidth(column)
+		}
+	}
+	return width
+}
+
+// tagColumn returns the display column of the field tag start, the tabs of indent
+// count as tabWidth, exact is false if the tag is changed by the previous executors,
+// its column is estimated by the names and types of the fields
+func (a *alignedFields) tagColumn(fi int) (column int, exact bool) {
+	field := a.fields[fi]
+	start := a.fs.Position(field.Pos())
+	indent := (start.Column - 1) * tabWidth
+	if field.Tag.Pos().IsValid() {
+		return indent + a.fs.Position(field.Tag.Pos()).Column - start.Column, true
+	}
+	if a.fs.Position(field.Type.End()).Line != start.Line {
+		// the tag follows the closing brace of the multiline type
+		return indent + len("} "), false
+	}
+	// the printer aligns the types and tags of the fields
+	nameWidth, typeWidth := 0, 0
+	for _, f := range a.fields {
+		var names []string
+		for _, name := range f.Names {
+			names = append(names, name.Name)
+		}
+		if len(names) != 0 {
+			nameWidth = max(nameWidth, displayWidth(strings.Join(names, ", "))+1)
+		}
+		typeWidth = max(typeWidth, int(f.Type.End()-f.Type.Pos())+1)
+	}
+	return indent + nameWidth + typeWidth, false
+}
+
+// lineWidth returns the width of the field line end with the tag
+func (a *alignedFields) lineWidth(fi int, widths []int) (int, bool) {
+	column, exact := a.tagColumn(fi)
+	return column + a.tagWidth(fi, widths), exact
+}
+
+// limit applies the policy of limit on the fields, unalign leaves the overflowed
+// lines unaligned, shrink pads fewer leading columns of all fields until the lines fit,
+// report returns the errors of the overflowed lines
+func (a *alignedFields) limit(limit lineLimit) error {
+	switch limit.policy {
+	case "report":
+		var errs tagDockerErr
+		widths := a.widths()
+		for fi := range a.fields {
+			// the estimated column isn't reported, the next pass checks the formatted lines
+			if width, exact := a.lineWidth(fi, widths); exact && width > limit.max && len(errs) < tagDockerMaxErr {
+				errs = append(errs, NewAstError(a.fs, a.fields[fi].Tag, fmt.Errorf("line length %d exceeds -max-line-len %d", width, limit.max)))
+			}
+		}
+		if len(errs) != 0 {
+			return errs
+		}
+	case "shrink":
+		full := append([]int(nil), a.pads...)
+		for fi := range a.pads {
+			a.pads[fi] = 0
+		}
+		// the lines overflowed without padding can't fit, they are left unaligned and
+		// don't shrink the others
+		fits := make([]bool, len(a.fields))
+		columns := 0
+		for fi := range a.fields {
+			width, _ := a.lineWidth(fi, nil)
+			if fits[fi] = width <= limit.max; fits[fi] {
+				columns = max(columns, full[fi])
+			}
+		}
+		for k := columns; k > 0; k-- {
+			for fi := range a.pads {
+				if fits[fi] {
+					a.pads[fi] = min(k, full[fi])
+				}
+			}
+			if a.fit(limit.max, fits) {
+				return nil
+			}
+		}
+		for fi := range a.pads {
+			a.pads[fi] = 0
+		}
+	default:
+		// the longest overflowed line is unaligned first, the others may fit without
+		// the padding of its columns
+		for {
+			widths := a.widths()
+			longest, longestWidth := -1, limit.max
+			for fi := range a.fields {
+				if a.pads[fi] == 0 {
+					continue
+				}
+				if width, _ := a.lineWidth(fi, widths); width > longestWidth {
+					longest, longestWidth = fi, width
+				}
+			}
+			if longest == -1 {
+				break
+			}
+			a.pads[longest] = 0
+		}
+	}
+	return nil
+}
+
+// fit reports whether the lines of fits are in maxLen
+func (a *alignedFields) fit(maxLen int, fits []bool) bool {
+	widths := a.widths()
+	for fi := range a.fields {
+		if width, _ := a.lineWidth(fi, widths); fits[fi] && width > maxLen {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"context"
+	"fmt"
+	"go/scanner"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// logger writes the diagnostics to stderr, the formatted source is written to stdout,
+// with -log-format json every diagnostic is a json object per line so the wrapping
+// tools can separate and parse them
+var logger = newLogger(stderrWriter{}, "text", false)
+
+// stderrWriter writes to the current os.Stderr, the worker redirects it per request
+type stderrWriter struct{}
+
+func (stderrWriter) Write(p []byte) (int, error) {
+	return os.Stderr.Write(p)
+}
+
+// parseLogFormat checks the -log-format value
+func parseLogFormat(format string) error {
+	switch format {
+	case "text", "json":
+		return nil
+	}
+	return fmt.Errorf("invalid -log-format %q, must be text or json", format)
+}
+
+// newLogger returns the logger writes the records in format to w, the text format
+// is the classic file:line:col: message, the labels of it are colored when color
+func newLogger(w io.Writer, format string, color bool) *slog.Logger {
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, nil))
+	}
+	return slog.New(&textHandler{w: w, color: color, mu: &sync.Mutex{}})
+}
+
+// textHandler writes a record per line as "command: pos: label message", the command
+// attribute is the subcommand logged it, the pos attribute is the position of
+// diagnostic, the label is the kind attribute or warning: for the warn level, the
+// other attributes are only for the json format
+type textHandler struct {
+	w     io.Writer
+	color bool
+	attrs []slog.Attr
+	mu    *sync.Mutex
+}
+
+func (h *textHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return true
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return &c
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	return h
+}
+
+func (h *textHandler) Handle(ctx context.Context, r slog.Record) error {
+	var command, pos, label string
+	visit := func(a slog.Attr) bool {
+		switch a.Key {
+		case "command":
+			command = a.Value.String()
+		case "pos":
+			pos = a.Value.String()
+		case "kind":
+			label = a.Value.String()
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		visit(a)
+	}
+	r.Attrs(visit)
+	if label == "" && r.Level == slog.LevelWarn {
+		label = "warning:"
+	}
+	if label != "" && h.color {
+		label = paint(colorYellow, label)
+	}
+
+	var b strings.Builder
+	if command != "" {
+		b.WriteString(command + ": ")
+	}
+	if pos != "" {
+		b.WriteString(pos + ": ")
+	}
+	if label != "" {
+		b.WriteString(label + " ")
+	}
+	b.WriteString(r.Message)
+	b.WriteByte('\n')
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+// logError logs err at level, the error lists are split to a record per error and
+// the positions of errors are the pos attribute
+func logError(l *slog.Logger, level slog.Level, err error) {
+	ctx := context.Background()
+	switch e := err.(type) {
+	case scanner.ErrorList:
+		for _, _e := range e {
+			logError(l, level, _e)
+		}
+	case tagDockerErr:
+		for _, _e := range e {
+			logError(l, level, _e)
+		}
+	case *scanner.Error:
+		if e.Pos.Filename != "" || e.Pos.IsValid() {
+			l.Log(ctx, level, e.Msg, "pos", e.Pos.String())
+		} else {
+			l.Log(ctx, level, e.Msg)
+		}
+	case *AstError:
+		l.Log(ctx, level, e.Err.Error(), "pos", e.Pos.String())
+	default:
+		l.Log(ctx, level, err.Error())
+	}
+}
+
+// commandError logs err of the subcommand command, the text format prefixes the
+// message with the command name e.g "undo: no journal in .tagfmt/undo"
+func commandError(command string, err error) {
+	logError(logger.With("command", command), slog.LevelError, err)
+}
+
+// quietHandler drops the records below the error level e.g the warnings, it's the
+// handler of -post-generate
+type quietHandler struct {
+	slog.Handler
+}
+
+func (h quietHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelError && h.Handler.Enabled(ctx, level)
+}
+
+func (h quietHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return quietHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h quietHandler) WithGroup(name string) slog.Handler {
+	return quietHandler{h.Handler.WithGroup(name)}
+}
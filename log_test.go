@@ -0,0 +1,81 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/scanner"
+	"go/token"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogText(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogger(&buf, "text", false)
+	pos := token.Position{Filename: "a.go", Line: 3, Column: 2}
+	var list scanner.ErrorList
+	list.Add(pos, "expected ';'")
+	logError(l, slog.LevelError, list)
+	logError(l, slog.LevelError, tagDockerErr{&AstError{Pos: pos, Err: errors.New("unknown key")}, errors.New("no position")})
+	logError(l, slog.LevelWarn, &AstError{Pos: pos, Err: errors.New("duplicate key")})
+	// the text format is the same as scanner.PrintError
+	assert.Equal(t, "a.go:3:2: expected ';'\n"+
+		"a.go:3:2: unknown key\n"+
+		"no position\n"+
+		"a.go:3:2: warning: duplicate key\n", buf.String())
+
+	buf.Reset()
+	newLogger(&buf, "text", true).Warn("duplicate key", "pos", "a.go:3:2")
+	assert.Equal(t, "a.go:3:2: \x1b[33mwarning:\x1b[0m duplicate key\n", buf.String())
+
+	buf.Reset()
+	logError(newLogger(&buf, "text", false).With("command", "check-payload"), slog.LevelError, &AstError{Pos: pos, Err: errors.New("unknown key")})
+	assert.Equal(t, "check-payload: a.go:3:2: unknown key\n", buf.String())
+}
+
+func TestLogJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogger(&buf, "json", false)
+	pos := token.Position{Filename: "a.go", Line: 3, Column: 2}
+	logError(l, slog.LevelError, tagDockerErr{&AstError{Pos: pos, Err: errors.New("unknown key")}, errors.New("no position")})
+	logError(l, slog.LevelWarn, &AstError{Pos: pos, Err: errors.New("duplicate key")})
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+	var records []map[string]interface{}
+	for _, line := range lines {
+		var r map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &r))
+		delete(r, "time")
+		records = append(records, r)
+	}
+	assert.Equal(t, []map[string]interface{}{
+		{"level": "ERROR", "msg": "unknown key", "pos": "a.go:3:2"},
+		{"level": "ERROR", "msg": "no position"},
+		{"level": "WARN", "msg": "duplicate key", "pos": "a.go:3:2"},
+	}, records)
+
+	// the errors of subcommands have the command attribute
+	buf.Reset()
+	defer func(l *slog.Logger) { logger = l }(logger)
+	logger = l
+	commandError("undo", errors.New("no journal"))
+	var r map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &r))
+	delete(r, "time")
+	assert.Equal(t, map[string]interface{}{"level": "ERROR", "msg": "no journal", "command": "undo"}, r)
+
+	assert.NoError(t, parseLogFormat("json"))
+	assert.EqualError(t, parseLogFormat("xml"), `invalid -log-format "xml", must be text or json`)
+}
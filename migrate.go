@@ -0,0 +1,290 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// migrationRule converts the key values of a field tag to the new library, the error
+// is reported with the tag position and nothing is migrated in the file
+type migrationRule func(field *ast.Field, keyValues []KeyValue) ([]KeyValue, error)
+
+// migration is a whole key conversion of migrate, it bundles the key renames, the
+// value transforms and the option mappings of a library upgrade
+type migration struct {
+	Name string
+	Desc string
+	Rule migrationRule
+}
+
+// migrations are the known migrations, yaml.v3 reads the tags of yaml.v2 as is, so
+// there isn't a migration for it
+var migrations = []*migration{
+	{
+		Name: "gorm-v2",
+		Desc: "gorm v1 to v2, e.g primary_key to primaryKey, unique_index to uniqueIndex, association_foreignkey to references",
+		Rule: migrateGormV2,
+	},
+	{
+		Name: "json-v2",
+		Desc: "encoding/json to encoding/json/v2, omitempty of the bool and number fields is omitzero, v2 omits only the empty json values",
+		Rule: migrateJSONV2,
+	},
+	{
+		Name: "binding-validate",
+		Desc: "gin binding to go-playground/validator validate, the rules are the same",
+		Rule: migrateBindingValidate,
+	},
+}
+
+// tagMigration is the migration of migrate, it's nil when migrate isn't running
+var tagMigration *migration
+
+// findMigration returns the migration of name
+func findMigration(name string) (*migration, error) {
+	var names []string
+	for _, m := range migrations {
+		if m.Name == name {
+			return m, nil
+		}
+		names = append(names, m.Name)
+	}
+	return nil, fmt.Errorf("unknown migration %q, the migrations are %s", name, strings.Join(names, " "))
+}
+
+// migrateMain migrates the tags of paths by the named migration, the global flags
+// -w -l -d -n work as formatting, -list prints the migrations
+//
+//	tagfmt -w migrate gorm-v2 ./...
+func migrateMain(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	list := fs.Bool("list", false, "print the migrations")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *list {
+		for _, m := range migrations {
+			fmt.Printf("%s\t%s\n", m.Name, m.Desc)
+		}
+		return exitOK
+	}
+	if fs.NArg() < 2 {
+		commandError("migrate", errors.New("usage: tagfmt [flags] migrate name path ..."))
+		return exitUsage
+	}
+	m, err := findMigration(fs.Arg(0))
+	if err != nil {
+		commandError("migrate", err)
+		return errorClass(err)
+	}
+	tagMigration = m
+	defer func() { tagMigration = nil }()
+	processPaths(fs.Args()[1:], nil)
+	return exitCode
+}
+
+// tagMigrator migrates the tags of the selected fields, the tags are converted in Scan,
+// so the fields can't be migrated are reported before any tag is changed
+type tagMigrator struct {
+	f         *ast.File
+	fs        *token.FileSet
+	filter    *Filter
+	migration *migration
+	fields    []*ast.Field
+	migrated  map[*ast.Field]string
+}
+
+func newTagMigrator(f *ast.File, fs *token.FileSet, filter *Filter, m *migration) *tagMigrator {
+	return &tagMigrator{f: f, fs: fs, filter: filter, migration: m, migrated: map[*ast.Field]string{}}
+}
+
+func (s *tagMigrator) Visit(node ast.Node) ast.Visitor {
+	cmap := fileCommentMap(s.fs, s.f)
+	visit := newTopVisit(cmap, s.filter, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagMigrator) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields == nil {
+		return
+	}
+	for _, field := range n.Fields.List {
+		if field.Tag != nil && s.filter.Field(name, getFieldOrTypeName(field)) {
+			s.fields = append(s.fields, field)
+		}
+	}
+}
+
+func (s *tagMigrator) Scan() error {
+	ast.Walk(s, s.f)
+	var errs tagDockerErr
+	for _, field := range s.fields {
+		quote, keyValues, err := ParseTag(field.Tag.Value)
+		if err != nil {
+			errs = appendErrors(errs, NewAstError(s.fs, field.Tag, err))
+			continue
+		}
+		migrated, err := s.migration.Rule(field, append([]KeyValue(nil), keyValues...))
+		if err != nil {
+			if len(errs) < tagDockerMaxErr {
+				errs = appendErrors(errs, NewAstError(s.fs, field.Tag, fmt.Errorf("migrate %s: %w", s.migration.Name, err)))
+			}
+			continue
+		}
+		var keyValuesRaw []string
+		for _, kv := range migrated {
+			kv.quote = quote
+			keyValuesRaw = append(keyValuesRaw, kv.String())
+		}
+		if value := quote + strings.Join(keyValuesRaw, " ") + quote; value != field.Tag.Value {
+			s.migrated[field] = value
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+func (s *tagMigrator) Execute() error {
+	for field, value := range s.migrated {
+		field.Tag.Value = value
+		field.Tag.ValuePos = 0
+	}
+	return nil
+}
+
+// gormV2Options are the gorm v1 options renamed in v2, the options are case-insensitive
+// in v1, the unknown options are kept
+var gormV2Options = map[string]string{
+	"primary_key":                      "primaryKey",
+	"auto_increment":                   "autoIncrement",
+	"unique_index":                     "uniqueIndex",
+	"embedded_prefix":                  "embeddedPrefix",
+	"foreignkey":                       "foreignKey",
+	"association_foreignkey":           "references",
+	"jointable_foreignkey":             "joinForeignKey",
+	"association_jointable_foreignkey": "joinReferences",
+	"polymorphic_value":                "polymorphicValue",
+	"not null":                         "not null",
+	"column":                           "column",
+	"type":                             "type",
+	"size":                             "size",
+	"precision":                        "precision",
+	"default":                          "default",
+	"index":                            "index",
+	"unique":                           "unique",
+	"embedded":                         "embedded",
+	"many2many":                        "many2many",
+	"polymorphic":                      "polymorphic",
+}
+
+// gormV2Removed are the gorm v1 options have no tag in v2, they are the query options
+// of v2
+var gormV2Removed = map[string]string{
+	"save_associations":          "db.Omit(clause.Associations)",
+	"association_autoupdate":     "db.Omit or FullSaveAssociations",
+	"association_autocreate":     "db.Omit",
+	"association_save_reference": "db.Omit",
+	"preload":                    "db.Preload",
+}
+
+// migrateGormV2 renames the options of gorm key, the values e.g type:varchar(100)
+// are kept
+func migrateGormV2(field *ast.Field, keyValues []KeyValue) ([]KeyValue, error) {
+	for i, kv := range keyValues {
+		if kv.Key != "gorm" || kv.Value == "-" {
+			continue
+		}
+		options := strings.Split(kv.Value, ";")
+		for j, option := range options {
+			name, value := option, ""
+			if idx := strings.IndexByte(option, ':'); idx != -1 {
+				name, value = option[:idx], option[idx:]
+			}
+			lower := strings.ToLower(strings.TrimSpace(name))
+			if api, ok := gormV2Removed[lower]; ok {
+				return nil, fmt.Errorf("gorm option %s is removed in v2, use %s instead", strings.TrimSpace(name), api)
+			}
+			if newName, ok := gormV2Options[lower]; ok {
+				options[j] = newName + value
+			}
+		}
+		keyValues[i].Value = strings.Join(options, ";")
+	}
+	return keyValues, nil
+}
+
+// jsonScalarTypes are the types omitempty of encoding/json omits the zero value, v2
+// omits only null "" {} and [], so they need omitzero
+var jsonScalarTypes = map[string]bool{
+	"bool": true, "int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	"float32": true, "float64": true, "byte": true, "rune": true,
+}
+
+// migrateJSONV2 replaces omitempty of the bool and number fields by omitzero, the
+// fields of the named types are kept, their kinds are unknown without the types
+func migrateJSONV2(field *ast.Field, keyValues []KeyValue) ([]KeyValue, error) {
+	ident, ok := field.Type.(*ast.Ident)
+	if !ok || !jsonScalarTypes[ident.Name] {
+		return keyValues, nil
+	}
+	for i, kv := range keyValues {
+		if kv.Key != "json" {
+			continue
+		}
+		options := strings.Split(kv.Value, ",")
+		var migrated []string
+		hasZero := false
+		for _, option := range options[1:] {
+			hasZero = hasZero || option == "omitzero"
+		}
+		for j, option := range options {
+			switch {
+			case j == 0 || option != "omitempty":
+				migrated = append(migrated, option)
+			case !hasZero:
+				migrated = append(migrated, "omitzero")
+				hasZero = true
+			}
+		}
+		keyValues[i].Value = strings.Join(migrated, ",")
+	}
+	return keyValues, nil
+}
+
+// migrateBindingValidate renames binding key to validate, the same rules of both keys
+// are merged, the different ones are an error
+func migrateBindingValidate(field *ast.Field, keyValues []KeyValue) ([]KeyValue, error) {
+	binding, validate := -1, -1
+	for i, kv := range keyValues {
+		switch kv.Key {
+		case "binding":
+			binding = i
+		case "validate":
+			validate = i
+		}
+	}
+	switch {
+	case binding == -1:
+		return keyValues, nil
+	case validate == -1:
+		keyValues[binding].Key = "validate"
+		return keyValues, nil
+	case keyValues[binding].Value != keyValues[validate].Value:
+		return nil, fmt.Errorf(`binding:"%s" conflicts with validate:"%s"`, keyValues[binding].Value, keyValues[validate].Value)
+	}
+	return append(keyValues[:binding], keyValues[binding+1:]...), nil
+}
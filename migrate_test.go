@@ -0,0 +1,103 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	migrate := func(name, src string) (string, error) {
+		m, err := findMigration(name)
+		require.NoError(t, err)
+		tagMigration = m
+		defer func() { tagMigration = nil }()
+		var buf bytes.Buffer
+		err = formatSource(&buf, "user.go", []byte(src), optionsFromFlags())
+		return buf.String(), err
+	}
+
+	res, err := migrate("gorm-v2", "package user\n\ntype User struct {\n"+
+		"\tID    uint   `gorm:\"PRIMARY_KEY;AUTO_INCREMENT\"`\n"+
+		"\tEmail string `gorm:\"type:varchar(100);unique_index:idx_email;NOT NULL\"`\n"+
+		"\tOrgID uint   `gorm:\"-\"`\n"+
+		"\tOrg   Org    `gorm:\"foreignkey:OrgID;association_foreignkey:ID\"`\n"+
+		"}\n")
+	require.NoError(t, err)
+	assert.Equal(t, "package user\n\ntype User struct {\n"+
+		"\tID    uint   `gorm:\"primaryKey;autoIncrement\"`\n"+
+		"\tEmail string `gorm:\"type:varchar(100);uniqueIndex:idx_email;not null\"`\n"+
+		"\tOrgID uint   `gorm:\"-\"`\n"+
+		"\tOrg   Org    `gorm:\"foreignKey:OrgID;references:ID\"`\n"+
+		"}\n", res)
+	_, err = migrate("gorm-v2", "package user\n\ntype User struct {\n\tOrg Org `gorm:\"association_autoupdate:false\"`\n}\n")
+	assert.EqualError(t, err, "user.go:4:10: migrate gorm-v2: gorm option association_autoupdate is removed in v2, use db.Omit or FullSaveAssociations instead")
+
+	res, err = migrate("json-v2", "package user\n\ntype User struct {\n"+
+		"\tAge   int       `json:\"age,omitempty\"`\n"+
+		"\tAdmin bool      `json:\"admin,omitempty,string\"`\n"+
+		"\tScore float64   `json:\"score,omitzero,omitempty\"`\n"+
+		"\tName  string    `json:\"name,omitempty\"`\n"+
+		"\tTags  []string  `json:\"tags,omitempty\"`\n"+
+		"\tLevel UserLevel `json:\"level,omitempty\"`\n"+
+		"}\n")
+	require.NoError(t, err)
+	assert.Equal(t, "package user\n\ntype User struct {\n"+
+		"\tAge   int       `json:\"age,omitzero\"`\n"+
+		"\tAdmin bool      `json:\"admin,omitzero,string\"`\n"+
+		"\tScore float64   `json:\"score,omitzero\"`\n"+
+		"\tName  string    `json:\"name,omitempty\"`\n"+
+		"\tTags  []string  `json:\"tags,omitempty\"`\n"+
+		"\tLevel UserLevel `json:\"level,omitempty\"`\n"+
+		"}\n", res)
+
+	res, err = migrate("binding-validate", "package user\n\ntype User struct {\n"+
+		"\tName  string `json:\"name\" binding:\"required\"`\n"+
+		"\tEmail string `binding:\"email\" validate:\"email\"`\n"+
+		"}\n")
+	require.NoError(t, err)
+	assert.Equal(t, "package user\n\ntype User struct {\n"+
+		"\tName  string `json:\"name\"      validate:\"required\"`\n"+
+		"\tEmail string `validate:\"email\"`\n"+
+		"}\n", res)
+	_, err = migrate("binding-validate", "package user\n\ntype User struct {\n\tName string `binding:\"required\" validate:\"min=1\"`\n}\n")
+	assert.EqualError(t, err, `user.go:4:14: migrate binding-validate: binding:"required" conflicts with validate:"min=1"`)
+
+	_, err = findMigration("yaml-v3")
+	assert.EqualError(t, err, `unknown migration "yaml-v3", the migrations are gorm-v2 json-v2 binding-validate`)
+}
+
+func TestMigrateMain(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "user.go")
+	require.NoError(t, ioutil.WriteFile(src, []byte("package user\n\ntype User struct {\n\tID uint `gorm:\"primary_key\"`\n}\n"), 0644))
+
+	*write = true
+	assert.Equal(t, exitOK, migrateMain([]string{"gorm-v2", dir}))
+	assert.Nil(t, tagMigration)
+	res, err := ioutil.ReadFile(src)
+	require.NoError(t, err)
+	assert.Equal(t, "package user\n\ntype User struct {\n\tID uint `gorm:\"primaryKey\"`\n}\n", string(res))
+
+	assert.Equal(t, exitUsage, migrateMain([]string{"gorm-v3", dir}))
+	assert.Equal(t, exitUsage, migrateMain([]string{"gorm-v2"}))
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// formatOffset formats only the struct type enclosing the byte offset of the file in
+// paths or the standard input, and prints the replacement of the changed lines as
+//
+//	start end
+//	new text
+//
+// start and end are the byte offsets of the original source, nothing is printed if
+// the struct is formatted, it's for the format struct action of editors, with -patch
+// the replacement is printed as the json line of -patch
+//
+//	tagfmt -offset 1234 user.go
+func formatOffset(offset int, paths []string) {
+	var (
+		filename string
+		src      []byte
+		err      error
+		stdin    bool
+	)
+	switch len(paths) {
+	case 0:
+		filename, stdin = "<standard input>", true
+		// the complete file name make errors point to the editor's file
+		if strings.HasSuffix(*srcdir, ".go") {
+			filename = *srcdir
+		}
+		src, err = ioutil.ReadAll(os.Stdin)
+	case 1:
+		filename = paths[0]
+		src, err = ioutil.ReadFile(filename)
+	default:
+		err = errors.New("-offset needs one file or the standard input")
+	}
+	if err != nil {
+		report(err)
+		return
+	}
+	edit, err := offsetEdit(filename, src, offset, stdin)
+	if err != nil {
+		report(err)
+		return
+	}
+	switch {
+	case edit == nil:
+	case *patch:
+		if err := writePatch(os.Stdout, filename, []sourceEdit{*edit}); err != nil {
+			report(err)
+		}
+	default:
+		fmt.Fprintf(os.Stdout, "%d %d\n%s", edit.start, edit.end, edit.text)
+	}
+}
+
+// offsetEdit formats the struct type enclosing offset of src, it returns the edit of
+// the changed lines, nil if the struct is formatted
+func offsetEdit(filename string, src []byte, offset int, stdin bool) (*sourceEdit, error) {
+	if offset < 0 || offset > len(src) {
+		return nil, fmt.Errorf("%s: offset %d is out of the file size %d", filename, offset, len(src))
+	}
+	fs := token.NewFileSet()
+	f, err := parser.ParseFile(fs, filename, src, parserMode)
+	if err != nil {
+		return nil, err
+	}
+	file := fs.File(f.Pos())
+	name, index := structAtOffset(f, file.Pos(offset))
+	if name == "" {
+		return nil, fmt.Errorf("%s: no struct type at offset %d", filename, offset)
+	}
+	optsName := filename
+	if stdin && *srcdir != "" {
+		optsName = srcdirFile(*srcdir)
+	}
+	opts, err := config.Options(optionsFromFlags(), optsName)
+	if err != nil {
+		return nil, err
+	}
+	opts.structName = name
+	var buf bytes.Buffer
+	if err := formatSource(&buf, filename, src, opts); err != nil {
+		return nil, err
+	}
+	res := buf.Bytes()
+	resFs := token.NewFileSet()
+	resF, err := parser.ParseFile(resFs, filename, res, parserMode)
+	if err != nil {
+		return nil, err
+	}
+	// only the declaration is replaced, the printer may align the code out of it
+	decl := structDecls(f, name)[index]
+	resDecl := structDecls(resF, name)[index]
+	resFile := resFs.File(resF.Pos())
+	start, end := file.Offset(decl.Pos()), file.Offset(decl.End())
+	edit := lineEdit(src[start:end], res[resFile.Offset(resDecl.Pos()):resFile.Offset(resDecl.End())])
+	if edit == nil {
+		return nil, nil
+	}
+	edit.start += start
+	edit.end += start
+	return edit, nil
+}
+
+// structDecls returns the declarations of struct type name in f, the single declaration
+// is the GenDecl with the type keyword, the one in a group is the TypeSpec
+func structDecls(f *ast.File, name string) []ast.Node {
+	var decls []ast.Node
+	ast.Inspect(f, func(node ast.Node) bool {
+		gen, ok := node.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			return true
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if ts.Name.Name != name || indirectStruct(ts.Type) == nil {
+				continue
+			}
+			if gen.Lparen.IsValid() {
+				decls = append(decls, ts)
+			} else {
+				decls = append(decls, gen)
+			}
+		}
+		return true
+	})
+	return decls
+}
+
+// structAtOffset returns the name of the struct type declaration enclosing pos and its
+// index in the declarations of the name, the anonymous structs belong to the
+// declaration enclosing them, the name is empty if there is none
+func structAtOffset(f *ast.File, pos token.Pos) (string, int) {
+	var name string
+	ast.Inspect(f, func(node ast.Node) bool {
+		if name != "" || node == nil || pos < node.Pos() || node.End() < pos {
+			return false
+		}
+		gen, ok := node.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			return true
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if indirectStruct(ts.Type) == nil {
+				continue
+			}
+			// the type keyword of a single declaration is in the struct
+			if !gen.Lparen.IsValid() || (ts.Pos() <= pos && pos <= ts.End()) {
+				name = ts.Name.Name
+			}
+		}
+		return false
+	})
+	if name == "" {
+		return "", 0
+	}
+	for i, decl := range structDecls(f, name) {
+		if decl.Pos() <= pos && pos <= decl.End() {
+			return name, i
+		}
+	}
+	return "", 0
+}
+
+// lineEdit returns the edit from src to res, it replaces the lines between the common
+// prefix and suffix lines, nil if they are the same
+func lineEdit(src, res []byte) *sourceEdit {
+	if bytes.Equal(src, res) {
+		return nil
+	}
+	start := 0
+	for start < len(src) && start < len(res) && src[start] == res[start] {
+		start++
+	}
+	start = bytes.LastIndexByte(src[:start], '\n') + 1
+	suffix := 0
+	for suffix < len(src)-start && suffix < len(res)-start && src[len(src)-1-suffix] == res[len(res)-1-suffix] {
+		suffix++
+	}
+	// the suffix starts at a line beginning
+	for suffix > 0 && src[len(src)-suffix-1] != '\n' {
+		suffix--
+	}
+	return &sourceEdit{start: start, end: len(src) - suffix, text: string(res[start : len(res)-suffix])}
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestOffsetEdit(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	src := "package main\n\n" +
+		"type A struct {\n\tB int `json:\"b\" xml:\"bb\"`\n\tCC int `json:\"cc\" xml:\"c\"`\n}\n\n" +
+		"type (\n\tC struct {\n\t\tB int `json:\"b\" xml:\"bb\"`\n\t\tCC int `json:\"cc\" xml:\"c\"`\n\t}\n\tD int\n)\n"
+	apply := func(edit *sourceEdit) string {
+		return src[:edit.start] + edit.text + src[edit.end:]
+	}
+
+	// the offset of type keyword selects the single declaration, the struct C is kept
+	edit, err := offsetEdit("a.go", []byte(src), strings.Index(src, "type A"), false)
+	require.NoError(t, err)
+	assert.Equal(t, "\tB  int `json:\"b\"  xml:\"bb\"`\n", edit.text)
+	assert.Equal(t, "package main\n\n"+
+		"type A struct {\n\tB  int `json:\"b\"  xml:\"bb\"`\n\tCC int `json:\"cc\" xml:\"c\"`\n}\n\n"+
+		"type (\n\tC struct {\n\t\tB int `json:\"b\" xml:\"bb\"`\n\t\tCC int `json:\"cc\" xml:\"c\"`\n\t}\n\tD int\n)\n", apply(edit))
+
+	edit, err = offsetEdit("a.go", []byte(src), strings.Index(src, "\"cc\" xml:\"c\"`\n\t}"), false)
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\n"+
+		"type A struct {\n\tB int `json:\"b\" xml:\"bb\"`\n\tCC int `json:\"cc\" xml:\"c\"`\n}\n\n"+
+		"type (\n\tC struct {\n\t\tB  int `json:\"b\"  xml:\"bb\"`\n\t\tCC int `json:\"cc\" xml:\"c\"`\n\t}\n\tD int\n)\n", apply(edit))
+
+	_, err = offsetEdit("a.go", []byte(src), strings.Index(src, "D int"), false)
+	assert.EqualError(t, err, "a.go: no struct type at offset "+strconv.Itoa(strings.Index(src, "D int")))
+	_, err = offsetEdit("a.go", []byte(src), len(src)+1, false)
+	assert.EqualError(t, err, "a.go: offset "+strconv.Itoa(len(src)+1)+" is out of the file size "+strconv.Itoa(len(src)))
+
+	// the formatted struct has no edit
+	edit, err = offsetEdit("a.go", []byte("package main\n\ntype A struct {\n\tB int `json:\"b\"`\n}\n"), 20, false)
+	require.NoError(t, err)
+	assert.Nil(t, edit)
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// fileJob is a file processed in background, its output is buffered until
+// all the files before it are written
+type fileJob struct {
+	path string
+	out  bytes.Buffer
+	err  error
+	// reported is the error of an argument or the walk, it's reported even if the
+	// file doesn't exist
+	reported bool
+	done     chan struct{}
+}
+
+// fileScheduler processes files in parallel, but writes their output and
+// errors in the order they are submitted, so -l and -d output are stable
+type fileScheduler struct {
+	sem      chan struct{}
+	jobs     chan *fileJob
+	finished chan struct{}
+}
+
+func newFileScheduler(parallel int, w io.Writer) *fileScheduler {
+	if parallel < 1 {
+		parallel = 1
+	}
+	s := &fileScheduler{
+		sem:      make(chan struct{}, parallel),
+		jobs:     make(chan *fileJob, parallel*2),
+		finished: make(chan struct{}),
+	}
+	go s.output(w)
+	return s
+}
+
+// Process formats the file in background
+func (s *fileScheduler) Process(path string) {
+	job := &fileJob{path: path, done: make(chan struct{})}
+	s.jobs <- job
+	s.sem <- struct{}{}
+	go func() {
+		defer func() {
+			<-s.sem
+			close(job.done)
+		}()
+		job.err = processFile(path, nil, &job.out, false)
+	}()
+}
+
+// Report reports the err in order with the files output
+func (s *fileScheduler) Report(path string, err error) {
+	job := &fileJob{path: path, err: err, reported: true, done: make(chan struct{})}
+	close(job.done)
+	s.jobs <- job
+}
+
+func (s *fileScheduler) output(w io.Writer) {
+	for job := range s.jobs {
+		<-job.done
+		w.Write(job.out.Bytes())
+		// Don't complain if a file was deleted in the meantime (i.e.
+		// the directory changed concurrently while running gofmt), the missing
+		// arguments are still reported.
+		if job.err != nil && (job.reported || !os.IsNotExist(job.err)) {
+			report(job.err)
+		}
+		if progress != nil {
+			progress.Step(job.err)
+		}
+	}
+	close(s.finished)
+}
+
+// Wait waits all the submitted files output
+func (s *fileScheduler) Wait() {
+	close(s.jobs)
+	<-s.finished
+}
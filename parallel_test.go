@@ -0,0 +1,74 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSchedulerOrder(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	*list = true
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var expect []string
+	for i := 0; i < 30; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("file%02d.go", i))
+		// make the early files larger, so they finish later
+		var src strings.Builder
+		src.WriteString("package main\n")
+		for j := 0; j < (30-i)*20; j++ {
+			fmt.Fprintf(&src, "type S%d struct {\n\tA string `json:\"a\" yaml:\"a\"`\n\tLongName string `json:\"long_name\" yaml:\"long_name\"`\n}\n", j)
+		}
+		require.NoError(t, ioutil.WriteFile(name, []byte(src.String()), 0644))
+		expect = append(expect, name)
+	}
+	for n := 0; n < 5; n++ {
+		var out bytes.Buffer
+		scheduler = newFileScheduler(8, &out)
+		for _, name := range expect {
+			scheduler.Process(name)
+		}
+		scheduler.Wait()
+		assert.Equal(t, strings.Join(expect, "\n")+"\n", out.String())
+	}
+	assert.Equal(t, exitChanged, exitCode)
+	exitCode = 0
+}
+
+func TestFileSchedulerReport(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the file deleted after the walk isn't an error
+	var out bytes.Buffer
+	scheduler = newFileScheduler(1, &out)
+	scheduler.Process(filepath.Join(dir, "deleted.go"))
+	scheduler.Wait()
+	assert.Equal(t, 0, exitCode)
+
+	// the missing argument is an io error
+	processPaths([]string{filepath.Join(dir, "nonexist.go")}, nil)
+	assert.Equal(t, exitIO, exitCode)
+	exitCode = 0
+}
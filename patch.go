@@ -0,0 +1,75 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// patchEdit is a replacement of -patch output, start and end are the byte offsets of
+// the original source
+type patchEdit struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+// filePatch is a line of -patch output, the edits are sorted and don't overlap, so the
+// editors apply them from the last one without moving the cursor and breaking the undo
+type filePatch struct {
+	File  string      `json:"file"`
+	Edits []patchEdit `json:"edits"`
+}
+
+// writePatch writes the edits of filename as a json line
+func writePatch(w io.Writer, filename string, edits []sourceEdit) error {
+	patch := filePatch{File: filename, Edits: []patchEdit{}}
+	for _, edit := range edits {
+		patch.Edits = append(patch.Edits, patchEdit{Start: edit.start, End: edit.end, Text: edit.text})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(patch)
+}
+
+// lineEdits returns the edits from src to res, every edit replaces the changed lines
+// of a hunk without context
+func lineEdits(src, res []byte) []sourceEdit {
+	var edits []sourceEdit
+	var current *sourceEdit
+	var text strings.Builder
+	offset := 0
+	flush := func() {
+		if current != nil {
+			current.text = text.String()
+			edits = append(edits, *current)
+			current = nil
+			text.Reset()
+		}
+	}
+	for _, op := range diffLines(splitLines(src), splitLines(res)) {
+		if op.kind == ' ' {
+			flush()
+			offset += len(op.line)
+			continue
+		}
+		if current == nil {
+			current = &sourceEdit{start: offset, end: offset}
+		}
+		if op.kind == '-' {
+			offset += len(op.line)
+			current.end = offset
+		} else {
+			text.WriteString(op.line)
+		}
+	}
+	flush()
+	return edits
+}
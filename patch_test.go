@@ -0,0 +1,47 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestLineEdits(t *testing.T) {
+	src := "a\nb\nc\nd\ne\n"
+	res := "a\nB\nc\nd\ne\nf\n"
+	edits := lineEdits([]byte(src), []byte(res))
+	assert.Equal(t, []sourceEdit{
+		{start: 2, end: 4, text: "B\n"},
+		{start: 10, end: 10, text: "f\n"},
+	}, edits)
+	assert.Equal(t, res, string(splice([]byte(src), edits)))
+	assert.Empty(t, lineEdits([]byte(src), []byte(src)))
+}
+
+func TestPatchOutput(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	*patch = true
+	src := "package main\n\ntype A struct {\n\tB int `json:\"b\" xml:\"bb\"`\n\tCC int `json:\"cc\" xml:\"c\"`\n}\n"
+	var out bytes.Buffer
+	require.NoError(t, processFile("<standard input>", strings.NewReader(src), &out, true))
+	assert.Equal(t, `{"file":"<standard input>","edits":[{"start":30,"end":57,"text":"\tB  int `+
+		"`json:\\\"b\\\"  xml:\\\"bb\\\"`"+`\n"}]}`+"\n", out.String())
+
+	// the formatted source has no patch
+	out.Reset()
+	require.NoError(t, processFile("a.go", strings.NewReader("package main\n"), &out, false))
+	assert.Empty(t, out.String())
+	assert.Equal(t, exitChanged, exitCode)
+	exitCode = 0
+}
@@ -0,0 +1,250 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// checkPayloadMain reports the keys of the sample json payload which don't map to a
+// tagged field of the struct and the missing keys of the fields without omitempty,
+// the typos of the wire names are caught without writing a test, the struct is selected
+// by -struct of check-payload, or else -struct or the struct patterns of the main command,
+// nothing is modified
+//
+//	tagfmt check-payload -struct User testdata/user.json ./api
+func checkPayloadMain(args []string) int {
+	fs := flag.NewFlagSet("check-payload", flag.ContinueOnError)
+	key := fs.String("key", "json", "the tag key of the payload names")
+	name := fs.String("struct", "", "the struct name of the payload, the -struct or the struct patterns of the main command by default")
+	// the flags may follow the payload e.g check-payload payload.json -struct User
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return exitUsage
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) == 0 {
+		commandError("check-payload", errors.New("usage: tagfmt check-payload -struct name [-key json] payload.json [path ...]"))
+		return exitUsage
+	}
+	paths := positional[1:]
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	selected, err := selectedStruct(*name, paths)
+	if err != nil {
+		commandError("check-payload", err)
+		return errorClass(err)
+	}
+	problems, err := checkPayload(positional[0], selected, *key, paths)
+	if err != nil {
+		commandError("check-payload", err)
+		return errorClass(err)
+	}
+	for _, problem := range problems {
+		logError(logger, slog.LevelError, problem)
+	}
+	if len(problems) != 0 {
+		return exitChanged
+	}
+	return exitOK
+}
+
+// payloadField is a field decoded from the payload key name, the promoted fields of
+// the embedded structs are the fields of the struct like encoding/json
+type payloadField struct {
+	name     string
+	path     string // Struct.Field
+	typ      ast.Expr
+	optional bool
+	pos      token.Position
+}
+
+// payloadChecker checks a payload against the structs of a package
+type payloadChecker struct {
+	fset     *token.FileSet
+	key      string
+	structs  map[string]*ast.StructType
+	filename string
+	data     []byte
+	problems []error
+}
+
+// checkPayload returns the problems of the payload file against the struct name
+// declared in paths, the nested structs are resolved in the package of name
+func checkPayload(payload, name, key string, paths []string) ([]error, error) {
+	filename, err := uniqueStructFile(name, paths)
+	if err != nil {
+		return nil, err
+	}
+	c := &payloadChecker{fset: token.NewFileSet(), key: key, filename: payload}
+	c.structs, err = packageStructs(c.fset, filename)
+	if err != nil {
+		return nil, err
+	}
+	root, data, err := readJSONNode(payload)
+	if err != nil {
+		return nil, err
+	}
+	c.data = data
+	c.checkValue(root, &ast.Ident{Name: name}, "")
+	return c.problems, nil
+}
+
+// position returns the position of the offset in the payload, the separators and the
+// spaces before the value are skipped
+func (c *payloadChecker) position(offset int64) token.Position {
+	for offset < int64(len(c.data)) && strings.IndexByte(" \t\r\n,:", c.data[offset]) != -1 {
+		offset++
+	}
+	pos := token.Position{Filename: c.filename, Offset: int(offset), Line: 1, Column: 1}
+	for _, b := range c.data[:offset] {
+		if b == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	return pos
+}
+
+func (c *payloadChecker) report(offset int64, format string, args ...interface{}) {
+	c.problems = append(c.problems, &AstError{Pos: c.position(offset), Err: fmt.Errorf(format, args...)})
+}
+
+// checkValue checks the value of path decoded to typ, the values of the types out of
+// the package and the maps are not checked
+func (c *payloadChecker) checkValue(node *jsonNode, typ ast.Expr, path string) {
+	switch t := typ.(type) {
+	case *ast.StarExpr:
+		c.checkValue(node, t.X, path)
+	case *ast.ArrayType:
+		if node.Kind == "array" {
+			for i, item := range node.Items {
+				c.checkValue(item, t.Elt, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case *ast.Ident, *ast.StructType:
+		name := "struct"
+		st, ok := t.(*ast.StructType)
+		if ident, isIdent := t.(*ast.Ident); isIdent {
+			name = ident.Name
+			st, ok = c.structs[name]
+		}
+		if !ok {
+			return
+		}
+		switch node.Kind {
+		case "object":
+			c.checkObject(node, name, st, path)
+		case "array":
+			// the payload of the list api
+			if path == "" {
+				for i, item := range node.Items {
+					c.checkValue(item, typ, fmt.Sprintf("[%d]", i))
+				}
+			}
+		}
+	}
+}
+
+// checkObject checks the keys of the object against the fields of st
+func (c *payloadChecker) checkObject(node *jsonNode, name string, st *ast.StructType, path string) {
+	fields := c.fields(name, st, map[*ast.StructType]bool{})
+	byName := map[string]payloadField{}
+	for _, f := range fields {
+		byName[f.name] = f
+	}
+	matched := map[string]bool{}
+	for _, key := range node.Keys {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+		if f, ok := byName[key]; ok {
+			matched[f.name] = true
+			c.checkValue(node.Values[key], f.typ, keyPath)
+			continue
+		}
+		// encoding/json matches the names case-insensitively, the other decoders don't
+		var folded []string
+		for _, f := range fields {
+			if strings.EqualFold(f.name, key) {
+				folded = append(folded, f.name)
+				matched[f.name] = true
+			}
+		}
+		if len(folded) != 0 {
+			c.report(node.KeyOffsets[key], "key %s matches %s %s only by case", keyPath, c.key, strings.Join(folded, " "))
+			continue
+		}
+		c.report(node.KeyOffsets[key], "unknown key %s, %s has no %s name %s", keyPath, name, c.key, key)
+	}
+	// the names of a field declaring several names e.g A, B string `json:"a"` share
+	// the tag name, it's missing once
+	var missing []payloadField
+	for _, f := range fields {
+		if !f.optional && !matched[f.name] {
+			missing = append(missing, f)
+			matched[f.name] = true
+		}
+	}
+	sort.SliceStable(missing, func(i, j int) bool {
+		return missing[i].pos.Line < missing[j].pos.Line
+	})
+	where := path
+	if where == "" {
+		where = "the payload"
+	}
+	for _, f := range missing {
+		c.report(node.Offset, "missing key %s in %s, %s at %s has no omitempty", f.name, where, f.path, f.pos)
+	}
+}
+
+// fields returns the payload fields of st, the names are of the tag key or the field
+// names, the fields of - and the unexported fields are skipped
+func (c *payloadChecker) fields(name string, st *ast.StructType, seen map[*ast.StructType]bool) []payloadField {
+	var fields []payloadField
+	for _, f := range keyFields(c.structs, name, st, c.key, seen) {
+		optional := false
+		if f.field.Tag != nil {
+			if _, keyValues, err := ParseTag(f.field.Tag.Value); err == nil {
+				for _, kv := range keyValues {
+					if kv.Key != c.key {
+						continue
+					}
+					for _, opt := range strings.Split(kv.Value, ",")[1:] {
+						optional = optional || opt == "omitempty" || opt == "omitzero"
+					}
+				}
+			}
+		}
+		fields = append(fields, payloadField{
+			name:     f.name,
+			path:     f.path,
+			typ:      f.field.Type,
+			optional: optional,
+			pos:      c.fset.Position(f.field.Pos()),
+		})
+	}
+	return fields
+}
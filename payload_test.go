@@ -0,0 +1,86 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckPayload(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "user.go"), []byte("package api\n\n"+
+		"type Base struct {\n\tID int64 `json:\"id\"`\n}\n\n"+
+		"type User struct {\n"+
+		"\tBase\n"+
+		"\tName    string   `json:\"name\"`\n"+
+		"\tEmail   string   `json:\"email,omitempty\"`\n"+
+		"\tFriends []Friend `json:\"friends,omitempty\"`\n"+
+		"\tSecret  string   `json:\"-\"`\n"+
+		"\tnote    string\n"+
+		"}\n\n"+
+		"type Friend struct {\n"+
+		"\tUserID int64  `json:\"user_id\"`\n"+
+		"\tNick   string `json:\"nick,omitempty\"`\n"+
+		"}\n\n"+
+		"type Pair struct {\n\tA, B string `json:\"ab\"`\n}\n"), 0644))
+	payload := filepath.Join(dir, "user.json")
+	require.NoError(t, ioutil.WriteFile(payload, []byte("{\n"+
+		"  \"id\": 1,\n"+
+		"  \"Name\": \"a\",\n"+
+		"  \"friends\": [\n"+
+		"    {\"user_id\": 2},\n"+
+		"    {\"userid\": 3, \"nik\": \"b\"}\n"+
+		"  ],\n"+
+		"  \"secret\": \"x\"\n"+
+		"}\n"), 0644))
+
+	problems, err := checkPayload(payload, "User", "json", []string{dir})
+	require.NoError(t, err)
+	var messages []string
+	for _, p := range problems {
+		messages = append(messages, p.Error())
+	}
+	goFile := filepath.Join(dir, "user.go")
+	assert.Equal(t, []string{
+		payload + ":3:3: key Name matches json name only by case",
+		payload + ":6:6: unknown key friends[1].userid, Friend has no json name userid",
+		payload + ":6:19: unknown key friends[1].nik, Friend has no json name nik",
+		payload + ":6:5: missing key user_id in friends[1], Friend.UserID at " + goFile + ":17:2 has no omitempty",
+		payload + ":8:3: unknown key secret, User has no json name secret",
+	}, messages)
+
+	// the names of a field sharing the tag name are missing once
+	require.NoError(t, ioutil.WriteFile(payload, []byte(`{}`), 0644))
+	problems, err = checkPayload(payload, "Pair", "json", []string{dir})
+	require.NoError(t, err)
+	require.Len(t, problems, 1)
+	assert.Equal(t, payload+":1:1: missing key ab in the payload, Pair.A at "+goFile+":22:2 has no omitempty", problems[0].Error())
+
+	// the array payload is checked by element, the flags may follow the payload
+	require.NoError(t, ioutil.WriteFile(payload, []byte(`[{"id": 1, "name": "a"}, {"id": 2, "name": "b", "email": ""}]`), 0644))
+	assert.Equal(t, exitOK, checkPayloadMain([]string{payload, "-struct", "User", dir}))
+	require.NoError(t, ioutil.WriteFile(payload, []byte(`[{"id": 1}]`), 0644))
+	assert.Equal(t, exitChanged, checkPayloadMain([]string{"-struct", "User", payload, dir}))
+	assert.Equal(t, exitUsage, checkPayloadMain([]string{"-struct", "User"}))
+	assert.Equal(t, exitIO, checkPayloadMain([]string{"-struct", "User", filepath.Join(dir, "none.json"), dir}))
+	// the struct patterns select more than one struct
+	assert.Equal(t, exitUsage, checkPayloadMain([]string{payload, dir}))
+	// -struct of the main command
+	*structName = "User"
+	assert.Equal(t, exitChanged, checkPayloadMain([]string{payload, dir}))
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pipelineStages is the builtin executors in the default order
+var pipelineStages = []string{"split", "doctor", "comment", "remnant", "rewrite", "rename", "migrate", "fill", "sync", "time", "redact", "sort", "align"}
+
+// defaultPipeline returns the builtin executors and the registered executors after them
+func defaultPipeline() []string {
+	order := append([]string(nil), pipelineStages...)
+	for _, exe := range registered() {
+		order = append(order, exe.name)
+	}
+	return order
+}
+
+// parsePipeline parses the executors order e.g doctor,fill,sort,align, the executors not
+// in the list are dropped, the empty pipeline is the default order
+func parsePipeline(s string) ([]string, error) {
+	stages := defaultPipeline()
+	if strings.TrimSpace(s) == "" {
+		return stages, nil
+	}
+	var order []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if !containsStage(stages, name) {
+			return nil, fmt.Errorf("unknown pipeline stage %q, the stages are %s", name, strings.Join(stages, ","))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate pipeline stage %q", name)
+		}
+		seen[name] = true
+		order = append(order, name)
+	}
+	return order, nil
+}
+
+func isBuiltinStage(name string) bool {
+	return containsStage(pipelineStages, name)
+}
+
+func containsStage(stages []string, name string) bool {
+	for _, stage := range stages {
+		if stage == name {
+			return true
+		}
+	}
+	return false
+}
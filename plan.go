@@ -0,0 +1,185 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"strings"
+)
+
+// fieldTag is the tag of field found by its path e.g User.Name
+type fieldTag struct {
+	Path string
+	Tag  string // the tag literal, empty when the field has no tag
+}
+
+// fieldTags returns the tags of every field in f in source order, the struct declared
+// without type name has the path prefix "struct"
+func fieldTags(f *ast.File) []fieldTag {
+	var tags []fieldTag
+	var walkStruct func(prefix string, st *ast.StructType)
+	walkStruct = func(prefix string, st *ast.StructType) {
+		if st.Fields == nil {
+			return
+		}
+		for _, field := range st.Fields.List {
+			names := make([]string, 0, len(field.Names))
+			for _, name := range field.Names {
+				names = append(names, name.Name)
+			}
+			if len(names) == 0 {
+				names = append(names, embeddedName(field.Type))
+			}
+			var tag string
+			if field.Tag != nil {
+				tag = field.Tag.Value
+			}
+			for _, name := range names {
+				path := prefix + "." + name
+				tags = append(tags, fieldTag{Path: path, Tag: tag})
+				if nested := indirectStruct(field.Type); nested != nil {
+					walkStruct(path, nested)
+				}
+			}
+		}
+	}
+	ast.Inspect(f, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.TypeSpec:
+			if st := indirectStruct(n.Type); st != nil {
+				walkStruct(n.Name.Name, st)
+				return false
+			}
+		case *ast.StructType:
+			walkStruct("struct", n)
+			return false
+		}
+		return true
+	})
+	return tags
+}
+
+// embeddedName returns the field name of embedded type e.g *pkg.Base => Base
+func embeddedName(expr ast.Expr) string {
+	for {
+		switch t := expr.(type) {
+		case *ast.Ident:
+			return t.Name
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.SelectorExpr:
+			return t.Sel.Name
+		case *ast.IndexExpr:
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		default:
+			return ""
+		}
+	}
+}
+
+// tagChanges describes how the tag changed from before to after,
+// e.g add json:"name", reorder keys
+func tagChanges(before, after string) []string {
+	if before == after {
+		return nil
+	}
+	var beforeKVs, afterKVs []KeyValue
+	var err error
+	if before != "" {
+		if _, beforeKVs, err = ParseTag(before); err != nil {
+			return []string{"change tag"}
+		}
+	}
+	if after != "" {
+		if _, afterKVs, err = ParseTag(after); err != nil {
+			return []string{"change tag"}
+		}
+	}
+	beforeMap := map[string]KeyValue{}
+	for _, kv := range beforeKVs {
+		beforeMap[kv.Key] = kv
+	}
+	afterMap := map[string]bool{}
+	var changes []string
+	var sameKeys []string
+	for _, kv := range afterKVs {
+		afterMap[kv.Key] = true
+		old, ok := beforeMap[kv.Key]
+		switch {
+		case !ok:
+			changes = append(changes, "add "+kv.String())
+		case old.Value != kv.Value:
+			changes = append(changes, "change "+old.String()+" to "+kv.String())
+			sameKeys = append(sameKeys, kv.Key)
+		default:
+			sameKeys = append(sameKeys, kv.Key)
+		}
+	}
+	var keptOrder []string
+	for _, kv := range beforeKVs {
+		if !afterMap[kv.Key] {
+			changes = append(changes, "remove "+kv.Key)
+		} else {
+			keptOrder = append(keptOrder, kv.Key)
+		}
+	}
+	if strings.Join(keptOrder, " ") != strings.Join(sameKeys, " ") {
+		changes = append(changes, "reorder keys")
+	}
+	if len(changes) == 0 {
+		changes = append(changes, "align")
+	}
+	return changes
+}
+
+// planChanges returns the planned tag operations of every changed field from src to res
+func planChanges(filename string, src, res []byte) ([]string, error) {
+	fset := token.NewFileSet()
+	before, err := parser.ParseFile(fset, filename, src, parserMode)
+	if err != nil {
+		return nil, err
+	}
+	after, err := parser.ParseFile(fset, filename, res, parserMode)
+	if err != nil {
+		return nil, err
+	}
+	// the same path may appear many times e.g struct declared in functions,
+	// they are matched in source order
+	beforeTags := map[string][]string{}
+	for _, t := range fieldTags(before) {
+		beforeTags[t.Path] = append(beforeTags[t.Path], t.Tag)
+	}
+	var plan []string
+	for _, t := range fieldTags(after) {
+		var old string
+		if tags := beforeTags[t.Path]; len(tags) != 0 {
+			old = tags[0]
+			beforeTags[t.Path] = tags[1:]
+		}
+		if changes := tagChanges(old, t.Tag); len(changes) != 0 {
+			plan = append(plan, t.Path+": "+strings.Join(changes, ", "))
+		}
+	}
+	return plan, nil
+}
+
+func writePlan(w io.Writer, filename string, plan []string) {
+	if len(plan) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s:\n", filename)
+	for _, p := range plan {
+		fmt.Fprintf(w, "\t%s\n", p)
+	}
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestDryRunPlan(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	*dryRun = true
+	*tagSort = true
+	*splitMulti = true
+	*fill = "json=snake(:field)"
+	*rewrite = "xml:*->"
+
+	src := "package main\n\n" +
+		"type User struct {\n" +
+		"\tID int `yaml:\"id\" json:\"id\"`\n" +
+		"\tName string ``\n" +
+		"\tAge int `json:\"age\" xml:\"age\"`\n" +
+		"\tA, B int `json:\"\"`\n" +
+		"\tAddr struct {\n\t\tZip string ``\n\t} ``\n" +
+		"\tNick string `json:\"nick\"`\n" +
+		"}\n\n" +
+		"func f() {\n\tvar v struct {\n\t\tX int `json:\"x\" yaml:\"x\"`\n\t\tLongName int `json:\"long_name\"`\n\t}\n\t_ = v\n}\n"
+	var out bytes.Buffer
+	err := processFile("user.go", strings.NewReader(src), &out, false)
+	require.NoError(t, err)
+	assert.Equal(t, "user.go:\n"+
+		"\tUser.ID: reorder keys\n"+
+		"\tUser.Name: add json:\"name\"\n"+
+		"\tUser.Age: remove xml\n"+
+		"\tUser.A: change json:\"\" to json:\"a\"\n"+
+		"\tUser.B: change json:\"\" to json:\"b\"\n"+
+		"\tUser.Addr: add json:\"addr\"\n"+
+		"\tUser.Addr.Zip: add json:\"zip\"\n"+
+		"\tstruct.X: align\n", out.String())
+
+	// nothing is printed for the formatted file
+	out.Reset()
+	err = processFile("user.go", strings.NewReader("package main\n\ntype A struct {\n\tB int `json:\"b\"`\n}\n"), &out, false)
+	require.NoError(t, err)
+	assert.Equal(t, "", out.String())
+	assert.Equal(t, exitChanged, exitCode)
+	exitCode = 0
+}
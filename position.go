@@ -0,0 +1,194 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fieldIndexes returns the position of every field in st from 0, a name of the
+// multi-name field is a position, it's the :index of fill for the csv like encodings
+func fieldIndexes(st *ast.StructType) map[*ast.Field]int {
+	indexes := map[*ast.Field]int{}
+	if st.Fields == nil {
+		return indexes
+	}
+	i := 0
+	for _, field := range st.Fields.List {
+		indexes[field] = i
+		if len(field.Names) > 1 {
+			i += len(field.Names)
+		} else {
+			i++
+		}
+	}
+	return indexes
+}
+
+// layoutSpec is the external layout of the fixed-position encodings, the field name
+// e.g Record.Name or Name is mapped to the tag value e.g 1,10
+type layoutSpec map[string]string
+
+type cachedLayout struct {
+	modTime time.Time
+	size    int64
+	spec    layoutSpec
+}
+
+// layoutSpecs is the loaded layout files, the rules of every file and pass share them
+var layoutSpecs = struct {
+	sync.Mutex
+	m map[string]cachedLayout
+}{m: map[string]cachedLayout{}}
+
+// loadLayoutSpec reads the json object of the layout file, the file changed since it's
+// loaded is read again, so the daemon doesn't serve the stale layout
+func loadLayoutSpec(filename string) (layoutSpec, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	layoutSpecs.Lock()
+	defer layoutSpecs.Unlock()
+	if cached, ok := layoutSpecs.m[filename]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.spec, nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	spec := layoutSpec{}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("layout %s: %w", filename, err)
+	}
+	layoutSpecs.m[filename] = cachedLayout{modTime: info.ModTime(), size: info.Size(), spec: spec}
+	return spec, nil
+}
+
+// lookup returns the value of the field of struct, the qualified name is preferred
+func (l layoutSpec) lookup(structName, field string) (string, bool) {
+	if value, ok := l[structName+"."+field]; ok && structName != "" {
+		return value, true
+	}
+	value, ok := l[field]
+	return value, ok
+}
+
+// parseLayoutRule parses the whole rule layout('file') and loads the layout file
+func parseLayoutRule(rule string) (layoutSpec, error) {
+	rule = strings.TrimSpace(rule)
+	arg := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(rule, "layout("), ")"))
+	if !strings.HasSuffix(rule, ")") || len(arg) < 2 || (arg[0] != '\'' && arg[0] != '"') || arg[len(arg)-1] != arg[0] {
+		return nil, &ruleError{Text: rule, Err: fmt.Errorf("layout() needs the quoted layout file e.g layout('layout.json')")}
+	}
+	spec, err := loadLayoutSpec(arg[1 : len(arg)-1])
+	if err != nil {
+		return nil, &ruleError{Text: rule, Err: err}
+	}
+	return spec, nil
+}
+
+// fieldPosition is the position of a field in a position key, the index is the range
+// of one position
+type fieldPosition struct {
+	field      *ast.Field
+	start, end int
+}
+
+// parsePosition parses the position of the tag value, the index e.g 3 or the range
+// e.g 1,10, ok is false for the names e.g csv:"name" and -
+func parsePosition(value string) (start, end int, ok bool) {
+	parts := strings.Split(value, ",")
+	start, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, false
+	}
+	end = start
+	if len(parts) > 1 {
+		if n, err := strconv.Atoi(strings.TrimSpace(parts[1])); err == nil {
+			end = n
+		}
+	}
+	return start, end, true
+}
+
+// checkPositions reports the gaps and the overlaps of the positions of key in the
+// fields of a struct, the positions are ordered by start and compared with the one
+// ends last before them, the errors are at the later field
+func checkPositions(fs *token.FileSet, key string, fields []*ast.Field) tagDockerErr {
+	var positions []fieldPosition
+	var errs tagDockerErr
+	for _, field := range fields {
+		_, keyValues, err := ParseTag(field.Tag.Value)
+		if err != nil {
+			continue
+		}
+		for _, kv := range keyValues {
+			if kv.Key != key {
+				continue
+			}
+			if start, end, ok := parsePosition(kv.Value); ok {
+				if end < start {
+					errs = append(errs, NewAstError(fs, field.Tag, fmt.Errorf("%s position %d,%d ends before it starts", key, start, end)))
+					continue
+				}
+				positions = append(positions, fieldPosition{field: field, start: start, end: end})
+			}
+		}
+	}
+	sort.SliceStable(positions, func(i, j int) bool {
+		return positions[i].start < positions[j].start
+	})
+	var last fieldPosition
+	if len(positions) != 0 {
+		last = positions[0]
+	}
+	for i := 1; i < len(positions); i++ {
+		cur := positions[i]
+		prev := last
+		if cur.end > last.end {
+			last = cur
+		}
+		switch {
+		case cur.start <= prev.end:
+			errs = append(errs, NewAstError(fs, cur.field.Tag, fmt.Errorf("%s position of %s overlaps %s", key, positionDesc(cur), positionDesc(prev))))
+		case cur.start > prev.end+1:
+			errs = append(errs, NewAstError(fs, cur.field.Tag, fmt.Errorf("%s positions %s are missing between %s and %s", key, rangeDesc(prev.end+1, cur.start-1), positionDesc(prev), positionDesc(cur))))
+		}
+	}
+	return errs
+}
+
+// positionDesc describes the field and its position e.g Name (1,10)
+func positionDesc(p fieldPosition) string {
+	name := getFieldName(p.field)
+	if name == "" {
+		name = embeddedName(p.field.Type)
+	}
+	if p.start == p.end {
+		return fmt.Sprintf("%s (%d)", name, p.start)
+	}
+	return fmt.Sprintf("%s (%d,%d)", name, p.start, p.end)
+}
+
+func rangeDesc(start, end int) string {
+	if start == end {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d-%d", start, end)
+}
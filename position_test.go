@@ -0,0 +1,89 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLayoutFill(t *testing.T) {
+	initParserMode()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	layout := filepath.Join(dir, "layout.json")
+	require.NoError(t, ioutil.WriteFile(layout, []byte(`{"Record.Name": "1,10", "Age": "11,13"}`), 0644))
+
+	src := "package wire\n\ntype Record struct {\n\tName string ``\n\tAge int ``\n\tNote string `fixed:\"14,20\"`\n}\n\n" +
+		"type Header struct {\n\tName string ``\n}\n"
+	var buf bytes.Buffer
+	opts := Options{Align: true, Fill: "fixed=layout('" + layout + "')", Pattern: ".*", StructPattern: ".*"}
+	require.NoError(t, formatSource(&buf, "wire.go", []byte(src), opts))
+	assert.Equal(t, "package wire\n\ntype Record struct {\n"+
+		"\tName string `fixed:\"1,10\"`\n"+
+		"\tAge  int    `fixed:\"11,13\"`\n"+
+		"\tNote string `fixed:\"14,20\"`\n}\n\n"+
+		// Header.Name isn't in the layout, the qualified name is required
+		"type Header struct {\n\tName string ``\n}\n", buf.String())
+
+	// the changed layout file is loaded again
+	require.NoError(t, ioutil.WriteFile(layout, []byte(`{"Record.Name": "1,12", "Age": "13,15"}`), 0644))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(layout, later, later))
+	buf.Reset()
+	require.NoError(t, formatSource(&buf, "wire.go", []byte("package wire\n\ntype Record struct {\n\tName string ``\n}\n"), opts))
+	assert.Equal(t, "package wire\n\ntype Record struct {\n\tName string `fixed:\"1,12\"`\n}\n", buf.String())
+
+	_, err = newTagFill(nil, nil, nil, "fixed=layout(layout.json)", false)
+	assert.EqualError(t, err, `-f "fixed=layout(layout.json)":6: layout() needs the quoted layout file e.g layout('layout.json') (near "layout(layout.json)")`)
+	_, err = newTagFill(nil, nil, nil, "fixed=upper(layout('a.json'))", false)
+	assert.EqualError(t, err, `-f "fixed=upper(layout('a.json'))":12: layout() must be the whole rule e.g fixed=layout('layout.json') (near "layout('a.json')")`)
+}
+
+func TestPositionKeys(t *testing.T) {
+	initParserMode()
+	check := func(src string) error {
+		var buf bytes.Buffer
+		return formatSource(&buf, "wire.go", []byte(src), Options{PositionKeys: "csv|fixed", Pattern: ".*", StructPattern: ".*"})
+	}
+	assert.NoError(t, check("package wire\n\ntype Record struct {\n"+
+		"\tName string `csv:\"0\" fixed:\"1,10\"`\n"+
+		"\tAge  int    `csv:\"1\" fixed:\"11,13\"`\n"+
+		"\tNote string `csv:\"note\" fixed:\"-\"`\n}\n"))
+
+	err := check("package wire\n\ntype Record struct {\n" +
+		"\tName string `fixed:\"1,10\"`\n" +
+		"\tAge  int    `fixed:\"8,12\"`\n" +
+		"\tCity string `fixed:\"16,20\"`\n}\n")
+	assert.EqualError(t, err, "wire.go:5:14: fixed position of Age (8,12) overlaps Name (1,10)\n"+
+		"wire.go:6:14: fixed positions 13-15 are missing between Age (8,12) and City (16,20)")
+
+	// the positions are compared with the one ends last, not the one before them
+	err = check("package wire\n\ntype Record struct {\n" +
+		"\tA string `fixed:\"1,20\"`\n" +
+		"\tB string `fixed:\"5,6\"`\n" +
+		"\tC string `fixed:\"8,10\"`\n" +
+		"\tD string `fixed:\"21,30\"`\n}\n")
+	assert.EqualError(t, err, "wire.go:5:11: fixed position of B (5,6) overlaps A (1,20)\n"+
+		"wire.go:6:11: fixed position of C (8,10) overlaps A (1,20)")
+
+	// the positions are ordered by start, not by the field order
+	err = check("package wire\n\ntype Record struct {\n" +
+		"\tAge  int    `csv:\"2\"`\n" +
+		"\tName string `csv:\"0\"`\n" +
+		"\tCode string `csv:\"5,3\"`\n}\n")
+	assert.EqualError(t, err, "wire.go:6:14: csv position 5,3 ends before it starts\n"+
+		"wire.go:4:14: csv positions 1 are missing between Name (0) and Age (2)")
+}
@@ -0,0 +1,375 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// tagPreset is the conventions of a tag key, it gives the key a default fill rule,
+// a sort position and the value validation
+type tagPreset struct {
+	Key string
+	// Fill is the default fill rule, used when -f has no rule for the key
+	Fill string
+	// Options are the known options after the name, e.g omitempty of json:"name,omitempty"
+	Options []string
+	// Validate checks the value, nil means the value is a name with the known options
+	Validate func(p *tagPreset, value string) error
+}
+
+// keepNameFill keeps the existing name and options, fills the snake case field name when it's empty
+const keepNameFill = "or(:tag_basic, snake(:field))+:tag_extra"
+
+var tagPresets = map[string]*tagPreset{}
+
+func registerPreset(p *tagPreset) {
+	tagPresets[p.Key] = p
+}
+
+func init() {
+	registerPreset(&tagPreset{
+		Key:     "json",
+		Fill:    keepNameFill,
+		Options: []string{"omitempty", "omitzero", "string"},
+	})
+	// github.com/vmihailenco/msgpack
+	registerPreset(&tagPreset{
+		Key:     "msgpack",
+		Fill:    keepNameFill,
+		Options: []string{"omitempty", "as_array", "inline", "noinline", "intern"},
+	})
+	// github.com/fxamacker/cbor
+	registerPreset(&tagPreset{
+		Key:     "cbor",
+		Fill:    keepNameFill,
+		Options: []string{"omitempty", "omitzero", "keyasint", "toarray"},
+	})
+	// github.com/gocarina/gocsv, the value is the column name, default=value is the value
+	// of the empty cell
+	registerPreset(&tagPreset{
+		Key:      "csv",
+		Fill:     keepNameFill,
+		Options:  []string{"omitempty", "default"},
+		Validate: checkCSV,
+	})
+	// github.com/hamba/avro, the value is the avro field name only
+	registerPreset(&tagPreset{
+		Key:      "avro",
+		Fill:     "or(:tag, snake(:field))",
+		Validate: checkAvro,
+	})
+	// github.com/parquet-go/parquet-go
+	registerPreset(&tagPreset{
+		Key:  "parquet",
+		Fill: keepNameFill,
+		Options: []string{
+			"optional", "list", "split", "enum", "uuid", "json", "bson", "date", "time", "timestamp", "decimal", "id",
+			"plain", "dict", "delta", "snappy", "gzip", "brotli", "lz4", "zstd", "uncompressed",
+		},
+		Validate: checkParquet,
+	})
+	// github.com/redis/go-redis and github.com/redis/rueidis/om object mapping,
+	// the rueidis key and version fields are redis:",key" and redis:",ver" without name
+	registerPreset(&tagPreset{
+		Key:     "redis",
+		Fill:    "or(:tag, snake(:field))",
+		Options: []string{"key", "ver", "exat"},
+	})
+	// github.com/vingarcia/ksql
+	registerPreset(&tagPreset{
+		Key:     "ksql",
+		Fill:    keepNameFill,
+		Options: []string{"json", "timeNowUTC", "timeNowUTC/skipUpdates", "skipInserts", "skipUpdates"},
+	})
+	// github.com/uptrace/bun, the options may have value e.g type:varchar(64), bun.BaseModel
+	// has no column name e.g bun:"table:users,alias:u"
+	registerPreset(&tagPreset{
+		Key:  "bun",
+		Fill: keepNameFill,
+		Options: []string{
+			"pk", "autoincrement", "identity", "notnull", "nullzero", "unique", "default", "type", "array",
+			"json_use_number", "msgpack", "scanonly", "soft_delete", "skipupdate", "composite", "hstore", "multirange",
+			"rel", "join", "m2m", "polymorphic", "on_delete", "on_update", "table", "alias", "select", "extend", "embed",
+		},
+		Validate: checkSQLOptions,
+	})
+	// github.com/go-pg/pg, tableName struct{} has the table name e.g pg:"users,alias:u"
+	registerPreset(&tagPreset{
+		Key:  "pg",
+		Fill: keepNameFill,
+		Options: []string{
+			"pk", "notnull", "unique", "use_zero", "default", "type", "array", "hstore", "composite",
+			"json_use_number", "msgpack", "rel", "join_fk", "fk", "on_delete", "on_update", "many2many",
+			"polymorphic", "discard_unknown_columns", "alias", "soft_delete", "partition_by", "select",
+		},
+		Validate: checkSQLOptions,
+	})
+}
+
+// checkCSV checks the options of gocsv, default has the value after '='
+func checkCSV(p *tagPreset, value string) error {
+	if value == "-" {
+		return nil
+	}
+	for _, opt := range strings.Split(value, ",")[1:] {
+		name := strings.TrimSpace(opt)
+		if idx := strings.IndexByte(name, '='); idx != -1 {
+			if name[:idx] != "default" {
+				return fmt.Errorf("csv option %s has no value", name[:idx])
+			}
+			continue
+		}
+		if name == "default" {
+			return fmt.Errorf("csv option default needs the value e.g default=0")
+		}
+		if name != "" && !p.knownOption(name) {
+			return fmt.Errorf("unknown csv option %s", name)
+		}
+	}
+	return nil
+}
+
+// checkSQLOptions checks the orm options, the option may have value after ':',
+// the first element is column name if it has no ':'
+func checkSQLOptions(p *tagPreset, value string) error {
+	if value == "-" {
+		return nil
+	}
+	options, err := splitOptions(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s option %s", p.Key, err)
+	}
+	if !strings.Contains(options[0], ":") {
+		options = options[1:]
+	}
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+		name := strings.SplitN(opt, ":", 2)[0]
+		if !p.knownOption(name) {
+			return fmt.Errorf("unknown %s option %s", p.Key, name)
+		}
+	}
+	return nil
+}
+
+// commonTagKeys are the well known tag keys besides the preset keys, they are allowed by -strict-keys
+var commonTagKeys = []string{
+	"json", "xml", "yaml", "toml", "bson", "db", "gorm", "sql", "mapstructure", "env", "default",
+	"validate", "binding", "form", "query", "uri", "header", "protobuf", "protobuf_oneof", "asn1",
+}
+
+// knownTagKeys returns the keys allowed by -strict-keys, extra are the user keys e.g a|b
+func knownTagKeys(extra string) map[string]bool {
+	known := map[string]bool{}
+	for _, key := range commonTagKeys {
+		known[key] = true
+	}
+	for key := range tagPresets {
+		known[key] = true
+	}
+	for _, key := range strings.Split(extra, "|") {
+		if key = strings.TrimSpace(key); key != "" {
+			known[key] = true
+		}
+	}
+	return known
+}
+
+var avroName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func checkAvro(p *tagPreset, value string) error {
+	if value == "" || value == "-" || avroName.MatchString(value) {
+		return nil
+	}
+	return fmt.Errorf("invalid avro name %q", value)
+}
+
+// splitOptions splits value by ',' outside the parentheses and quotes,
+// e.g "amount,decimal(2,10),default:'a,b'" => [amount decimal(2,10) default:'a,b']
+func splitOptions(value string) ([]string, error) {
+	var options []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth < 0 {
+				return nil, errors.New(value[start:])
+			}
+		case c == ',' && depth == 0:
+			options = append(options, value[start:i])
+			start = i + 1
+		}
+	}
+	if depth != 0 || quote != 0 {
+		return nil, errors.New(value[start:])
+	}
+	return append(options, value[start:]), nil
+}
+
+var parquetTimeUnits = map[string]bool{"millisecond": true, "microsecond": true, "nanosecond": true}
+
+// checkParquet checks the parquet options, some of them have arguments,
+// e.g timestamp(millisecond), id(1), decimal(2,10)
+func checkParquet(p *tagPreset, value string) error {
+	if value == "-" {
+		return nil
+	}
+	options, err := splitOptions(value)
+	if err != nil {
+		return fmt.Errorf("invalid parquet option %s", err)
+	}
+	for _, opt := range options[1:] {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+		name, args := opt, ""
+		if i := strings.IndexByte(opt, '('); i != -1 {
+			if !strings.HasSuffix(opt, ")") {
+				return fmt.Errorf("invalid parquet option %s", opt)
+			}
+			name, args = opt[:i], opt[i+1:len(opt)-1]
+		}
+		if !p.knownOption(name) {
+			return fmt.Errorf("unknown parquet option %s", name)
+		}
+		if name != opt {
+			if err := checkParquetArgs(name, args); err != nil {
+				return fmt.Errorf("invalid parquet option %s: %s", opt, err)
+			}
+		}
+	}
+	return nil
+}
+
+func checkParquetArgs(name, args string) error {
+	switch name {
+	case "time", "timestamp":
+		if !parquetTimeUnits[args] {
+			return fmt.Errorf("unknown time unit %s", args)
+		}
+	case "id":
+		if _, err := strconv.Atoi(args); err != nil {
+			return fmt.Errorf("id must be integer")
+		}
+	case "decimal":
+		parts := strings.Split(args, ",")
+		if len(parts) < 2 || len(parts) > 3 {
+			return fmt.Errorf("decimal needs scale and precision")
+		}
+		for _, part := range parts[:2] {
+			if _, err := strconv.Atoi(strings.TrimSpace(part)); err != nil {
+				return fmt.Errorf("decimal scale and precision must be integer")
+			}
+		}
+	default:
+		return fmt.Errorf("%s has no arguments", name)
+	}
+	return nil
+}
+
+// parsePresets returns the presets of names e.g json|msgpack in order
+func parsePresets(names string) ([]*tagPreset, error) {
+	var presets []*tagPreset
+	for _, name := range strings.Split(names, "|") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		p, ok := tagPresets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown preset %s", name)
+		}
+		presets = append(presets, p)
+	}
+	return presets, nil
+}
+
+// Check validates the tag value of preset key
+func (p *tagPreset) Check(value string) error {
+	if p.Validate != nil {
+		return p.Validate(p, value)
+	}
+	return checkNameOptions(p, value)
+}
+
+// checkNameOptions checks value is the form name,opt1,opt2 and the options are known
+func checkNameOptions(p *tagPreset, value string) error {
+	if value == "-" {
+		return nil
+	}
+	for _, opt := range strings.Split(value, ",")[1:] {
+		if opt = strings.TrimSpace(opt); opt != "" && !p.knownOption(opt) {
+			return fmt.Errorf("unknown %s option %s", p.Key, opt)
+		}
+	}
+	return nil
+}
+
+func (p *tagPreset) knownOption(opt string) bool {
+	for _, o := range p.Options {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// presetFill returns the fill rule with the presets default rules, the rules in fill win
+func presetFill(fill string, presets []*tagPreset) (string, error) {
+	if len(presets) == 0 {
+		return fill, nil
+	}
+	rules, err := parseFieldRule(fill)
+	if err != nil {
+		return "", newFlagError("-f", fill, err)
+	}
+	var cells []string
+	if strings.TrimSpace(fill) != "" {
+		cells = append(cells, fill)
+	}
+	for _, p := range presets {
+		if _, ok := rules[p.Key]; !ok && p.Fill != "" {
+			cells = append(cells, p.Key+"="+p.Fill)
+		}
+	}
+	return strings.Join(cells, "|"), nil
+}
+
+// presetSortOrder appends the presets keys to order
+func presetSortOrder(order []string, presets []*tagPreset) []string {
+	exist := map[string]bool{}
+	for _, key := range order {
+		exist[key] = true
+	}
+	for _, p := range presets {
+		if !exist[p.Key] {
+			order = append(order, p.Key)
+		}
+	}
+	return order
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"strings"
+)
+
+// previewMain prints every struct of the files or packages in paths with its tags as
+// an aligned table of the fields and their key values, nothing is modified, it's for
+// the code review discussions of wire formats, the structs are selected like the
+// formatting by -struct or -sp and -sP
+//
+//	tagfmt -struct User preview ./models
+func previewMain(args []string) int {
+	fs := flag.NewFlagSet("preview", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	filter, err := optionsFromFlags().Filter()
+	if err != nil {
+		commandError("preview", err)
+		return errorClass(err)
+	}
+	files, err := packageFiles(paths)
+	if err != nil {
+		commandError("preview", err)
+		return errorClass(err)
+	}
+	var structs []shownStruct
+	for _, filename := range files {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, filename, nil, parserMode)
+		if err != nil {
+			report(err)
+			return errorClass(err)
+		}
+		structs = append(structs, previewStructs(fset, f, filter)...)
+	}
+	if err := writePreview(os.Stdout, structs); err != nil {
+		commandError("preview", err)
+		return errorClass(err)
+	}
+	return exitOK
+}
+
+// previewStructs returns the struct types of f selected by filter
+func previewStructs(fset *token.FileSet, f *ast.File, filter *Filter) []shownStruct {
+	var structs []shownStruct
+	ast.Inspect(f, func(node ast.Node) bool {
+		spec, ok := node.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		if st := indirectStruct(spec.Type); st == nil || !filter.Struct(spec.Name.Name) {
+			return false
+		}
+		structs = append(structs, newShownStruct(fset, spec))
+		return false
+	})
+	return structs
+}
+
+// writePreview writes the structs as the tables of field, type and a column per key
+// in the order of their first appearance
+func writePreview(w io.Writer, structs []shownStruct) error {
+	return writeTable(w, 2, func(tw io.Writer) {
+		for i, st := range structs {
+			if i != 0 {
+				// the empty line ends the column block, the structs are aligned separately
+				fmt.Fprintln(tw)
+			}
+			var keys []string
+			index := map[string]int{}
+			for _, f := range st.Fields {
+				for _, k := range f.Keys {
+					if _, ok := index[k.Key]; !ok {
+						index[k.Key] = len(keys)
+						keys = append(keys, k.Key)
+					}
+				}
+			}
+			fmt.Fprintf(tw, "%s %s\n", st.Name, st.Pos)
+			fmt.Fprintf(tw, "FIELD\tTYPE\t%s\n", strings.Join(keys, "\t"))
+			for _, f := range st.Fields {
+				cells := []string{f.Path, f.Type}
+				if f.Error != "" {
+					cells = append(cells, "! "+f.Error)
+				} else {
+					values := make([]string, len(keys))
+					for _, k := range f.Keys {
+						values[index[k.Key]] = strings.Join(append([]string{k.Value}, k.Options...), ",")
+					}
+					cells = append(cells, values...)
+				}
+				fmt.Fprintf(tw, "%s\n", strings.Join(cells, "\t"))
+			}
+		}
+	})
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestPreview(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	src := "package user\n\n" +
+		"type User struct {\n" +
+		"\tID      int    `json:\"id\" gorm:\"primary_key\"`\n" +
+		"\tName    string `json:\"name,omitempty\" gorm:\"column:name\"`\n" +
+		"\tAddress struct {\n\t\tZip string `json:\"zip\" xml:\"zip\"`\n\t} `json:\"address\"`\n" +
+		"\tAge int\n" +
+		"}\n\n" +
+		"type Bad struct {\n\tX []*int `json`\n}\n\n" +
+		"type Skipped struct {\n\tX int `json:\"x\"`\n}\n"
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "user.go", src, parserMode)
+	require.NoError(t, err)
+	*inverseStructPattern = "Skipped"
+	filter, err := optionsFromFlags().Filter()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, writePreview(&buf, previewStructs(fset, f, filter)))
+	assert.Equal(t, "User user.go:3:6\n"+
+		"FIELD        TYPE         json            gorm         xml\n"+
+		"ID           int          id              primary_key\n"+
+		"Name         string       name,omitempty  column:name\n"+
+		"Address      struct{...}  address\n"+
+		"Address.Zip  string       zip                          zip\n"+
+		"Age          int\n"+
+		"\n"+
+		"Bad user.go:12:6\n"+
+		"FIELD  TYPE\n"+
+		"X      []*int  ! invalid tag\n", buf.String())
+
+	// -struct selects one struct like the formatting
+	*structName = "Skipped"
+	filter, err = optionsFromFlags().Filter()
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, writePreview(&buf, previewStructs(fset, f, filter)))
+	assert.Equal(t, "Skipped user.go:16:6\n"+
+		"FIELD  TYPE  json\n"+
+		"X      int   x\n", buf.String())
+}
@@ -0,0 +1,274 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// promotedField is one field of the effective field set of struct
+type promotedField struct {
+	Name   string // the key name when the key is given, otherwise the field name
+	Var    *types.Var
+	Tag    string
+	From   []string // the embedded fields it's promoted through
+	index  []int
+	tagged bool
+}
+
+// promotedMain prints the effective field set of structs in the packages of paths, the
+// fields promoted from embedded structs are included, -struct or -sp and -sP select the
+// structs, the root of go.work workspace loads the packages of every module it uses
+//
+//	tagfmt -struct User promoted [-key json] [path ...]
+func promotedMain(args []string) int {
+	fs := flag.NewFlagSet("promoted", flag.ContinueOnError)
+	key := fs.String("key", "", "use the tag key's name rules e.g json, the fields with \"-\" are omitted")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	filter, err := optionsFromFlags().Filter()
+	if err != nil {
+		commandError("promoted", err)
+		return errorClass(err)
+	}
+	dirs, err := typedPackageDirs(paths)
+	if err != nil {
+		commandError("promoted", err)
+		return errorClass(err)
+	}
+	for _, dir := range dirs {
+		pkg, err := loadTypedPackage(dir)
+		if err != nil {
+			commandError("promoted", err)
+			return errorClass(err)
+		}
+		if err := writePromoted(os.Stdout, pkg, filter, *key); err != nil {
+			commandError("promoted", err)
+			return errorClass(err)
+		}
+	}
+	return exitOK
+}
+
+// loadTypedPackage parses and type checks the package in dir, the imported packages
+// are type checked from source, they are resolved in the module or go.work workspace
+// of dir instead of the working directory
+func loadTypedPackage(dir string) (*types.Package, error) {
+	pkg, _, err := typeCheckDir(dir, nil)
+	return pkg, err
+}
+
+// typeCheckDir type checks the package of dir like loadTypedPackage and records the
+// types of the parsed files in info, info can be nil
+func typeCheckDir(dir string, info *types.Info) (*types.Package, []*ast.File, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parserMode)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, f)
+	}
+	pkg, err := checkFiles(dir, bp.ImportPath, fset, files, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkg, files, nil
+}
+
+// checkFiles type checks the files of package path, the imports are resolved in dir,
+// the package is checked completely even if there are errors, the first one is returned
+func checkFiles(dir, path string, fset *token.FileSet, files []*ast.File, info *types.Info) (*types.Package, error) {
+	// the source importer runs go list in build.Default.Dir
+	defer func(wd string) { build.Default.Dir = wd }(build.Default.Dir)
+	build.Default.Dir = dir
+	var firstErr error
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			if firstErr == nil {
+				firstErr = err
+			}
+		},
+	}
+	pkg, _ := conf.Check(path, fset, files, info)
+	return pkg, firstErr
+}
+
+// writePromoted writes the fields of the structs in pkg selected by filter as aligned
+// rows, the padding of the untagged fields at the line end is trimmed
+func writePromoted(w io.Writer, pkg *types.Package, filter *Filter, key string) error {
+	qualifier := types.RelativeTo(pkg)
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, tabWidth, 1, ' ', tabwriter.TabIndent)
+	for _, name := range pkg.Scope().Names() {
+		obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok || obj.IsAlias() || !filter.Struct(name) {
+			continue
+		}
+		st, ok := obj.Type().Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		fields, conflicts := promotedFields(st, key)
+		fmt.Fprintf(tw, "%s.%s\n", pkg.Name(), name)
+		for _, f := range fields {
+			fmt.Fprintf(tw, "\t%s\t%s\t", f.Name, types.TypeString(f.Var.Type(), qualifier))
+			if f.Tag != "" {
+				fmt.Fprintf(tw, "`%s`", f.Tag)
+			}
+			if len(f.From) != 0 {
+				fmt.Fprintf(tw, "\t// from %s", strings.Join(f.From, "."))
+			}
+			fmt.Fprintln(tw)
+		}
+		for _, c := range conflicts {
+			fmt.Fprintf(tw, "\t// %s\n", c)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		if _, err := fmt.Fprintln(w, strings.TrimRight(scanner.Text(), " \t")); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// promotedFields returns the fields of st including the promoted ones like encoding/json,
+// the shallower field hides the deeper one, the fields with the same name at the same depth
+// are dropped except only one of them is tagged, the dropped names are reported by conflicts
+func promotedFields(st *types.Struct, key string) (fields []promotedField, conflicts []string) {
+	type embedded struct {
+		st    *types.Struct
+		from  []string
+		index []int
+	}
+	current := []embedded{{st: st}}
+	visited := map[*types.Struct]bool{}
+	hidden := map[string]bool{}
+	for len(current) != 0 {
+		var next []embedded
+		level := map[string][]promotedField{}
+		var names []string
+		for _, e := range current {
+			// the struct embedded in an upper level has been expanded, e.g the recursive
+			// embedding through pointer
+			if visited[e.st] {
+				continue
+			}
+			for i := 0; i < e.st.NumFields(); i++ {
+				v := e.st.Field(i)
+				tag := e.st.Tag(i)
+				name := v.Name()
+				tagged := false
+				if key != "" {
+					value := reflect.StructTag(tag).Get(key)
+					if value == "-" {
+						continue
+					}
+					if n := strings.Split(value, ",")[0]; n != "" {
+						name, tagged = n, true
+					}
+				}
+				index := append(append([]int(nil), e.index...), i)
+				if v.Embedded() && !tagged {
+					typ := v.Type()
+					if ptr, ok := typ.(*types.Pointer); ok {
+						typ = ptr.Elem()
+					}
+					if sub, ok := typ.Underlying().(*types.Struct); ok {
+						next = append(next, embedded{st: sub, from: append(append([]string(nil), e.from...), v.Name()), index: index})
+						continue
+					}
+				}
+				if !v.Exported() {
+					continue
+				}
+				if _, ok := level[name]; !ok {
+					names = append(names, name)
+				}
+				level[name] = append(level[name], promotedField{Name: name, Var: v, Tag: tag, From: e.from, index: index, tagged: tagged})
+			}
+		}
+		for _, name := range names {
+			if hidden[name] {
+				continue
+			}
+			hidden[name] = true
+			candidates := level[name]
+			if len(candidates) > 1 && key != "" {
+				var tagged []promotedField
+				for _, c := range candidates {
+					if c.tagged {
+						tagged = append(tagged, c)
+					}
+				}
+				if len(tagged) != 0 {
+					candidates = tagged
+				}
+			}
+			if len(candidates) == 1 {
+				fields = append(fields, candidates[0])
+				continue
+			}
+			var from []string
+			for _, c := range candidates {
+				from = append(from, strings.Join(append(append([]string(nil), c.From...), c.Var.Name()), "."))
+			}
+			conflicts = append(conflicts, fmt.Sprintf("%s: conflict between %s, dropped", name, strings.Join(from, " and ")))
+		}
+		for _, e := range current {
+			visited[e.st] = true
+		}
+		current = next
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		a, b := fields[i].index, fields[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return fields, conflicts
+}
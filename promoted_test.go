@@ -0,0 +1,132 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestPromotedFields(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "user.go"), []byte("package user\n\n"+
+		"type Base struct {\n\tID int `json:\"id\"`\n\tsecret string\n}\n\n"+
+		"type Contact struct {\n\tEmail string `json:\"email\"`\n\tPhone string `json:\"-\"`\n}\n\n"+
+		"type Profile struct {\n\tEmail string\n\tBio string `json:\"bio\"`\n}\n\n"+
+		"type Node struct {\n\t*Node\n\tValue int `json:\"value\"`\n}\n\n"+
+		"type User struct {\n\tBase\n\t*Contact\n\tProfile\n\tName string `json:\"name\"`\n\tNamed Base `json:\"named\"`\n}\n\n"+
+		"type Kind int\n\n"+
+		"type Event struct {\n\tKind\n\tName string `json:\"name\"`\n}\n"), 0644)
+	require.NoError(t, err)
+	pkg, err := loadTypedPackage(dir)
+	require.NoError(t, err)
+
+	*structPattern = "^(User|Node)$"
+	filter, err := optionsFromFlags().Filter()
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, writePromoted(&buf, pkg, filter, "json"))
+	assert.Equal(t, "user.Node\n"+
+		"\tvalue int `json:\"value\"`\n"+
+		"user.User\n"+
+		"\tid    int    `json:\"id\"`    // from Base\n"+
+		"\temail string `json:\"email\"` // from Contact\n"+
+		"\tEmail string                // from Profile\n"+
+		"\tbio   string `json:\"bio\"`   // from Profile\n"+
+		"\tname  string `json:\"name\"`\n"+
+		"\tnamed Base   `json:\"named\"`\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, writePromoted(&buf, pkg, filter, ""))
+	assert.Equal(t, "user.Node\n"+
+		"\tValue int `json:\"value\"`\n"+
+		"user.User\n"+
+		"\tID    int    `json:\"id\"`  // from Base\n"+
+		"\tPhone string `json:\"-\"`   // from Contact\n"+
+		"\tBio   string `json:\"bio\"` // from Profile\n"+
+		"\tName  string `json:\"name\"`\n"+
+		"\tNamed Base   `json:\"named\"`\n"+
+		"\t// Email: conflict between Contact.Email and Profile.Email, dropped\n", buf.String())
+
+	// -struct selects one struct like the formatting, the struct patterns are ignored
+	*structName = "Node"
+	filter, err = optionsFromFlags().Filter()
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, writePromoted(&buf, pkg, filter, "json"))
+	assert.Equal(t, "user.Node\n"+
+		"\tvalue int `json:\"value\"`\n", buf.String())
+
+	// the untagged embedded field has no trailing padding
+	*structName = "Event"
+	filter, err = optionsFromFlags().Filter()
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, writePromoted(&buf, pkg, filter, "json"))
+	assert.Equal(t, "user.Event\n"+
+		"\tKind Kind\n"+
+		"\tname string `json:\"name\"`\n", buf.String())
+}
+
+func TestPromotedWorkspace(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go command is needed to resolve the workspace modules")
+	}
+	// -mod=mod isn't allowed in workspace mode
+	t.Setenv("GOFLAGS", "")
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"go.work":           "go 1.21\n\nuse (\n\t./base // the shared types\n\t./api\n)\n",
+		"base/go.mod":       "module example.com/base\n\ngo 1.21\n",
+		"base/base.go":      "package base\n\ntype Base struct {\n\tID int `json:\"id\"`\n}\n",
+		"api/go.mod":        "module example.com/api\n\ngo 1.21\n",
+		"api/user/user.go":  "package user\n\nimport \"example.com/base\"\n\ntype User struct {\n\tbase.Base\n\tName string `json:\"name\"`\n}\n",
+		"api/testdata/x.go": "package broken\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	modules, err := workspaceModules(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "base"), filepath.Join(dir, "api")}, modules)
+	dirs, err := typedPackageDirs([]string{dir})
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "base"), filepath.Join(dir, "api", "user")}, dirs)
+
+	*structPattern = "^User$"
+	filter, err := optionsFromFlags().Filter()
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	for _, dir := range dirs {
+		pkg, err := loadTypedPackage(dir)
+		require.NoError(t, err)
+		require.NoError(t, writePromoted(&buf, pkg, filter, "json"))
+	}
+	assert.Equal(t, "user.User\n"+
+		"\tid   int    `json:\"id\"` // from Base\n"+
+		"\tname string `json:\"name\"`\n", buf.String())
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// protoInternalFields are the unexported fields of the messages generated by
+// protoc-gen-go, the protoimpl ones of APIv2 and the XXX_ ones of APIv1
+var protoInternalFields = map[string]string{
+	"state":                "MessageState",
+	"sizeCache":            "SizeCache",
+	"unknownFields":        "UnknownFields",
+	"XXX_NoUnkeyedLiteral": "",
+	"XXX_unrecognized":     "",
+	"XXX_sizecache":        "",
+}
+
+// isProtoGenerated reports whether the struct is a message or oneof wrapper generated
+// by protoc-gen-go, they are detected by the shape instead of the generated file header,
+// which is lost when the file is post-processed by the other tools, the protobuf runtime
+// expects their tags as generated so they are only formatted when the fill rules read
+// :proto_name, see Filter.Proto
+func isProtoGenerated(name string, n *ast.StructType) bool {
+	if n.Fields == nil {
+		return false
+	}
+	for _, field := range n.Fields.List {
+		for _, ident := range field.Names {
+			typeName, ok := protoInternalFields[ident.Name]
+			if !ok {
+				continue
+			}
+			if typeName == "" {
+				return true
+			}
+			if sel, ok := field.Type.(*ast.SelectorExpr); ok && sel.Sel.Name == typeName {
+				return true
+			}
+		}
+	}
+	// the oneof wrapper e.g type User_Email struct { Email string `protobuf:"bytes,2,opt,name=email,proto3,oneof"` }
+	if !strings.Contains(name, "_") || len(n.Fields.List) != 1 || n.Fields.List[0].Tag == nil {
+		return false
+	}
+	_, keyValues, err := ParseTag(n.Fields.List[0].Tag.Value)
+	if err != nil {
+		return false
+	}
+	for _, kv := range keyValues {
+		if kv.Key == "protobuf" && hasOption(kv.Value, "oneof") {
+			return true
+		}
+	}
+	return false
+}
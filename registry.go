@@ -0,0 +1,57 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"go/ast"
+	"go/token"
+	"sync"
+)
+
+// ExecutorFactory creates the executor of a file with the file's options, the executor
+// only processes the structs and fields selected by filter, a nil Executor means
+// the executor has nothing to do with opts
+type ExecutorFactory func(f *ast.File, fs *token.FileSet, opts Options, filter *Filter) (Executor, error)
+
+type registeredExecutor struct {
+	name    string
+	factory ExecutorFactory
+}
+
+var (
+	registryMu          sync.RWMutex
+	registeredExecutors []registeredExecutor
+)
+
+// RegisterExecutor adds a custom executor e.g a company tag policy to the pipeline, it
+// runs after the builtin executors by default, and the name can be used in -pipeline.
+// it's usually called by the main package of a command calls Main after it, or before
+// Source, it panics if the name is registered or is a builtin executor
+func RegisterExecutor(name string, factory ExecutorFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if factory == nil {
+		panic("tagfmt: RegisterExecutor factory is nil")
+	}
+	if isBuiltinStage(name) {
+		panic("tagfmt: RegisterExecutor " + name + " is a builtin executor")
+	}
+	for _, exe := range registeredExecutors {
+		if exe.name == name {
+			panic("tagfmt: RegisterExecutor called twice for executor " + name)
+		}
+	}
+	registeredExecutors = append(registeredExecutors, registeredExecutor{name: name, factory: factory})
+}
+
+// registered returns the registered executors in the registration order
+func registered() []registeredExecutor {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]registeredExecutor(nil), registeredExecutors...)
+}
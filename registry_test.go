@@ -0,0 +1,90 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/ast"
+	"go/token"
+	"testing"
+)
+
+// noXMLPolicy is a tag policy rejects the xml key
+type noXMLPolicy struct {
+	f      *ast.File
+	fs     *token.FileSet
+	filter *Filter
+	Err    error
+}
+
+func (s *noXMLPolicy) Scan() error {
+	ast.Walk(s, s.f)
+	return s.Err
+}
+
+func (s *noXMLPolicy) Execute() error {
+	return nil
+}
+
+func (s *noXMLPolicy) Visit(node ast.Node) ast.Visitor {
+	visit := newTopVisit(fileCommentMap(s.fs, s.f), s.filter, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *noXMLPolicy) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	for _, field := range n.Fields.List {
+		if field.Tag == nil || s.Err != nil {
+			continue
+		}
+		_, keyValues, err := ParseTag(field.Tag.Value)
+		if err != nil {
+			s.Err = NewAstError(s.fs, field.Tag, err)
+			return
+		}
+		for _, kv := range keyValues {
+			if kv.Key == "xml" {
+				s.Err = NewAstError(s.fs, field.Tag, errors.New("xml key is not allowed"))
+				return
+			}
+		}
+	}
+}
+
+func TestRegisterExecutor(t *testing.T) {
+	defer func() { registeredExecutors = nil }()
+	RegisterExecutor("noxml", func(f *ast.File, fs *token.FileSet, opts Options, filter *Filter) (Executor, error) {
+		return &noXMLPolicy{f: f, fs: fs, filter: filter}, nil
+	})
+	assert.Panics(t, func() {
+		RegisterExecutor("noxml", func(f *ast.File, fs *token.FileSet, opts Options, filter *Filter) (Executor, error) {
+			return nil, nil
+		})
+	})
+	assert.Panics(t, func() {
+		RegisterExecutor("sort", func(f *ast.File, fs *token.FileSet, opts Options, filter *Filter) (Executor, error) {
+			return nil, nil
+		})
+	})
+	assert.Equal(t, []string{"split", "doctor", "comment", "remnant", "rewrite", "rename", "migrate", "fill", "sync", "time", "redact", "sort", "align", "noxml"}, defaultPipeline())
+
+	src := []byte("package a\n\ntype A struct {\n\tName string `xml:\"name\" json:\"name\"`\n}\n")
+	opts := Options{Align: true, Sort: true, Pattern: ".*", StructPattern: ".*"}
+	var buf bytes.Buffer
+	err := formatSource(&buf, "a.go", src, opts)
+	assert.EqualError(t, err, "a.go:4:14: xml key is not allowed")
+
+	// the pipeline without noxml drops it
+	opts.Pipeline = "doctor,sort,align"
+	buf.Reset()
+	err = formatSource(&buf, "a.go", src, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "package a\n\ntype A struct {\n\tName string `json:\"name\" xml:\"name\"`\n}\n", buf.String())
+}
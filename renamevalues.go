@@ -0,0 +1,203 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// valueRenames is the old name to new name mapping of rename-values, it's nil when
+// rename-values isn't running
+var valueRenames *renameMap
+
+// renameMap renames the names of key, it records the names found in the files
+type renameMap struct {
+	key   string
+	names map[string]string
+
+	mu    sync.Mutex
+	found map[string]bool
+}
+
+// loadRenameMap reads the json object of old name to new name from filename
+func loadRenameMap(key, filename string) (*renameMap, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var names map[string]string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	for old, name := range names {
+		if old == "" || name == "" || old == "-" || name == "-" {
+			return nil, fmt.Errorf("%s: invalid rename %q -> %q", filename, old, name)
+		}
+	}
+	return &renameMap{key: key, names: names, found: map[string]bool{}}, nil
+}
+
+// rename returns the new name of name and records name is found
+func (m *renameMap) rename(name string) (string, bool) {
+	newName, ok := m.names[name]
+	if ok {
+		m.mu.Lock()
+		m.found[name] = true
+		m.mu.Unlock()
+	}
+	return newName, ok
+}
+
+// notFound returns the old names never found in the files
+func (m *renameMap) notFound() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for name := range m.names {
+		if !m.found[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// logNotFound logs the report of old names never found
+func (m *renameMap) logNotFound() {
+	for _, name := range m.notFound() {
+		commandError("rename-values", fmt.Errorf("%s:%q is not found", m.key, name))
+	}
+}
+
+// checkFound returns the error of old names never found, they are likely typos
+func (m *renameMap) checkFound() error {
+	var errs tagDockerErr
+	for _, name := range m.notFound() {
+		errs = append(errs, fmt.Errorf("rename-values: %s:%q is not found", m.key, name))
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// renameValuesMain renames the names of key by the mapping file in paths, the global
+// flags -w -l -d -n work as formatting, the names never found are reported, with -strict
+// they are errors and no file is written
+//
+//	tagfmt -w rename-values [-strict] -key json -map renames.json ./...
+func renameValuesMain(args []string) int {
+	fs := flag.NewFlagSet("rename-values", flag.ContinueOnError)
+	key := fs.String("key", "json", "the tag key to rename")
+	mapFile := fs.String("map", "", "json file of old name to new name e.g {\"user_name\": \"username\"}")
+	strict := fs.Bool("strict", false, "fail without writing any file if a name in the map is never found")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *key == "" || *mapFile == "" {
+		commandError("rename-values", errors.New("-key and -map must not be empty"))
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		commandError("rename-values", errors.New("no path to rename"))
+		return exitUsage
+	}
+	renames, err := loadRenameMap(*key, *mapFile)
+	if err != nil {
+		commandError("rename-values", err)
+		return errorClass(err)
+	}
+	valueRenames = renames
+	defer func() { valueRenames = nil }()
+
+	if *strict {
+		// the files are written after all names are found
+		defer func(atomic bool) { *atomicRunFlag = atomic }(*atomicRunFlag)
+		*atomicRunFlag = true
+		processPaths(fs.Args(), renames.checkFound)
+		return exitCode
+	}
+	processPaths(fs.Args(), nil)
+	renames.logNotFound()
+	return exitCode
+}
+
+// tagValueRenamer renames the name of key, the options after the name are kept
+type tagValueRenamer struct {
+	f       *ast.File
+	fs      *token.FileSet
+	filter  *Filter
+	renames *renameMap
+	fields  []*ast.Field
+}
+
+func (s *tagValueRenamer) Visit(node ast.Node) ast.Visitor {
+	cmap := fileCommentMap(s.fs, s.f)
+	visit := newTopVisit(cmap, s.filter, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagValueRenamer) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields != nil {
+		for _, field := range n.Fields.List {
+			if field.Tag != nil && s.filter.Field(name, getFieldName(field)) {
+				s.fields = append(s.fields, field)
+			}
+		}
+	}
+}
+
+func (s *tagValueRenamer) Scan() error {
+	ast.Walk(s, s.f)
+	return nil
+}
+
+func (s *tagValueRenamer) Execute() error {
+	for _, field := range s.fields {
+		quote, keyValues, err := ParseTag(field.Tag.Value)
+		if err != nil {
+			return NewAstError(s.fs, field.Tag, err)
+		}
+		changed := false
+		for i, kv := range keyValues {
+			if kv.Key != s.renames.key {
+				continue
+			}
+			name, extra := kv.Value, ""
+			if idx := strings.IndexByte(name, ','); idx != -1 {
+				name, extra = name[:idx], name[idx:]
+			}
+			if newName, ok := s.renames.rename(name); ok {
+				keyValues[i].Value = newName + extra
+				changed = true
+			}
+		}
+		if changed {
+			var keyValuesRaw []string
+			for _, kv := range keyValues {
+				keyValuesRaw = append(keyValuesRaw, kv.String())
+			}
+			field.Tag.Value = quote + strings.Join(keyValuesRaw, " ") + quote
+			field.Tag.ValuePos = 0
+		}
+	}
+	return nil
+}
+
+func newTagValueRename(f *ast.File, fs *token.FileSet, filter *Filter, renames *renameMap) *tagValueRenamer {
+	return &tagValueRenamer{f: f, fs: fs, filter: filter, renames: renames}
+}
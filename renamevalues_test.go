@@ -0,0 +1,74 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenameValues(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	mapFile := filepath.Join(dir, "renames.json")
+	err = ioutil.WriteFile(mapFile, []byte(`{"user_name": "username", "username": "login", "nick": "nickname"}`), 0644)
+	require.NoError(t, err)
+	src := filepath.Join(dir, "user.go")
+	err = ioutil.WriteFile(src, []byte("package user\n\n"+
+		"type User struct {\n"+
+		"\tUserName string `json:\"user_name,omitempty\" yaml:\"user_name\"`\n"+
+		"\tLogin    string `json:\"username\"`\n"+
+		"}\n"), 0644)
+	require.NoError(t, err)
+
+	renames, err := loadRenameMap("json", mapFile)
+	require.NoError(t, err)
+	valueRenames = renames
+	var buf bytes.Buffer
+	err = formatSource(&buf, "user.go", []byte("package user\n\ntype A struct {\n\tX int `json:\"user_name\"`\n}\n"), optionsFromFlags())
+	valueRenames = nil
+	require.NoError(t, err)
+	// the renamed name isn't renamed again by the next pass
+	assert.Equal(t, "package user\n\ntype A struct {\n\tX int `json:\"username\"`\n}\n", buf.String())
+	assert.Equal(t, []string{"nick", "username"}, renames.notFound())
+
+	*write = true
+	code := renameValuesMain([]string{"-key", "json", "-map", mapFile, dir})
+	assert.Equal(t, 0, code)
+	assert.Nil(t, valueRenames)
+	res, err := ioutil.ReadFile(src)
+	require.NoError(t, err)
+	assert.Equal(t, "package user\n\n"+
+		"type User struct {\n"+
+		"\tUserName string `json:\"username,omitempty\" yaml:\"user_name\"`\n"+
+		"\tLogin    string `json:\"login\"`\n"+
+		"}\n", string(res))
+
+	// nick is never found, the strict mode doesn't write any file
+	err = ioutil.WriteFile(mapFile, []byte(`{"login": "account", "nick": "nickname"}`), 0644)
+	require.NoError(t, err)
+	code = renameValuesMain([]string{"-strict", "-map", mapFile, dir})
+	assert.Equal(t, 2, code)
+	assert.False(t, *atomicRunFlag)
+	res2, err := ioutil.ReadFile(src)
+	require.NoError(t, err)
+	assert.Equal(t, string(res), string(res2))
+	exitCode = 0
+
+	_, err = loadRenameMap("json", mapFile+".missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// rolePattern select the role for structs whose name matched Struct
+type rolePattern struct {
+	Struct string `json:"struct"`
+	Role   string `json:"role"`
+
+	re *regexp.Regexp
+}
+
+// roleChain is the options and filter used by the structs of one role,
+// the default chain has the empty role name
+type roleChain struct {
+	Role    string
+	Options Options
+	Filter  *Filter
+}
+
+func (c *Config) compileRoles() error {
+	for i := range c.RolePatterns {
+		p := &c.RolePatterns[i]
+		if _, ok := c.Roles[p.Role]; !ok {
+			return fmt.Errorf("role pattern %q: unknown role %q", p.Struct, p.Role)
+		}
+		re, err := regexp.Compile(p.Struct)
+		if err != nil {
+			return err
+		}
+		p.re = re
+	}
+	return nil
+}
+
+// commentRole returns the role name of // tagrole: name comment
+func commentRole(comments []*ast.CommentGroup) string {
+	for _, group := range comments {
+		for _, cmd := range group.List {
+			if strings.HasPrefix(cmd.Text, "//") {
+				text := strings.TrimSpace(cmd.Text[len("//"):])
+				if strings.HasPrefix(text, "tagrole:") {
+					return strings.TrimSpace(text[len("tagrole:"):])
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// structRoles returns the role of every struct has a role in file, the role comment
+// is preferred to role pattern, the nested anonymous structs have the same role as
+// the struct they are in
+func (c *Config) structRoles(f *ast.File, fs *token.FileSet) (map[*ast.StructType]string, error) {
+	if c == nil || len(c.Roles) == 0 {
+		return nil, nil
+	}
+	roles := map[*ast.StructType]string{}
+	var err error
+	all := &Filter{
+		Field:  func(string, string) bool { return true },
+		Struct: func(...string) bool { return true },
+		Proto:  true,
+	}
+	visit := newTopVisit(fileCommentMap(fs, f), all, func(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+		if _, ok := roles[n]; ok || err != nil {
+			return
+		}
+		role := commentRole(comments)
+		if role != "" {
+			if _, ok := c.Roles[role]; !ok {
+				err = NewAstError(fs, n, fmt.Errorf("unknown role %s", role))
+				return
+			}
+		} else if name != "" {
+			for _, p := range c.RolePatterns {
+				if p.re.MatchString(name) {
+					role = p.Role
+					break
+				}
+			}
+		}
+		if role == "" {
+			return
+		}
+		ast.Inspect(n, func(node ast.Node) bool {
+			if s, ok := node.(*ast.StructType); ok {
+				roles[s] = role
+			}
+			return true
+		})
+	})
+	ast.Walk(visit, f)
+	return roles, err
+}
+
+// roleChains split the structs in file to chains by their role, every chain only
+// selects the structs of its role
+func (c *Config) roleChains(f *ast.File, fs *token.FileSet, base Options) ([]roleChain, error) {
+	roles, err := c.structRoles(f, fs)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := base.Filter()
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return []roleChain{{Options: base, Filter: filter}}, nil
+	}
+	filter.Node = func(n *ast.StructType) bool {
+		_, ok := roles[n]
+		return !ok
+	}
+	chains := []roleChain{{Options: base, Filter: filter}}
+	used := map[string]bool{}
+	var names []string
+	for _, role := range roles {
+		if !used[role] {
+			used[role] = true
+			names = append(names, role)
+		}
+	}
+	sort.Strings(names)
+	for _, role := range names {
+		opts := base
+		if err := json.Unmarshal(c.Roles[role], &opts); err != nil {
+			return nil, fmt.Errorf("role %s: %s", role, err)
+		}
+		filter, err := opts.Filter()
+		if err != nil {
+			return nil, fmt.Errorf("role %s: %s", role, err)
+		}
+		role := role
+		filter.Node = func(n *ast.StructType) bool {
+			return roles[n] == role
+		}
+		chains = append(chains, roleChain{Role: role, Options: opts, Filter: filter})
+	}
+	return chains, nil
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// shownStruct is the parsed view of a struct's tags
+type shownStruct struct {
+	Name   string       `json:"name"`
+	Pos    string       `json:"pos"`
+	Fields []shownField `json:"fields"`
+}
+
+// shownField is a field and its tag keys, the nested struct fields have the
+// path e.g Address.ZipCode
+type shownField struct {
+	Path  string     `json:"path"`
+	Pos   string     `json:"pos"`
+	Type  string     `json:"type"`
+	Tag   string     `json:"tag,omitempty"`
+	Keys  []shownKey `json:"keys,omitempty"`
+	Error string     `json:"error,omitempty"`
+}
+
+// shownKey is a tag key, the value is split to the name and options by comma
+type shownKey struct {
+	Key     string   `json:"key"`
+	Value   string   `json:"value"`
+	Options []string `json:"options,omitempty"`
+}
+
+// showMain prints the parsed tags of the struct name in the packages of paths, it's
+// for debugging why a rule matched or didn't, the name is the first argument or -struct
+//
+//	tagfmt show [-json] User ./models
+func showMain(args []string) int {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print json instead of table")
+	name := fs.String("struct", "", "the struct name, instead of the first argument")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	opts := optionsFromFlags()
+	var paths []string
+	opts.structName, paths = structArg(*name, fs.Args())
+	if opts.structName == "" {
+		commandError("show", errors.New("usage: tagfmt show [-json] [-struct name] name [path ...]"))
+		return exitUsage
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	structs, err := showStructs(opts, paths)
+	if err != nil {
+		commandError("show", err)
+		return errorClass(err)
+	}
+	if *asJSON {
+		data, err := json.MarshalIndent(structs, "", "\t")
+		if err != nil {
+			commandError("show", err)
+			return errorClass(err)
+		}
+		fmt.Printf("%s\n", data)
+		return exitOK
+	}
+	if err := writeShown(os.Stdout, structs); err != nil {
+		commandError("show", err)
+		return errorClass(err)
+	}
+	return exitOK
+}
+
+// showStructs parses the structs selected by opts in the packages of paths, -struct
+// only parses the files declaring it
+func showStructs(opts Options, paths []string) ([]shownStruct, error) {
+	filter, err := opts.Filter()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	if opts.structName != "" {
+		files, err = structFiles(opts.structName, paths)
+	} else {
+		files, err = packageFiles(paths)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var structs []shownStruct
+	for _, filename := range files {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, filename, nil, parserMode)
+		if err != nil {
+			return nil, err
+		}
+		structs = append(structs, previewStructs(fset, f, filter)...)
+	}
+	if len(structs) == 0 {
+		return nil, fmt.Errorf("%s matched no struct in %s", opts.structPatternDesc(), strings.Join(paths, " "))
+	}
+	return structs, nil
+}
+
+// newShownStruct parses the tags of the struct declared by spec
+func newShownStruct(fset *token.FileSet, spec *ast.TypeSpec) shownStruct {
+	return shownStruct{
+		Name:   spec.Name.Name,
+		Pos:    fset.Position(spec.Pos()).String(),
+		Fields: showFields(fset, indirectStruct(spec.Type)),
+	}
+}
+
+// showFields parses the tags of st and its nested structs
+func showFields(fset *token.FileSet, st *ast.StructType) []shownField {
+	var fields []shownField
+	if st.Fields == nil {
+		return nil
+	}
+	for _, field := range st.Fields.List {
+		names := make([]string, 0, len(field.Names))
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+		if len(names) == 0 {
+			names = append(names, embeddedName(field.Type))
+		}
+		typ := types.ExprString(field.Type)
+		if _, ok := field.Type.(*ast.StructType); ok {
+			// the fields of nested struct follow it
+			typ = "struct{...}"
+		}
+		for _, name := range names {
+			shown := shownField{Path: name, Pos: fset.Position(field.Pos()).String(), Type: typ}
+			if field.Tag != nil {
+				shown.Tag = field.Tag.Value
+				_, keyValues, err := ParseTag(field.Tag.Value)
+				if err != nil {
+					shown.Error = err.Error()
+				}
+				for _, kv := range keyValues {
+					value := strings.Split(kv.Value, ",")
+					shown.Keys = append(shown.Keys, shownKey{Key: kv.Key, Value: value[0], Options: value[1:]})
+				}
+			}
+			fields = append(fields, shown)
+			if nested := indirectStruct(field.Type); nested != nil {
+				for _, f := range showFields(fset, nested) {
+					f.Path = name + "." + f.Path
+					fields = append(fields, f)
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// writeShown writes the structs as a table of field, key, value and options
+func writeShown(w io.Writer, structs []shownStruct) error {
+	return writeTable(w, 1, func(tw io.Writer) {
+		for i, st := range structs {
+			if i != 0 {
+				fmt.Fprintln(tw)
+			}
+			fmt.Fprintf(tw, "%s %s\n", st.Name, st.Pos)
+			fmt.Fprintf(tw, "FIELD\tKEY\tVALUE\tOPTIONS\n")
+			for _, f := range st.Fields {
+				switch {
+				case f.Error != "":
+					fmt.Fprintf(tw, "%s\t!\t%s\t%s\n", f.Path, f.Tag, f.Error)
+				case len(f.Keys) == 0:
+					fmt.Fprintf(tw, "%s\t-\t\t\n", f.Path)
+				default:
+					for j, k := range f.Keys {
+						path := f.Path
+						if j != 0 {
+							path = ""
+						}
+						fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", path, k.Key, k.Value, strings.Join(k.Options, ","))
+					}
+				}
+			}
+		}
+	})
+}
+
+// writeTable writes the tab separated cells written by fn as the aligned columns
+// separated by padding spaces, an empty line ends a column block, the padding of
+// the empty cells at the line end is trimmed
+func writeTable(w io.Writer, padding int, fn func(tw io.Writer)) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, tabWidth, padding, ' ', 0)
+	fn(tw)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		if _, err := fmt.Fprintln(w, strings.TrimRight(scanner.Text(), " ")); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
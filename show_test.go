@@ -0,0 +1,70 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShow(t *testing.T) {
+	initParserMode()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	src := "package models\n\n" +
+		"type User struct {\n" +
+		"\tName    string `json:\"name,omitempty\" gorm:\"column:name\"`\n" +
+		"\tAge     int\n" +
+		"\tAddress struct {\n" +
+		"\t\tZipCode string `json:\"zip_code\"`\n" +
+		"\t} `json:\"address\"`\n" +
+		"\tBad string `json:name`\n" +
+		"}\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "user.go"), []byte(src), 0644))
+
+	opts := Options{Pattern: ".*", StructPattern: ".*", structName: "User"}
+	structs, err := showStructs(opts, []string{dir})
+	require.NoError(t, err)
+	require.Len(t, structs, 1)
+	fields := structs[0].Fields
+	require.Len(t, fields, 5)
+	assert.Equal(t, []shownKey{
+		{Key: "json", Value: "name", Options: []string{"omitempty"}},
+		{Key: "gorm", Value: "column:name", Options: []string{}},
+	}, fields[0].Keys)
+	assert.Equal(t, "Address.ZipCode", fields[3].Path)
+	assert.Equal(t, "struct{...}", fields[2].Type)
+	assert.Equal(t, ErrInvalidTag.Error(), fields[4].Error)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeShown(&buf, structs))
+	assert.Equal(t, "User "+filepath.Join(dir, "user.go")+":3:6\n"+
+		"FIELD           KEY  VALUE       OPTIONS\n"+
+		"Name            json name        omitempty\n"+
+		"                gorm column:name\n"+
+		"Age             -\n"+
+		"Address         json address\n"+
+		"Address.ZipCode json zip_code\n"+
+		"Bad             !    `json:name` "+ErrInvalidTag.Error()+"\n", buf.String())
+
+	// the name is the first argument, -struct of show or of the main command
+	resetFlags()
+	defer resetFlags()
+	assert.Equal(t, exitOK, showMain([]string{"User", dir}))
+	assert.Equal(t, exitOK, showMain([]string{"-struct", "User", dir}))
+	assert.Equal(t, exitUsage, showMain([]string{"Order", dir}))
+	assert.Equal(t, exitUsage, showMain(nil))
+	*structName = "User"
+	assert.Equal(t, exitOK, showMain([]string{"-json", dir}))
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// skipEntry is a file, struct or field skipped and why
+type skipEntry struct {
+	pos token.Position
+	msg string
+}
+
+// skipReport collects the skipped items of -v, they are written after all files
+// are processed, the silent non-matches are the top source of "tagfmt did nothing"
+type skipReport struct {
+	mu      sync.Mutex
+	entries []skipEntry
+}
+
+// skips is not nil when -v is set
+var skips *skipReport
+
+// Add records the item at pos is skipped
+func (r *skipReport) Add(pos token.Position, format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, skipEntry{pos: pos, msg: fmt.Sprintf(format, args...)})
+}
+
+// Log logs the skipped items sorted by position and resets the report, the records
+// are the info level with the skip kind
+func (r *skipReport) Log(l *slog.Logger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sort.SliceStable(r.entries, func(i, j int) bool {
+		a, b := r.entries[i].pos, r.entries[j].pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	for _, e := range r.entries {
+		pos := e.pos.Filename
+		if e.pos.IsValid() {
+			pos = e.pos.String()
+		}
+		l.Info(e.msg, "pos", pos, "kind", "skip")
+	}
+	r.entries = nil
+}
+
+// scanFile records the structs and fields of f not selected by the patterns of opts
+func (r *skipReport) scanFile(f *ast.File, fs *token.FileSet, opts Options) error {
+	return scanSelection(f, fs, opts, func(pos token.Pos, kind, name string, selected bool) {
+		if selected {
+			return
+		}
+		switch {
+		case kind == "protobuf":
+			r.Add(fs.Position(pos), "struct %s: generated by protoc-gen-go, only the -f rules read :proto_name format it", name)
+		case kind == "field":
+			r.Add(fs.Position(pos), "field %s: not matched by %s", name, opts.fieldPatternDesc())
+		case name == "":
+			r.Add(fs.Position(pos), "anonymous struct: not matched by %s", opts.structPatternDesc())
+		default:
+			r.Add(fs.Position(pos), "struct %s: not matched by %s", name, opts.structPatternDesc())
+		}
+	})
+}
+
+// scanSelection calls fn with every struct and field of f and whether the patterns of
+// opts select it, kind is struct or field, or protobuf for the struct generated by
+// protoc-gen-go, the fields of the structs not selected are skipped
+func scanSelection(f *ast.File, fs *token.FileSet, opts Options, fn func(pos token.Pos, kind, name string, selected bool)) error {
+	filter, err := opts.Filter()
+	if err != nil {
+		return err
+	}
+	aliases := typeAliases(f)
+	var rangeFields func(structName string, st *ast.StructType)
+	rangeFields = func(structName string, st *ast.StructType) {
+		if st.Fields == nil {
+			return
+		}
+		for _, field := range st.Fields.List {
+			if name := getFieldOrTypeName(field); name != "" {
+				fn(field.Pos(), "field", name, filter.Field(structName, name))
+			}
+			if nested, ok := field.Type.(*ast.StructType); ok {
+				rangeFields("", nested)
+			}
+		}
+	}
+	ast.Inspect(f, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.TypeSpec:
+			st := indirectStruct(n.Type)
+			if st == nil {
+				return false
+			}
+			name := n.Name.Name
+			if !filter.Proto && isProtoGenerated(name, st) {
+				fn(n.Pos(), "protobuf", name, false)
+				return false
+			}
+			selected := filter.Struct(append([]string{name, typeSpecName(n)}, aliases[name]...)...)
+			fn(n.Pos(), "struct", name, selected)
+			if selected {
+				rangeFields(name, st)
+			}
+			return false
+		case *ast.StructType:
+			selected := filter.Struct("")
+			fn(n.Pos(), "struct", "", selected)
+			if selected {
+				rangeFields("", n)
+			}
+			return false
+		}
+		return true
+	})
+	return nil
+}
+
+// matchCount counts the structs and fields selected by the patterns of -require-match
+type matchCount struct {
+	structs, fields int64
+}
+
+// matches is not nil when -require-match is set
+var matches *matchCount
+
+// scanFile counts the structs and fields of f selected by the patterns of opts
+func (m *matchCount) scanFile(f *ast.File, fs *token.FileSet, opts Options) error {
+	return scanSelection(f, fs, opts, func(pos token.Pos, kind, name string, selected bool) {
+		if !selected {
+			return
+		}
+		if kind == "field" {
+			atomic.AddInt64(&m.fields, 1)
+		} else {
+			atomic.AddInt64(&m.structs, 1)
+		}
+	})
+}
+
+// check returns the error if the patterns of opts matched no struct or field,
+// so typo'd patterns in CI scripts don't silently pass
+func (m *matchCount) check(opts Options) error {
+	switch {
+	case atomic.LoadInt64(&m.structs) == 0:
+		return fmt.Errorf("require-match: %s matched no struct", opts.structPatternDesc())
+	case atomic.LoadInt64(&m.fields) == 0:
+		return fmt.Errorf("require-match: %s matched no field", opts.fieldPatternDesc())
+	}
+	return nil
+}
+
+// structPatternDesc describes the struct pattern flag of options e.g -sp "^User$"
+func (o Options) structPatternDesc() string {
+	if o.structName != "" {
+		return fmt.Sprintf("-struct %q", o.structName)
+	}
+	return patternDesc("-sp", o.StructPattern, "-spg", o.StructGlob, "-sP", o.InverseStructPattern) + o.patternModeDesc()
+}
+
+// fieldPatternDesc describes the field pattern flag of options e.g -p "^Name$"
+func (o Options) fieldPatternDesc() string {
+	return patternDesc("-p", o.Pattern, "-pg", o.FieldGlob, "-P", o.InversePattern) + o.patternModeDesc()
+}
+
+// patternDesc describes the include and exclude pattern flags, the default include
+// pattern is omitted if there is an exclude pattern
+func patternDesc(patternFlag, pattern, globFlag, glob, inverseFlag, inverse string) string {
+	var desc []string
+	switch {
+	case glob != "":
+		desc = append(desc, globFlag+" "+strconv.Quote(glob))
+	case inverse == "" || pattern != ".*":
+		desc = append(desc, patternFlag+" "+strconv.Quote(pattern))
+	}
+	if inverse != "" {
+		desc = append(desc, inverseFlag+" "+strconv.Quote(inverse))
+	}
+	return strings.Join(desc, " ")
+}
+
+func (o Options) patternModeDesc() string {
+	var desc string
+	if o.ExactPattern {
+		desc += " -exact"
+	}
+	if o.PatternIgnoreCase {
+		desc += " -pi"
+	}
+	return desc
+}
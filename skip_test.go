@@ -0,0 +1,84 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSkipReport(t *testing.T) {
+	initParserMode()
+	skips = &skipReport{}
+	defer func() { skips = nil }()
+	src := "package user\n\n" +
+		"type User struct {\n" +
+		"\tName string `json:\"name\"`\n" +
+		"\tAge  int    `json:\"age\"`\n" +
+		"}\n\n" +
+		"type PowerUser struct {\n" +
+		"\tName string `json:\"name\"`\n" +
+		"}\n"
+	opts := Options{Align: true, Pattern: "^Name$", StructPattern: "User", ExactPattern: true}
+	var buf bytes.Buffer
+	require.NoError(t, formatSource(&buf, "user.go", []byte(src), opts))
+	skips.Add(token.Position{Filename: "a_test.go"}, "file: the _test.go files are skipped by -w, use -tests to include them")
+
+	var report bytes.Buffer
+	skips.Log(newLogger(&report, "text", false))
+	// the recheck passes don't report again
+	assert.Equal(t, "a_test.go: skip file: the _test.go files are skipped by -w, use -tests to include them\n"+
+		"user.go:5:2: skip field Age: not matched by -p \"^Name$\" -exact\n"+
+		"user.go:8:6: skip struct PowerUser: not matched by -sp \"User\" -exact\n", report.String())
+
+	buf.Reset()
+	err := formatTemplate(&buf, "user.go.tmpl", []byte("type User struct {\n\tID int `json:\"{{.ID}}\"`\n}\n"), opts, "{{")
+	require.NoError(t, err)
+	report.Reset()
+	skips.Log(newLogger(&report, "text", false))
+	assert.Equal(t, "user.go.tmpl:1:1: skip struct: the template actions in it can't be formatted\n", report.String())
+
+	buf.Reset()
+	src = "package pb\n\ntype User_Email struct {\n\tEmail string `protobuf:\"bytes,2,opt,name=email,proto3,oneof\"`\n}\n"
+	require.NoError(t, formatSource(&buf, "user.pb.go", []byte(src), Options{Align: true}))
+	report.Reset()
+	skips.Log(newLogger(&report, "text", false))
+	assert.Equal(t, "user.pb.go:3:6: skip struct User_Email: generated by protoc-gen-go, only the -f rules read :proto_name format it\n", report.String())
+}
+
+func TestRequireMatch(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	src := "package user\n\ntype User struct {\n\tName string `json:\"name\"`\n}\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "user.go"), []byte(src), 0644))
+
+	*requireMatch = true
+	*list = true
+	processPaths([]string{dir}, nil)
+	assert.Equal(t, 0, exitCode)
+	assert.Nil(t, matches)
+
+	// the typo'd pattern fails
+	*structPattern = "^Usr$"
+	processPaths([]string{dir}, nil)
+	assert.Equal(t, 2, exitCode)
+	exitCode = 0
+
+	m := &matchCount{structs: 1}
+	assert.EqualError(t, m.check(Options{Pattern: "^Nmae$"}), `require-match: -p "^Nmae$" matched no field`)
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// the wrappers of the snippets, the line directive after them keeps the positions
+// of errors the same as src, the formatted snippet starts after it
+const (
+	declWrapper = "package p\n"
+	stmtWrapper = "package p\nfunc _() {\n"
+)
+
+// Source formats src with opts like go/format.Source, so the other formatters e.g the
+// gofumpt and golines wrappers chain tagfmt in their pipelines, src is a full go file
+// or a snippet of declarations or statements e.g a struct type, the leading and
+// trailing spaces and the indentation of the snippet are kept, it's safe for concurrent
+// use, the parser mode and config of the command aren't used
+func Source(src []byte, opts Options) ([]byte, error) {
+	opts.source = true
+	return formatFragment("<source>", src, opts)
+}
+
+// formatFragment formats the full file or the snippet src, the snippet is wrapped in
+// a file to format and unwrapped after
+func formatFragment(filename string, src []byte, opts Options) ([]byte, error) {
+	opts.fragment = true
+	var out bytes.Buffer
+	fset := token.NewFileSet()
+	_, err := parser.ParseFile(fset, filename, src, opts.parseMode())
+	if err == nil || !strings.Contains(err.Error(), "expected 'package'") {
+		if err := formatSource(&out, filename, src, opts); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}
+
+	// the declaration list e.g type A struct {...}
+	opts.snippet = true
+	directive := "//line " + filename + ":1:1\n"
+	wrapped := []byte(declWrapper + directive + string(src))
+	_, err = parser.ParseFile(fset, filename, wrapped, opts.parseMode())
+	if err == nil {
+		if err := formatSource(&out, filename, wrapped, opts); err != nil {
+			return nil, err
+		}
+		return unwrapSnippet(out.Bytes(), directive, ""), nil
+	}
+	if !strings.Contains(err.Error(), "expected declaration") {
+		return nil, err
+	}
+
+	// the statement list e.g var a struct {...}
+	wrapped = []byte(stmtWrapper + directive + string(src) + "\n}")
+	if err := formatSource(&out, filename, wrapped, opts); err != nil {
+		return nil, err
+	}
+	return unwrapSnippet(out.Bytes(), directive, "\n}"), nil
+}
+
+// unwrapSnippet returns the snippet after the line directive of the formatted res,
+// suffix is the end of the wrapper, the bytes out of the changed fields are kept as
+// is, so the snippet keeps the leading and trailing spaces and indentation of src
+func unwrapSnippet(res []byte, directive, suffix string) []byte {
+	if i := bytes.Index(res, []byte(directive)); i != -1 {
+		res = res[i+len(directive):]
+	}
+	return bytes.TrimSuffix(res, []byte(suffix))
+}
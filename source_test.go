@@ -0,0 +1,67 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	opts := optionsFromFlags()
+	opts.Fill = "json=snake(:field)"
+	for _, c := range []struct {
+		src  string
+		want string
+	}{
+		{
+			// full file
+			src:  "package a\n\ntype A struct {\n\tUserName string ``\n\tID int ``\n}\n",
+			want: "package a\n\ntype A struct {\n\tUserName string `json:\"user_name\"`\n\tID       int    `json:\"id\"`\n}\n",
+		},
+		{
+			// declarations
+			src:  "\ntype A struct {\n\tUserName string ``\n\tID int ``\n}\n\n",
+			want: "\ntype A struct {\n\tUserName string `json:\"user_name\"`\n\tID       int    `json:\"id\"`\n}\n\n",
+		},
+		{
+			// indented statements
+			src:  "\t\tvar a struct {\n\t\t\tUserName string ``\n\t\t\tText string `json:\"x\"`\n\t\t}\n\t\t_ = `raw\nline`\n",
+			want: "\t\tvar a struct {\n\t\t\tUserName string `json:\"user_name\"`\n\t\t\tText     string `json:\"text\"`\n\t\t}\n\t\t_ = `raw\nline`\n",
+		},
+	} {
+		res, err := Source([]byte(c.src), opts)
+		require.NoError(t, err)
+		assert.Equal(t, c.want, string(res))
+	}
+
+	_, err := Source([]byte("type A struct {\n\tID int `json`\n}\n"), opts)
+	assert.EqualError(t, err, "<source>:2:9: invalid tag")
+}
+
+func TestSourceConcurrent(t *testing.T) {
+	// Source shares no state with the command, the calls run in parallel
+	opts := Options{Align: true, Sort: true, Pattern: ".*", StructPattern: ".*", Fill: "json=snake(:field)"}
+	src := []byte("type A struct {\n\tUserName string `yaml:\"u\"`\n\tID int ``\n}\n")
+	want := "type A struct {\n\tUserName string `json:\"user_name\" yaml:\"u\"`\n\tID       int    `json:\"id\"`\n}\n"
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := Source(src, opts)
+			assert.NoError(t, err)
+			assert.Equal(t, want, string(res))
+		}()
+	}
+	wg.Wait()
+}
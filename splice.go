@@ -0,0 +1,499 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tagSpan is a tag literal and its source span before the executors change it
+type tagSpan struct {
+	value      string
+	start, end int
+}
+
+// fieldSpan is a field and its source span from the first name to the tag end
+type fieldSpan struct {
+	field      *ast.Field
+	start, end int
+}
+
+// sourceEdit replaces src[start:end] with text
+type sourceEdit struct {
+	start, end int
+	text       string
+}
+
+// sourceSpans is the tags, fields and comment positions of a file before the
+// executors run, the changes of executors are turned to edits of the source by them
+type sourceSpans struct {
+	file     *token.File
+	tags     map[*ast.BasicLit]tagSpan
+	fields   map[*ast.Field]bool
+	untagged map[*ast.Field]int // the type end of the fields without tag
+	names    map[*ast.Ident]fieldSpan
+	comments map[*ast.Comment]token.Pos
+}
+
+// recordSpans records the tags, fields and comment positions of f
+func recordSpans(f *ast.File, fs *token.FileSet) *sourceSpans {
+	spans := &sourceSpans{
+		file:     fs.File(f.Pos()),
+		tags:     map[*ast.BasicLit]tagSpan{},
+		fields:   map[*ast.Field]bool{},
+		untagged: map[*ast.Field]int{},
+		names:    map[*ast.Ident]fieldSpan{},
+		comments: map[*ast.Comment]token.Pos{},
+	}
+	for _, field := range fileFields(f) {
+		spans.fields[field] = true
+		if field.Tag != nil {
+			spans.tags[field.Tag] = tagSpan{
+				value: field.Tag.Value,
+				start: spans.offset(field.Tag.Pos()),
+				end:   spans.offset(field.Tag.End()),
+			}
+		} else {
+			spans.untagged[field] = spans.offset(field.Type.End())
+		}
+		for _, name := range field.Names {
+			spans.names[name] = fieldSpan{
+				field: field,
+				start: spans.offset(field.Pos()),
+				end:   spans.offset(field.End()),
+			}
+		}
+	}
+	for _, group := range f.Comments {
+		for _, c := range group.List {
+			spans.comments[c] = c.Slash
+		}
+	}
+	return spans
+}
+
+func (spans *sourceSpans) offset(pos token.Pos) int {
+	return spans.file.Offset(pos)
+}
+
+// fileFields returns the fields of all structs in f
+func fileFields(f *ast.File) []*ast.Field {
+	var fields []*ast.Field
+	ast.Inspect(f, func(node ast.Node) bool {
+		if st, ok := node.(*ast.StructType); ok && st.Fields != nil {
+			fields = append(fields, st.Fields.List...)
+		}
+		return true
+	})
+	return fields
+}
+
+// edits computes the source edits from the changes of the executors, they are the
+// changed tag values, the added and removed tags, the split fields and the moved and
+// removed comments
+func (spans *sourceSpans) edits(f *ast.File, src []byte) ([]sourceEdit, error) {
+	var edits []sourceEdit
+	attached := map[*ast.BasicLit]bool{}
+	replaced := map[*ast.Field][]*ast.Field{}
+	var replacedOrder []*ast.Field
+	for _, field := range fileFields(f) {
+		if !spans.fields[field] {
+			// the new field comes from splitting the original field of its name
+			if len(field.Names) == 0 {
+				return nil, fmt.Errorf("unsupported change of anonymous field %s", spans.fieldType(field, src))
+			}
+			orig, ok := spans.names[field.Names[0]]
+			if !ok {
+				return nil, fmt.Errorf("unsupported change of field %s", field.Names[0].Name)
+			}
+			if replaced[orig.field] == nil {
+				replacedOrder = append(replacedOrder, orig.field)
+			}
+			replaced[orig.field] = append(replaced[orig.field], field)
+			continue
+		}
+		if field.Tag == nil {
+			continue
+		}
+		attached[field.Tag] = true
+		span, ok := spans.tags[field.Tag]
+		if typeEnd, untagged := spans.untagged[field]; !ok && untagged {
+			value, err := requoteTag(field.Tag.Value)
+			if err != nil {
+				return nil, &AstError{Pos: spans.file.Position(spans.file.Pos(typeEnd)), Err: err}
+			}
+			edits = append(edits, sourceEdit{start: typeEnd, end: typeEnd, text: " " + value})
+			continue
+		}
+		if !ok {
+			return nil, fmt.Errorf("unsupported change of field %s tag", getFieldName(field))
+		}
+		if field.Tag.Value != span.value {
+			value, err := requoteTag(field.Tag.Value)
+			if err != nil {
+				return nil, &AstError{Pos: spans.file.Position(spans.file.Pos(span.start)), Err: err}
+			}
+			edits = append(edits, sourceEdit{start: span.start, end: span.end, text: value})
+		}
+	}
+
+	for lit, span := range spans.tags {
+		if attached[lit] {
+			continue
+		}
+		// the tag of replaced field is in its replacement
+		inReplaced := false
+		for orig := range replaced {
+			if orig.Tag == lit {
+				inReplaced = true
+				break
+			}
+		}
+		if !inReplaced {
+			edits = append(edits, sourceEdit{start: span.start, end: span.end})
+		}
+	}
+
+	// the comments moved inside the replaced fields are written after the new fields
+	moved := map[*ast.Field][]string{}
+	kept := map[*ast.Comment]bool{}
+	for _, group := range f.Comments {
+		for _, c := range group.List {
+			slash, ok := spans.comments[c]
+			if !ok {
+				return nil, fmt.Errorf("unsupported change of comment %s", c.Text)
+			}
+			kept[c] = true
+			if c.Slash == slash {
+				continue
+			}
+			start, end := spans.offset(slash), spans.offset(c.Slash)
+			if orig := spans.replacedField(replacedOrder, start); orig != nil {
+				moved[orig] = append(moved[orig], c.Text)
+				continue
+			}
+			edits = append(edits,
+				sourceEdit{start: start, end: start + len(c.Text)},
+				sourceEdit{start: end, end: end, text: " " + c.Text},
+			)
+		}
+	}
+
+	// the removed comments e.g the commented-out tags
+	for c, slash := range spans.comments {
+		if !kept[c] {
+			start := spans.offset(slash)
+			edits = append(edits, sourceEdit{start: start, end: start + len(c.Text)})
+		}
+	}
+
+	for _, orig := range replacedOrder {
+		span := spans.names[orig.Names[0]]
+		var lines []string
+		for _, field := range replaced[orig] {
+			var names []string
+			for _, name := range field.Names {
+				names = append(names, name.Name)
+			}
+			line := strings.Join(names, ", ") + " " + spans.fieldType(field, src)
+			if field.Tag != nil {
+				value, err := requoteTag(field.Tag.Value)
+				if err != nil {
+					return nil, &AstError{Pos: spans.file.Position(spans.file.Pos(span.start)), Err: err}
+				}
+				line += " " + value
+			}
+			lines = append(lines, line)
+		}
+		// the new fields are indented like the original field
+		indent := ""
+		if ls := bytes.LastIndexByte(src[:span.start], '\n') + 1; isBlank(src[ls:span.start]) {
+			indent = string(src[ls:span.start])
+		}
+		text := strings.Join(lines, "\n"+indent)
+		for _, comment := range moved[orig] {
+			text += " " + comment
+		}
+		edits = append(edits, sourceEdit{start: span.start, end: span.end, text: text})
+	}
+
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+	for i := 1; i < len(edits); i++ {
+		if edits[i].start < edits[i-1].end {
+			pos := spans.file.Position(spans.file.Pos(edits[i].start))
+			return nil, fmt.Errorf("%s: overlapping changes", pos)
+		}
+	}
+	return edits, nil
+}
+
+// requoteTag returns the changed tag literal which is valid go, the changed values may
+// have the characters can't be in the quote style, the raw string with a backquote, a
+// newline or a carriage return is quoted as the interpreted string instead, the
+// interpreted string must be valid as is because its escapes are ambiguous
+func requoteTag(value string) (string, error) {
+	if len(value) < 2 {
+		return "", ErrInvalidTag
+	}
+	body := value[1 : len(value)-1]
+	switch value[0] {
+	case '`':
+		if strings.ContainsAny(body, "`\n\r") {
+			return strconv.Quote(body), nil
+		}
+		return value, nil
+	case '"':
+		if _, err := strconv.Unquote(value); err != nil {
+			return "", fmt.Errorf("changed tag %s is not a valid string literal, the quotes, backslashes and newlines of values must be escaped", value)
+		}
+		return value, nil
+	}
+	return "", ErrInvalidTag
+}
+
+// replacedField returns the replaced field contains offset
+func (spans *sourceSpans) replacedField(replaced []*ast.Field, offset int) *ast.Field {
+	for _, field := range replaced {
+		span := spans.names[field.Names[0]]
+		if span.start <= offset && offset < span.end {
+			return field
+		}
+	}
+	return nil
+}
+
+// fieldType returns the source text of field type, the type node is shared with
+// the original field, so its position is valid
+func (spans *sourceSpans) fieldType(field *ast.Field, src []byte) string {
+	return string(src[spans.offset(field.Type.Pos()):spans.offset(field.Type.End())])
+}
+
+// splice applies the edits to src, the bytes out of the edits are kept as is
+func splice(src []byte, edits []sourceEdit) []byte {
+	var buf bytes.Buffer
+	last := 0
+	for _, edit := range edits {
+		buf.Write(src[last:edit.start])
+		buf.WriteString(edit.text)
+		last = edit.end
+	}
+	buf.Write(src[last:])
+	return buf.Bytes()
+}
+
+// realignedStructs returns the offsets in the spliced source of the structs to realign,
+// they're the structs processed by the align executors and the innermost structs of
+// the edits, so a struct is realigned the same whether or not its tags are changed
+func realignedStructs(file *ast.File, spans *sourceSpans, executors []Executor, edits []sourceEdit) map[int]bool {
+	selected := map[*ast.StructType]bool{}
+	for _, exe := range executors {
+		if formatter, ok := exe.(*tagFormatter); ok {
+			for _, st := range formatter.aligned {
+				selected[st] = true
+			}
+		}
+	}
+	if len(edits) != 0 {
+		structs := fileStructs(file)
+		for _, edit := range edits {
+			// the structs are in preorder, the last one containing the edit is innermost
+			var inner *ast.StructType
+			for _, st := range structs {
+				if spans.offset(st.Pos()) <= edit.start && edit.end <= spans.offset(st.End()) {
+					inner = st
+				}
+			}
+			if inner != nil {
+				selected[inner] = true
+			}
+		}
+	}
+	realign := make(map[int]bool, len(selected))
+	for st := range selected {
+		realign[splicedOffset(spans.offset(st.Pos()), edits)] = true
+	}
+	return realign
+}
+
+// splicedOffset returns the offset in the spliced source of the source offset, it
+// isn't in any edit
+func splicedOffset(offset int, edits []sourceEdit) int {
+	spliced := offset
+	for _, edit := range edits {
+		if edit.end > offset {
+			break
+		}
+		spliced += len(edit.text) - (edit.end - edit.start)
+	}
+	return spliced
+}
+
+// printSpliced realigns the structs of the spliced source src at the offsets of realign,
+// file is the parsed src or nil if src must be parsed. the outermost struct is printed
+// by itself like gofmt with the printer mode, but only the padding of the realigned
+// fields is written back to src, every byte out of their field lines is kept as is
+func printSpliced(out *bytes.Buffer, filename string, src []byte, realign map[int]bool, fileSet *token.FileSet, file *ast.File, parseMode parser.Mode, mode printer.Mode) error {
+	if len(realign) == 0 {
+		_, err := out.Write(src)
+		return err
+	}
+	if file == nil {
+		fileSet = token.NewFileSet()
+		var err error
+		file, err = parser.ParseFile(fileSet, filename, src, parseMode)
+		if err != nil {
+			return err
+		}
+	}
+	srcFile := fileSet.File(file.Pos())
+	cfg := printer.Config{Mode: mode, Tabwidth: tabWidth}
+	var edits []sourceEdit
+	var printed bytes.Buffer
+	var err error
+	ast.Inspect(file, func(node ast.Node) bool {
+		st, ok := node.(*ast.StructType)
+		if !ok || st.Fields == nil || err != nil {
+			return err == nil
+		}
+		var structs []*ast.StructType
+		for _, nested := range fileStructs(st) {
+			if realign[srcFile.Offset(nested.Pos())] {
+				structs = append(structs, nested)
+			}
+		}
+		if len(structs) != 0 {
+			printed.Reset()
+			var structEdits []sourceEdit
+			structEdits, err = realignStruct(&printed, cfg, filename, src, srcFile, fileSet, file, st, structs, parseMode)
+			edits = append(edits, structEdits...)
+		}
+		// the nested structs are realigned with the outermost one
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+	_, err = out.Write(splice(src, edits))
+	return err
+}
+
+// realignStruct prints the outermost struct st with its comments and returns the edits
+// of the field paddings differ from the printed ones, only the fields of selected, st
+// or its nested structs, are realigned
+func realignStruct(printed *bytes.Buffer, cfg printer.Config, filename string, src []byte, srcFile *token.File, fileSet *token.FileSet, file *ast.File, st *ast.StructType, selected []*ast.StructType, parseMode parser.Mode) ([]sourceEdit, error) {
+	if err := cfg.Fprint(printed, fileSet, &printer.CommentedNode{Node: st, Comments: file.Comments}); err != nil {
+		return nil, err
+	}
+	printedSet := token.NewFileSet()
+	expr, err := parser.ParseExprFrom(printedSet, filename, printed.Bytes(), parseMode)
+	if err != nil {
+		return nil, err
+	}
+	printedTok := printedSet.File(expr.Pos())
+	structs, printedStructs := fileStructs(st), fileStructs(expr)
+	if len(structs) != len(printedStructs) {
+		return nil, fmt.Errorf("%s: the printed struct has %d nested structs instead of %d", filename, len(printedStructs), len(structs))
+	}
+	var edits []sourceEdit
+	for i, st := range structs {
+		if !containsStruct(selected, st) {
+			continue
+		}
+		fields, printedFields := st.Fields.List, printedStructs[i].Fields.List
+		if len(fields) != len(printedFields) {
+			return nil, fmt.Errorf("%s: the printed struct has %d fields instead of %d", filename, len(printedFields), len(fields))
+		}
+		for j := range fields {
+			ranges := fieldRanges(srcFile, src, fields[j])
+			printedRanges := fieldRanges(printedTok, printed.Bytes(), printedFields[j])
+			for k, r := range ranges {
+				if text := printedRanges[k].text; r.text != text {
+					edits = append(edits, sourceEdit{start: r.start, end: r.end, text: text})
+				}
+			}
+		}
+	}
+	return edits, nil
+}
+
+func containsStruct(structs []*ast.StructType, st *ast.StructType) bool {
+	for _, s := range structs {
+		if s == st {
+			return true
+		}
+	}
+	return false
+}
+
+// fileStructs returns all structs in node in the source order
+func fileStructs(node ast.Node) []*ast.StructType {
+	var structs []*ast.StructType
+	ast.Inspect(node, func(node ast.Node) bool {
+		if st, ok := node.(*ast.StructType); ok && st.Fields != nil {
+			structs = append(structs, st)
+		}
+		return true
+	})
+	return structs
+}
+
+// fieldRanges returns the head and the tail of field in src, the head is the names
+// and padding before the type, the tail is the padding, tag and line comment after
+// the type. the indentation and the type are out of them, the nested struct type is
+// realigned by itself
+func fieldRanges(file *token.File, src []byte, field *ast.Field) []sourceEdit {
+	start := file.Offset(field.Pos())
+	typeStart, typeEnd := file.Offset(field.Type.Pos()), file.Offset(field.Type.End())
+	end := file.Offset(field.End())
+	if field.Comment != nil {
+		end = file.Offset(field.Comment.End())
+	}
+	if le := bytes.IndexByte(src[end:], '\n'); le != -1 && isBlank(src[end:end+le]) {
+		end += le
+	} else if le == -1 && isBlank(src[end:]) {
+		end = len(src)
+	}
+	// the \r of crlf line ending is kept, the printed text has none
+	if end > typeEnd && src[end-1] == '\r' {
+		end--
+	}
+	return []sourceEdit{
+		{start: start, end: typeStart, text: string(src[start:typeStart])},
+		{start: typeEnd, end: end, text: string(src[typeEnd:end])},
+	}
+}
+
+func isBlank(b []byte) bool {
+	return len(bytes.TrimLeft(b, " \t\r")) == 0
+}
+
+// padPrinterMode returns the printer mode of pad, the gap between the field type and
+// tag is padded with spaces like gofmt or with tabs, the padding inside the tags is
+// always spaces because reflect.StructTag only skips the spaces between keys
+func padPrinterMode(pad string) (printer.Mode, error) {
+	switch pad {
+	case "", "space":
+		return printerMode, nil
+	case "tab":
+		return printerMode &^ printer.UseSpaces, nil
+	}
+	return 0, fmt.Errorf("invalid pad %q, must be space or tab", pad)
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestSpliceEdits(t *testing.T) {
+	src := []byte("package main\n\n" +
+		"type A struct {\n" +
+		"\tName string `yaml:\"name\" json:\"name\"` // name\n" +
+		"\tX, Y int /* xy */ `json:\"\"`\n" +
+		"\tB struct {\n\t\tZ int `yaml:\"z\"`\n\t} `json:\"b\"`\n" +
+		"}\n\n" +
+		"type  C  struct { X int `yaml:\"x\"` }\n")
+	fs := token.NewFileSet()
+	f, err := parser.ParseFile(fs, "a.go", src, parser.ParseComments)
+	require.NoError(t, err)
+	spans := recordSpans(f, fs)
+	require.Len(t, spans.tags, 5)
+
+	fields := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec).Type.(*ast.StructType).Fields
+	fields.List[0].Tag.Value = "`json:\"name\" yaml:\"name\"`"
+	fields.List[0].Tag.ValuePos = 0
+	fields.List[2].Tag = nil
+	// split X, Y and move the comment to the tag end
+	split := splitField(fields.List[1])
+	fields.List = append(fields.List[:1], append(split, fields.List[2:]...)...)
+	f.Comments[1].List[0].Slash = split[0].Tag.End()
+
+	edits, err := spans.edits(f, src)
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\n"+
+		"type A struct {\n"+
+		"\tName string `json:\"name\" yaml:\"name\"` // name\n"+
+		"\tX int `json:\"\"`\n\tY int `json:\"\"` /* xy */\n"+
+		"\tB struct {\n\t\tZ int `yaml:\"z\"`\n\t} \n"+
+		"}\n\n"+
+		"type  C  struct { X int `yaml:\"x\"` }\n", string(splice(src, edits)))
+}
+
+func TestRequoteTag(t *testing.T) {
+	for value, expected := range map[string]string{
+		"`json:\"name\"`":       "`json:\"name\"`",
+		"`desc:\"a`b\"`":        "\"desc:\\\"a`b\\\"\"",
+		"`desc:\"a\nb\"`":       "\"desc:\\\"a\\nb\\\"\"",
+		"\"json:\\\"name\\\"\"": "\"json:\\\"name\\\"\"",
+		"\"desc:\\\"a`b\\\"\"":  "\"desc:\\\"a`b\\\"\"",
+	} {
+		got, err := requoteTag(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, got, value)
+	}
+	_, err := requoteTag("\"desc:\\\"a\"b\\\"\"")
+	assert.EqualError(t, err, "changed tag \"desc:\\\"a\"b\\\"\" is not a valid string literal, the quotes, backslashes and newlines of values must be escaped")
+}
+
+func TestFormatOnlyStructRanges(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	opts := optionsFromFlags()
+	opts.Sort = true
+	src := "package main\nimport \"fmt\"\n\n" +
+		"type A struct {\n" +
+		"\tName   string `yaml:\"name\" json:\"name\"`  // name\n" +
+		"    ID int `json:\"id\"`\n" +
+		"}\n\n" +
+		"type B struct {\n" +
+		"\tID int `json:\"id\"`\n" +
+		"\tLongName   string `json:\"long_name\"`\n" +
+		"}\n\n" +
+		"func  main( ) { fmt.Println( A{}, B{} ) }\n"
+	var out bytes.Buffer
+	require.NoError(t, formatSource(&out, "a.go", []byte(src), opts))
+	// the sorted tag of A and the padding of the fields of A and the unedited B are
+	// changed, the code out of the structs is kept as is
+	assert.Equal(t, "package main\nimport \"fmt\"\n\n"+
+		"type A struct {\n"+
+		"\tName string `json:\"name\" yaml:\"name\"` // name\n"+
+		"    ID   int    `json:\"id\"`\n"+
+		"}\n\n"+
+		"type B struct {\n"+
+		"\tID       int    `json:\"id\"`\n"+
+		"\tLongName string `json:\"long_name\"`\n"+
+		"}\n\n"+
+		"func  main( ) { fmt.Println( A{}, B{} ) }\n", out.String())
+
+	// B isn't realigned without the align executor, only the edited A is
+	opts.Align = false
+	out.Reset()
+	require.NoError(t, formatSource(&out, "a.go", []byte(src), opts))
+	assert.Equal(t, "package main\nimport \"fmt\"\n\n"+
+		"type A struct {\n"+
+		"\tName string `json:\"name\" yaml:\"name\"` // name\n"+
+		"    ID   int    `json:\"id\"`\n"+
+		"}\n\n"+
+		"type B struct {\n"+
+		"\tID int `json:\"id\"`\n"+
+		"\tLongName   string `json:\"long_name\"`\n"+
+		"}\n\n"+
+		"func  main( ) { fmt.Println( A{}, B{} ) }\n", out.String())
+}
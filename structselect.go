@@ -0,0 +1,360 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// formatStruct formats only the struct named name in the packages of paths, with
+// -w -l -d -n the files declaring it are processed as usual, otherwise the formatted
+// declaration is printed
+//
+//	tagfmt -struct User ./models
+func formatStruct(name string, paths []string) {
+	files, err := structFiles(name, paths)
+	if err != nil {
+		report(err)
+		return
+	}
+	if *write || *list || *doDiff || *dryRun {
+		processPaths(files, nil)
+		return
+	}
+	for i, filename := range files {
+		if i != 0 {
+			fmt.Fprintln(os.Stdout)
+		}
+		if err := printStruct(os.Stdout, name, filename); err != nil {
+			report(err)
+		}
+	}
+}
+
+// structArg returns the struct name of the subcommands of one struct e.g show User ./models,
+// it's the -struct of the subcommand, the -struct of the main command or the first of
+// args, the rest of args are the paths, the name is empty if there is none
+func structArg(name string, args []string) (string, []string) {
+	switch {
+	case name != "":
+		return name, args
+	case *structName != "":
+		return *structName, args
+	case len(args) == 0:
+		return "", nil
+	}
+	return args[0], args[1:]
+}
+
+// selectedStruct returns the struct name of a subcommand, or else the struct selected by
+// -struct or the struct patterns e.g -sp "^User$" of the main command, the patterns must
+// select one name in the packages of paths
+func selectedStruct(name string, paths []string) (string, error) {
+	if name != "" {
+		return name, nil
+	}
+	if *structName != "" {
+		return *structName, nil
+	}
+	opts := optionsFromFlags()
+	filter, err := opts.Filter()
+	if err != nil {
+		return "", err
+	}
+	files, err := packageFiles(paths)
+	if err != nil {
+		return "", err
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, filename := range files {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, filename, nil, parserMode)
+		if err != nil {
+			return "", err
+		}
+		for _, st := range previewStructs(fset, f, filter) {
+			if !seen[st.Name] {
+				seen[st.Name] = true
+				names = append(names, st.Name)
+			}
+		}
+	}
+	switch len(names) {
+	case 0:
+		return "", fmt.Errorf("%s matched no struct in %s", opts.structPatternDesc(), strings.Join(paths, " "))
+	case 1:
+		return names[0], nil
+	}
+	return "", fmt.Errorf("%s matched the structs %s, choose one by -struct", opts.structPatternDesc(), strings.Join(names, " "))
+}
+
+// structFiles returns the go files declaring the struct name in the packages of paths,
+// a directory is the package in it, a path ends with ... includes the sub packages,
+// with -struct-index only the files changed since the last lookup are parsed
+func structFiles(name string, paths []string) ([]string, error) {
+	candidates, err := packageFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	if *structIndexFlag {
+		idx := loadStructIndex(indexFile)
+		positions, err := idx.Lookup(name, candidates)
+		if err != nil {
+			return nil, err
+		}
+		if err := idx.Save(indexFile); err != nil {
+			return nil, err
+		}
+		files = indexedStructFiles(positions)
+	} else {
+		files, err = scanStructFiles(name, candidates)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("struct %s is not found in %s", name, strings.Join(paths, " "))
+	}
+	return files, nil
+}
+
+// uniqueStructFile returns the file declaring the struct name, the struct must be
+// declared once in paths
+func uniqueStructFile(name string, paths []string) (string, error) {
+	files, err := structFiles(name, paths)
+	if err != nil {
+		return "", err
+	}
+	if len(files) > 1 {
+		return "", fmt.Errorf("struct %s is declared in %s, choose one by the paths", name, strings.Join(files, " "))
+	}
+	return files[0], nil
+}
+
+// scanStructFiles parses the candidates mention name and returns the ones declaring it
+func scanStructFiles(name string, candidates []string) ([]string, error) {
+	var files []string
+	var errs tagDockerErr
+	for _, filename := range candidates {
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Contains(src, []byte(name)) {
+			continue
+		}
+		fs := token.NewFileSet()
+		f, err := parser.ParseFile(fs, filename, src, parserMode)
+		if err != nil {
+			// like the index, the struct in the other files is still found
+			errs = append(errs, err)
+			continue
+		}
+		if findStructDecl(f, name) != nil {
+			files = append(files, filename)
+		}
+	}
+	if len(files) == 0 && len(errs) != 0 {
+		return nil, errs
+	}
+	return files, nil
+}
+
+// packageFiles returns the go files in the packages of paths, a directory is the
+// package in it, a path ends with ... includes the sub packages, the templates and
+// the skipped _test.go files are excluded
+func packageFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		if strings.HasSuffix(path, "...") {
+			path = filepath.Clean(strings.TrimSuffix(path, "..."))
+			err := filepath.Walk(path, func(path string, f os.FileInfo, err error) error {
+				if err == nil && isGoFile(f) && !isTemplateFile(path) && !isSkippedTestFile(path) {
+					files = append(files, path)
+				}
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+		} else if dir, err := os.Stat(path); err != nil {
+			return nil, err
+		} else if dir.IsDir() {
+			infos, err := ioutil.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range infos {
+				if isGoFile(f) && !isTemplateFile(f.Name()) && !isSkippedTestFile(f.Name()) {
+					files = append(files, filepath.Join(path, f.Name()))
+				}
+			}
+		} else {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// findStructDecl returns the declaration of struct type name and its spec
+func findStructDecl(f *ast.File, name string) *ast.TypeSpec {
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if _, ok := ts.Type.(*ast.StructType); ok && ts.Name.Name == name {
+				return ts
+			}
+		}
+	}
+	return nil
+}
+
+// packageStructs parses the package of filename and returns its structs by name, the
+// embedded and nested structs of a struct are resolved in them
+func packageStructs(fset *token.FileSet, filename string) (map[string]*ast.StructType, error) {
+	files, err := packageFiles([]string{filepath.Dir(filename)})
+	if err != nil {
+		return nil, err
+	}
+	structs := map[string]*ast.StructType{}
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file, nil, parserMode)
+		if err != nil {
+			return nil, err
+		}
+		ast.Inspect(f, func(node ast.Node) bool {
+			if spec, ok := node.(*ast.TypeSpec); ok {
+				if st, ok := spec.Type.(*ast.StructType); ok {
+					structs[spec.Name.Name] = st
+				}
+			}
+			return true
+		})
+	}
+	return structs, nil
+}
+
+// keyField is an exported field named by the tag key or the field name
+type keyField struct {
+	field  *ast.Field
+	name   string
+	goName string
+	path   string // Struct.Field
+}
+
+// keyFields returns the fields of st named by key like encoding/json, the fields of -
+// and the unexported fields are skipped, the promoted fields of the embedded structs
+// without name are flattened, the embedded types are resolved in structs
+func keyFields(structs map[string]*ast.StructType, name string, st *ast.StructType, key string, seen map[*ast.StructType]bool) []keyField {
+	if st.Fields == nil || seen[st] {
+		return nil
+	}
+	seen[st] = true
+	defer delete(seen, st)
+	var fields []keyField
+	for _, field := range st.Fields.List {
+		tagName := ""
+		if field.Tag != nil {
+			tagName = keyName(field, key)
+		}
+		if tagName == "-" {
+			continue
+		}
+		if len(field.Names) == 0 && tagName == "" {
+			typ := field.Type
+			if star, ok := typ.(*ast.StarExpr); ok {
+				typ = star.X
+			}
+			var embedded *ast.StructType
+			switch t := typ.(type) {
+			case *ast.StructType:
+				embedded = t
+			case *ast.Ident:
+				embedded = structs[t.Name]
+			}
+			if embedded != nil {
+				fields = append(fields, keyFields(structs, embeddedName(field.Type), embedded, key, seen)...)
+				continue
+			}
+		}
+		for _, goName := range fieldNames(field) {
+			if !ast.IsExported(goName) {
+				continue
+			}
+			fieldName := tagName
+			if fieldName == "" {
+				fieldName = goName
+			}
+			fields = append(fields, keyField{field: field, name: fieldName, goName: goName, path: name + "." + goName})
+		}
+	}
+	return fields
+}
+
+// printStruct formats filename and writes the declaration of struct name, the struct
+// in a grouped declaration is written as a single declaration
+func printStruct(w io.Writer, name string, filename string) error {
+	var buf bytes.Buffer
+	if err := processFile(filename, nil, &buf, false); err != nil {
+		return err
+	}
+	res := buf.Bytes()
+	fs := token.NewFileSet()
+	f, err := parser.ParseFile(fs, filename, res, parserMode)
+	if err != nil {
+		return err
+	}
+	spec := findStructDecl(f, name)
+	if spec == nil {
+		return fmt.Errorf("struct %s is not found in %s", name, filename)
+	}
+	var decl *ast.GenDecl
+	for _, d := range f.Decls {
+		if gen, ok := d.(*ast.GenDecl); ok && gen.Pos() <= spec.Pos() && spec.End() <= gen.End() {
+			decl = gen
+			break
+		}
+	}
+	file := fs.File(f.Pos())
+	if !decl.Lparen.IsValid() {
+		start := decl.Pos()
+		if decl.Doc != nil {
+			start = decl.Doc.Pos()
+		}
+		_, err := fmt.Fprintf(w, "%s\n", res[file.Offset(start):file.Offset(decl.End())])
+		return err
+	}
+	start := spec.Pos()
+	if spec.Doc != nil {
+		start = spec.Doc.Pos()
+	}
+	// the type keyword is inserted after the doc comment
+	text := string(res[file.Offset(start):file.Offset(spec.Pos())]) + "type " +
+		string(res[file.Offset(spec.Pos()):file.Offset(spec.End())])
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, "\t")
+	}
+	_, err = fmt.Fprintf(w, "%s\n", strings.Join(lines, "\n"))
+	return err
+}
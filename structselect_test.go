@@ -0,0 +1,90 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatStruct(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	user := "package models\n\n" +
+		"// User is a user\n" +
+		"type User struct {\n" +
+		"\tName string `json:\"name\" xml:\"name\"`\n" +
+		"\tAge int `json:\"age\" xml:\"age\"`\n" +
+		"}\n\n" +
+		"type Other struct {\n" +
+		"\tA  string `json:\"a\" xml:\"a\"`\n" +
+		"\tBB int    `json:\"bb\" xml:\"bb\"`\n" +
+		"}\n"
+	group := "package models\n\n" +
+		"type (\n" +
+		"\t// Item is an item\n" +
+		"\tItem struct {\n" +
+		"\t\tID int `json:\"id\" xml:\"id\"`\n" +
+		"\t\tTitle string `json:\"title\" xml:\"title\"`\n" +
+		"\t}\n" +
+		")\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "user.go"), []byte(user), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "item.go"), []byte(group), 0644))
+
+	files, err := structFiles("User", []string{dir})
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "user.go")}, files)
+	_, err = structFiles("Missing", []string{dir})
+	assert.EqualError(t, err, "struct Missing is not found in "+dir)
+
+	var buf bytes.Buffer
+	require.NoError(t, printStruct(&buf, "User", filepath.Join(dir, "user.go")))
+	assert.Equal(t, "// User is a user\n"+
+		"type User struct {\n"+
+		"\tName string `json:\"name\" xml:\"name\"`\n"+
+		"\tAge  int    `json:\"age\"  xml:\"age\"`\n"+
+		"}\n", buf.String())
+
+	err = printStruct(&buf, "Item", filepath.Join(dir, "user.go"))
+	assert.EqualError(t, err, "struct Item is not found in "+filepath.Join(dir, "user.go"))
+
+	buf.Reset()
+	require.NoError(t, printStruct(&buf, "Item", filepath.Join(dir, "item.go")))
+	assert.Equal(t, "// Item is an item\n"+
+		"type Item struct {\n"+
+		"\tID    int    `json:\"id\"    xml:\"id\"`\n"+
+		"\tTitle string `json:\"title\" xml:\"title\"`\n"+
+		"}\n", buf.String())
+
+	// only the named struct is written, the struct pattern of config doesn't select
+	// the other struct
+	configName := filepath.Join(dir, "tagfmt.json")
+	require.NoError(t, ioutil.WriteFile(configName, []byte(`{"packages": [{"path": "...", "options": {"struct_pattern": "Other"}}]}`), 0644))
+	config, err = loadConfigCached(configName)
+	require.NoError(t, err)
+	*write = true
+	*structName = "User"
+	formatStruct("User", []string{dir + "/..."})
+	require.Equal(t, 0, exitCode)
+	res, err := ioutil.ReadFile(filepath.Join(dir, "user.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(res), "\tAge  int    `json:\"age\"  xml:\"age\"`\n")
+	assert.Contains(t, string(res), "\tA  string `json:\"a\" xml:\"a\"`\n")
+	res, err = ioutil.ReadFile(filepath.Join(dir, "item.go"))
+	require.NoError(t, err)
+	assert.Equal(t, group, string(res))
+}
@@ -0,0 +1,266 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// swagParam is a @Param annotation of swaggo, the attributes are the example(...)
+// and Format(...) after the description, structs are the structs the handler and the
+// types of its parameters refer to, the parameter is one of their fields
+type swagParam struct {
+	pos      token.Position
+	name     string
+	in       string
+	required bool
+	attrs    map[string]string // the lower case attribute name to the value
+	structs  []string
+}
+
+// swagField is a struct field can be a swaggo parameter, the name is from the tag key
+// of the parameter location
+type swagField struct {
+	pos        token.Position
+	structName string
+	path       string // Struct.Field
+	in         string
+	name       string
+	tags       map[string]string
+	required   bool
+}
+
+// swagParamKeys are the tag keys naming the parameter of location, they are the keys
+// of gin binding that swaggo reads for the struct parameters
+var swagParamKeys = map[string][]string{
+	"query":    {"form", "query"},
+	"formData": {"form"},
+	"path":     {"uri"},
+	"header":   {"header"},
+}
+
+var (
+	swagParamExpr = regexp.MustCompile(`@Param\s+(\S+)\s+(\S+)\s+(\S+)\s+(\S+)(?:\s+("[^"]*"|\S+))?(.*)`)
+	swagAttrExpr  = regexp.MustCompile(`(\w+)\(([^)]*)\)`)
+)
+
+// swagCheckMain reports the drift between the swaggo @Param annotations and the struct
+// tags of the parameters in the packages of paths, the example(...) and Format(...)
+// must agree with the example and format tags, the required must agree with the
+// required of binding or validate tag
+//
+//	tagfmt swag-check ./...
+func swagCheckMain(args []string) int {
+	fs := flag.NewFlagSet("swag-check", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := packageFiles(paths)
+	if err != nil {
+		commandError("swag-check", err)
+		return errorClass(err)
+	}
+	drifts, err := swagCheck(files)
+	if err != nil {
+		report(err)
+		return errorClass(err)
+	}
+	for _, drift := range drifts {
+		logError(logger, slog.LevelError, drift)
+	}
+	if len(drifts) != 0 {
+		return exitChanged
+	}
+	return exitOK
+}
+
+// swagCheck returns the drifts of the @Param annotations and the fields in files, the
+// annotation is compared with the fields of the structs its handler refers to, the
+// structs are of the package of the handler
+func swagCheck(files []string) ([]error, error) {
+	fset := token.NewFileSet()
+	parsed := make([]*ast.File, 0, len(files))
+	// the fields by the package directory and struct name
+	fields := map[string][]swagField{}
+	for _, filename := range files {
+		f, err := parser.ParseFile(fset, filename, nil, parserMode)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, f)
+		dir := filepath.Dir(filename)
+		for _, field := range swagFields(f, fset) {
+			key := dir + "\x00" + field.structName
+			fields[key] = append(fields[key], field)
+		}
+	}
+	var params []swagParam
+	for i, f := range parsed {
+		dir := filepath.Dir(files[i])
+		params = append(params, swagParams(f, fset, func(name string) bool {
+			_, ok := fields[dir+"\x00"+name]
+			return ok
+		})...)
+	}
+
+	var drifts []error
+	for _, param := range params {
+		var candidates []swagField
+		for _, name := range param.structs {
+			candidates = append(candidates, fields[filepath.Dir(param.pos.Filename)+"\x00"+name]...)
+		}
+		for _, field := range candidates {
+			if field.in != param.in || field.name != param.name {
+				continue
+			}
+			for _, attr := range []string{"example", "format"} {
+				value, ok := param.attrs[attr]
+				tag, tagged := field.tags[attr]
+				if ok && tagged && value != tag {
+					drifts = append(drifts, &AstError{Pos: param.pos, Err: fmt.Errorf(
+						"@Param %s %s(%s) doesn't agree with %s %s:%q at %s", param.name, attr, value, field.path, attr, tag, field.pos)})
+				}
+			}
+			if param.required != field.required {
+				drifts = append(drifts, &AstError{Pos: param.pos, Err: fmt.Errorf(
+					"@Param %s required %t doesn't agree with %s binding required %t at %s", param.name, param.required, field.path, field.required, field.pos)})
+			}
+		}
+	}
+	sort.SliceStable(drifts, func(i, j int) bool {
+		a, b := drifts[i].(*AstError).Pos, drifts[j].(*AstError).Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Line < b.Line
+	})
+	return drifts, nil
+}
+
+// swagParams returns the @Param annotations in the doc comments of the handlers of f,
+// isStruct reports whether the name is a struct of the package has parameter fields
+func swagParams(f *ast.File, fset *token.FileSet, isStruct func(name string) bool) []swagParam {
+	var params []swagParam
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Doc == nil {
+			continue
+		}
+		var structs []string
+		seen := map[string]bool{}
+		refer := func(name string) {
+			if !seen[name] && isStruct(name) {
+				seen[name] = true
+				structs = append(structs, name)
+			}
+		}
+		ast.Inspect(fn, func(node ast.Node) bool {
+			if ident, ok := node.(*ast.Ident); ok {
+				refer(ident.Name)
+			}
+			return true
+		})
+		start := len(params)
+		for _, c := range fn.Doc.List {
+			m := swagParamExpr.FindStringSubmatch(c.Text)
+			if m == nil {
+				continue
+			}
+			// the struct type of the parameter e.g @Param q query api.ListQuery
+			refer(m[3][strings.LastIndex(m[3], ".")+1:])
+			param := swagParam{
+				pos:      fset.Position(c.Slash),
+				name:     m[1],
+				in:       m[2],
+				required: m[4] == "true",
+				attrs:    map[string]string{},
+			}
+			for _, attr := range swagAttrExpr.FindAllStringSubmatch(m[6], -1) {
+				param.attrs[strings.ToLower(attr[1])] = attr[2]
+			}
+			params = append(params, param)
+		}
+		for i := start; i < len(params); i++ {
+			params[i].structs = structs
+		}
+	}
+	return params
+}
+
+// swagFields returns the fields of the structs in f named by the parameter keys
+func swagFields(f *ast.File, fset *token.FileSet) []swagField {
+	var fields []swagField
+	ast.Inspect(f, func(node ast.Node) bool {
+		spec, ok := node.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok || st.Fields == nil {
+			return false
+		}
+		for _, field := range st.Fields.List {
+			if field.Tag == nil || len(field.Names) == 0 {
+				continue
+			}
+			_, keyValues, err := ParseTag(field.Tag.Value)
+			if err != nil {
+				continue
+			}
+			tags := map[string]string{}
+			for _, kv := range keyValues {
+				tags[kv.Key] = kv.Value
+			}
+			required := false
+			for _, key := range []string{"binding", "validate"} {
+				for _, rule := range strings.Split(tags[key], ",") {
+					if rule == "required" {
+						required = true
+					}
+				}
+			}
+			for in, keys := range swagParamKeys {
+				for _, key := range keys {
+					value, ok := tags[key]
+					if !ok {
+						continue
+					}
+					name := strings.Split(value, ",")[0]
+					if name == "" || name == "-" {
+						continue
+					}
+					fields = append(fields, swagField{
+						pos:        fset.Position(field.Pos()),
+						structName: spec.Name.Name,
+						path:       spec.Name.Name + "." + field.Names[0].Name,
+						in:         in,
+						name:       name,
+						tags:       tags,
+						required:   required,
+					})
+					break
+				}
+			}
+		}
+		return false
+	})
+	return fields
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSwagCheck(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	src := "package api\n\n" +
+		"type ListQuery struct {\n" +
+		"\tPage  int    `form:\"page\" binding:\"required\" example:\"1\"`\n" +
+		"\tEmail string `form:\"email\" format:\"email\" example:\"a@b.c\"`\n" +
+		"\tID    int    `uri:\"id\" binding:\"required\"`\n" +
+		"}\n\n" +
+		"// List godoc\n" +
+		"// @Param page query int true \"page number\" example(2)\n" +
+		"// @Param email query string false \"email\" Format(uri) example(a@b.c)\n" +
+		"// @Param id path int false \"id\"\n" +
+		"// @Param body body ListQuery true \"body\"\n" +
+		"func List() {}\n\n" +
+		"type OtherQuery struct {\n" +
+		"\tPage int `form:\"page\" example:\"3\"`\n" +
+		"}\n\n" +
+		"// the fields of the structs the handler doesn't refer to are not compared\n" +
+		"// @Param page query int false \"page number\" example(3)\n" +
+		"func Other() {\n\tvar q OtherQuery\n\t_ = q\n}\n"
+	filename := filepath.Join(dir, "api.go")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(src), 0644))
+
+	drifts, err := swagCheck([]string{filename})
+	require.NoError(t, err)
+	var msgs []string
+	for _, drift := range drifts {
+		msgs = append(msgs, drift.Error())
+	}
+	assert.Equal(t, []string{
+		filename + `:10:1: @Param page example(2) doesn't agree with ListQuery.Page example:"1" at ` + filename + ":4:2",
+		filename + `:11:1: @Param email format(uri) doesn't agree with ListQuery.Email format:"email" at ` + filename + ":5:2",
+		filename + ":12:1: @Param id required false doesn't agree with ListQuery.ID binding required true at " + filename + ":6:2",
+	}, msgs)
+
+	// the annotations agree with the tags
+	src = "package api\n\n" +
+		"type ListQuery struct {\n" +
+		"\tPage int `form:\"page\" binding:\"required\" example:\"1\"`\n" +
+		"}\n\n" +
+		"// @Param page query int true \"page number\" example(1)\n" +
+		"func List() {}\n"
+	require.NoError(t, ioutil.WriteFile(filename, []byte(src), 0644))
+	drifts, err = swagCheck([]string{filename})
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+	assert.Equal(t, exitOK, swagCheckMain([]string{dir}))
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// walkFollowSymlinks walks the file tree like filepath.Walk but follows symlinks,
+// every file and directory is visited once, so a symlink cycle won't loop forever
+func walkFollowSymlinks(root string, walkFn filepath.WalkFunc) error {
+	visited := map[fileID]bool{}
+	return walkFollow(root, visited, walkFn)
+}
+
+func walkFollow(path string, visited map[fileID]bool, walkFn filepath.WalkFunc) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return walkFn(path, nil, err)
+	}
+	id, err := getFileID(path, fi)
+	if err != nil {
+		return walkFn(path, fi, err)
+	}
+	if visited[id] {
+		return nil
+	}
+	visited[id] = true
+
+	err = walkFn(path, fi, nil)
+	if err != nil || !fi.IsDir() {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return walkFn(path, fi, err)
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return walkFn(path, fi, err)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err := walkFollow(filepath.Join(path, name), visited, walkFn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWalkFollowSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlink requires privilege on windows")
+	}
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// dir/a/a.go
+	// dir/a/loop -> dir
+	// dir/b -> dir/a
+	// dir/gen/g.go -> dir/a/a.go
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "gen"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a", "a.go"), []byte("package a\n"), 0644))
+	require.NoError(t, os.Symlink(dir, filepath.Join(dir, "a", "loop")))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "a"), filepath.Join(dir, "b")))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "a", "a.go"), filepath.Join(dir, "gen", "g.go")))
+
+	var visited []string
+	err = walkFollowSymlinks(dir, func(path string, info os.FileInfo, err error) error {
+		require.NoError(t, err)
+		rel, err := filepath.Rel(dir, path)
+		require.NoError(t, err)
+		visited = append(visited, filepath.ToSlash(rel))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{".", "a", "a/a.go", "gen"}, visited)
+}
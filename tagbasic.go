@@ -5,21 +5,28 @@
  *
  */
 
-package main
+package tagfmt
 
-import "go/ast"
+import (
+	"go/ast"
+	"sort"
+	"strings"
+)
 
 type toyVisitExecutor func(name string, comments []*ast.CommentGroup, n *ast.StructType)
 
 type toyVisit struct {
 	executor toyVisitExecutor
+	filter   *Filter
 	cmap     ast.CommentMap
+	aliases  map[string][]string // type name => the alias names of this type
 	Comments []*ast.CommentGroup
 }
 
-func newTopVisit(cmap ast.CommentMap, executor toyVisitExecutor) *toyVisit {
+func newTopVisit(cmap ast.CommentMap, filter *Filter, executor toyVisitExecutor) *toyVisit {
 	return &toyVisit{
 		cmap:     cmap,
+		filter:   filter,
 		executor: executor,
 	}
 }
@@ -29,7 +36,9 @@ func (s *toyVisit) Copy() *toyVisit {
 	copy(comments, s.Comments)
 	return &toyVisit{
 		executor: s.executor,
+		filter:   s.filter,
 		cmap:     s.cmap,
+		aliases:  s.aliases,
 		Comments: comments,
 	}
 }
@@ -37,7 +46,9 @@ func (s *toyVisit) Copy() *toyVisit {
 func (s *toyVisit) WithComments(comments []*ast.CommentGroup) *toyVisit {
 	return &toyVisit{
 		executor: s.executor,
+		filter:   s.filter,
 		cmap:     s.cmap,
+		aliases:  s.aliases,
 		Comments: comments,
 	}
 }
@@ -55,6 +66,8 @@ func (s *toyVisit) rangeField(fields *ast.FieldList) {
 
 func (s *toyVisit) Visit(node ast.Node) ast.Visitor {
 	switch n := node.(type) {
+	case *ast.File:
+		s.aliases = typeAliases(n)
 	case *ast.GenDecl:
 		if comments := s.cmap[n]; len(comments) != 0 {
 			return s.WithComments(comments)
@@ -65,15 +78,32 @@ func (s *toyVisit) Visit(node ast.Node) ast.Visitor {
 		}
 	case *ast.TypeSpec:
 		name := n.Name.Name
-		if typ, ok := n.Type.(*ast.StructType); ok {
-			if structFieldSelect(name) {
+		// struct defined directly or indirectly e.g type Users []struct{...}
+		if typ := indirectStruct(n.Type); typ != nil && (s.filter.Proto || !isProtoGenerated(name, typ)) {
+			names := append([]string{name, typeSpecName(n)}, s.aliases[name]...)
+			if s.filter.selectStruct(typ, names...) {
 				s.executor(name, s.Comments, typ)
 				s.rangeField(typ.Fields)
 			}
 		}
 		return nil
+	case *ast.FuncType:
+		// the struct types of constraints are skipped, their tags are part of the type
+		// identity, changing them breaks the types satisfied the constraints
+		if n.TypeParams != nil {
+			if n.Params != nil {
+				ast.Walk(s, n.Params)
+			}
+			if n.Results != nil {
+				ast.Walk(s, n.Results)
+			}
+			return nil
+		}
+	case *ast.InterfaceType:
+		// the type sets of constraints and the method signatures, see FuncType
+		return nil
 	case *ast.StructType:
-		if structFieldSelect("") {
+		if s.filter.selectStruct(n, "") {
 			s.executor("", s.Comments, n)
 			s.rangeField(n.Fields)
 		}
@@ -81,3 +111,72 @@ func (s *toyVisit) Visit(node ast.Node) ast.Visitor {
 	}
 	return s
 }
+
+// typeSpecName returns type name with its type parameters e.g Pair[K, V]
+func typeSpecName(n *ast.TypeSpec) string {
+	if n.TypeParams == nil || len(n.TypeParams.List) == 0 {
+		return n.Name.Name
+	}
+	var params []string
+	for _, field := range n.TypeParams.List {
+		for _, name := range field.Names {
+			params = append(params, name.Name)
+		}
+	}
+	return n.Name.Name + "[" + strings.Join(params, ", ") + "]"
+}
+
+// indirectStruct returns the struct type defined through pointer, array, slice, map or chan
+func indirectStruct(expr ast.Expr) *ast.StructType {
+	for {
+		switch t := expr.(type) {
+		case *ast.StructType:
+			return t
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.ArrayType:
+			expr = t.Elt
+		case *ast.MapType:
+			expr = t.Value
+		case *ast.ChanType:
+			expr = t.Value
+		case *ast.ParenExpr:
+			expr = t.X
+		default:
+			return nil
+		}
+	}
+}
+
+// typeAliases returns type name => the names of alias or defined type base on it in file,
+// e.g type A = User; type B A; will get User => [A B], A => [B]
+func typeAliases(f *ast.File) map[string][]string {
+	base := map[string]string{}
+	ast.Inspect(f, func(node ast.Node) bool {
+		if spec, ok := node.(*ast.TypeSpec); ok {
+			expr := spec.Type
+			if index, ok := expr.(*ast.IndexExpr); ok {
+				expr = index.X
+			} else if index, ok := expr.(*ast.IndexListExpr); ok {
+				expr = index.X
+			}
+			if ident, ok := expr.(*ast.Ident); ok && ident.Name != spec.Name.Name {
+				base[spec.Name.Name] = ident.Name
+			}
+		}
+		return true
+	})
+	aliases := map[string][]string{}
+	for name := range base {
+		// walk the alias chain, visited avoid the invalid recursive alias
+		visited := map[string]bool{name: true}
+		for b, ok := base[name]; ok && !visited[b]; b, ok = base[b] {
+			visited[b] = true
+			aliases[b] = append(aliases[b], name)
+		}
+	}
+	for _, names := range aliases {
+		sort.Strings(names)
+	}
+	return aliases
+}
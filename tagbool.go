@@ -0,0 +1,43 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// boolTagKeys are the keys have the boolean value instead of a name, e.g swaggo
+// swaggerignore:"true" and envconfig split_words:"true", the fill of the missing keys
+// skips them and the doctor checks their values with -preset or -strict-keys
+var boolTagKeys = map[string]bool{
+	"swaggerignore": true, // github.com/swaggo/swag
+	"readonly":      true, // github.com/swaggo/swag
+	"split_words":   true, // github.com/kelseyhightower/envconfig
+	"ignored":       true, // github.com/kelseyhightower/envconfig
+}
+
+// unsetTagValue is the value of unset() fill rule, the key is removed instead of
+// being written, it can't be in a tag value
+const unsetTagValue = "\x00unset"
+
+// keepTagValue is the value of skip() fill rule and the unmatched conditional rules,
+// the value is kept as is and the missing key isn't added
+const keepTagValue = "\x00keep"
+
+// checkBoolTag checks the value of boolean key is true or false, the empty value is a
+// placeholder of unset key e.g swaggerignore:"" to be filled by set()
+func checkBoolTag(kv KeyValue) error {
+	if kv.Value == "" {
+		return nil
+	}
+	if _, err := strconv.ParseBool(kv.Value); err != nil {
+		return fmt.Errorf("%s is not a boolean value, use true or false", kv.String())
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+)
+
+// tagCommentReflow moves the comments between field type and tag to the field
+// tag end, e.g `ID int /* id */ `json:"id"` // pk` to `ID int `json:"id"` /* id */ // pk`,
+// the tag length changed by other executors makes these comments stranded in the
+// middle of the line, the line comments are aligned by printer
+type tagCommentReflow struct {
+	f      *ast.File
+	fs     *token.FileSet
+	filter *Filter
+	fields []*ast.Field
+}
+
+func (s *tagCommentReflow) Visit(node ast.Node) ast.Visitor {
+	cmap := fileCommentMap(s.fs, s.f)
+	visit := newTopVisit(cmap, s.filter, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagCommentReflow) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields != nil {
+		for _, field := range n.Fields.List {
+			if field.Tag != nil && s.filter.Field(name, getFieldName(field)) {
+				s.fields = append(s.fields, field)
+			}
+		}
+	}
+}
+
+// Scan moves comments before the other executors change the tag, the output moves
+// comments by position, so the comments are moved to the original tag end
+func (s *tagCommentReflow) Scan() error {
+	ast.Walk(s, s.f)
+	for _, field := range s.fields {
+		typeEnd, tagPos, tagEnd := field.Type.End(), field.Tag.Pos(), field.Tag.End()
+		i := sort.Search(len(s.f.Comments), func(i int) bool {
+			return s.f.Comments[i].Pos() >= typeEnd
+		})
+		for ; i < len(s.f.Comments) && s.f.Comments[i].End() <= tagPos; i++ {
+			// the comments keep their order, they are still before the line comment
+			for _, c := range s.f.Comments[i].List {
+				// the directive e.g /*line a.go:1*/ is for the next token, it stays
+				if isDirective(c.Text) {
+					continue
+				}
+				c.Slash = tagEnd
+			}
+		}
+	}
+	return nil
+}
+
+func (s *tagCommentReflow) Execute() error {
+	return nil
+}
+
+func newTagCommentReflow(f *ast.File, fs *token.FileSet, filter *Filter) *tagCommentReflow {
+	return &tagCommentReflow{f: f, fs: fs, filter: filter}
+}
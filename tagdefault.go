@@ -0,0 +1,314 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultSources are the sources of default() fill rule, tried in the order of
+// -default-source
+var defaultSources = []string{"const", "constructor"}
+
+// fieldDefaults is the default values of the struct fields in a package, they are
+// evaluated by type checking the package, so the constant expressions e.g 8 * 1024
+// and 5 * time.Second are filled as their values
+type fieldDefaults struct {
+	sources []string
+	// consts is the formatted values of the package level constants
+	consts map[string]string
+	// constructors is the formatted values of the fields set by the constructors,
+	// struct name => field name => value
+	constructors map[string]map[string]string
+}
+
+// lookup returns the default value of the field of struct, the const source is the
+// constant Default<Struct><Field> or <Struct><Field>Default, the constructor source is
+// the constant value set to the field in the function New<Struct> or Default<Struct>
+func (d *fieldDefaults) lookup(structName, field string) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+	for _, source := range d.sources {
+		switch source {
+		case "const":
+			for _, name := range []string{"Default" + structName + field, structName + field + "Default"} {
+				if value, ok := d.consts[name]; ok {
+					return value, true
+				}
+			}
+		case "constructor":
+			if value, ok := d.constructors[structName][field]; ok {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseDefaultSources parses the -default-source value e.g const,constructor
+func parseDefaultSources(s string) ([]string, error) {
+	var sources []string
+	for _, source := range strings.Split(s, ",") {
+		source = strings.TrimSpace(source)
+		switch source {
+		case "const", "constructor":
+		default:
+			return nil, fmt.Errorf("invalid default source %q, the sources are %s", source, strings.Join(defaultSources, " "))
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// fillUsesDefaults report whether the fill rule has default(), the package is type
+// checked only for it
+func fillUsesDefaults(rule string) bool {
+	cells, err := splitWithoutQuote(rule, '|')
+	if err != nil {
+		return false
+	}
+	for _, cell := range cells {
+		if keyVal := splitFillCell(cell); len(keyVal) == 2 && strings.TrimSpace(keyVal[1]) == "default()" {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldDefaultsCache is the loaded defaults by package directory and sources, the
+// files of a package share one type check, the loads are serialized because the
+// source importer depends on build.Default.Dir
+var fieldDefaultsCache = struct {
+	sync.Mutex
+	m map[string]cachedDefaults
+}{m: map[string]cachedDefaults{}}
+
+// cachedDefaults is the defaults of a package and the stamp of its files when they
+// are loaded, the daemon and worker load them again after the files change
+type cachedDefaults struct {
+	stamp    string
+	defaults *fieldDefaults
+}
+
+// packageStamp returns the names, modification times and sizes of the go files of
+// dir, the stat is cheaper than type checking the package on every request
+func packageStamp(dir string) (string, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, name := range bp.GoFiles {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", name, info.ModTime().UnixNano(), info.Size())
+	}
+	return b.String(), nil
+}
+
+// loadFieldDefaults type checks the package of dir and collects the defaults of
+// sources, the empty source is all of them, the package is read from the disk, so
+// the source from standard input uses the defaults of the package in -srcdir
+func loadFieldDefaults(dir, source string) (*fieldDefaults, error) {
+	if source == "" {
+		source = strings.Join(defaultSources, ",")
+	}
+	sources, err := parseDefaultSources(source)
+	if err != nil {
+		return nil, err
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	fieldDefaultsCache.Lock()
+	defer fieldDefaultsCache.Unlock()
+	cacheKey := dir + "\x00" + source
+	stamp, err := packageStamp(dir)
+	if err != nil {
+		return nil, fmt.Errorf("default(): %w", err)
+	}
+	if cached, ok := fieldDefaultsCache.m[cacheKey]; ok && cached.stamp == stamp {
+		return cached.defaults, nil
+	}
+	info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}}
+	pkg, files, err := typeCheckDir(dir, info)
+	if err != nil {
+		return nil, fmt.Errorf("default(): %w", err)
+	}
+	d := newFieldDefaults(sources, pkg, info, files)
+	fieldDefaultsCache.m[cacheKey] = cachedDefaults{stamp: stamp, defaults: d}
+	return d, nil
+}
+
+// loadFragmentDefaults type checks the source from standard input, its package clause
+// needn't match the directory, the files of dir in the same package are checked with
+// it, so are the files of the editor's package, the snippet without package clause
+// is in the package of dir, the errors e.g the unresolved imports degrade default()
+// to the values can be evaluated, they are warned instead of failing
+func loadFragmentDefaults(fset *token.FileSet, file *ast.File, dir, source string, snippet, warnErr bool) (*fieldDefaults, error) {
+	if source == "" {
+		source = strings.Join(defaultSources, ",")
+	}
+	sources, err := parseDefaultSources(source)
+	if err != nil {
+		return nil, err
+	}
+	files := []*ast.File{file}
+	filename := fset.Position(file.Package).Filename
+	if bp, err := build.ImportDir(dir, 0); err == nil && (snippet || bp.Name == file.Name.Name) {
+		if snippet {
+			// the copy is checked, the wrapper package clause is printed as is
+			renamed := *file
+			renamed.Name = ast.NewIdent(bp.Name)
+			files[0] = &renamed
+		}
+		for _, name := range bp.GoFiles {
+			path := filepath.Join(dir, name)
+			if sameFile(path, filename) {
+				continue
+			}
+			f, err := parser.ParseFile(fset, path, nil, parserMode)
+			if err != nil {
+				continue
+			}
+			files = append(files, f)
+		}
+	}
+	fieldDefaultsCache.Lock()
+	defer fieldDefaultsCache.Unlock()
+	info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}}
+	absDir, _ := filepath.Abs(dir)
+	pkg, err := checkFiles(absDir, files[0].Name.Name, fset, files, info)
+	if err != nil && warnErr {
+		warn(fmt.Errorf("default() uses the values evaluated without the type errors: %w", err))
+	}
+	return newFieldDefaults(sources, pkg, info, files), nil
+}
+
+// sameFile report whether the paths are the same file, the missing files aren't
+func sameFile(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	return err == nil && os.SameFile(fa, fb)
+}
+
+// newFieldDefaults collects the defaults of sources in the type checked files of pkg
+func newFieldDefaults(sources []string, pkg *types.Package, info *types.Info, files []*ast.File) *fieldDefaults {
+	d := &fieldDefaults{sources: sources, consts: map[string]string{}, constructors: map[string]map[string]string{}}
+	for _, name := range pkg.Scope().Names() {
+		// the unknown value depends on the type errors
+		if c, ok := pkg.Scope().Lookup(name).(*types.Const); ok && c.Val().Kind() != constant.Unknown {
+			d.consts[name] = formatDefault(c.Type(), c.Val())
+		}
+	}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || fn.Body == nil {
+				continue
+			}
+			for _, prefix := range []string{"New", "Default"} {
+				if name := strings.TrimPrefix(fn.Name.Name, prefix); name != fn.Name.Name && name != "" {
+					d.collectConstructor(pkg, info, name, fn.Body)
+				}
+			}
+		}
+	}
+	return d
+}
+
+// collectConstructor collects the constant values set to the fields of struct name in
+// the constructor body, by the composite literal e.g &Config{Port: 8080} or the
+// assignment e.g c.Port = 8080, the first value of a field is used
+func (d *fieldDefaults) collectConstructor(pkg *types.Package, info *types.Info, name string, body *ast.BlockStmt) {
+	isStruct := func(typ types.Type) bool {
+		if ptr, ok := typ.(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		named, ok := typ.(*types.Named)
+		return ok && named.Obj().Pkg() == pkg && named.Obj().Name() == name
+	}
+	set := func(field string, value ast.Expr) {
+		tv, ok := info.Types[value]
+		if !ok || tv.Value == nil || tv.Value.Kind() == constant.Unknown {
+			return
+		}
+		if d.constructors[name] == nil {
+			d.constructors[name] = map[string]string{}
+		}
+		if _, ok := d.constructors[name][field]; !ok {
+			d.constructors[name][field] = formatDefault(tv.Type, tv.Value)
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CompositeLit:
+			if !isStruct(info.Types[n].Type) {
+				return true
+			}
+			for _, elt := range n.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*ast.Ident); ok {
+						set(key.Name, kv.Value)
+					}
+				}
+			}
+		case *ast.AssignStmt:
+			if len(n.Lhs) != len(n.Rhs) {
+				return true
+			}
+			for i, lhs := range n.Lhs {
+				if sel, ok := lhs.(*ast.SelectorExpr); ok && isStruct(info.Types[sel.X].Type) {
+					set(sel.Sel.Name, n.Rhs[i])
+				}
+			}
+		}
+		return true
+	})
+}
+
+// formatDefault formats the constant value as the default tags of config libraries,
+// the string is unquoted and time.Duration is formatted like 5s
+func formatDefault(typ types.Type, value constant.Value) string {
+	if named, ok := typ.(*types.Named); ok && named.Obj().Pkg() != nil &&
+		named.Obj().Pkg().Path() == "time" && named.Obj().Name() == "Duration" {
+		if d, ok := constant.Int64Val(value); ok {
+			return time.Duration(d).String()
+		}
+	}
+	switch value.Kind() {
+	case constant.String:
+		return constant.StringVal(value)
+	case constant.Float:
+		f, _ := constant.Float64Val(value)
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	default:
+		return value.ExactString()
+	}
+}
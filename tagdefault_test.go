@@ -0,0 +1,120 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFillDefaults(t *testing.T) {
+	initParserMode()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/config\n\ngo 1.21\n"), 0644))
+	src := "package config\n\nimport \"time\"\n\n" +
+		"const (\n\tDefaultConfigPort = 8000 + 80\n\tConfigTimeoutDefault = 5 * time.Second\n)\n\n" +
+		"type Config struct {\n\tPort int ``\n\tTimeout time.Duration ``\n\tHost string ``\n\tRatio float64 ``\n\tDebug bool ``\n\tName string `default:\"keep\"`\n}\n\n" +
+		"func NewConfig() *Config {\n\tc := &Config{Host: \"local\" + \"host\", Port: 1}\n\tc.Ratio = 1.0 / 4\n\tc.Debug = true\n\treturn c\n}\n"
+	filename := filepath.Join(dir, "config.go")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(src), 0644))
+
+	format := func(source string) string {
+		var buf bytes.Buffer
+		opts := Options{Align: true, Fill: "default=default()", DefaultSource: source, Pattern: ".*", StructPattern: ".*"}
+		require.NoError(t, formatSource(&buf, filename, []byte(src), opts))
+		return buf.String()
+	}
+	res := format("")
+	assert.Contains(t, res, "\tPort    int           `default:\"8080\"`\n")
+	assert.Contains(t, res, "\tTimeout time.Duration `default:\"5s\"`\n")
+	assert.Contains(t, res, "\tHost    string        `default:\"localhost\"`\n")
+	assert.Contains(t, res, "\tRatio   float64       `default:\"0.25\"`\n")
+	assert.Contains(t, res, "\tDebug   bool          `default:\"true\"`\n")
+	// the field without default is kept
+	assert.Contains(t, res, "\tName    string        `default:\"keep\"`\n")
+
+	// the constructor is preferred
+	res = format("constructor,const")
+	assert.Contains(t, res, "\tPort    int           `default:\"1\"`\n")
+	assert.Contains(t, res, "\tTimeout time.Duration `default:\"5s\"`\n")
+
+	var buf bytes.Buffer
+	err = formatSource(&buf, filename, []byte(src), Options{Fill: "default=default()", DefaultSource: "env", Pattern: ".*", StructPattern: ".*"})
+	assert.EqualError(t, err, `invalid default source "env", the sources are const constructor`)
+	_, err = newTagFill(nil, nil, nil, "default=default()+'s'", false)
+	assert.EqualError(t, err, `-f "default=default()+'s'":8: default() must be the whole rule e.g default=default() (near "default()")`)
+}
+
+func TestFieldDefaultsReload(t *testing.T) {
+	initParserMode()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/config\n\ngo 1.21\n"), 0644))
+	filename := filepath.Join(dir, "config.go")
+	require.NoError(t, ioutil.WriteFile(filename, []byte("package config\n\nconst DefaultConfigPort = 80\n"), 0644))
+
+	d, err := loadFieldDefaults(dir, "const")
+	require.NoError(t, err)
+	value, _ := d.lookup("Config", "Port")
+	assert.Equal(t, "80", value)
+	// the daemon sees the changed constant
+	require.NoError(t, ioutil.WriteFile(filename, []byte("package config\n\nconst DefaultConfigPort = 8080\n"), 0644))
+	d, err = loadFieldDefaults(dir, "const")
+	require.NoError(t, err)
+	value, _ = d.lookup("Config", "Port")
+	assert.Equal(t, "8080", value)
+}
+
+func TestFragmentDefaults(t *testing.T) {
+	initParserMode()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/config\n\ngo 1.21\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "config.go"), []byte("package config\n\nconst DefaultServerPort = 80\n\nconst DefaultServerHost = \"disk\"\n"), 0644))
+	opts := Options{Align: true, Fill: "default=default()", Pattern: ".*", StructPattern: ".*"}
+
+	// the package clause matches no directory, the snippet is type checked alone
+	src := "package review\n\nconst DefaultServerPort = 8080\n\ntype Server struct {\n\tPort int ``\n\tHost string ``\n}\n"
+	res, err := formatFragment(filepath.Join(dir, "<standard input>"), []byte(src), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "package review\n\nconst DefaultServerPort = 8080\n\ntype Server struct {\n\tPort int    `default:\"8080\"`\n\tHost string ``\n}\n", string(res))
+
+	// the editor's buffer replaces its file in the package
+	src = "package config\n\nconst DefaultServerPort = 8080\n\ntype Server struct {\n\tPort int ``\n\tHost string ``\n}\n"
+	res, err = formatFragment(filepath.Join(dir, "config.go"), []byte(src), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "package config\n\nconst DefaultServerPort = 8080\n\ntype Server struct {\n\tPort int    `default:\"8080\"`\n\tHost string ``\n}\n", string(res))
+	res, err = formatFragment(filepath.Join(dir, "server.go"), []byte(src[len("package config\n\nconst DefaultServerPort = 8080\n"):]), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "\ntype Server struct {\n\tPort int    `default:\"80\"`\n\tHost string `default:\"disk\"`\n}\n", string(res))
+
+	// the snippet from standard input is in the package of -srcdir
+	resetFlags()
+	defer resetFlags()
+	*srcdir = dir
+	*fill = "default=default()"
+	var buf bytes.Buffer
+	require.NoError(t, processFile("<standard input>", strings.NewReader("type Server struct {\n\tHost string ``\n}\n"), &buf, true))
+	assert.Equal(t, "type Server struct {\n\tHost string `default:\"disk\"`\n}\n", buf.String())
+
+	// the type errors degrade to the values can be evaluated
+	src = "package review\n\nconst (\n\tDefaultServerPort = 8080\n\tDefaultServerHost = undefinedHost\n)\n\ntype Server struct {\n\tPort int ``\n\tHost string ``\n}\n"
+	res, err = formatFragment(filepath.Join(dir, "<standard input>"), []byte(src), opts)
+	require.NoError(t, err)
+	assert.Contains(t, string(res), "\tPort int    `default:\"8080\"`\n\tHost string ``\n")
+}
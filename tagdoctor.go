@@ -5,46 +5,94 @@
  *
  */
 
-package main
+package tagfmt
 
 import (
+	"fmt"
 	"go/ast"
 	"go/token"
+	"sort"
+	"strings"
 )
 
 const tagDockerMaxErr = 5
 
 type tagDockerErr []error
 
+// Error returns one error per line, every line starts with file:line:col
 func (e tagDockerErr) Error() string {
-	s := "detect error: \n"
+	var lines []string
 	for _, _e := range e {
-		s += "    " + _e.Error() + "\n"
+		lines = append(lines, _e.Error())
 	}
-	return s
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns the errors, errors.Is and errors.As check every one of them
+func (e tagDockerErr) Unwrap() []error {
+	return e
+}
+
+// appendErrors appends err to errs, the errors of tagDockerErr are flattened and the
+// error reported by several executors e.g the invalid tag is appended once
+func appendErrors(errs tagDockerErr, err error) tagDockerErr {
+	if err == nil {
+		return errs
+	}
+	if list, ok := err.(tagDockerErr); ok {
+		for _, e := range list {
+			errs = appendErrors(errs, e)
+		}
+		return errs
+	}
+	for _, e := range errs {
+		if e.Error() == err.Error() {
+			return errs
+		}
+	}
+	return append(errs, err)
 }
 
 type tagDoctor struct {
-	f   *ast.File
-	fs  *token.FileSet
-	Err tagDockerErr
+	f       *ast.File
+	fs      *token.FileSet
+	filter  *Filter
+	presets []*tagPreset    // the values of preset keys are checked
+	known   map[string]bool // the allowed keys, nil means all keys
+	// maxTagLen is the length budget of the tag literal, 0 means no limit
+	maxTagLen int
+	// positionKeys are the keys of the field positions e.g csv, their gaps and overlaps
+	// in a struct are reported
+	positionKeys []string
+	// checkBools checks the values of boolean keys, the generic names e.g readonly have
+	// other meanings out of swaggo and envconfig, so it's only by -preset or -strict-keys
+	checkBools bool
+	Err          tagDockerErr
 }
 
 func (s *tagDoctor) Visit(node ast.Node) ast.Visitor {
-	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
-	visit := newTopVisit(cmap, s.executor)
+	cmap := fileCommentMap(s.fs, s.f)
+	visit := newTopVisit(cmap, s.filter, s.executor)
 	return visit.Visit(node)
 }
 
 func (t *tagDoctor) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
 	if n.Fields != nil {
+		var tagged []*ast.Field
 		for _, field := range n.Fields.List {
 			fieldName := getFieldOrTypeName(field)
-			if fieldFilter(fieldName) == false {
+			if t.filter.Field(name, fieldName) == false {
 				continue
 			}
 			if field.Tag != nil {
-				_, _, err := ParseTag(field.Tag.Value)
+				tagged = append(tagged, field)
+				_, keyValues, err := ParseTag(field.Tag.Value)
+				if err == nil {
+					err = t.checkPresets(keyValues)
+				}
+				if err == nil {
+					err = t.checkLength(keyValues)
+				}
 				if err != nil {
 					if len(t.Err) < tagDockerMaxErr {
 						t.Err = append(t.Err, NewAstError(t.fs, field.Tag, err))
@@ -52,10 +100,64 @@ func (t *tagDoctor) executor(name string, comments []*ast.CommentGroup, n *ast.S
 				}
 			}
 		}
+		for _, key := range t.positionKeys {
+			for _, err := range checkPositions(t.fs, key, tagged) {
+				if len(t.Err) < tagDockerMaxErr {
+					t.Err = append(t.Err, err)
+				}
+			}
+		}
 	}
 	return
 }
 
+func (t *tagDoctor) checkPresets(keyValues []KeyValue) error {
+	for _, kv := range keyValues {
+		if t.known != nil && !t.known[kv.Key] {
+			return fmt.Errorf("unknown tag key %s", kv.Key)
+		}
+		if t.checkBools && boolTagKeys[kv.Key] {
+			if err := checkBoolTag(kv); err != nil {
+				return err
+			}
+		}
+		for _, p := range t.presets {
+			if p.Key == kv.Key {
+				if err := p.Check(kv.Value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkLength checks the length of tag literal is in the budget, the long tag is often
+// the validation logic should move out of it, the keys are listed from the longest.
+// the length is of the keys joined by one space and the quotes, the alignment padding
+// isn't counted
+func (t *tagDoctor) checkLength(keyValues []KeyValue) error {
+	length := len(`""`)
+	for i, kv := range keyValues {
+		if i != 0 {
+			length++
+		}
+		length += len(kv.String())
+	}
+	if t.maxTagLen == 0 || length <= t.maxTagLen {
+		return nil
+	}
+	sorted := append([]KeyValue(nil), keyValues...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].String()) > len(sorted[j].String())
+	})
+	var keys []string
+	for _, kv := range sorted {
+		keys = append(keys, fmt.Sprintf("%s %d", kv.Key, len(kv.String())))
+	}
+	return fmt.Errorf("tag length %d exceeds -max-tag-len %d (%s)", length, t.maxTagLen, strings.Join(keys, ", "))
+}
+
 func (t *tagDoctor) Scan() error {
 	ast.Walk(t, t.f)
 	if len(t.Err) != 0 {
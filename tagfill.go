@@ -5,25 +5,36 @@
 // *
 // */
 //
-package main
+package tagfmt
 
 import (
 	"errors"
+	"fmt"
 	"go/ast"
 	"go/token"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 )
 
 type tagFillerFields struct {
-	fields    []*ast.Field
-	keySet    map[string]struct{}
-	tagFilter map[string]bool
+	fields     []*ast.Field
+	keySet     map[string]struct{}
+	tagFilter  map[string]bool
+	structName string
+	// indexes is the position of the fields in the struct, the fields are a group of it
+	indexes map[*ast.Field]int
 }
 
 type ruleFuncArgs struct {
 	Field  *ast.Field
 	OldTag string // old tag value
+	Struct string // the struct name, empty for the struct declared without type name
+	// Defaults is the default values of default() rule, nil if the rule isn't used
+	Defaults *fieldDefaults
+	// Index is the position of the field in the struct from 0, it's :index
+	Index int
 }
 
 func newRuleArgs(f *ast.Field, oldTag string) *ruleFuncArgs {
@@ -39,8 +50,18 @@ type tagFiller struct {
 	Err          error
 	f            *ast.File
 	fs           *token.FileSet
+	filter       *Filter
 	ruleSet      map[string]tagFieldRule
 	needFillList []tagFillerFields
+	quiet        bool
+	// keepOptions keeps the options of the replaced values, see keepValueOptions
+	keepOptions bool
+	// defaults is the default values of default() rule
+	defaults *fieldDefaults
+	// conditional is the keys with the conditional rules, the recheck keeps their values,
+	// the conditions are checked against the source instead of the values just filled
+	conditional map[string]bool
+	recheck     bool
 }
 
 func ruleSetClone(rs map[string]tagFieldRule) map[string]tagFieldRule {
@@ -57,25 +78,38 @@ func (s *tagFiller) Scan() error {
 }
 
 func (s *tagFiller) Execute() error {
+	ruleSet := s.ruleSet
+	if s.recheck && len(s.conditional) != 0 {
+		ruleSet = ruleSetClone(ruleSet)
+		for key := range s.conditional {
+			ruleSet[key] = func(args *ruleFuncArgs) (newTagName string) {
+				return keepTagValue
+			}
+		}
+	}
 	for _, needFill := range s.needFillList {
 		if needFill.tagFilter == nil {
-			fieldsTagFill(needFill.fields, needFill.keySet, s.ruleSet)
+			if err := fieldsTagFill(s.fs, needFill, ruleSet, s.keepOptions, s.defaults); err != nil {
+				return err
+			}
 		} else {
-			ruleSet := map[string]tagFieldRule{}
-			for key, rule := range s.ruleSet {
+			filtered := map[string]tagFieldRule{}
+			for key, rule := range ruleSet {
 				if needFill.tagFilter[key] {
-					ruleSet[key] = rule
+					filtered[key] = rule
 				}
 			}
-			fieldsTagFill(needFill.fields, needFill.keySet, ruleSet)
+			if err := fieldsTagFill(s.fs, needFill, filtered, s.keepOptions, s.defaults); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
 func (s *tagFiller) Visit(node ast.Node) ast.Visitor {
-	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
-	visit := newTopVisit(cmap, s.executor)
+	cmap := fileCommentMap(s.fs, s.f)
+	visit := newTopVisit(cmap, s.filter, s.executor)
 	return visit.Visit(node)
 }
 
@@ -108,15 +142,23 @@ func (s *tagFiller) executor(name string, comments []*ast.CommentGroup, n *ast.S
 		var cacheFieldList []*ast.Field
 		var preFieldLine int
 		tagsFilter := s.findCommentTags(comments)
+		indexes := fieldIndexes(n)
 		for _, field := range n.Fields.List {
 			fieldName := getFieldOrTypeName(field)
-			if fieldFilter(fieldName) == false {
+			if s.filter.Field(name, fieldName) == false {
+				continue
+			}
+			// one tag can't hold the different names, skip it
+			if len(field.Names) > 1 && field.Tag != nil {
+				if !s.quiet {
+					warn(NewAstError(s.fs, field, ErrMultiNameField))
+				}
 				continue
 			}
 			line := s.fs.Position(field.Pos()).Line
 			// If there are blank lines or nil field tag in the structure, reset
 			if field.Tag == nil || preFieldLine+1 < line {
-				s.needFillList = append(s.needFillList, tagFillerFields{cacheFieldList, keySet, tagsFilter})
+				s.needFillList = append(s.needFillList, tagFillerFields{cacheFieldList, keySet, tagsFilter, name, indexes})
 				keySet = map[string]struct{}{}
 				cacheFieldList = nil
 			}
@@ -134,13 +176,20 @@ func (s *tagFiller) executor(name string, comments []*ast.CommentGroup, n *ast.S
 			}
 		}
 		if cacheFieldList != nil {
-			s.needFillList = append(s.needFillList, tagFillerFields{cacheFieldList, keySet, tagsFilter})
+			s.needFillList = append(s.needFillList, tagFillerFields{cacheFieldList, keySet, tagsFilter, name, indexes})
 		}
 	}
 }
 
-func fieldsTagFill(fields []*ast.Field, keySet map[string]struct{}, ruleSet map[string]tagFieldRule) {
-	for _, f := range fields {
+func fieldsTagFill(fs *token.FileSet, needFill tagFillerFields, ruleSet map[string]tagFieldRule, keepOptions bool, defaults *fieldDefaults) error {
+	keySet := needFill.keySet
+	newRuleArgs := func(f *ast.Field, oldTag string) *ruleFuncArgs {
+		args := newRuleArgs(f, oldTag)
+		args.Struct, args.Defaults = needFill.structName, defaults
+		args.Index = needFill.indexes[f]
+		return args
+	}
+	for _, f := range needFill.fields {
 		if f.Tag != nil {
 			rs := ruleSetClone(ruleSet)
 			fillMissing := ruleSet["*"]
@@ -148,8 +197,8 @@ func fieldsTagFill(fields []*ast.Field, keySet map[string]struct{}, ruleSet map[
 			var appendKeyValues []KeyValue
 			quote, keyValues, err := ParseTag(f.Tag.Value)
 			if err != nil {
-				// must be nil error
-				panic(err)
+				// the doctor reports it first unless it's dropped from the pipeline
+				return NewAstError(fs, f, err)
 			}
 			if fillMissing != nil {
 				missingKeySet := keySetClone(keySet)
@@ -157,6 +206,12 @@ func fieldsTagFill(fields []*ast.Field, keySet map[string]struct{}, ruleSet map[
 				for _, kv := range keyValues {
 					delete(missingKeySet, kv.Key)
 				}
+				// the boolean keys are not names, they are set by their own rules
+				for k := range missingKeySet {
+					if boolTagKeys[k] {
+						delete(missingKeySet, k)
+					}
+				}
 				for k := range rs {
 					delete(missingKeySet, k)
 				}
@@ -166,32 +221,50 @@ func fieldsTagFill(fields []*ast.Field, keySet map[string]struct{}, ruleSet map[
 				}
 
 				for _, k := range missingKeys {
-					appendKeyValues = append(appendKeyValues, KeyValue{
-						Key:   k,
-						quote: quote,
-						Value: fillMissing(newRuleArgs(f, "")),
-					})
+					if value := fillMissing(newRuleArgs(f, "")); value != keepTagValue {
+						appendKeyValues = append(appendKeyValues, KeyValue{
+							Key:   k,
+							quote: quote,
+							Value: value,
+						})
+					}
 				}
 
 				f.Tag.ValuePos = 0
 			}
 			missingRuleSet := ruleSetClone(rs)
 
-			for i, kv := range keyValues {
-				if rs[kv.Key] != nil {
-					keyValues[i].Value = rs[kv.Key](newRuleArgs(f, kv.Value))
-				}
-			}
 			for _, kv := range keyValues {
 				delete(missingRuleSet, kv.Key)
 			}
+			filled := keyValues[:0]
+			for _, kv := range keyValues {
+				if rs[kv.Key] != nil {
+					value := rs[kv.Key](newRuleArgs(f, kv.Value))
+					if value == unsetTagValue {
+						continue
+					}
+					if value == keepTagValue {
+						filled = append(filled, kv)
+						continue
+					}
+					if keepOptions {
+						value = keepValueOptions(kv.Value, value)
+					}
+					kv.Value = value
+				}
+				filled = append(filled, kv)
+			}
+			keyValues = filled
 
 			for k, rule := range missingRuleSet {
-				appendKeyValues = append(appendKeyValues, KeyValue{
-					Key:   k,
-					quote: quote,
-					Value: rule(newRuleArgs(f, "")),
-				})
+				if value := rule(newRuleArgs(f, "")); value != unsetTagValue && value != keepTagValue {
+					appendKeyValues = append(appendKeyValues, KeyValue{
+						Key:   k,
+						quote: quote,
+						Value: value,
+					})
+				}
 			}
 			sort.Slice(appendKeyValues, func(i, j int) bool {
 				return appendKeyValues[i].Key < appendKeyValues[j].Key
@@ -207,6 +280,17 @@ func fieldsTagFill(fields []*ast.Field, keySet map[string]struct{}, ruleSet map[
 		}
 
 	}
+	return nil
+}
+
+// keepValueOptions returns value with the options of old e.g ,omitempty,string, the
+// value has its own options or is empty e.g - is returned as is
+func keepValueOptions(old, value string) string {
+	i := strings.IndexByte(old, ',')
+	if i == -1 || value == "" || value == "-" || strings.Contains(value, ",") {
+		return value
+	}
+	return value + old[i:]
 }
 
 func keySetClone(keySet map[string]struct{}) map[string]struct{} {
@@ -221,6 +305,8 @@ func findRightBracket(r string) int {
 	c := 0 // extra left bracket count
 	for i := 0; i < len(r); i++ {
 		switch _c := r[i]; _c {
+		case '\\':
+			i++
 		case '(':
 			c++
 		case ')':
@@ -247,6 +333,8 @@ func splitPlusSign(r string) ([]string, error) {
 	s, e := 0, 0
 	for ; e < len(r); e++ {
 		switch c := r[e]; c {
+		case '\\':
+			e++
 		case '+':
 			rule = append(rule, r[s:e])
 			s = e + 1
@@ -254,14 +342,14 @@ func splitPlusSign(r string) ([]string, error) {
 			e++
 			i := findRightBracket(r[e:])
 			if i == -1 {
-				return nil, ErrUnclosedBracket
+				return nil, &ruleError{Text: r[e-1:], Err: ErrUnclosedBracket}
 			}
 			e += i
 		case '\'', '"':
 			e++
 			ni := findNextQuote(r, e, c)
 			if ni == -1 {
-				return nil, ErrUnclosedQuote
+				return nil, &ruleError{Text: r[e-1:], Err: ErrUnclosedQuote}
 			}
 			e = ni
 		}
@@ -272,32 +360,40 @@ func splitPlusSign(r string) ([]string, error) {
 	return rule, nil
 }
 
-func parseFieldRuleSingle(r string) (tagFieldRule, error) {
+// fillFunctions and fillVariables are the names can be used in fill rule
+var (
+	fillFunctions = []string{"upper", "lower", "snake", "upper_camel", "lower_camel", "title", "dot", "trimprefix", "trimsuffix", "replace", "coalesce", "key", "or", "set", "unset", "skip", "default", "layout"}
+	fillVariables = []string{":field", ":tag", ":tag_basic", ":tag_extra", ":proto_name", ":index"}
+	// bareKey is the unquoted arg of coalesce() read as the value of the key
+	bareKey = regexp.MustCompile(`^[A-Za-z_][\w.-]*$`)
+)
+
+func parseFieldRuleSingle(r string, strict bool) (tagFieldRule, error) {
 	if strings.HasSuffix(r, ")") { // function rule
 		bi := strings.Index(r, "(")
 		if bi == -1 {
-			return nil, errors.New("parse rule failure, invalid rule string")
+			return nil, &ruleError{Text: r, Err: errors.New("parse rule failure, invalid rule string")}
 		}
 		argsStr := r[bi+1 : len(r)-1]
 		switch r[:bi] {
 		case "upper":
-			subRuleList, err := parseFieldMultiRule(argsStr, 1)
+			subRuleList, err := parseFieldMultiRule(argsStr, 1, strict)
 			if err != nil {
 				return nil, err
 			}
 			return func(args *ruleFuncArgs) (newTagName string) {
-				return strings.ToUpper(subRuleList[0](args))
+				return asciiUpper(subRuleList[0](args))
 			}, nil
 		case "lower":
-			subRuleList, err := parseFieldMultiRule(argsStr, 1)
+			subRuleList, err := parseFieldMultiRule(argsStr, 1, strict)
 			if err != nil {
 				return nil, err
 			}
 			return func(args *ruleFuncArgs) (newTagName string) {
-				return strings.ToLower(subRuleList[0](args))
+				return asciiLower(subRuleList[0](args))
 			}, nil
 		case "snake":
-			subRuleList, err := parseFieldMultiRule(argsStr, 1)
+			subRuleList, err := parseFieldMultiRule(argsStr, 1, strict)
 			if err != nil {
 				return nil, err
 			}
@@ -305,7 +401,7 @@ func parseFieldRuleSingle(r string) (tagFieldRule, error) {
 				return snakeConvert(subRuleList[0](args))
 			}, nil
 		case "upper_camel":
-			subRuleList, err := parseFieldMultiRule(argsStr, 1)
+			subRuleList, err := parseFieldMultiRule(argsStr, 1, strict)
 			if err != nil {
 				return nil, err
 			}
@@ -313,15 +409,88 @@ func parseFieldRuleSingle(r string) (tagFieldRule, error) {
 				return upperCamelConvert(subRuleList[0](args))
 			}, nil
 		case "lower_camel":
-			subRuleList, err := parseFieldMultiRule(argsStr, 1)
+			subRuleList, err := parseFieldMultiRule(argsStr, 1, strict)
 			if err != nil {
 				return nil, err
 			}
 			return func(args *ruleFuncArgs) (newTagName string) {
 				return lowerCamelConvert(subRuleList[0](args))
 			}, nil
+		case "title":
+			subRuleList, err := parseFieldMultiRule(argsStr, 1, strict)
+			if err != nil {
+				return nil, err
+			}
+			return func(args *ruleFuncArgs) (newTagName string) {
+				return titleConvert(subRuleList[0](args))
+			}, nil
+		case "dot":
+			subRuleList, err := parseFieldMultiRule(argsStr, 1, strict)
+			if err != nil {
+				return nil, err
+			}
+			return func(args *ruleFuncArgs) (newTagName string) {
+				return dotConvert(subRuleList[0](args))
+			}, nil
+		case "trimprefix", "trimsuffix":
+			subRuleList, err := parseFieldMultiRule(argsStr, 2, strict)
+			if err != nil {
+				return nil, err
+			}
+			trim := strings.TrimPrefix
+			if r[:bi] == "trimsuffix" {
+				trim = strings.TrimSuffix
+			}
+			return func(args *ruleFuncArgs) (newTagName string) {
+				return trim(subRuleList[0](args), subRuleList[1](args))
+			}, nil
+		case "replace":
+			subRuleList, err := parseFieldMultiRule(argsStr, 3, strict)
+			if err != nil {
+				return nil, err
+			}
+			return func(args *ruleFuncArgs) (newTagName string) {
+				return strings.ReplaceAll(subRuleList[0](args), subRuleList[1](args), subRuleList[2](args))
+			}, nil
+		case "coalesce":
+			// the bare key name is the value of the key e.g coalesce(json,snake(:field))
+			coalesceArgs, err := splitArgs(argsStr)
+			if err != nil {
+				return nil, err
+			}
+			// the skip value - of a key isn't a name of the other keys, it's absent
+			keyArgs := make([]bool, len(coalesceArgs))
+			for i, arg := range coalesceArgs {
+				if bareKey.MatchString(strings.TrimSpace(arg)) {
+					coalesceArgs[i] = "key(" + strings.TrimSpace(arg) + ")"
+				}
+				keyArgs[i] = strings.HasPrefix(strings.TrimSpace(coalesceArgs[i]), "key(")
+			}
+			subRuleList, err := parseFieldMultiRule(strings.Join(coalesceArgs, ","), -1, strict)
+			if err != nil {
+				return nil, err
+			}
+			if len(subRuleList) < 2 {
+				return nil, &ruleError{Text: r, Err: errors.New("coalesce() needs 2 args at least")}
+			}
+			return func(args *ruleFuncArgs) (newTagName string) {
+				for i, rule := range subRuleList {
+					if val := rule(args); val != "" && !(keyArgs[i] && val == "-") {
+						return val
+					}
+				}
+				return ""
+			}, nil
+		case "key":
+			key := strings.Trim(strings.TrimSpace(argsStr), `'"`)
+			if key == "" || strings.ContainsAny(key, ":\"'` ,") {
+				return nil, &ruleError{Text: r, Err: fmt.Errorf("invalid key %q of key(), it must be a tag key e.g key(json)", argsStr)}
+			}
+			return func(args *ruleFuncArgs) (newTagName string) {
+				return keyName(args.Field, key)
+			}, nil
 		case "or":
-			subRuleList, err := parseFieldMultiRule(argsStr, 2)
+			subRuleList, err := parseFieldMultiRule(argsStr, 2, strict)
 			if err != nil {
 				return nil, err
 			}
@@ -332,11 +501,27 @@ func parseFieldRuleSingle(r string) (tagFieldRule, error) {
 				}
 				return subRuleList[1](args)
 			}, nil
+		case "set":
+			if strings.TrimSpace(argsStr) != "" {
+				return nil, &ruleError{Text: r, Err: errors.New("set() has no args")}
+			}
+			return func(args *ruleFuncArgs) (newTagName string) {
+				return "true"
+			}, nil
+		case "unset":
+			return nil, &ruleError{Text: r, Err: errors.New("unset() must be the whole rule e.g swaggerignore=unset()")}
+		case "skip":
+			return nil, &ruleError{Text: r, Err: errors.New(`skip() must be the whole rule e.g json[val="-"]=skip()`)}
+		case "default":
+			return nil, &ruleError{Text: r, Err: errors.New("default() must be the whole rule e.g default=default()")}
+		case "layout":
+			return nil, &ruleError{Text: r, Err: errors.New("layout() must be the whole rule e.g fixed=layout('layout.json')")}
 		default:
-			return nil, errors.New("invalid field rule " + r[:bi])
+			return nil, &ruleError{Text: r, Err: fmt.Errorf("invalid field rule %s, the functions are %s", r[:bi], strings.Join(fillFunctions, " "))}
 		}
 	} else {
-		if len(r) > 0 && (r[0] == '\'' || r[0] == '"') {
+		quoted := len(r) > 0 && (r[0] == '\'' || r[0] == '"')
+		if quoted {
 			r = strings.Trim(r, string(r[0]))
 		}
 		if r == ":field" { // fetch field name
@@ -361,14 +546,70 @@ func parseFieldRuleSingle(r string) (tagFieldRule, error) {
 				}
 				return ""
 			}, nil
+		} else if r == ":proto_name" { // fetch the name of protobuf tag
+			return func(args *ruleFuncArgs) (newTagName string) {
+				return protoName(args.Field)
+			}, nil
+		} else if r == ":index" { // fetch the position of field in struct
+			return func(args *ruleFuncArgs) (newTagName string) {
+				return strconv.Itoa(args.Index)
+			}, nil
 		} else {
+			if strict && !quoted {
+				return nil, &ruleError{Text: r, Err: fmt.Errorf("unknown variable %s, the variables are %s, quote the literal text e.g '%s'", r, strings.Join(fillVariables, " "), r)}
+			}
+			literal := unescapeRule(r)
 			return func(args *ruleFuncArgs) (newTagName string) {
-				return r
+				return literal
 			}, nil
 		}
 	}
 }
 
+// keyName returns the name of key in the field tag, it's the value before the first ',',
+// empty if the field hasn't the key
+func keyName(field *ast.Field, key string) string {
+	if field.Tag == nil {
+		return ""
+	}
+	_, keyValues, err := ParseTag(field.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	for _, kv := range keyValues {
+		if kv.Key == key {
+			return strings.SplitN(kv.Value, ",", 2)[0]
+		}
+	}
+	return ""
+}
+
+// protoName returns the proto field name in the tag of the field generated by
+// protoc-gen-go, it's the name option of protobuf:"bytes,1,opt,name=foo_bar,proto3" or
+// the value of protobuf_oneof:"foo_bar", empty if the field has neither
+func protoName(field *ast.Field) string {
+	if field.Tag == nil {
+		return ""
+	}
+	_, keyValues, err := ParseTag(field.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	for _, kv := range keyValues {
+		switch kv.Key {
+		case "protobuf":
+			for _, opt := range strings.Split(kv.Value, ",") {
+				if strings.HasPrefix(opt, "name=") {
+					return strings.TrimPrefix(opt, "name=")
+				}
+			}
+		case "protobuf_oneof":
+			return kv.Value
+		}
+	}
+	return ""
+}
+
 func findNextQuote(s string, i int, quote byte) int {
 	for j := i; j < len(s); j++ {
 		switch o := s[j]; o {
@@ -381,41 +622,135 @@ func findNextQuote(s string, i int, quote byte) int {
 	return -1
 }
 
+// splitWithoutQuote splits s by key like splitUnquoted, the trailing empty part is dropped
 func splitWithoutQuote(s string, key byte) ([]string, error) {
+	sub, err := splitUnquoted(s, key)
+	if err != nil {
+		return nil, err
+	}
+	if sub[len(sub)-1] == "" {
+		sub = sub[:len(sub)-1]
+	}
+	return sub, nil
+}
+
+// splitUnquoted splits s by the sep out of the quotes and not escaped by backslash,
+// the parts are raw, so their lengths locate the errors in the flag value
+func splitUnquoted(s string, sep byte) ([]string, error) {
 	var sub []string
 	pre := 0
 	for i := 0; i < len(s); i++ {
-		c := s[i]
-		if s[i] == '"' || s[i] == '\'' {
+		switch c := s[i]; c {
+		case '\\':
+			i++
+		case '"', '\'':
 			nextQuote := findNextQuote(s, i+1, c)
 			if nextQuote == -1 {
-				return nil, ErrUnclosedQuote
+				return nil, &ruleError{Text: s[i:], Err: ErrUnclosedQuote}
 			}
 			i = nextQuote
-		} else if s[i] == key {
+		case sep:
 			sub = append(sub, s[pre:i])
 			pre = i + 1
+		}
+	}
+	return append(sub, s[pre:]), nil
+}
 
+// indexUnquoted returns the index of the first c out of the quotes and not escaped by
+// backslash, -1 if there is none
+func indexUnquoted(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"', '\'':
+			end := findNextQuote(s, i+1, s[i])
+			if end == -1 {
+				return -1
+			}
+			i = end
+		case c:
+			return i
+		}
+	}
+	return -1
+}
+
+// ruleEscapes are the characters of the flag mini-languages can be escaped by backslash
+// e.g oneof\=a\|b, the backslash before the other characters is kept, so the literal
+// regular expressions e.g \d+ needn't be escaped twice
+const ruleEscapes = `|=+,()'"\`
+
+// unescapeRule removes the backslash before the escapable characters
+func unescapeRule(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(ruleEscapes, s[i+1]) != -1 {
+			i++
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.String()
+}
+
+// unquoteRuleKey returns the key of the -so and -sw cells, the quoted key e.g 'a|b'
+// and the escaped key e.g a\|b can contain the separators
+func unquoteRuleKey(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && findNextQuote(s, 1, s[0]) == len(s)-1 {
+		s = s[1 : len(s)-1]
+	}
+	return unescapeRule(s)
+}
+
+// splitArgs splits the function args by the commas out of the quotes and the brackets,
+// the arg can be a function with several args e.g replace(trimprefix(:tag,'x'),'a','b')
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	pre := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			i++
+		case '"', '\'':
+			nextQuote := findNextQuote(s, i+1, c)
+			if nextQuote == -1 {
+				return nil, &ruleError{Text: s[i:], Err: ErrUnclosedQuote}
+			}
+			i = nextQuote
+		case '(':
+			end := findRightBracket(s[i+1:])
+			if end == -1 {
+				return nil, &ruleError{Text: s[i:], Err: ErrUnclosedBracket}
+			}
+			i += end + 1
+		case ',':
+			args = append(args, s[pre:i])
+			pre = i + 1
 		}
 	}
 	if pre != len(s) {
-		sub = append(sub, s[pre:])
+		args = append(args, s[pre:])
 	}
-	return sub, nil
+	return args, nil
 }
 
 // parse multiple rule, split with ',',
 // r: is the rule string
 // argsNum: args number limit, return error if args not equal to the argsNum
-// e.g: parseFieldMultiRule(":tag, My+',omitempty'", 2) => will get two tagFieldRule
-func parseFieldMultiRule(r string, argsNum int) ([]tagFieldRule, error) {
+// e.g: parseFieldMultiRule(":tag, My+',omitempty'", 2) => will get two tagFieldRule, argsNum -1 means any number
+func parseFieldMultiRule(r string, argsNum int, strict bool) ([]tagFieldRule, error) {
 	r = strings.TrimSpace(r)
-	rSplitComma, err := splitWithoutQuote(r, ',')
+	rSplitComma, err := splitArgs(r)
 	if err != nil {
 		return nil, err
 	}
-	if len(rSplitComma) != argsNum {
-		return nil, errors.New("args number wrong")
+	if argsNum != -1 && len(rSplitComma) != argsNum {
+		return nil, &ruleError{Text: r, Err: fmt.Errorf("args number wrong, want %d args", argsNum)}
 	}
 	var ruleList []tagFieldRule
 	for _, rule := range rSplitComma {
@@ -425,7 +760,7 @@ func parseFieldMultiRule(r string, argsNum int) ([]tagFieldRule, error) {
 		}
 		var subRules []tagFieldRule
 		for _, r := range ruleStrList {
-			single, err := parseFieldRuleSingle(r)
+			single, err := parseFieldRuleSingle(r, strict)
 			if err != nil {
 				return nil, err
 			}
@@ -443,14 +778,14 @@ func parseFieldMultiRule(r string, argsNum int) ([]tagFieldRule, error) {
 }
 
 // parse with '+' rule
-func parseFieldRulePlus(r string) (tagFieldRule, error) {
+func parseFieldRulePlus(r string, strict bool) (tagFieldRule, error) {
 	ruleStrList, err := splitPlusSign(r)
 	if err != nil {
 		return nil, err
 	}
 	var subRules []tagFieldRule
 	for _, r := range ruleStrList {
-		single, err := parseFieldRuleSingle(r)
+		single, err := parseFieldRuleSingle(r, strict)
 		if err != nil {
 			return nil, err
 		}
@@ -465,48 +800,244 @@ func parseFieldRulePlus(r string) (tagFieldRule, error) {
 	}, nil
 }
 
+// parseFillKeys returns the keys of rule key part, the grouped keys
+// e.g (json,yaml) share the same rule
+func parseFillKeys(s string) ([]string, error) {
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "(") {
+		return []string{s}, nil
+	}
+	if !strings.HasSuffix(trimmed, ")") {
+		return nil, &ruleError{Text: s, Err: errors.New("invalid fill key group (" + s + ")")}
+	}
+	var keys []string
+	for _, key := range strings.Split(trimmed[1:len(trimmed)-1], ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, &ruleError{Text: s, Err: errors.New("invalid fill key group (" + s + ")")}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
 func parseFieldRule(s string) (map[string]tagFieldRule, error) {
+	return parseFillRule(s, false)
+}
+
+// fillCondition is the predicate of the conditional fill rule e.g json[val=""],
+// the current value of key is compared, the missing key has the empty value
+type fillCondition struct {
+	value string
+	not   bool
+}
+
+func (c *fillCondition) match(value string) bool {
+	return (value == c.value) != c.not
+}
+
+// conditionalRule is a rule of the key, cond is nil for the unconditional rule
+type conditionalRule struct {
+	cond *fillCondition
+	rule tagFieldRule
+}
+
+// splitFillCell splits the cell to the key part and the rule, the = in the condition
+// of the key part e.g json[val="-"]=skip() isn't the separator
+func splitFillCell(cell string) []string {
+	start := strings.IndexByte(cell, '[')
+	if eq := indexUnquoted(cell, '='); start == -1 || (eq != -1 && eq < start) {
+		if eq == -1 {
+			return []string{cell}
+		}
+		return []string{cell[:eq], cell[eq+1:]}
+	}
+	for i := start + 1; i < len(cell); i++ {
+		switch cell[i] {
+		case '\'', '"':
+			if end := findNextQuote(cell, i+1, cell[i]); end != -1 {
+				i = end
+			}
+		case ']':
+			if eq := strings.IndexByte(cell[i:], '='); eq != -1 {
+				return []string{cell[:i+eq], cell[i+eq+1:]}
+			}
+			return []string{cell}
+		}
+	}
+	return []string{cell}
+}
+
+// parseFillCondition splits the key part to the keys and the condition e.g
+// json[val=""] and (json,yaml)[val!='-'], the condition value is quoted
+func parseFillCondition(s string) (string, *fillCondition, error) {
+	trimmed := strings.TrimSpace(s)
+	start := strings.IndexByte(trimmed, '[')
+	if start == -1 {
+		return s, nil, nil
+	}
+	invalid := &ruleError{Text: trimmed[start:], Err: fmt.Errorf(`invalid fill condition %s, must be [val="value"] or [val!="value"]`, trimmed[start:])}
+	if !strings.HasSuffix(trimmed, "]") {
+		return "", nil, invalid
+	}
+	expr := strings.TrimSpace(trimmed[start+1 : len(trimmed)-1])
+	cond := &fillCondition{}
+	switch {
+	case strings.HasPrefix(expr, "val!="):
+		cond.not, expr = true, expr[len("val!="):]
+	case strings.HasPrefix(expr, "val="):
+		expr = expr[len("val="):]
+	default:
+		return "", nil, invalid
+	}
+	expr = strings.TrimSpace(expr)
+	if len(expr) < 2 || (expr[0] != '\'' && expr[0] != '"') || findNextQuote(expr, 1, expr[0]) != len(expr)-1 {
+		return "", nil, invalid
+	}
+	cond.value = expr[1 : len(expr)-1]
+	return trimmed[:start], cond, nil
+}
+
+// chainRules returns the rule of the conditional rules, the first matched rule
+// is applied, the value is kept if none of them is matched
+func chainRules(rules []conditionalRule) tagFieldRule {
+	return func(args *ruleFuncArgs) (newTagName string) {
+		for _, r := range rules {
+			if r.cond == nil || r.cond.match(args.OldTag) {
+				return r.rule(args)
+			}
+		}
+		return keepTagValue
+	}
+}
+
+// parseFillRule parses the fill rule, in strict mode the unquoted text must be a
+// variable, so the typo e.g :feild isn't filled as literal text, the rules of a key
+// with conditions e.g json[val="-"]=skip()|json=snake(:field) are tried in order
+func parseFillRule(s string, strict bool) (map[string]tagFieldRule, error) {
+	rules, _, err := parseConditionalFillRule(s, strict)
+	return rules, err
+}
+
+// parseConditionalFillRule is parseFillRule that also returns the keys with the
+// conditional rules
+func parseConditionalFillRule(s string, strict bool) (map[string]tagFieldRule, map[string]bool, error) {
 	rules := map[string]tagFieldRule{}
+	conditional := map[string][]conditionalRule{}
 	var err error
 	ruleList, err := splitWithoutQuote(s, '|')
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	offset := 0
 	for _, cell := range ruleList {
-		keyVal := strings.SplitN(cell, "=", 2)
+		cellOffset := offset
+		offset += len(cell) + 1
+		keyVal := splitFillCell(cell)
+		keyPart, cond, err := parseFillCondition(keyVal[0])
+		if err != nil {
+			return nil, nil, locateRuleError(err, keyVal[0], cellOffset)
+		}
+		keys, err := parseFillKeys(keyPart)
+		if err != nil {
+			return nil, nil, locateRuleError(err, keyVal[0], cellOffset)
+		}
+		var rule tagFieldRule
+		switch {
 		// if value is nil ,use key hold rule
-		if len(keyVal) == 1 {
-			rules[keyVal[0]], err = parseFieldRulePlus("")
-			if err != nil { // error must be not nil
-				panic(err)
-			}
-			rules[keyVal[0]] = func(info *ruleFuncArgs) (newTagName string) {
+		case len(keyVal) == 1:
+			rule = func(info *ruleFuncArgs) (newTagName string) {
 				return ""
 			}
-			continue
-		}
-		if len(keyVal) != 2 {
-			return nil, errors.New("invalid fill rule (" + cell + ")")
+		case strings.TrimSpace(keyVal[1]) == "unset()":
+			rule = func(info *ruleFuncArgs) (newTagName string) {
+				return unsetTagValue
+			}
+		case strings.TrimSpace(keyVal[1]) == "skip()" || strings.TrimSpace(keyVal[1]) == "skip":
+			rule = func(info *ruleFuncArgs) (newTagName string) {
+				return keepTagValue
+			}
+		case strings.TrimSpace(keyVal[1]) == "default()":
+			rule = func(info *ruleFuncArgs) (newTagName string) {
+				if value, ok := info.Defaults.lookup(info.Struct, getFieldName(info.Field)); ok {
+					return value
+				}
+				return keepTagValue
+			}
+		case strings.HasPrefix(strings.TrimSpace(keyVal[1]), "layout("):
+			spec, err := parseLayoutRule(keyVal[1])
+			if err != nil {
+				return nil, nil, locateRuleError(err, keyVal[1], cellOffset+len(keyVal[0])+1)
+			}
+			rule = func(info *ruleFuncArgs) (newTagName string) {
+				if value, ok := spec.lookup(info.Struct, getFieldName(info.Field)); ok {
+					return value
+				}
+				return keepTagValue
+			}
+		default:
+			rule, err = parseFieldRulePlus(keyVal[1], strict)
+			if err != nil {
+				return nil, nil, locateRuleError(err, keyVal[1], cellOffset+len(keyVal[0])+1)
+			}
 		}
-
-		rule, err := parseFieldRulePlus(keyVal[1])
-		if err != nil {
-			return nil, err
+		for _, key := range keys {
+			if cond == nil && conditional[key] == nil {
+				rules[key] = rule
+				continue
+			}
+			// the unconditional rule before the first condition is tried in its order
+			if prev, ok := rules[key]; ok && conditional[key] == nil {
+				conditional[key] = []conditionalRule{{rule: prev}}
+			}
+			conditional[key] = append(conditional[key], conditionalRule{cond: cond, rule: rule})
 		}
-		rules[keyVal[0]] = rule
 	}
-	return rules, nil
+	conditionalKeys := map[string]bool{}
+	for key, list := range conditional {
+		rules[key] = chainRules(list)
+		conditionalKeys[key] = true
+	}
+	return rules, conditionalKeys, nil
 }
 
-func newTagFill(f *ast.File, fs *token.FileSet, rule string) (*tagFiller, error) {
-	ruleSet, err := parseFieldRule(rule)
+func newTagFill(f *ast.File, fs *token.FileSet, filter *Filter, rule string, strict bool) (*tagFiller, error) {
+	ruleSet, conditional, err := parseConditionalFillRule(rule, strict)
 	if err != nil {
-		return nil, err
+		return nil, newFlagError("-f", rule, err)
 	}
-	s := &tagFiller{fs: fs, f: f, ruleSet: ruleSet}
+	s := &tagFiller{fs: fs, f: f, filter: filter, ruleSet: ruleSet, conditional: conditional}
 	return s, nil
 }
 
+// asciiUpper maps a-z to A-Z, the other runes are kept, unlike strings.ToUpper the
+// result doesn't depend on the unicode tables of the go version e.g the special cases
+// of ß and ǅ, so the same rule fills the same tags everywhere like the other cases
+func asciiUpper(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'a' && c <= 'z' {
+			b[i] = c - 'a' + 'A'
+		}
+	}
+	return string(b)
+}
+
+// asciiLower maps A-Z to a-z, the other runes are kept, e.g İ isn't mapped to the
+// two runes i̇ like strings.ToLower
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c - 'A' + 'a'
+		}
+	}
+	return string(b)
+}
+
+// snakeConvert converts name to snake case, the last upper case letter of an acronym
+// followed by lower case starts the next word e.g HTTPServer to http_server, unless
+// only the whole acronym is an initialism e.g IDandValue to id_and_value
 func snakeConvert(name string) string {
 	if len(name) == 0 {
 		return ""
@@ -526,7 +1057,14 @@ func snakeConvert(name string) string {
 			lowerCount = 0
 		} else if a >= 'a' && a <= 'z' {
 			if upperCount > 1 {
-				convert = append(convert, '_', a)
+				run := name[i-int(upperCount) : i]
+				if isInitialism(run) && !isInitialism(run[:len(run)-1]) {
+					convert = append(convert, '_', a)
+				} else {
+					// the last upper case letter starts the word
+					last := convert[len(convert)-1]
+					convert = append(convert[:len(convert)-1], '_', last, a)
+				}
 			} else {
 				convert = append(convert, a)
 			}
@@ -540,6 +1078,12 @@ func snakeConvert(name string) string {
 	return string(convert)
 }
 
+// isInitialism reports whether the upper case word is an initialism of golint e.g HTTP
+func isInitialism(word string) bool {
+	_, ok := goIdentWords[asciiLower(word)]
+	return ok
+}
+
 func upperCamelConvert(name string) string {
 	if len(name) == 0 {
 		return ""
@@ -596,3 +1140,65 @@ func lowerCamelConvert(name string) string {
 	}
 	return string(newName)
 }
+
+// snakeWords returns the words of the snake case of name
+func snakeWords(name string) []string {
+	return strings.FieldsFunc(snakeConvert(name), func(r rune) bool {
+		return r == '_'
+	})
+}
+
+// caseWords splits name to the words, a word starts at the capital after a lower case
+// letter or digit and at the last capital of a run followed by a lower case letter e.g
+// HTTPServerName to HTTP Server Name, the other characters e.g _ separate the words
+func caseWords(name string) []string {
+	isUpper := func(c byte) bool { return c >= 'A' && c <= 'Z' }
+	isLower := func(c byte) bool { return c >= 'a' && c <= 'z' }
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	var words []string
+	start := -1
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		// the bytes of the non-ascii letters are in the word
+		if !isUpper(c) && !isLower(c) && !isDigit(c) && c < 0x80 {
+			if start != -1 {
+				words = append(words, name[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start == -1 {
+			start = i
+			continue
+		}
+		if prev := name[i-1]; isUpper(c) && (isLower(prev) || isDigit(prev) ||
+			isUpper(prev) && i+1 < len(name) && isLower(name[i+1])) {
+			words = append(words, name[start:i])
+			start = i
+		}
+	}
+	if start != -1 {
+		words = append(words, name[start:])
+	}
+	return words
+}
+
+// titleConvert converts name to the title case with spaces for the description-style
+// tags e.g UserName to User Name, the runs of capitals are kept e.g UserID to User ID
+func titleConvert(name string) string {
+	words := caseWords(name)
+	for i, word := range words {
+		words[i] = upperCamelConvert(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// dotConvert converts name to the dot case of the config libraries e.g UserName to
+// user.name
+func dotConvert(name string) string {
+	words := caseWords(name)
+	for i, word := range words {
+		words[i] = asciiLower(word)
+	}
+	return strings.Join(words, ".")
+}
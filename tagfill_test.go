@@ -4,12 +4,14 @@
  * license that can be found in the LICENSE file.
  */
 
-package main
+package tagfmt
 
 import (
+	"bytes"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go/ast"
+	"go/token"
 	"testing"
 )
 
@@ -25,6 +27,15 @@ func TestLowerCamelConvert(t *testing.T) {
 	assert.Equal(t, lowerCamelConvert("big_pigeon"), "bigPigeon")
 }
 
+func TestASCIICaseConvert(t *testing.T) {
+	assert.Equal(t, "USERNAME", asciiUpper("userName"))
+	assert.Equal(t, "username", asciiLower("UserName"))
+	// the special cases of unicode are kept
+	assert.Equal(t, "İsim", asciiLower("İsim"))
+	assert.Equal(t, "STRAßE", asciiUpper("straße"))
+	assert.Equal(t, "ǅ", asciiUpper("ǅ"))
+}
+
 func TestSnakeConvert(t *testing.T) {
 	assert.Equal(t, snakeConvert("UserDetail"), "user_detail")
 	assert.Equal(t, snakeConvert("OneToOne"), "one_to_one")
@@ -34,6 +45,36 @@ func TestSnakeConvert(t *testing.T) {
 	assert.Equal(t, snakeConvert("NameHTTPtest"), "name_http_test")
 	assert.Equal(t, snakeConvert("IDandValue"), "id_and_value")
 	assert.Equal(t, snakeConvert("toyorm.User.field"), "toyorm.user.field")
+	for _, c := range []struct{ name, snake string }{
+		{"HTTPServerURL", "http_server_url"},
+		{"XName", "x_name"},
+		{"APIKey", "api_key"},
+		{"JSONData", "json_data"},
+		{"HTTPSProxy", "https_proxy"},
+		{"ID", "id"},
+		{"GetHTTPResponseCode", "get_http_response_code"},
+		{"ABTest", "ab_test"},
+	} {
+		assert.Equal(t, c.snake, snakeConvert(c.name), c.name)
+	}
+}
+
+func TestTitleConvert(t *testing.T) {
+	assert.Equal(t, titleConvert("UserDetail"), "User Detail")
+	assert.Equal(t, titleConvert("_user_detail"), "User Detail")
+	assert.Equal(t, titleConvert("UserDetailID"), "User Detail ID")
+	assert.Equal(t, titleConvert("NameHTTPTest"), "Name HTTP Test")
+	assert.Equal(t, titleConvert("userName"), "User Name")
+	assert.Equal(t, titleConvert("HTTPServerName"), "HTTP Server Name")
+	assert.Equal(t, titleConvert("ID2Name"), "ID2 Name")
+}
+
+func TestDotConvert(t *testing.T) {
+	assert.Equal(t, dotConvert("UserDetail"), "user.detail")
+	assert.Equal(t, dotConvert("_user_detail"), "user.detail")
+	assert.Equal(t, dotConvert("NameHTTPTest"), "name.http.test")
+	assert.Equal(t, dotConvert("HTTPServerName"), "http.server.name")
+	assert.Equal(t, dotConvert("ID2Name"), "id2.name")
 }
 
 func TestParseFieldRule(t *testing.T) {
@@ -80,5 +121,104 @@ func TestParseFieldRule(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, rules["binding"](testFieldArgs("UserDetail", "")), "a|b|c+d,e")
 	}
+	{
+		rules, err := parseFieldRule("(json, yaml,mapstructure)=snake(:field)|(toml,xml)")
+		require.NoError(t, err)
+		assert.Len(t, rules, 5)
+		assert.Equal(t, rules["json"](testFieldArgs("UserDetail", "")), "user_detail")
+		assert.Equal(t, rules["yaml"](testFieldArgs("UserDetail", "")), "user_detail")
+		assert.Equal(t, rules["mapstructure"](testFieldArgs("UserDetail", "")), "user_detail")
+		assert.Equal(t, rules["toml"](testFieldArgs("UserDetail", "")), "")
+		assert.Equal(t, rules["xml"](testFieldArgs("UserDetail", "")), "")
+	}
+	{
+		_, err := parseFieldRule("(json,)=snake(:field)")
+		assert.EqualError(t, err, "invalid fill key group ((json,))")
+		_, err = parseFieldRule("(json,yaml=snake(:field)")
+		assert.EqualError(t, err, "invalid fill key group ((json,yaml)")
+	}
+	{
+		// the bare key name of coalesce() is the value of the key
+		rules, err := parseFieldRule("yaml=coalesce(json, snake(:field))")
+		require.NoError(t, err)
+		tagged := newRuleArgs(&ast.Field{
+			Names: []*ast.Ident{{Name: "UserDetail"}},
+			Tag:   &ast.BasicLit{Kind: token.STRING, Value: "`json:\"detail,omitempty\"`"},
+		}, "")
+		assert.Equal(t, "detail", rules["yaml"](tagged))
+		assert.Equal(t, "user_detail", rules["yaml"](testFieldArgs("UserDetail", "")))
+		// the skipped json field isn't named - in yaml
+		skipped := newRuleArgs(&ast.Field{
+			Names: []*ast.Ident{{Name: "UserDetail"}},
+			Tag:   &ast.BasicLit{Kind: token.STRING, Value: "`json:\"-\"`"},
+		}, "")
+		assert.Equal(t, "user_detail", rules["yaml"](skipped))
+		// the literal - is still a value
+		rules, err = parseFieldRule("yaml=coalesce(json,'-')")
+		require.NoError(t, err)
+		assert.Equal(t, "-", rules["yaml"](skipped))
+	}
+	{
+		// the escaped characters of the mini-language are literal text
+		rules, err := parseFieldRule(`validate=oneof\=a\|b|binding='it\'s'+\+|regexp='^\d+$'`)
+		require.NoError(t, err)
+		assert.Len(t, rules, 3)
+		assert.Equal(t, "oneof=a|b", rules["validate"](testFieldArgs("UserDetail", "")))
+		assert.Equal(t, "it's+", rules["binding"](testFieldArgs("UserDetail", "")))
+		assert.Equal(t, `^\d+$`, rules["regexp"](testFieldArgs("UserDetail", "")))
+	}
+}
+
+func TestParseFillRuleConditionOrder(t *testing.T) {
+	args := func(oldTag string) *ruleFuncArgs {
+		return newRuleArgs(&ast.Field{Names: []*ast.Ident{{Name: "UserName"}}}, oldTag)
+	}
+	{
+		rules, err := parseFillRule(`json=snake(:field)|json[val="-"]=skip()`, false)
+		require.NoError(t, err)
+		assert.Equal(t, "user_name", rules["json"](args("")))
+		assert.Equal(t, "user_name", rules["json"](args("-")))
+	}
+	{
+		rules, err := parseFillRule(`json[val="-"]=skip()|json=snake(:field)`, false)
+		require.NoError(t, err)
+		assert.Equal(t, "user_name", rules["json"](args("")))
+		assert.Equal(t, keepTagValue, rules["json"](args("-")))
+	}
+}
+
+func TestFillConditionRecheck(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	format := func(fill, tag string) string {
+		src := "package main\n\ntype User struct {\n\tUserID int `" + tag + "`\n}\n"
+		opts := optionsFromFlags()
+		opts.Fill = fill
+		var out bytes.Buffer
+		require.NoError(t, formatSource(&out, "user.go", []byte(src), opts))
+		return out.String()
+	}
+	// the condition is checked against the source, not the value filled by the first pass
+	assert.Contains(t, format(`json[val="-"]='hidden'|json=snake(:field)`, `json:"-"`), "`json:\"hidden\"`")
+	assert.Contains(t, format(`json[val=""]='todo'|json[val="todo"]='done'`, `json:""`), "`json:\"todo\"`")
+	assert.Contains(t, format(`json[val=""]='todo'|json[val="todo"]='done'`, `json:"todo"`), "`json:\"done\"`")
+	// the bare skip is skip()
+	assert.Contains(t, format(`json[val="-"]=skip|json=snake(:field)`, `json:"-"`), "`json:\"-\"`")
+	assert.Contains(t, format(`json[val="-"]=skip|json=snake(:field)`, `json:""`), "`json:\"user_id\"`")
+}
+
+func TestSortFlagEscapes(t *testing.T) {
+	order, err := parseSortOrder(`json|'x|y'|a\|b`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"json", "x|y", "a|b"}, order)
+
+	weights, err := parseSortWeight(`'x=y'=2|a\|b=1|json=after:'x=y'`)
+	require.NoError(t, err)
+	assert.Equal(t, 2, weights.weight("x=y"))
+	assert.Equal(t, 1, weights.weight("a|b"))
+	assert.Equal(t, []sortRelation{{Key: "json", Anchor: "x=y", After: true}}, weights.relations)
 
+	_, err = parseSortWeight(`json=1|'yaml=2`)
+	assert.EqualError(t, err, `-sw "json=1|'yaml=2":7: unclosed quote (near "'yaml=2")`)
 }
@@ -5,20 +5,34 @@
  *
  */
 
-package main
+package tagfmt
 
 import (
+	"errors"
 	"go/ast"
 	"go/token"
 	"strings"
-	"unicode/utf8"
 )
 
 type tagFormatter struct {
 	Err        error
 	f          *ast.File
 	fs         *token.FileSet
-	needFormat [][]*ast.Field
+	filter     *Filter
+	keys       []string       // only align the structs have one of the keys, empty means all
+	groups     map[string]int // the group index of keys, the keys in a group share a column
+	limit      lineLimit
+	current    *ast.StructType
+	needFormat []alignGroup
+	// aligned is the structs processed by Execute, their field columns are realigned
+	// even if their tags don't change
+	aligned []*ast.StructType
+}
+
+// alignGroup is the fields aligned together, st is the struct they belong to
+type alignGroup struct {
+	st     *ast.StructType
+	fields []*ast.Field
 }
 
 func (s *tagFormatter) Scan() error {
@@ -27,8 +41,13 @@ func (s *tagFormatter) Scan() error {
 }
 
 func (s *tagFormatter) Execute() error {
-	for _, fields := range s.needFormat {
-		err := fieldsTagFormat(fields)
+	for _, group := range s.needFormat {
+		// check the keys in Execute, the keys may be filled by the previous executors
+		if len(s.keys) != 0 && !structHasKey(group.st, s.keys) {
+			continue
+		}
+		s.aligned = append(s.aligned, group.st)
+		err := fieldsTagFormat(s.fs, group.fields, s.groups, s.limit)
 		if err != nil {
 			s.Err = err
 			return err
@@ -39,8 +58,29 @@ func (s *tagFormatter) Execute() error {
 
 func (s *tagFormatter) recordFields(fwt []*ast.Field) {
 	if len(fwt) != 0 {
-		s.needFormat = append(s.needFormat, fwt)
+		s.needFormat = append(s.needFormat, alignGroup{st: s.current, fields: fwt})
+	}
+}
+
+// structHasKey report whether any field of st has one of keys
+func structHasKey(st *ast.StructType, keys []string) bool {
+	for _, field := range st.Fields.List {
+		if field.Tag == nil {
+			continue
+		}
+		_, keyValues, err := ParseTag(field.Tag.Value)
+		if err != nil {
+			continue
+		}
+		for _, kv := range keyValues {
+			for _, key := range keys {
+				if kv.Key == key {
+					return true
+				}
+			}
+		}
 	}
+	return false
 }
 
 func getFieldName(node *ast.Field) string {
@@ -80,6 +120,7 @@ func (fields *tagFormatterFields) reset(f *tagFormatter) {
 func (s *tagFormatter) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
 	if n.Fields != nil {
 		var ffields tagFormatterFields
+		s.current = n
 
 		if len(n.Fields.List) == 0 {
 			return
@@ -89,7 +130,7 @@ func (s *tagFormatter) executor(name string, comments []*ast.CommentGroup, n *as
 		preAnonymousELine := -1
 		for _, field := range n.Fields.List {
 			fieldName := getFieldOrTypeName(field)
-			if field.Tag == nil || fieldFilter(fieldName) == false {
+			if field.Tag == nil || s.filter.Field(name, fieldName) == false {
 				ffields.reset(s)
 				continue
 			}
@@ -122,42 +163,93 @@ func (s *tagFormatter) executor(name string, comments []*ast.CommentGroup, n *as
 }
 
 func (s *tagFormatter) Visit(node ast.Node) ast.Visitor {
-	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
-	visit := newTopVisit(cmap, s.executor)
+	cmap := fileCommentMap(s.fs, s.f)
+	visit := newTopVisit(cmap, s.filter, s.executor)
 	return visit.Visit(node)
 }
 
-func fieldsTagFormat(fields []*ast.Field) error {
-	var longestList []int
-	for _, field := range fields {
-		_, keyWords, err := ParseTag(field.Tag.Value)
+// fieldsTagFormat aligns the tags of fields by the columns, a column is a key or the
+// adjacent keys of a group in groups, the keys in a column are separated by one space,
+// the lines over the limit are handled by its policy
+func fieldsTagFormat(fs *token.FileSet, fields []*ast.Field, groups map[string]int, limit lineLimit) error {
+	quotes := make([]string, len(fields))
+	aligned := &alignedFields{fs: fs, fields: fields, columns: make([][]string, len(fields)), pads: make([]int, len(fields))}
+	for fi, field := range fields {
+		quote, keyWords, err := ParseTag(field.Tag.Value)
 		if err != nil {
+			return NewAstError(fs, field, err)
+		}
+		quotes[fi], aligned.columns[fi] = quote, alignColumns(keyWords, groups)
+		aligned.pads[fi] = len(aligned.columns[fi])
+	}
+	if limit.max > 0 {
+		if err := aligned.limit(limit); err != nil {
 			return err
 		}
-		for i, kv := range keyWords {
-			if i >= len(longestList) {
-				longestList = append(longestList, 0)
+	}
+	longestList := aligned.widths()
+
+	// the tags are rebuilt from the parsed keys, the extra spaces between the keys and
+	// around the tag are dropped, only the padding separates the columns
+	var builder strings.Builder
+	for fi, field := range fields {
+		builder.Reset()
+		builder.WriteString(quotes[fi])
+		for i, column := range aligned.columns[fi] {
+			if i != 0 {
+				builder.WriteByte(' ')
+			}
+			builder.WriteString(column)
+			// the last one doesn't need padding
+			if i < aligned.pads[fi] && i != len(aligned.columns[fi])-1 {
+				builder.WriteString(strings.Repeat(" ", longestList[i]-displayWidth(column)))
 			}
-			kvLen := utf8.RuneCountInString(kv.String())
-			longestList[i] = max(kvLen, longestList[i])
 		}
+		builder.WriteString(quotes[fi])
+		field.Tag.Value = builder.String()
+		field.Tag.ValuePos = 0
 	}
+	return nil
+}
 
-	for _, field := range fields {
-		quote, keyWords, err := ParseTag(field.Tag.Value)
-		if err != nil {
-			return err
-		}
-		var keyValueRaw []string
-		for i, kv := range keyWords {
-			kvLen := utf8.RuneCountInString(kv.String())
-			keyValueRaw = append(keyValueRaw, kv.String()+strings.Repeat(" ", longestList[i]-kvLen))
+// alignColumns joins the adjacent keys of the same group, every key is a column
+// without groups
+func alignColumns(keyValues []KeyValue, groups map[string]int) []string {
+	var columns []string
+	for i, kv := range keyValues {
+		if i != 0 {
+			group, ok := groups[kv.Key]
+			prev, prevOk := groups[keyValues[i-1].Key]
+			if ok && prevOk && group == prev {
+				columns[len(columns)-1] += " " + kv.String()
+				continue
+			}
 		}
+		columns = append(columns, kv.String())
+	}
+	return columns
+}
 
-		field.Tag.Value = quote + strings.TrimRight(strings.Join(keyValueRaw, " "), " ") + quote
-		field.Tag.ValuePos = 0
+// parseAlignGroups parses the key groups e.g json,yaml|gorm,sql to the group index of
+// keys, a key is in one group only
+func parseAlignGroups(s string) (map[string]int, error) {
+	groups := map[string]int{}
+	offset := 0
+	for i, cell := range strings.Split(s, "|") {
+		for _, key := range strings.Split(cell, ",") {
+			keyOffset := offset + len(key) - len(strings.TrimLeft(key, " "))
+			offset += len(key) + 1
+			key = strings.TrimSpace(key)
+			if key == "" {
+				continue
+			}
+			if _, ok := groups[key]; ok {
+				return nil, &ruleError{Offset: keyOffset, Text: key, Err: errors.New("key is in more than one align group"), located: true}
+			}
+			groups[key] = i
+		}
 	}
-	return nil
+	return groups, nil
 }
 
 func max(a, b int) int {
@@ -167,7 +259,7 @@ func max(a, b int) int {
 	return b
 }
 
-func newTagFmt(f *ast.File, fs *token.FileSet) *tagFormatter {
-	s := &tagFormatter{fs: fs, f: f}
+func newTagFmt(f *ast.File, fs *token.FileSet, filter *Filter, keys []string, groups map[string]int, limit lineLimit) *tagFormatter {
+	s := &tagFormatter{fs: fs, f: f, filter: filter, keys: keys, groups: groups, limit: limit}
 	return s
 }
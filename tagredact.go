@@ -0,0 +1,121 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"go/ast"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// tagRedactor hides the secret fields e.g Password and Token from the serialization,
+// the fields whose names match the pattern get the redaction keys, json:"-" by default,
+// the wrong values are replaced and the missing keys are added
+type tagRedactor struct {
+	f       *ast.File
+	fs      *token.FileSet
+	filter  *Filter
+	pattern *regexp.Regexp
+	keys    []KeyValue
+	fields  []*ast.Field
+}
+
+func (s *tagRedactor) Visit(node ast.Node) ast.Visitor {
+	cmap := fileCommentMap(s.fs, s.f)
+	visit := newTopVisit(cmap, s.filter, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagRedactor) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields != nil {
+		for _, field := range n.Fields.List {
+			if !s.filter.Field(name, getFieldName(field)) {
+				continue
+			}
+			// one tag is shared by the names, it's hidden if one of them is secret
+			for _, ident := range field.Names {
+				if s.pattern.MatchString(ident.Name) {
+					s.fields = append(s.fields, field)
+					break
+				}
+			}
+		}
+	}
+}
+
+func (s *tagRedactor) Scan() error {
+	ast.Walk(s, s.f)
+	return nil
+}
+
+func (s *tagRedactor) Execute() error {
+	for _, field := range s.fields {
+		if err := s.redactField(field); err != nil {
+			return NewAstError(s.fs, field.Tag, err)
+		}
+	}
+	return nil
+}
+
+func (s *tagRedactor) redactField(field *ast.Field) error {
+	return editFieldTag(field, func(e *tagEdit) {
+		for _, redact := range s.keys {
+			found := false
+			for i, kv := range e.keyValues {
+				if kv.Key == redact.Key {
+					found = true
+					e.set(i, redact.Value)
+				}
+			}
+			if !found {
+				e.add(redact.Key, redact.Value)
+			}
+		}
+	})
+}
+
+// parseRedactKeys parses the redaction keys e.g json|yaml|log=mask, the key without value
+// is -, which is omitted by encoding/json, yaml and the most encoders
+func parseRedactKeys(s string) ([]KeyValue, error) {
+	var keys []KeyValue
+	for _, cell := range strings.Split(s, "|") {
+		if strings.TrimSpace(cell) == "" {
+			continue
+		}
+		kv := KeyValue{Value: "-"}
+		if i := strings.IndexByte(cell, '='); i != -1 {
+			kv.Key, kv.Value = strings.TrimSpace(cell[:i]), strings.TrimSpace(cell[i+1:])
+			if kv.Value == "" {
+				return nil, ErrRedactKey
+			}
+		} else {
+			kv.Key = strings.TrimSpace(cell)
+		}
+		if kv.Key == "" || strings.ContainsAny(kv.Key, ":\"` ") {
+			return nil, ErrRedactKey
+		}
+		keys = append(keys, kv)
+	}
+	if len(keys) == 0 {
+		return nil, ErrRedactKey
+	}
+	return keys, nil
+}
+
+func newTagRedact(f *ast.File, fs *token.FileSet, filter *Filter, pattern, keys string) (*tagRedactor, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, newFlagError("-redact", pattern, err)
+	}
+	redactKeys, err := parseRedactKeys(keys)
+	if err != nil {
+		return nil, newFlagError("-redact-key", keys, err)
+	}
+	return &tagRedactor{f: f, fs: fs, filter: filter, pattern: re, keys: redactKeys}, nil
+}
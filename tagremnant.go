@@ -0,0 +1,140 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// tagRemnant finds the trailing comments of fields look like the disabled tags e.g
+// // json:"old_name", they are often the unfinished migrations, report reports them,
+// remove removes the comments, restore adds their keys missing in the tag and removes
+// the comments, the existing keys are kept
+type tagRemnant struct {
+	f      *ast.File
+	fs     *token.FileSet
+	filter *Filter
+	policy string
+	fields []*ast.Field
+}
+
+func (s *tagRemnant) Visit(node ast.Node) ast.Visitor {
+	cmap := fileCommentMap(s.fs, s.f)
+	visit := newTopVisit(cmap, s.filter, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagRemnant) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields == nil {
+		return
+	}
+	for _, field := range n.Fields.List {
+		if s.filter.Field(name, getFieldOrTypeName(field)) && remnantTag(field.Comment) != nil {
+			s.fields = append(s.fields, field)
+		}
+	}
+}
+
+func (s *tagRemnant) Scan() error {
+	ast.Walk(s, s.f)
+	if s.policy != "report" {
+		return nil
+	}
+	var errs tagDockerErr
+	for _, field := range s.fields {
+		if len(errs) < tagDockerMaxErr {
+			text := strings.TrimSpace(strings.TrimPrefix(field.Comment.List[0].Text, "//"))
+			errs = append(errs, NewAstError(s.fs, field.Comment, fmt.Errorf("commented-out tag %s, use -remnants remove or restore", text)))
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+func (s *tagRemnant) Execute() error {
+	for _, field := range s.fields {
+		if s.policy == "restore" {
+			if err := s.restoreField(field); err != nil {
+				return NewAstError(s.fs, field.Tag, err)
+			}
+		}
+		s.removeComment(field)
+	}
+	return nil
+}
+
+func (s *tagRemnant) restoreField(field *ast.Field) error {
+	return editFieldTag(field, func(e *tagEdit) {
+		for _, old := range remnantTag(field.Comment) {
+			if e.index(old.Key) == -1 {
+				e.add(old.Key, old.Value)
+			}
+		}
+	})
+}
+
+// removeComment removes the trailing comment of field from the file
+func (s *tagRemnant) removeComment(field *ast.Field) {
+	for i, group := range s.f.Comments {
+		if group == field.Comment {
+			s.f.Comments = append(s.f.Comments[:i:i], s.f.Comments[i+1:]...)
+			break
+		}
+	}
+	field.Comment = nil
+}
+
+// remnantTag returns the key values of the comment which is a disabled tag, the
+// comment is the tag with or without the backquotes, nil if it isn't a tag
+func remnantTag(group *ast.CommentGroup) []KeyValue {
+	if group == nil || len(group.List) != 1 || !strings.HasPrefix(group.List[0].Text, "//") {
+		return nil
+	}
+	text := strings.TrimSpace(strings.TrimPrefix(group.List[0].Text, "//"))
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "`"), "`")
+	if text == "" || strings.Contains(text, "`") {
+		return nil
+	}
+	_, keyValues, err := ParseTag("`" + text + "`")
+	if err != nil || len(keyValues) == 0 {
+		return nil
+	}
+	for _, kv := range keyValues {
+		if !isTagKey(kv.Key) {
+			return nil
+		}
+	}
+	return keyValues
+}
+
+// isTagKey reports whether key is the word of letters, digits, _ - and .
+func isTagKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseRemnants checks the -remnants value
+func parseRemnants(policy string) error {
+	switch policy {
+	case "", "report", "remove", "restore":
+		return nil
+	}
+	return fmt.Errorf("invalid remnants %q, must be report, remove or restore", policy)
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// reportMain runs the read-only report command of fs e.g keys and conformance, the
+// packages of the paths, . by default, are collected by collect and printed as json
+// with -json or as text by write, nothing is modified
+func reportMain(fs *flag.FlagSet, args []string, collect func(paths []string) (interface{}, error), write func(w io.Writer, packages interface{}) error) int {
+	asJSON := fs.Bool("json", false, "print json instead of text")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	packages, err := collect(paths)
+	if err != nil {
+		commandError(fs.Name(), err)
+		return errorClass(err)
+	}
+	if *asJSON {
+		data, err := json.MarshalIndent(packages, "", "\t")
+		if err != nil {
+			commandError(fs.Name(), err)
+			return errorClass(err)
+		}
+		fmt.Printf("%s\n", data)
+		return exitOK
+	}
+	if err := write(os.Stdout, packages); err != nil {
+		commandError(fs.Name(), err)
+		return errorClass(err)
+	}
+	return exitOK
+}
+
+// walkPackageFiles calls fn with the files of paths and their options, pkg is the
+// index of the package directory dir in the order of their first file, so a new
+// package has the index of the count of packages seen before
+func walkPackageFiles(paths []string, fn func(pkg int, dir, filename string, opts Options) error) error {
+	files, err := packageFiles(paths)
+	if err != nil {
+		return err
+	}
+	index := map[string]int{}
+	for _, filename := range files {
+		opts, err := config.Options(optionsFromFlags(), filename)
+		if err != nil {
+			return err
+		}
+		dir := filepath.Dir(filename)
+		i, ok := index[dir]
+		if !ok {
+			i = len(index)
+			index[dir] = i
+		}
+		if err := fn(i, dir, filename, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// taggedField is a tagged field of a declared struct or its nested structs, path is
+// the field path from the declared struct e.g Address.Zip
+type taggedField struct {
+	field     *ast.Field
+	path      string
+	keyValues []KeyValue
+}
+
+// walkTaggedStructs parses filename and calls fn with the tagged fields of every
+// struct declared in it and selected by filter, the fields of the nested structs are
+// included, the invalid tags are the doctor's, they are not walked
+func walkTaggedStructs(fset *token.FileSet, filename string, filter *Filter, fn func(spec *ast.TypeSpec, fields []taggedField)) error {
+	f, err := parser.ParseFile(fset, filename, nil, parserMode)
+	if err != nil {
+		return err
+	}
+	ast.Inspect(f, func(node ast.Node) bool {
+		spec, ok := node.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		st := indirectStruct(spec.Type)
+		if st == nil || isProtoGenerated(spec.Name.Name, st) || !filter.Struct(spec.Name.Name) {
+			return false
+		}
+		fn(spec, taggedFields(spec.Name.Name, "", st, filter, nil))
+		return false
+	})
+	return nil
+}
+
+// taggedFields appends the tagged fields of st and its nested structs to fields,
+// prefix is the path of st
+func taggedFields(structName, prefix string, st *ast.StructType, filter *Filter, fields []taggedField) []taggedField {
+	if st.Fields == nil {
+		return fields
+	}
+	for _, field := range st.Fields.List {
+		name := getFieldName(field)
+		if name == "" {
+			name = embeddedName(field.Type)
+		}
+		if field.Tag != nil && filter.Field(structName, getFieldName(field)) {
+			if _, keyValues, err := ParseTag(field.Tag.Value); err == nil && len(keyValues) != 0 {
+				fields = append(fields, taggedField{field: field, path: prefix + name, keyValues: keyValues})
+			}
+		}
+		if nested := indirectStruct(field.Type); nested != nil {
+			fields = taggedFields(structName, prefix+name+".", nested, filter, fields)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeReportPackage writes src as user.go of a temporary package for the report
+// commands, it's removed after the test
+func writeReportPackage(t *testing.T, src string) (dir, filename string) {
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	filename = filepath.Join(dir, "user.go")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(src), 0644))
+	return dir, filename
+}
+
+func TestWalkTaggedStructs(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	src := "package user\n\n" +
+		"type User struct {\n" +
+		"\tID   int    `json:\"id\"`\n" +
+		"\tName string `json:\"name`\n" +
+		"\tAddress struct {\n\t\tZip string `json:\"zip\"`\n\t}\n" +
+		"\tAge int\n" +
+		"}\n\n" +
+		"type Empty struct {\n\tName string\n}\n"
+	_, filename := writeReportPackage(t, src)
+	filter, err := optionsFromFlags().Filter()
+	require.NoError(t, err)
+
+	walked := map[string][]string{}
+	err = walkTaggedStructs(token.NewFileSet(), filename, filter, func(spec *ast.TypeSpec, fields []taggedField) {
+		walked[spec.Name.Name] = []string{}
+		for _, f := range fields {
+			walked[spec.Name.Name] = append(walked[spec.Name.Name], f.path)
+		}
+	})
+	require.NoError(t, err)
+	// the invalid tag of Name isn't walked
+	assert.Equal(t, map[string][]string{"User": {"ID", "Address.Zip"}, "Empty": {}}, walked)
+}
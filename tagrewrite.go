@@ -0,0 +1,157 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+var ErrRewriteRule = errors.New(`rewrite rule must be the form 'key:"value" -> key:"value"'`)
+
+// rewriteTerm is one side of rewrite rule, e.g json:"a" or json:*
+type rewriteTerm struct {
+	Key   string
+	Value string
+	Any   bool // value is *, match any value or keep the matched value
+}
+
+// rewriteRule is like gofmt -r, but rewrite the tag key value,
+// e.g json:"a" -> json:"b" replace the value, xml:* -> delete all xml keys
+type rewriteRule struct {
+	Pattern     rewriteTerm
+	Replacement *rewriteTerm // nil means delete
+}
+
+func parseRewriteRule(rule string) (*rewriteRule, error) {
+	parts := strings.Split(rule, "->")
+	if len(parts) != 2 {
+		return nil, ErrRewriteRule
+	}
+	pattern, err := parseRewriteTerm(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, err
+	}
+	r := &rewriteRule{Pattern: *pattern}
+	if replacement := strings.TrimSpace(parts[1]); replacement != "" {
+		r.Replacement, err = parseRewriteTerm(replacement)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func parseRewriteTerm(s string) (*rewriteTerm, error) {
+	if key := strings.TrimSuffix(s, ":*"); key != s {
+		if key == "" || strings.ContainsAny(key, " :\"") {
+			return nil, ErrRewriteRule
+		}
+		return &rewriteTerm{Key: key, Any: true}, nil
+	}
+	_, keyValues, err := ParseTag("`" + s + "`")
+	if err != nil || len(keyValues) != 1 {
+		return nil, ErrRewriteRule
+	}
+	return &rewriteTerm{Key: keyValues[0].Key, Value: keyValues[0].Value}, nil
+}
+
+// rewrite apply rule to keyValues, report whether the keyValues has changed
+func (r *rewriteRule) rewrite(keyValues []KeyValue) ([]KeyValue, bool) {
+	var changed bool
+	list := keyValues[:0]
+	for _, kv := range keyValues {
+		if kv.Key != r.Pattern.Key || (!r.Pattern.Any && kv.Value != r.Pattern.Value) {
+			list = append(list, kv)
+			continue
+		}
+		changed = true
+		if r.Replacement == nil {
+			continue
+		}
+		kv.Key = r.Replacement.Key
+		if !r.Replacement.Any {
+			kv.Value = r.Replacement.Value
+		}
+		list = append(list, kv)
+	}
+	return list, changed
+}
+
+type tagRewriter struct {
+	f      *ast.File
+	fs     *token.FileSet
+	filter *Filter
+	rule   *rewriteRule
+	fields []*ast.Field
+}
+
+func (s *tagRewriter) Visit(node ast.Node) ast.Visitor {
+	cmap := fileCommentMap(s.fs, s.f)
+	visit := newTopVisit(cmap, s.filter, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagRewriter) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields != nil {
+		for _, field := range n.Fields.List {
+			if s.filter.Field(name, getFieldName(field)) && field.Tag != nil {
+				s.fields = append(s.fields, field)
+			}
+		}
+	}
+}
+
+// Scan rewrite tags directly, the deleted tags must not be recorded by
+// the other executors
+func (s *tagRewriter) Scan() error {
+	ast.Walk(s, s.f)
+	for _, field := range s.fields {
+		quote, keyValues, err := ParseTag(field.Tag.Value)
+		if err != nil {
+			return NewAstError(s.fs, field.Tag, err)
+		}
+		keyValues, changed := s.rule.rewrite(keyValues)
+		if !changed {
+			continue
+		}
+		keys := map[string]bool{}
+		for _, kv := range keyValues {
+			if keys[kv.Key] {
+				return NewAstError(s.fs, field.Tag, fmt.Errorf("duplicate key %s after rewrite", kv.Key))
+			}
+			keys[kv.Key] = true
+		}
+		if len(keyValues) == 0 {
+			field.Tag = nil
+			continue
+		}
+		var keyValuesRaw []string
+		for _, kv := range keyValues {
+			keyValuesRaw = append(keyValuesRaw, kv.String())
+		}
+		field.Tag.Value = quote + strings.Join(keyValuesRaw, " ") + quote
+		field.Tag.ValuePos = 0
+	}
+	return nil
+}
+
+func (s *tagRewriter) Execute() error {
+	return nil
+}
+
+func newTagRewrite(f *ast.File, fs *token.FileSet, filter *Filter, rule string) (*tagRewriter, error) {
+	r, err := parseRewriteRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	return &tagRewriter{f: f, fs: fs, filter: filter, rule: r}, nil
+}
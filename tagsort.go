@@ -5,12 +5,16 @@
  *
  */
 
-package main
+package tagfmt
 
 import (
+	"errors"
+	"fmt"
 	"go/ast"
 	"go/token"
+	"path"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -22,9 +26,10 @@ type tagSorterWeightKey struct {
 type tagSorter struct {
 	f       *ast.File
 	fs      *token.FileSet
+	filter  *Filter
 	Err     error
 	order   []string
-	weights map[string]int
+	weights *sortWeights
 	fields  []*ast.Field
 }
 
@@ -37,52 +42,126 @@ func (s *tagSorter) Execute() error {
 	for _, field := range s.fields {
 		err := sortField(field, s.order, s.weights)
 		if err != nil {
-			s.Err = err
-			return err
+			s.Err = NewAstError(s.fs, field, err)
+			return s.Err
 		}
 	}
 	return s.Err
 }
 
 func (s *tagSorter) Visit(node ast.Node) ast.Visitor {
-	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
-	visit := newTopVisit(cmap, s.executor)
+	cmap := fileCommentMap(s.fs, s.f)
+	visit := newTopVisit(cmap, s.filter, s.executor)
 	return visit.Visit(node)
 }
 
 func (s *tagSorter) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
 	if n.Fields != nil {
 		for _, field := range n.Fields.List {
-			if fieldFilter(getFieldName(field)) && field.Tag != nil {
+			if s.filter.Field(name, getFieldName(field)) && field.Tag != nil {
 				s.fields = append(s.fields, field)
 			}
 		}
 	}
 }
 
-func sortField(field *ast.Field, order []string, weight map[string]int) error {
-	quote, keyValues, err := ParseTag(field.Tag.Value)
-	if err != nil {
-		return err
+// isKeyPattern report whether the key of -so -sw is a wildcard pattern e.g x-*
+func isKeyPattern(key string) bool {
+	return strings.ContainsAny(key, "*?[")
+}
+
+// matchKey report whether key matches the key or wildcard pattern of -so -sw
+func matchKey(pattern, key string) bool {
+	if !isKeyPattern(pattern) {
+		return pattern == key
 	}
-	sort.Slice(keyValues, func(i, j int) bool {
-		iKey := keyValues[i].Key
-		jKey := keyValues[j].Key
-		if weight[iKey] > weight[jKey] {
-			return true
-		} else if weight[iKey] < weight[jKey] {
-			return false
-		}
-		for _, o := range order {
-			if iKey == o {
-				return true
-			} else if jKey == o {
-				return false
+	matched, _ := path.Match(pattern, key)
+	return matched
+}
+
+// sortRank returns the position of key in order, the exact key is before the
+// wildcard patterns, the key not in order is ranked last
+func sortRank(order []string, key string) int {
+	for i, o := range order {
+		if o == key {
+			return i
+		}
+	}
+	for i, o := range order {
+		if isKeyPattern(o) && matchKey(o, key) {
+			return i
+		}
+	}
+	return len(order)
+}
+
+// sortRelation places Key right before or after the Anchor key e.g validate=after:json
+type sortRelation struct {
+	Key    string
+	Anchor string
+	After  bool
+}
+
+// sortWeights is the weights of -sw, the exact key is before the wildcard patterns,
+// the patterns are matched in the order they are given, the relations are applied
+// after sorting in the order they are given
+type sortWeights struct {
+	keys      map[string]int
+	patterns  []tagSorterWeightKey
+	relations []sortRelation
+}
+
+// relocate moves the keys of relations next to their anchors, the relation whose
+// anchor is not in keyValues is skipped
+func (w *sortWeights) relocate(keyValues []KeyValue) []KeyValue {
+	for _, r := range w.relations {
+		var moved, rest []KeyValue
+		for _, kv := range keyValues {
+			if kv.Key == r.Key {
+				moved = append(moved, kv)
+			} else {
+				rest = append(rest, kv)
+			}
+		}
+		anchor := -1
+		for i, kv := range rest {
+			if kv.Key == r.Anchor {
+				anchor = i
+				if !r.After {
+					break
+				}
 			}
 		}
+		if len(moved) == 0 || anchor == -1 {
+			continue
+		}
+		if r.After {
+			anchor++
+		}
+		keyValues = append(append(append([]KeyValue{}, rest[:anchor]...), moved...), rest[anchor:]...)
+	}
+	return keyValues
+}
 
-		return iKey < jKey
-	})
+// weight returns the weight of key, default 0
+func (w *sortWeights) weight(key string) int {
+	if weight, ok := w.keys[key]; ok {
+		return weight
+	}
+	for _, p := range w.patterns {
+		if matchKey(p.Key, key) {
+			return p.Weight
+		}
+	}
+	return 0
+}
+
+func sortField(field *ast.Field, order []string, weights *sortWeights) error {
+	quote, keyValues, err := ParseTag(field.Tag.Value)
+	if err != nil {
+		return err
+	}
+	keyValues = sortKeyValues(keyValues, order, weights)
 	var keyValuesRaw []string
 	for _, kv := range keyValues {
 		keyValuesRaw = append(keyValuesRaw, kv.String())
@@ -93,8 +172,138 @@ func sortField(field *ast.Field, order []string, weight map[string]int) error {
 	return nil
 }
 
-func newTagSort(f *ast.File, fs *token.FileSet, order []string, weights map[string]int) *tagSorter {
-	s := &tagSorter{f: f, order: order, fs: fs, weights: weights}
+// sortKeyValues sorts keyValues in place by the weights, the order and the key name,
+// then moves the keys of relations
+func sortKeyValues(keyValues []KeyValue, order []string, weights *sortWeights) []KeyValue {
+	sort.SliceStable(keyValues, func(i, j int) bool {
+		iKey := keyValues[i].Key
+		jKey := keyValues[j].Key
+		if iWeight, jWeight := weights.weight(iKey), weights.weight(jKey); iWeight != jWeight {
+			return iWeight > jWeight
+		}
+		if iRank, jRank := sortRank(order, iKey), sortRank(order, jKey); iRank != jRank {
+			return iRank < jRank
+		}
+		return iKey < jKey
+	})
+	return weights.relocate(keyValues)
+}
+
+// parseSortWeight parses the -sw value e.g json=1|yaml=2|desc=-1|x-*=-2|validate=after:json,
+// the key with | or = is quoted e.g 'a|b'=1 or escaped e.g a\|b=1
+func parseSortWeight(s string) (*sortWeights, error) {
+	weights := &sortWeights{keys: map[string]int{}}
+	cells, err := splitUnquoted(s, '|')
+	if err != nil {
+		return nil, newFlagError("-sw", s, err)
+	}
+	offset := 0
+	for _, weightStr := range cells {
+		weightOffset := offset
+		offset += len(weightStr) + 1
+		if strings.TrimSpace(weightStr) == "" {
+			continue
+		}
+		eq := indexUnquoted(weightStr, '=')
+		if eq == -1 || indexUnquoted(weightStr[eq+1:], '=') != -1 {
+			return nil, newFlagError("-sw", s, &ruleError{Offset: weightOffset, Text: weightStr, Err: errors.New("weight must be the form key=weight"), located: true})
+		}
+		keyVals := []string{weightStr[:eq], weightStr[eq+1:]}
+		key := unquoteRuleKey(keyVals[0])
+		if key == "" {
+			return nil, newFlagError("-sw", s, &ruleError{Offset: weightOffset, Text: weightStr, Err: errors.New("empty key"), located: true})
+		}
+		if err := checkKeyPattern(key); err != nil {
+			return nil, newFlagError("-sw", s, &ruleError{Offset: weightOffset, Text: keyVals[0], Err: err, located: true})
+		}
+		if relation := strings.TrimSpace(keyVals[1]); strings.Contains(relation, ":") {
+			r, err := parseSortRelation(key, relation)
+			if err != nil {
+				return nil, newFlagError("-sw", s, &ruleError{Offset: weightOffset + len(keyVals[0]) + 1, Text: keyVals[1], Err: err, located: true})
+			}
+			weights.relations = append(weights.relations, r)
+			continue
+		}
+		val, err := strconv.Atoi(strings.TrimSpace(keyVals[1]))
+		if err != nil {
+			return nil, newFlagError("-sw", s, &ruleError{Offset: weightOffset + len(keyVals[0]) + 1, Text: keyVals[1], Err: errors.New("weight must be an integer"), located: true})
+		}
+		if isKeyPattern(key) {
+			weights.patterns = append(weights.patterns, tagSorterWeightKey{Weight: val, Key: key})
+		} else {
+			weights.keys[key] = val
+		}
+	}
+	return weights, nil
+}
+
+// parseSortRelation parses the relation of key e.g after:json
+func parseSortRelation(key, relation string) (sortRelation, error) {
+	r := sortRelation{Key: key}
+	idx := strings.IndexByte(relation, ':')
+	switch relation[:idx] {
+	case "before":
+	case "after":
+		r.After = true
+	default:
+		return r, fmt.Errorf("unknown relation %s, the relations are before after", relation[:idx])
+	}
+	r.Anchor = unquoteRuleKey(relation[idx+1:])
+	switch {
+	case r.Anchor == "":
+		return r, errors.New("empty anchor key")
+	case isKeyPattern(key) || isKeyPattern(r.Anchor):
+		return r, errors.New("relation doesn't support key pattern")
+	case r.Anchor == key:
+		return r, errors.New("key is relative to itself")
+	}
+	return r, nil
+}
+
+// parseSortOrder parses the -so value e.g json|yaml|desc, the key with | is quoted
+// e.g 'a|b' or escaped e.g a\|b
+func parseSortOrder(s string) ([]string, error) {
+	var order []string
+	if strings.TrimSpace(s) == "" {
+		return order, nil
+	}
+	keys, err := splitUnquoted(s, '|')
+	if err != nil {
+		return nil, newFlagError("-so", s, err)
+	}
+	seen := map[string]bool{}
+	offset := 0
+	for _, key := range keys {
+		keyOffset := offset
+		offset += len(key) + 1
+		trimmed := unquoteRuleKey(key)
+		switch {
+		case trimmed == "":
+			return nil, newFlagError("-so", s, &ruleError{Offset: keyOffset, Text: key, Err: errors.New("empty key"), located: true})
+		case strings.ContainsAny(trimmed, " \t:\"`"):
+			return nil, newFlagError("-so", s, &ruleError{Offset: keyOffset, Text: key, Err: errors.New("invalid key"), located: true})
+		case seen[trimmed]:
+			return nil, newFlagError("-so", s, &ruleError{Offset: keyOffset, Text: key, Err: errors.New("duplicate key"), located: true})
+		}
+		if err := checkKeyPattern(trimmed); err != nil {
+			return nil, newFlagError("-so", s, &ruleError{Offset: keyOffset, Text: key, Err: err, located: true})
+		}
+		seen[trimmed] = true
+		order = append(order, trimmed)
+	}
+	return order, nil
+}
+
+// checkKeyPattern checks the syntax of wildcard pattern
+func checkKeyPattern(key string) error {
+	if _, err := path.Match(key, ""); err != nil {
+		return errors.New("invalid key pattern")
+	}
+	return nil
+}
+
+func newTagSort(f *ast.File, fs *token.FileSet, filter *Filter, order []string, weights *sortWeights) *tagSorter {
+	s := &tagSorter{f: f, order: order, fs: fs, filter: filter, weights: weights}
 
 	return s
 }
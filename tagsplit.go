@@ -0,0 +1,94 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+)
+
+var ErrMultiNameField = errors.New("field declares multiple names with one shared tag, use -split-multi to split it")
+
+// tagSplitter rewrite the multi-name field e.g. A, B string `json:""` to
+// separate fields, every new field has its own copy of the tag
+type tagSplitter struct {
+	f      *ast.File
+	fs     *token.FileSet
+	filter *Filter
+	fields []*ast.FieldList
+	split  map[*ast.Field]bool // the selected multi-name fields
+}
+
+func (s *tagSplitter) Visit(node ast.Node) ast.Visitor {
+	cmap := fileCommentMap(s.fs, s.f)
+	visit := newTopVisit(cmap, s.filter, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagSplitter) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields != nil {
+		s.fields = append(s.fields, n.Fields)
+		for _, field := range n.Fields.List {
+			if len(field.Names) > 1 && field.Tag != nil && s.filter.Field(name, getFieldName(field)) {
+				s.split[field] = true
+			}
+		}
+	}
+}
+
+// Scan split fields directly, because the other executors need to record
+// the new fields in their Scan
+func (s *tagSplitter) Scan() error {
+	ast.Walk(s, s.f)
+	for _, fields := range s.fields {
+		var list []*ast.Field
+		for _, field := range fields.List {
+			if s.split[field] {
+				list = append(list, splitField(field)...)
+			} else {
+				list = append(list, field)
+			}
+		}
+		fields.List = list
+	}
+	return nil
+}
+
+func (s *tagSplitter) Execute() error {
+	return nil
+}
+
+// splitField split field to one name one field, the doc comment keep in first field
+// and line comment keep in last field
+func splitField(field *ast.Field) []*ast.Field {
+	var list []*ast.Field
+	for i, name := range field.Names {
+		newField := &ast.Field{
+			Names: []*ast.Ident{name},
+			Type:  field.Type,
+			Tag: &ast.BasicLit{
+				ValuePos: field.Tag.ValuePos,
+				Kind:     field.Tag.Kind,
+				Value:    field.Tag.Value,
+			},
+		}
+		if i == 0 {
+			newField.Doc = field.Doc
+		}
+		if i == len(field.Names)-1 {
+			newField.Comment = field.Comment
+		}
+		list = append(list, newField)
+	}
+	return list
+}
+
+func newTagSplit(f *ast.File, fs *token.FileSet, filter *Filter) *tagSplitter {
+	return &tagSplitter{f: f, fs: fs, filter: filter, split: map[*ast.Field]bool{}}
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// tagSyncer keeps the values of key pairs the same, e.g gin binding and validator validate,
+// the missing or empty one is copied from the other, the different values are reported
+type tagSyncer struct {
+	f      *ast.File
+	fs     *token.FileSet
+	filter *Filter
+	pairs  [][2]string
+	fields []*ast.Field
+}
+
+func (s *tagSyncer) Visit(node ast.Node) ast.Visitor {
+	cmap := fileCommentMap(s.fs, s.f)
+	visit := newTopVisit(cmap, s.filter, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagSyncer) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields != nil {
+		for _, field := range n.Fields.List {
+			if field.Tag != nil && s.filter.Field(name, getFieldName(field)) {
+				s.fields = append(s.fields, field)
+			}
+		}
+	}
+}
+
+func (s *tagSyncer) Scan() error {
+	ast.Walk(s, s.f)
+	return nil
+}
+
+func (s *tagSyncer) Execute() error {
+	var errs tagDockerErr
+	for _, field := range s.fields {
+		if err := s.syncField(field); err != nil && len(errs) < tagDockerMaxErr {
+			errs = append(errs, NewAstError(s.fs, field.Tag, err))
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+func (s *tagSyncer) syncField(field *ast.Field) error {
+	quote, keyValues, err := ParseTag(field.Tag.Value)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, pair := range s.pairs {
+		a, b := -1, -1
+		for i, kv := range keyValues {
+			switch kv.Key {
+			case pair[0]:
+				a = i
+			case pair[1]:
+				b = i
+			}
+		}
+		if a == -1 && b == -1 {
+			continue
+		}
+		if a == -1 || b == -1 {
+			from, to := a, pair[1]
+			if a == -1 {
+				from, to = b, pair[0]
+			}
+			if keyValues[from].Value != "" {
+				keyValues = append(keyValues, KeyValue{Key: to, quote: quote, Value: keyValues[from].Value})
+				changed = true
+			}
+			continue
+		}
+		switch va, vb := keyValues[a].Value, keyValues[b].Value; {
+		case va == vb:
+		case va == "":
+			keyValues[a].Value, changed = vb, true
+		case vb == "":
+			keyValues[b].Value, changed = va, true
+		default:
+			return fmt.Errorf("%s contradicts %s", keyValues[a].String(), keyValues[b].String())
+		}
+	}
+	if changed {
+		var keyValuesRaw []string
+		for _, kv := range keyValues {
+			keyValuesRaw = append(keyValuesRaw, kv.String())
+		}
+		field.Tag.Value = quote + strings.Join(keyValuesRaw, " ") + quote
+		field.Tag.ValuePos = 0
+	}
+	return nil
+}
+
+var ErrSyncRule = errors.New("sync rule must be the form key1=key2 e.g binding=validate")
+
+// parseSyncPairs parses the key pairs e.g binding=validate|form=query
+func parseSyncPairs(s string) ([][2]string, error) {
+	var pairs [][2]string
+	for _, cell := range strings.Split(s, "|") {
+		if strings.TrimSpace(cell) == "" {
+			continue
+		}
+		keys := strings.Split(cell, "=")
+		if len(keys) != 2 {
+			return nil, ErrSyncRule
+		}
+		a, b := strings.TrimSpace(keys[0]), strings.TrimSpace(keys[1])
+		if a == "" || b == "" || a == b {
+			return nil, ErrSyncRule
+		}
+		pairs = append(pairs, [2]string{a, b})
+	}
+	return pairs, nil
+}
+
+func newTagSync(f *ast.File, fs *token.FileSet, filter *Filter, rule string) (*tagSyncer, error) {
+	pairs, err := parseSyncPairs(rule)
+	if err != nil {
+		return nil, err
+	}
+	return &tagSyncer{f: f, fs: fs, filter: filter, pairs: pairs}, nil
+}
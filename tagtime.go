@@ -0,0 +1,177 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// timeHint is a convention of the time.Time fields, it's the companion key e.g
+// gin time_format:"2006-01-02" or the option of an existing key e.g
+// parquet:",timestamp(millisecond)"
+type timeHint struct {
+	Key    string
+	Value  string
+	Option bool
+}
+
+// tagTimeHinter fills the time hints of the time.Time fields, the field type is
+// matched by the import of time package, the missing companion keys are added and
+// the missing options are appended, the existing values are kept
+type tagTimeHinter struct {
+	f      *ast.File
+	fs     *token.FileSet
+	filter *Filter
+	hints  []timeHint
+	fields []*ast.Field
+}
+
+func (s *tagTimeHinter) Visit(node ast.Node) ast.Visitor {
+	cmap := fileCommentMap(s.fs, s.f)
+	visit := newTopVisit(cmap, s.filter, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagTimeHinter) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields == nil {
+		return
+	}
+	timePkg := importName(s.f, "time")
+	if timePkg == "" {
+		return
+	}
+	for _, field := range n.Fields.List {
+		if len(field.Names) != 0 && s.filter.Field(name, getFieldName(field)) && isTimeType(field.Type, timePkg) {
+			s.fields = append(s.fields, field)
+		}
+	}
+}
+
+func (s *tagTimeHinter) Scan() error {
+	ast.Walk(s, s.f)
+	return nil
+}
+
+func (s *tagTimeHinter) Execute() error {
+	for _, field := range s.fields {
+		if err := s.hintField(field); err != nil {
+			return NewAstError(s.fs, field.Tag, err)
+		}
+	}
+	return nil
+}
+
+func (s *tagTimeHinter) hintField(field *ast.Field) error {
+	return editFieldTag(field, func(e *tagEdit) {
+		for _, hint := range s.hints {
+			found := false
+			for i, kv := range e.keyValues {
+				if kv.Key != hint.Key {
+					continue
+				}
+				found = true
+				// the ignored field has no option
+				if hint.Option && kv.Value != "-" && !hasOption(kv.Value, hint.Value) {
+					e.set(i, kv.Value+","+hint.Value)
+				}
+			}
+			if !found && !hint.Option {
+				e.add(hint.Key, hint.Value)
+			}
+		}
+	})
+}
+
+// hasOption reports whether the options after the name of value have option
+func hasOption(value, option string) bool {
+	options := strings.Split(value, ",")
+	for _, o := range options[1:] {
+		if strings.TrimSpace(o) == option {
+			return true
+		}
+	}
+	return false
+}
+
+// importName returns the name of the package path imported by f, "." for the dot
+// import, empty if it isn't imported
+func importName(f *ast.File, path string) string {
+	for _, spec := range f.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err != nil || p != path {
+			continue
+		}
+		if spec.Name != nil {
+			if spec.Name.Name == "_" {
+				continue
+			}
+			return spec.Name.Name
+		}
+		return path[strings.LastIndexByte(path, '/')+1:]
+	}
+	return ""
+}
+
+// isTimeType reports whether expr is time.Time or *time.Time, timePkg is the import
+// name of time package
+func isTimeType(expr ast.Expr, timePkg string) bool {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch t := expr.(type) {
+	case *ast.SelectorExpr:
+		x, ok := t.X.(*ast.Ident)
+		return ok && x.Name == timePkg && t.Sel.Name == "Time"
+	case *ast.Ident:
+		return timePkg == "." && t.Name == "Time"
+	}
+	return false
+}
+
+// parseTimeHints parses the time hints e.g time_format=2006-01-02|parquet,timestamp(millisecond),
+// key=value is the companion key, key,option is the option appended to key
+func parseTimeHints(s string) ([]timeHint, error) {
+	var hints []timeHint
+	for _, cell := range strings.Split(s, "|") {
+		if strings.TrimSpace(cell) == "" {
+			continue
+		}
+		var hint timeHint
+		eq, comma := strings.IndexByte(cell, '='), strings.IndexByte(cell, ',')
+		switch {
+		case comma != -1 && (eq == -1 || comma < eq):
+			hint = timeHint{Key: cell[:comma], Value: strings.TrimSpace(cell[comma+1:]), Option: true}
+			if strings.Contains(hint.Value, ",") {
+				return nil, ErrTimeHint
+			}
+		case eq != -1:
+			hint = timeHint{Key: cell[:eq], Value: strings.TrimSpace(cell[eq+1:])}
+		default:
+			return nil, ErrTimeHint
+		}
+		hint.Key = strings.TrimSpace(hint.Key)
+		if hint.Key == "" || hint.Value == "" || strings.ContainsAny(hint.Key, ":\"` ") || strings.ContainsAny(hint.Value, "\"`") {
+			return nil, ErrTimeHint
+		}
+		hints = append(hints, hint)
+	}
+	if len(hints) == 0 {
+		return nil, ErrTimeHint
+	}
+	return hints, nil
+}
+
+func newTagTimeHint(f *ast.File, fs *token.FileSet, filter *Filter, rule string) (*tagTimeHinter, error) {
+	hints, err := parseTimeHints(rule)
+	if err != nil {
+		return nil, newFlagError("-time-hint", rule, err)
+	}
+	return &tagTimeHinter{f: f, fs: fs, filter: filter, hints: hints}, nil
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// templateSuffix is the suffix of go source template files
+const templateSuffix = ".go.tmpl"
+
+// templateStructStart matches the start of a struct declaration in template
+var templateStructStart = regexp.MustCompile(`(?m)^([ \t]*)type[ \t]+\w+(\[[^\]\n]*\])?[ \t]+struct[ \t]*\{`)
+
+// templateBlock is a struct declaration without template actions in template,
+// src[start:end] is from the line start to the closing brace
+type templateBlock struct {
+	start, end int
+	line       int
+	indent     string
+}
+
+func isTemplateFile(filename string) bool {
+	return strings.HasSuffix(filename, templateSuffix)
+}
+
+// parseTemplateDelims parses the template delimiters e.g "{{ }}"
+func parseTemplateDelims(s string) (left, right string, err error) {
+	delims := strings.Fields(s)
+	if len(delims) != 2 {
+		return "", "", fmt.Errorf("template delimiters must be the left and right delimiters separated by space e.g \"{{ }}\", got %q", s)
+	}
+	return delims[0], delims[1], nil
+}
+
+// findTemplateBlocks returns the struct declarations in src, the declarations contain
+// the left delimiter are skipped, the template actions in them can't be formatted
+func findTemplateBlocks(src []byte, left string) (blocks, skipped []templateBlock) {
+	offset := 0
+	for {
+		loc := templateStructStart.FindSubmatchIndex(src[offset:])
+		if loc == nil {
+			return blocks, skipped
+		}
+		start := offset + loc[0]
+		indent := string(src[offset+loc[2] : offset+loc[3]])
+		end := matchBrace(src, offset+loc[1]-1)
+		if end == -1 {
+			return blocks, skipped
+		}
+		offset = end
+		block := templateBlock{
+			start:  start,
+			end:    end,
+			line:   bytes.Count(src[:start], []byte("\n")) + 1,
+			indent: indent,
+		}
+		if bytes.Contains(src[start:end], []byte(left)) {
+			skipped = append(skipped, block)
+			continue
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+// matchBrace returns the offset after the brace matching src[open], the braces in
+// strings and comments are skipped, -1 means it's not closed
+func matchBrace(src []byte, open int) int {
+	depth := 0
+	for i := open; i < len(src); i++ {
+		switch src[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '`':
+			j := bytes.IndexByte(src[i+1:], '`')
+			if j == -1 {
+				return -1
+			}
+			i += j + 1
+		case '"', '\'':
+			quote := src[i]
+			for i++; i < len(src) && src[i] != quote && src[i] != '\n'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+		case '/':
+			if i+1 < len(src) && src[i+1] == '/' {
+				j := bytes.IndexByte(src[i:], '\n')
+				if j == -1 {
+					return -1
+				}
+				i += j
+			} else if i+1 < len(src) && src[i+1] == '*' {
+				j := bytes.Index(src[i+2:], []byte("*/"))
+				if j == -1 {
+					return -1
+				}
+				i += j + 3
+			}
+		}
+	}
+	return -1
+}
+
+// processTemplate formats the template src with the delimiters of flag
+func processTemplate(out *bytes.Buffer, filename string, src []byte, opts Options) error {
+	if *dryRun {
+		return fmt.Errorf("%s: dry run doesn't support templates", filename)
+	}
+	left, _, err := parseTemplateDelims(*tmplDelims)
+	if err != nil {
+		return err
+	}
+	return formatTemplate(out, filename, src, opts, left)
+}
+
+// formatTemplate formats the struct declarations without template actions in the
+// template src, the other text is kept as is
+func formatTemplate(out *bytes.Buffer, filename string, src []byte, opts Options, left string) error {
+	blocks, skipped := findTemplateBlocks(src, left)
+	if skips != nil {
+		for _, block := range skipped {
+			skips.Add(token.Position{Filename: filename, Line: block.line, Column: len(block.indent) + 1}, "struct: the template actions in it can't be formatted")
+		}
+	}
+	last := 0
+	for _, block := range blocks {
+		res, err := formatTemplateBlock(filename, src[block.start:block.end], block, opts)
+		if err != nil {
+			return err
+		}
+		out.Write(src[last:block.start])
+		out.Write(res)
+		last = block.end
+	}
+	out.Write(src[last:])
+	return nil
+}
+
+// formatTemplateBlock formats the block as a go file, the line directive makes the
+// error positions point to the template
+func formatTemplateBlock(filename string, block []byte, b templateBlock, opts Options) ([]byte, error) {
+	lines := strings.Split(string(block), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, b.indent)
+	}
+	prefix := fmt.Sprintf("package p\n\n//line %s:%d:%d\n", filename, b.line, len(b.indent)+1)
+	var buf bytes.Buffer
+	err := formatSource(&buf, filename, []byte(prefix+strings.Join(lines, "\n")+"\n"), opts)
+	if err != nil {
+		return nil, err
+	}
+	res := buf.String()
+	if !strings.HasPrefix(res, prefix) {
+		return nil, fmt.Errorf("%s:%d: the struct declaration can't be formatted in template", filename, b.line)
+	}
+	lines = strings.Split(strings.TrimSuffix(res[len(prefix):], "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = b.indent + line
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
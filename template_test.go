@@ -0,0 +1,49 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFormatTemplate(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	*tagSort = true
+
+	src := "package {{ .Package }}\n\n" +
+		"type {{ .Name }} struct {\n\tID int `yaml:\"id\" json:\"id\"`\n}\n\n" +
+		"type User struct {\n\tID int `yaml:\"id\" json:\"id\"`\n\tLongName string `json:\"long_name\"` // }\n}\n\n" +
+		"type Item struct {\n\t{{ range .Fields }}{{ .Name }} string `yaml:\"x\" json:\"x\"`{{ end }}\n}\n\n" +
+		"func f() {\n\ttype Inner struct {\n\t\tA int `yaml:\"a\" json:\"a\"`\n\t}\n}\n"
+	var buf bytes.Buffer
+	err := formatTemplate(&buf, "model.go.tmpl", []byte(src), optionsFromFlags(), "{{")
+	require.NoError(t, err)
+	assert.Equal(t, "package {{ .Package }}\n\n"+
+		"type {{ .Name }} struct {\n\tID int `yaml:\"id\" json:\"id\"`\n}\n\n"+
+		"type User struct {\n\tID       int    `json:\"id\"        yaml:\"id\"`\n\tLongName string `json:\"long_name\"` // }\n}\n\n"+
+		"type Item struct {\n\t{{ range .Fields }}{{ .Name }} string `yaml:\"x\" json:\"x\"`{{ end }}\n}\n\n"+
+		"func f() {\n\ttype Inner struct {\n\t\tA int `json:\"a\" yaml:\"a\"`\n\t}\n}\n", buf.String())
+
+	// the other delimiters
+	buf.Reset()
+	err = formatTemplate(&buf, "model.go.tmpl", []byte("type A struct {\n\tX int `yaml:\"x\" json:\"x\"` // <% .X %>\n}\n"), optionsFromFlags(), "<%")
+	require.NoError(t, err)
+	assert.Equal(t, "type A struct {\n\tX int `yaml:\"x\" json:\"x\"` // <% .X %>\n}\n", buf.String())
+
+	buf.Reset()
+	err = formatTemplate(&buf, "model.go.tmpl", []byte("{{/* header */}}\n\ntype A struct {\n\tX int `json`\n}\n"), optionsFromFlags(), "{{")
+	assert.EqualError(t, err, "model.go.tmpl:4:8: invalid tag")
+
+	_, _, err = parseTemplateDelims("{{")
+	assert.Error(t, err)
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// confirmInput is the answer of the -dry-run-then-write confirmation, the tests replace it
+var confirmInput io.Reader = os.Stdin
+
+// progress is not nil in the check phase of -dry-run-then-write
+var progress *progressBar
+
+// progressBar reports the progress of the two phases of -dry-run-then-write, the
+// bar is redrawn at most every 100ms and only on the terminal
+type progressBar struct {
+	w        io.Writer
+	terminal bool
+	label    string
+	total    int
+	done     int
+	errors   int
+	drawn    time.Time
+}
+
+func newProgressBar(label string, total int) *progressBar {
+	return &progressBar{w: os.Stderr, terminal: isTerminal(os.Stderr), label: label, total: total}
+}
+
+// Step counts a finished file, err is the error of the file
+func (p *progressBar) Step(err error) {
+	p.done++
+	if err != nil {
+		p.errors++
+	}
+	if p.terminal && time.Since(p.drawn) >= 100*time.Millisecond {
+		p.draw()
+		p.drawn = time.Now()
+	}
+}
+
+// draw draws the bar with the total, the counter without it
+func (p *progressBar) draw() {
+	if p.total == 0 {
+		fmt.Fprintf(p.w, "\r%s: %d files", p.label, p.done)
+		return
+	}
+	const width = 30
+	n := p.done * width / p.total
+	fmt.Fprintf(p.w, "\r%s: [%s%s] %d/%d", p.label, strings.Repeat("=", n), strings.Repeat(" ", width-n), p.done, p.total)
+}
+
+// Finish draws the final state and ends the line of the bar
+func (p *progressBar) Finish() {
+	if p.terminal && p.done != 0 {
+		p.draw()
+		fmt.Fprintln(p.w)
+	}
+}
+
+// confirmWrites reports the counts of the check phase and asks whether the pending
+// files are written, -yes answers it
+func confirmWrites(a *atomicRun, checked *progressBar) bool {
+	logger.Info(fmt.Sprintf("%d files checked, %d files to write, %d errors", checked.done, len(a.files), checked.errors), "command", "dry-run-then-write")
+	if len(a.files) == 0 {
+		return false
+	}
+	if *yes {
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "write %d files? [y/N] ", len(a.files))
+	answer, _ := bufio.NewReader(confirmInput).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	logger.Info("nothing written", "command", "dry-run-then-write")
+	return false
+}
+
+// Write writes all files with the progress bar, unlike Commit the failed files are
+// reported and the others are still written
+func (a *atomicRun) Write() {
+	a.sort()
+	bar := newProgressBar("writing", len(a.files))
+	written := 0
+	for _, f := range a.files {
+		err := writeFile(f.filename, f.src, f.res, f.perm)
+		if err != nil {
+			report(err)
+		} else {
+			written++
+		}
+		bar.Step(err)
+	}
+	bar.Finish()
+	logger.Info(fmt.Sprintf("%d files written", written), "command", "dry-run-then-write")
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDryRunThenWrite(t *testing.T) {
+	defer resetFlags()
+	defer func() { confirmInput = os.Stdin }()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	src := "package a\n\ntype A struct {\n\tName string `json:\"name\" xml:\"name\"`\n\tB int `json:\"b\" xml:\"b\"`\n}\n"
+	formatted := "package a\n\ntype A struct {\n\tName string `json:\"name\" xml:\"name\"`\n\tB    int    `json:\"b\"    xml:\"b\"`\n}\n"
+	filename := filepath.Join(dir, "a.go")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(src), 0644))
+
+	osArgs := os.Args
+	defer func() { os.Args = osArgs }()
+	run := func(answer string, args ...string) string {
+		resetFlags()
+		exitCode = 0
+		confirmInput = strings.NewReader(answer)
+		os.Args = append([]string{"tagfmt"}, args...)
+		gofmtMain()
+		require.Equal(t, 0, exitCode)
+		data, err := ioutil.ReadFile(filename)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	// the answer no and the empty answer write nothing
+	assert.Equal(t, src, run("n\n", "-dry-run-then-write", dir))
+	assert.Equal(t, src, run("", "-dry-run-then-write", dir))
+	assert.Equal(t, formatted, run("y\n", "-dry-run-then-write", dir))
+
+	require.NoError(t, ioutil.WriteFile(filename, []byte(src), 0644))
+	assert.Equal(t, formatted, run("", "-dry-run-then-write", "-yes", dir))
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"sort"
+	"unicode"
+)
+
+// wideRanges are the east asian wide and fullwidth characters and the emoji, they
+// take two columns in the monospace fonts, the ranges are sorted
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // hangul jamo
+	{0x2E80, 0x303E},   // cjk radicals, kangxi, cjk symbols and punctuation
+	{0x3041, 0x33FF},   // hiragana, katakana, bopomofo, cjk compatibility
+	{0x3400, 0x4DBF},   // cjk unified ideographs extension a
+	{0x4E00, 0x9FFF},   // cjk unified ideographs
+	{0xA000, 0xA4CF},   // yi
+	{0xAC00, 0xD7A3},   // hangul syllables
+	{0xF900, 0xFAFF},   // cjk compatibility ideographs
+	{0xFE30, 0xFE4F},   // cjk compatibility forms
+	{0xFF00, 0xFF60},   // fullwidth forms
+	{0xFFE0, 0xFFE6},   // fullwidth signs
+	{0x1F300, 0x1F64F}, // pictographs and emoticons
+	{0x1F900, 0x1F9FF}, // supplemental symbols and pictographs
+	{0x20000, 0x2FFFD}, // cjk unified ideographs extension b and later
+	{0x30000, 0x3FFFD}, // cjk unified ideographs extension g
+}
+
+// runeWidth returns the number of columns r takes in the monospace fonts, the
+// combining marks and the zero width characters take none
+func runeWidth(r rune) int {
+	switch {
+	case r == 0x200B || r == 0x200C || r == 0x200D || r == 0xFEFF:
+		return 0
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return 0
+	}
+	i := sort.Search(len(wideRanges), func(i int) bool { return wideRanges[i][1] >= r })
+	if i < len(wideRanges) && wideRanges[i][0] <= r {
+		return 2
+	}
+	return 1
+}
+
+// displayWidth returns the number of columns s takes, the columns inside the tags
+// are aligned by it instead of the bytes or runes so the cjk values line up in the
+// editor, the field names before the tags are left to gofmt
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return width
+}
@@ -0,0 +1,29 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	for s, width := range map[string]int{
+		"name":        4,
+		"名字":          4,
+		"ニック":         6,
+		"한국":          4,
+		"ｆｕｌｌ":        8,
+		"cafe\u0301":  4, // combining acute accent
+		"a\u200bb":    2, // zero width space
+		"😀":           2,
+		"\U00020000x": 3,
+	} {
+		assert.Equal(t, width, displayWidth(s), s)
+	}
+}
@@ -0,0 +1,243 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Bazel persistent worker protocol, see https://bazel.build/remote/persistent
+// the WorkRequest and WorkResponse are length-delimited protobuf messages by default,
+// or JSON objects when the action requires json worker protocol
+
+var errInvalidProto = errors.New("invalid worker protobuf message")
+
+type workRequest struct {
+	Arguments []string `json:"arguments"`
+	RequestID int32    `json:"requestId"`
+	Cancel    bool     `json:"cancel"`
+}
+
+// workerFlag is a flag the worker started with, e.g the bazel startup args
+type workerFlag struct {
+	name, value string
+}
+
+type workResponse struct {
+	ExitCode  int32  `json:"exitCode"`
+	Output    string `json:"output"`
+	RequestID int32  `json:"requestId"`
+}
+
+// runWorker handles the requests one by one until r is closed
+func runWorker(r io.Reader, w io.Writer, jsonProtocol bool) error {
+	startup := startupFlags()
+	br := bufio.NewReader(r)
+	decoder := json.NewDecoder(br)
+	encoder := json.NewEncoder(w)
+	for {
+		var req workRequest
+		var err error
+		if jsonProtocol {
+			err = decoder.Decode(&req)
+		} else {
+			err = readProtoWorkRequest(br, &req)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// requests are handled in order, the cancelled one is already finished
+		if req.Cancel {
+			continue
+		}
+		code, output := runWorkRequest(startup, req.Arguments)
+		resp := workResponse{ExitCode: int32(code), Output: output, RequestID: req.RequestID}
+		if jsonProtocol {
+			err = encoder.Encode(resp)
+		} else {
+			_, err = w.Write(encodeProtoWorkResponse(resp))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// startupFlags returns the flags changed from their defaults, the worker mode flags
+// are excluded
+func startupFlags() []workerFlag {
+	var flags []workerFlag
+	commandLine.Visit(func(f *flag.Flag) {
+		if f.Name == "persistent_worker" || f.Name == "worker_protocol" {
+			return
+		}
+		if value := f.Value.String(); value != f.DefValue {
+			flags = append(flags, workerFlag{name: f.Name, value: value})
+		}
+	})
+	return flags
+}
+
+// runWorkRequest runs tagfmt with the startup flags and args like the command line,
+// returns its exit code and output
+func runWorkRequest(startup []workerFlag, args []string) (code int, output string) {
+	args, err := expandParamsFiles(args)
+	if err != nil {
+		return 2, err.Error() + "\n"
+	}
+	stdin, stdout, stderr := os.Stdin, os.Stdout, os.Stderr
+	osArgs := os.Args
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		return 2, err.Error() + "\n"
+	}
+	defer devNull.Close()
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return 2, err.Error() + "\n"
+	}
+	var buf bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(&buf, pr)
+		close(copied)
+	}()
+	// the standard input is the work request stream, hide it from the request
+	os.Stdin, os.Stdout, os.Stderr = devNull, pw, pw
+	os.Args = append([]string{osArgs[0]}, args...)
+	defer func() {
+		os.Stdin, os.Stdout, os.Stderr = stdin, stdout, stderr
+		os.Args = osArgs
+	}()
+
+	resetFlags()
+	// the repeated flags of args are joined to the startup ones
+	for _, f := range startup {
+		if err := commandLine.Set(f.name, f.value); err != nil {
+			return 2, err.Error() + "\n"
+		}
+	}
+	exitCode = 0
+	gofmtMain()
+	pw.Close()
+	<-copied
+	pr.Close()
+	return exitCode, buf.String()
+}
+
+// expandParamsFiles replaces the @file argument with the arguments in file, one per line
+func expandParamsFiles(args []string) ([]string, error) {
+	var expanded []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "@") && !strings.HasPrefix(arg, "@@") {
+			data, err := ioutil.ReadFile(arg[1:])
+			if err != nil {
+				return nil, err
+			}
+			for _, line := range strings.Split(string(data), "\n") {
+				if line = strings.TrimRight(line, "\r"); line != "" {
+					expanded = append(expanded, line)
+				}
+			}
+			continue
+		}
+		expanded = append(expanded, strings.TrimPrefix(arg, "@"))
+	}
+	return expanded, nil
+}
+
+func readProtoWorkRequest(r *bufio.Reader, req *workRequest) error {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return err
+	}
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return errInvalidProto
+		}
+		msg = msg[n:]
+		field, wireType := key>>3, key&7
+		switch wireType {
+		case 0: // varint
+			v, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return errInvalidProto
+			}
+			msg = msg[n:]
+			switch field {
+			case 3:
+				req.RequestID = int32(v)
+			case 4:
+				req.Cancel = v != 0
+			}
+		case 2: // length-delimited
+			l, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < l {
+				return errInvalidProto
+			}
+			if field == 1 {
+				req.Arguments = append(req.Arguments, string(msg[n:n+int(l)]))
+			}
+			msg = msg[n+int(l):]
+		case 1: // 64-bit
+			if len(msg) < 8 {
+				return errInvalidProto
+			}
+			msg = msg[8:]
+		case 5: // 32-bit
+			if len(msg) < 4 {
+				return errInvalidProto
+			}
+			msg = msg[4:]
+		default:
+			return errInvalidProto
+		}
+	}
+	return nil
+}
+
+func encodeProtoWorkResponse(resp workResponse) []byte {
+	var msg []byte
+	if resp.ExitCode != 0 {
+		msg = appendUvarint(msg, 1<<3|0)
+		msg = appendUvarint(msg, uint64(int64(resp.ExitCode)))
+	}
+	if resp.Output != "" {
+		msg = appendUvarint(msg, 2<<3|2)
+		msg = appendUvarint(msg, uint64(len(resp.Output)))
+		msg = append(msg, resp.Output...)
+	}
+	if resp.RequestID != 0 {
+		msg = appendUvarint(msg, 3<<3|0)
+		msg = appendUvarint(msg, uint64(int64(resp.RequestID)))
+	}
+	return append(appendUvarint(nil, uint64(len(msg))), msg...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
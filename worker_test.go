@@ -0,0 +1,117 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func encodeProtoWorkRequest(args []string, id int32) []byte {
+	var msg []byte
+	for _, arg := range args {
+		msg = appendUvarint(msg, 1<<3|2)
+		msg = appendUvarint(msg, uint64(len(arg)))
+		msg = append(msg, arg...)
+	}
+	msg = appendUvarint(msg, 3<<3|0)
+	msg = appendUvarint(msg, uint64(id))
+	return append(appendUvarint(nil, uint64(len(msg))), msg...)
+}
+
+func decodeProtoWorkResponse(t *testing.T, r *bufio.Reader) workResponse {
+	size, err := binary.ReadUvarint(r)
+	require.NoError(t, err)
+	msg := make([]byte, size)
+	_, err = io.ReadFull(r, msg)
+	require.NoError(t, err)
+	var resp workResponse
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		msg = msg[n:]
+		if key&7 == 2 {
+			l, n := binary.Uvarint(msg)
+			resp.Output = string(msg[n : n+int(l)])
+			msg = msg[n+int(l):]
+			continue
+		}
+		v, n := binary.Uvarint(msg)
+		msg = msg[n:]
+		if key>>3 == 1 {
+			resp.ExitCode = int32(v)
+		} else {
+			resp.RequestID = int32(v)
+		}
+	}
+	return resp
+}
+
+func TestWorker(t *testing.T) {
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	unformatted := filepath.Join(dir, "a.go")
+	require.NoError(t, ioutil.WriteFile(unformatted, []byte("package a\n\ntype A struct {\n\tName string `json:\"name\" xml:\"name\"`\n\tB int `json:\"b\" xml:\"b\"`\n}\n"), 0644))
+	formatted := filepath.Join(dir, "b.go")
+	require.NoError(t, ioutil.WriteFile(formatted, []byte("package a\n\ntype B struct {\n\tName string `json:\"name\"`\n}\n"), 0644))
+	params := filepath.Join(dir, "check.params")
+	require.NoError(t, ioutil.WriteFile(params, []byte("-l\n"+unformatted+"\n"+formatted+"\n"), 0644))
+
+	var in bytes.Buffer
+	in.Write(encodeProtoWorkRequest([]string{"-l", formatted}, 1))
+	in.Write(encodeProtoWorkRequest([]string{"@" + params}, 2))
+	in.Write(encodeProtoWorkRequest([]string{"-unknown"}, 3))
+	var out bytes.Buffer
+	require.NoError(t, runWorker(&in, &out, false))
+
+	r := bufio.NewReader(&out)
+	assert.Equal(t, workResponse{RequestID: 1}, decodeProtoWorkResponse(t, r))
+	// the unformatted file fails the check action
+	assert.Equal(t, workResponse{ExitCode: exitChanged, RequestID: 2, Output: unformatted + "\nsummary: 1 file needs formatting (exit 1)\n"}, decodeProtoWorkResponse(t, r))
+	resp := decodeProtoWorkResponse(t, r)
+	assert.Equal(t, int32(3), resp.RequestID)
+	assert.Equal(t, int32(2), resp.ExitCode)
+	assert.True(t, strings.Contains(resp.Output, "flag provided but not defined: -unknown"))
+
+	in.Reset()
+	out.Reset()
+	in.WriteString(`{"arguments": ["-l", "` + unformatted + `"], "requestId": 4}`)
+	require.NoError(t, runWorker(&in, &out, true))
+	assert.Equal(t, `{"exitCode":1,"output":"`+unformatted+`\nsummary: 1 file needs formatting (exit 1)\n","requestId":4}`+"\n", out.String())
+
+	// the repeated flags are parsed once per request
+	code, output := runWorkRequest(nil, []string{"-l", "-f", "json=upper(:field)", "-f", "xml=upper(:field)", "-p", "Name", "-p", "B", formatted})
+	assert.Equal(t, exitChanged, code)
+	assert.Equal(t, formatted+"\nsummary: 1 file needs formatting (exit 1)\n", output)
+	assert.Equal(t, "json=upper(:field)|xml=upper(:field)", *fill)
+	assert.Equal(t, "Name|B", *pattern)
+
+	// the startup flags apply to every request and combine with its flags
+	resetFlags()
+	sorted := filepath.Join(dir, "c.go")
+	require.NoError(t, ioutil.WriteFile(sorted, []byte("package a\n\ntype C struct {\n\tName string `xml:\"name\" json:\"name\"`\n}\n"), 0644))
+	require.NoError(t, commandLine.Parse([]string{"-persistent_worker", "-s", "-p", "Name"}))
+	in.Reset()
+	out.Reset()
+	in.WriteString(`{"arguments": ["-l", "` + sorted + `"], "requestId": 5}`)
+	in.WriteString(`{"arguments": ["-l", "-p", "B", "` + sorted + `", "` + unformatted + `"], "requestId": 6}`)
+	require.NoError(t, runWorker(&in, &out, true))
+	assert.Equal(t, `{"exitCode":1,"output":"`+sorted+`\nsummary: 1 file needs formatting (exit 1)\n","requestId":5}`+"\n"+
+		`{"exitCode":1,"output":"`+sorted+`\n`+unformatted+`\nsummary: 2 files need formatting (exit 1)\n","requestId":6}`+"\n", out.String())
+	assert.Equal(t, "Name|B", *pattern)
+}
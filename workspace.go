@@ -0,0 +1,128 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package tagfmt
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// workspaceModules returns the module directories used by the go.work in dir, nil if
+// dir has no go.work, the directories are joined with dir
+func workspaceModules(dir string) ([]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "go.work"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var modules []string
+	addUse := func(path string) {
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			path = unquoted
+		}
+		if path != "" {
+			modules = append(modules, filepath.Join(dir, filepath.FromSlash(path)))
+		}
+	}
+	inBlock := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i != -1 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case inBlock && line == ")":
+			inBlock = false
+		case inBlock:
+			addUse(line)
+		case line == "use (":
+			inBlock = true
+		case strings.HasPrefix(line, "use "):
+			addUse(strings.TrimSpace(strings.TrimPrefix(line, "use ")))
+		}
+	}
+	return modules, scanner.Err()
+}
+
+// typedPackageDirs returns the package directories of paths for the typed loads, a
+// path ends with ... includes the sub packages in the same module, the root of go.work
+// workspace is the packages of every module it uses, so every package is loaded in its
+// own module instead of failing for the files out of the module
+func typedPackageDirs(paths []string) ([]string, error) {
+	var dirs []string
+	for _, path := range paths {
+		recursive := strings.HasSuffix(path, "...")
+		if recursive {
+			path = filepath.Clean(strings.TrimSuffix(path, "..."))
+		}
+		modules, err := workspaceModules(path)
+		if err != nil {
+			return nil, err
+		}
+		if modules != nil {
+			for _, module := range modules {
+				sub, err := modulePackageDirs(module)
+				if err != nil {
+					return nil, err
+				}
+				dirs = append(dirs, sub...)
+			}
+			continue
+		}
+		if !recursive {
+			dirs = append(dirs, path)
+			continue
+		}
+		sub, err := modulePackageDirs(path)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, sub...)
+	}
+	return dirs, nil
+}
+
+// modulePackageDirs returns the directories have go files under root, the nested
+// modules, testdata, vendor and the hidden directories are skipped like go list
+func modulePackageDirs(root string) ([]string, error) {
+	var dirs []string
+	seen := map[string]bool{}
+	err := filepath.Walk(root, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if f.IsDir() {
+			name := f.Name()
+			if path != root {
+				if name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+					return filepath.SkipDir
+				}
+				if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if isGoFile(f) && !strings.HasSuffix(f.Name(), "_test.go") {
+			if dir := filepath.Dir(path); !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+		return nil
+	})
+	return dirs, err
+}